@@ -18,7 +18,10 @@ import (
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	"github.com/nais/azureator/controllers/azureadapplication"
+	"github.com/nais/azureator/pkg/azure"
 	"github.com/nais/azureator/pkg/azure/client"
+	"github.com/nais/azureator/pkg/azure/fake"
+	"github.com/nais/azureator/pkg/azure/fake/memory"
 	"github.com/nais/azureator/pkg/config"
 	azureMetrics "github.com/nais/azureator/pkg/metrics"
 	"github.com/nais/azureator/pkg/synchronizer"
@@ -93,17 +96,26 @@ func run() error {
 		return fmt.Errorf("unable to set up ready check: %w", err)
 	}
 
-	azureClient, err := client.New(ctx, &cfg.Azure)
-	if err != nil {
-		return fmt.Errorf("instantiating Azure client: %w", err)
-	}
-
-	azureCtx, cancel := context.WithTimeout(ctx, 1*time.Minute)
-	defer cancel()
-
-	azureOpenIDConfig, err := config.NewAzureOpenIdConfig(azureCtx, cfg.Azure.Tenant)
-	if err != nil {
-		return fmt.Errorf("fetching Azure OpenID Configuration: %w", err)
+	var azureClient azure.Client
+	var azureOpenIDConfig *config.AzureOpenIdConfig
+
+	if cfg.Azure.InMemory.Enabled {
+		setupLog.Info("WARNING: using in-memory Azure AD directory; no applications will be registered in Azure AD")
+		azureClient = memory.NewClient(cfg.Azure.Tenant.Id).WithAPISettings(cfg.Azure.APISettings)
+		azureOpenIDConfig = new(fake.AzureOpenIdConfig())
+	} else {
+		azureClient, err = client.New(ctx, &cfg.Azure)
+		if err != nil {
+			return fmt.Errorf("instantiating Azure client: %w", err)
+		}
+
+		azureCtx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+		defer cancel()
+
+		azureOpenIDConfig, err = config.NewAzureOpenIdConfig(azureCtx, cfg.Azure.Tenant)
+		if err != nil {
+			return fmt.Errorf("fetching Azure OpenID Configuration: %w", err)
+		}
 	}
 
 	syncer := synchronizer.New(cfg.ClusterName, mgr.GetClient(), mgr.GetAPIReader())
@@ -134,6 +146,7 @@ func run() error {
 		azureClient,
 		cfg.Azure.Tenant.Id,
 		cfg.Controller.SweepInterval,
+		syncer.SkippedGroups(),
 	)); err != nil {
 		return fmt.Errorf("registering synchronizer periodic sweep runnable: %w", err)
 	}
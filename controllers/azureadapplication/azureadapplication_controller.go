@@ -38,6 +38,7 @@ import (
 	azureReconciler "github.com/nais/azureator/pkg/reconciler/azure"
 	"github.com/nais/azureator/pkg/reconciler/finalizer"
 	"github.com/nais/azureator/pkg/reconciler/secrets"
+	"github.com/nais/azureator/pkg/secrets/sink"
 	"github.com/nais/azureator/pkg/synchronizer"
 	"github.com/nais/azureator/pkg/transaction"
 	"github.com/nais/azureator/pkg/transaction/options"
@@ -85,7 +86,8 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	defer cancel()
 
 	tx, err := r.Prepare(ctx, req)
-	if err != nil {
+	var invalidErr *options.InvalidError
+	if err != nil && !errors.As(err, &invalidErr) {
 		if apierrors.IsNotFound(err) {
 			return ctrl.Result{}, nil
 		}
@@ -100,6 +102,9 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 
 	if tx.Options.Tenant.Ignore {
 		tx.Logger.Debugf("resource is not addressed to tenant %s, ignoring...", r.Config.Azure.Tenant)
+		metrics.SetCredentialsExpiry(tx.Instance, time.Time{})
+		metrics.SetCredentials(tx.Instance, nil)
+		metrics.SetNextRotation(tx.Instance, time.Time{})
 
 		err := r.Azure().ProcessOrphaned(*tx)
 		if err != nil {
@@ -119,20 +124,33 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, nil
 	}
 
+	// invalid options are handled after the finalizer, so that applications with invalid annotations can be deleted
+	if invalidErr != nil {
+		metrics.SetCredentialsExpiry(tx.Instance, time.Time{})
+		metrics.SetCredentials(tx.Instance, nil)
+		metrics.SetNextRotation(tx.Instance, time.Time{})
+		return r.HandleError(*tx, invalidErr)
+	}
+
 	err = r.Azure().DeleteExpiredCredentials(*tx)
 	if err != nil {
 		return r.HandleError(*tx, err)
 	}
 
 	// ensure that existing credentials set are in sync with Azure
-	validCredentials, err := r.Azure().ValidateCredentials(*tx)
+	validation, err := r.Azure().ValidateCredentials(*tx)
 	if err != nil {
 		return r.HandleError(*tx, err)
 	}
-	if !validCredentials {
+	if !validation.Valid {
 		tx.Options.Process.Synchronize = true
 		tx.Options.Process.Secret.Valid = false
 	}
+	tx.Options.Process.Secret.ExpiresAt = validation.ExpiresAt
+	if validation.Valid || tx.Options.Process.Secret.CredentialMode.WorkloadIdentity() {
+		metrics.SetCredentialsExpiry(tx.Instance, validation.ExpiresAt)
+		metrics.SetCredentials(tx.Instance, validation.Registrations)
+	}
 
 	err = r.Secrets().DeleteUnused(*tx)
 	if err != nil {
@@ -150,9 +168,9 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	if !tx.Options.Process.Synchronize {
 		// controller-runtime cache resync events are ignored when EventFilter is used,
 		// so we requeue manually after a period of time to evaluate secret rotation
-		requeueAfter := r.Config.SecretRotation.MaxAge - orphanedSecretCleanupGracePeriod
-		if requeueAfter <= 0 {
-			requeueAfter = r.Config.SecretRotation.MaxAge
+		requeueAfter := tx.Options.Process.Secret.MaxAge
+		if nextRotation, found := r.reportNextRotation(*tx); found {
+			requeueAfter = max(time.Until(nextRotation), orphanedSecretCleanupGracePeriod)
 		}
 
 		return ctrl.Result{RequeueAfter: requeueAfter}, nil
@@ -188,9 +206,9 @@ func (r *Reconciler) Prepare(ctx context.Context, req ctrl.Request) (*transactio
 		return nil, fmt.Errorf("preparing transaction secrets: %w", err)
 	}
 
-	opts, err := options.NewOptions(*instance, *r.Config, *transactionSecrets)
-	if err != nil {
-		return nil, fmt.Errorf("preparing transaction options: %w", err)
+	opts, optionsErr := options.NewOptions(*instance, *r.Config, *transactionSecrets)
+	if optionsErr != nil && !errors.As(optionsErr, new(*options.InvalidError)) {
+		return nil, fmt.Errorf("preparing transaction options: %w", optionsErr)
 	}
 
 	tx := &transaction.Transaction{
@@ -210,7 +228,8 @@ func (r *Reconciler) Prepare(ctx context.Context, req ctrl.Request) (*transactio
 	}
 
 	tx.ExistsInAzure = exists
-	return tx, nil
+	// the transaction is returned along with any *options.InvalidError, as the finalizer does not depend on valid options
+	return tx, optionsErr
 }
 
 func (r *Reconciler) Process(tx transaction.Transaction) error {
@@ -248,6 +267,13 @@ func (r *Reconciler) HandleError(tx transaction.Transaction, err error) (ctrl.Re
 }
 
 func (r *Reconciler) isUnrecoverableError(tx transaction.Transaction, err error) bool {
+	// invalid options will not resolve by themselves; the application is reconciled again once it has been changed
+	var invalidErr *options.InvalidError
+	if errors.As(err, &invalidErr) {
+		r.ReportEvent(tx, corev1.EventTypeWarning, events.FailedSynchronization, fmt.Sprintf("Invalid configuration: %s", invalidErr.Err))
+		return true
+	}
+
 	var alreadyOwnedErr *controllerutil.AlreadyOwnedError
 	// this happens only if multiple instances of AzureAdApplication attempt to use the same secret name.
 	// we don't want to retry these.
@@ -272,9 +298,9 @@ func (r *Reconciler) Complete(tx transaction.Transaction) (ctrl.Result, error) {
 	tx.Instance.Status.SynchronizationTenant = r.Config.Azure.Tenant.Id
 	tx.Instance.Status.SynchronizationTenantName = r.Config.Azure.Tenant.Name
 
-	newHash, err := tx.Instance.Hash()
+	newHash, err := customresources.Hash(tx.Instance, tx.Options.Process.Azure.Settings()...)
 	if err != nil {
-		return ctrl.Result{}, fmt.Errorf("calculating application hash: %w", err)
+		return ctrl.Result{}, err
 	}
 	tx.Instance.Status.SynchronizationHash = newHash
 
@@ -299,6 +325,7 @@ func (r *Reconciler) Complete(tx transaction.Transaction) (ctrl.Result, error) {
 		return ctrl.Result{}, err
 	}
 
+	r.reportNextRotation(tx)
 	return ctrl.Result{RequeueAfter: orphanedSecretCleanupGracePeriod}, nil
 }
 
@@ -332,7 +359,7 @@ func (r *Reconciler) Finalizer() reconciler.Finalizer {
 }
 
 func (r *Reconciler) Secrets() reconciler.Secrets {
-	return secrets.NewSecretsReconciler(r, r.AzureOpenIDConfig, r.Client, r.Reader, r.Scheme)
+	return secrets.NewSecretsReconciler(r, r.AzureOpenIDConfig, r.Client, r.Reader, r.Scheme, sink.New(r.Config.SecretSinks))
 }
 
 func (r *Reconciler) updateAnnotations(tx transaction.Transaction) error {
@@ -346,7 +373,10 @@ func (r *Reconciler) updateAnnotations(tx transaction.Transaction) error {
 			annotations.RemoveAnnotation(tx.Instance, annotations.RotateKey)
 			annotations.RemoveAnnotation(existing, annotations.RotateKey)
 		}
-
+		if customresources.HasRevokeAnnotation(tx.Instance) {
+			annotations.RemoveAnnotation(tx.Instance, annotations.RevokeKey)
+			annotations.RemoveAnnotation(existing, annotations.RevokeKey)
+		}
 		merged := existing.GetAnnotations()
 		maps.Copy(merged, tx.Instance.GetAnnotations())
 
@@ -360,6 +390,57 @@ func (r *Reconciler) updateAnnotations(tx transaction.Transaction) error {
 	return nil
 }
 
+// nextRotation returns the time at which the credentials for the application are due for rotation, if any.
+// Rotations outside the rotation window are deferred to the start of the next window, unless the credentials expire
+// in Azure AD before then.
+func (r *Reconciler) nextRotation(tx transaction.Transaction) (time.Time, bool) {
+	opts := tx.Options.Process.Secret
+	if opts.CredentialMode.WorkloadIdentity() {
+		return time.Time{}, false
+	}
+
+	next, found := customresources.NextRotation(tx.Instance, opts.MaxAge)
+	if found && opts.RotationWindow != nil {
+		if now := time.Now(); next.Before(now) {
+			// the rotation is overdue, so it happens in the current or next window
+			next = now
+		}
+		next = opts.RotationWindow.Next(next)
+		found = !next.IsZero()
+	}
+
+	if opts.KeyTypeDeferred && opts.RotationWindow != nil {
+		// the rotation due to a changed key type happens in the next window
+		if window := opts.RotationWindow.Next(time.Now()); !window.IsZero() && (!found || window.Before(next)) {
+			next, found = window, true
+		}
+	}
+
+	// the end date is only known for the credentials that were validated, i.e. if they have not been replaced since
+	if !opts.ExpiresAt.IsZero() && opts.Valid && !opts.Rotate {
+		expiry := opts.ExpiresAt.Add(-r.Config.SecretRotation.Expiry.Margin)
+		if !found || expiry.Before(next) {
+			return expiry, true
+		}
+	}
+
+	return next, found
+}
+
+// reportNextRotation records the next rotation of the credentials for the application in the next rotation metric,
+// and returns it.
+func (r *Reconciler) reportNextRotation(tx transaction.Transaction) (time.Time, bool) {
+	nextRotation, found := r.nextRotation(tx)
+	if !found {
+		metrics.SetNextRotation(tx.Instance, time.Time{})
+		return nextRotation, false
+	}
+
+	tx.Logger.Debugf("credentials are next due for rotation at %s", nextRotation.UTC().Format(time.RFC3339))
+	metrics.SetNextRotation(tx.Instance, nextRotation)
+	return nextRotation, true
+}
+
 func (r *Reconciler) updateStatus(tx transaction.Transaction) error {
 	err := r.UpdateApplication(tx.Ctx, tx.Instance, func(existing *v1.AzureAdApplication) error {
 		existing.Status = tx.Instance.Status
@@ -14,6 +14,7 @@ import (
 	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	"github.com/nais/liberator/pkg/crd"
 	"github.com/nais/liberator/pkg/events"
+	"github.com/nais/liberator/pkg/kubernetes"
 	"github.com/nais/liberator/pkg/logrus2logr"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -30,13 +31,14 @@ import (
 	controller "github.com/nais/azureator/controllers/azureadapplication"
 	"github.com/nais/azureator/pkg/annotations"
 	"github.com/nais/azureator/pkg/azure/fake"
-	az "github.com/nais/azureator/pkg/azure/fake/client"
+	"github.com/nais/azureator/pkg/azure/fake/memory"
 	"github.com/nais/azureator/pkg/config"
 	"github.com/nais/azureator/pkg/customresources"
 	"github.com/nais/azureator/pkg/fixtures"
 	"github.com/nais/azureator/pkg/labels"
 	"github.com/nais/azureator/pkg/reconciler/finalizer"
 	"github.com/nais/azureator/pkg/secrets"
+	"github.com/nais/azureator/pkg/secrets/sink"
 	"github.com/nais/azureator/pkg/synchronizer"
 	"github.com/nais/azureator/pkg/util/test"
 )
@@ -50,12 +52,19 @@ const (
 	unusedSecret       = "unused-secret"
 	newSecret          = "new-secret"
 
-	namespace = "aura"
+	namespace   = "aura"
+	clusterName = "test-cluster"
+	tenantId    = "some-id"
+
+	// applicationExists is registered in the directory before the tests start, while applicationNotExists is not.
+	applicationExists    = "exists-in-azure"
+	applicationNotExists = "not-exists-in-azure"
 )
 
 var (
 	cli            client.Client
-	azureClient    = az.NewFakeAzureClient()
+	sinkDirectory  string
+	azureClient    = memory.NewClient(tenantId)
 	secretDataKeys = secrets.NewSecretDataKeys()
 )
 
@@ -77,11 +86,11 @@ func TestReconciler_CreateAzureAdApplication(t *testing.T) {
 	}{
 		{
 			"Application already exists in Azure AD",
-			az.ApplicationExists,
+			applicationExists,
 		},
 		{
 			"Application does not exist in Azure AD",
-			az.ApplicationNotExistsName,
+			applicationNotExists,
 		},
 	}
 	for _, c := range cases {
@@ -118,6 +127,31 @@ func TestReconciler_CreateAzureAdApplication(t *testing.T) {
 	}
 }
 
+func TestReconciler_CreateAzureAdApplication_Throttled_ShouldRetry(t *testing.T) {
+	appName := "should-retry-when-throttled"
+	t.Cleanup(azureClient.ClearFaults)
+
+	previousCalls := azureClient.Calls(memory.OperationCreate)
+	azureClient.InjectFault(memory.OperationCreate, memory.Throttle(2))
+
+	clusterFixtures := fixtures.New(cli, fixtures.Config{
+		AzureAppName:     appName,
+		SecretName:       fmt.Sprintf("%s-%s", appName, alreadyInUseSecret),
+		UnusedSecretName: unusedSecret,
+		NamespaceName:    namespace,
+	}).WithMinimalConfig()
+
+	if err := clusterFixtures.Setup(); err != nil {
+		t.Fatalf("failed to set up cluster fixtures: %v", err)
+	}
+
+	instance := assertApplicationExists(t, appName)
+	assert.Equal(t, 3, azureClient.Calls(memory.OperationCreate)-previousCalls, "throttled registrations should be retried until successful")
+
+	_, found := azureClient.Application(kubernetes.UniformResourceName(instance, clusterName))
+	assert.True(t, found, "application should be registered in the directory")
+}
+
 func TestReconciler_CreateAzureAdApplication_ShouldNotProcessNonMatchingTenantAnnotation(t *testing.T) {
 	appName := "should-not-process-non-matching-tenant-annotation"
 	secretName := fmt.Sprintf("%s-%s", appName, alreadyInUseSecret)
@@ -142,7 +176,7 @@ func TestReconciler_CreateAzureAdApplication_ShouldNotProcessNonMatchingTenantAn
 }
 
 func TestReconciler_UpdateAzureAdApplication_InvalidPreAuthorizedApps_ShouldNotRetry(t *testing.T) {
-	instance := assertApplicationExists(t, az.ApplicationExists)
+	instance := assertApplicationExists(t, applicationExists)
 
 	previousPreAuthorizedApps := instance.Spec.PreAuthorizedApplications
 	invalidPreAuthorizedApp := v1.AccessPolicyInboundRule{AccessPolicyRule: v1.AccessPolicyRule{
@@ -166,17 +200,16 @@ func TestReconciler_UpdateAzureAdApplication_InvalidPreAuthorizedApps_ShouldNotR
 }
 
 func TestReconciler_PeriodicResync_UnassignedPreAuthorizedApps(t *testing.T) {
-	instance := assertApplicationExists(t, az.ApplicationExists)
+	instance := assertApplicationExists(t, applicationExists)
 
-	// Add a preAuthorizedApp with "invalid" (fake treats it as unresolvable) and "resync"
-	// (fake's PreAuthorizedAppClientID returns assignable, simulating the app appeared in Azure later).
+	// Add a preAuthorizedApp that does not exist in the directory yet, and is thus unassigned.
 	previousPreAuthorizedApps := instance.Spec.PreAuthorizedApplications
-	invalidPreAuthorizedApp := v1.AccessPolicyInboundRule{AccessPolicyRule: v1.AccessPolicyRule{
-		Application: "invalid-periodic-resync-app",
+	laterPreAuthorizedApp := v1.AccessPolicyInboundRule{AccessPolicyRule: v1.AccessPolicyRule{
+		Application: "periodic-resync-app",
 		Namespace:   "some-namespace",
 		Cluster:     "some-cluster",
 	}}
-	instance.Spec.PreAuthorizedApplications = append(previousPreAuthorizedApps, invalidPreAuthorizedApp)
+	instance.Spec.PreAuthorizedApplications = append(previousPreAuthorizedApps, laterPreAuthorizedApp)
 
 	// Wait for the initial sync to complete (hash changes due to spec change)
 	updatedInstance := updateApplication(t, instance, eventuallyHashUpdated(instance))
@@ -185,9 +218,10 @@ func TestReconciler_PeriodicResync_UnassignedPreAuthorizedApps(t *testing.T) {
 
 	previousSyncTime := updatedInstance.Status.SynchronizationTime
 
-	// The sweep goroutine (running with SweepInterval=3s) will detect the unassigned
-	// preAuthorizedApp, verify it can be assigned (fake returns true for
-	// names containing "resync"), and write the resync annotation — triggering reconcile.
+	// The preAuthorizedApp appears in the directory. The sweep goroutine (running with SweepInterval=3s) will detect
+	// the unassigned preAuthorizedApp, verify it can be assigned, and write the resync annotation — triggering reconcile.
+	azureClient.AddApplication(customresources.GetUniqueName(laterPreAuthorizedApp.AccessPolicyRule))
+
 	key := client.ObjectKey{Name: updatedInstance.GetName(), Namespace: updatedInstance.GetNamespace()}
 	resyncedInstance := &v1.AzureAdApplication{}
 	assert.Eventually(t, func() bool {
@@ -201,7 +235,7 @@ func TestReconciler_PeriodicResync_UnassignedPreAuthorizedApps(t *testing.T) {
 		"Hash should be unchanged — this was a periodic resync, not a spec change")
 	assert.NotContains(t, resyncedInstance.Annotations, annotations.ResynchronizeKey)
 	assert.NotEmpty(t, resyncedInstance.Status.PreAuthorizedApps)
-	assert.NotEmpty(t, resyncedInstance.Status.PreAuthorizedApps.Unassigned, "should have unassigned preAuthorizedApps")
+	assert.Empty(t, resyncedInstance.Status.PreAuthorizedApps.Unassigned, "should have assigned the preAuthorizedApp")
 
 	// Clean up: reset preAuthorizedApplications
 	resyncedInstance.Spec.PreAuthorizedApplications = previousPreAuthorizedApps
@@ -209,7 +243,7 @@ func TestReconciler_PeriodicResync_UnassignedPreAuthorizedApps(t *testing.T) {
 }
 
 func TestReconciler_UpdateAzureAdApplication_ResyncAnnotation_ShouldResyncAndNotModifySecrets(t *testing.T) {
-	instance := assertApplicationExists(t, az.ApplicationExists)
+	instance := assertApplicationExists(t, applicationExists)
 
 	previousHash := instance.Status.SynchronizationHash
 	previousSecretRotationTime := instance.Status.SynchronizationSecretRotationTime
@@ -229,7 +263,7 @@ func TestReconciler_UpdateAzureAdApplication_ResyncAnnotation_ShouldResyncAndNot
 }
 
 func TestReconciler_UpdateAzureAdApplication_RotateAnnotation_ShouldRotateSecrets(t *testing.T) {
-	instance := assertApplicationExists(t, az.ApplicationExists)
+	instance := assertApplicationExists(t, applicationExists)
 
 	previousHash := instance.Status.SynchronizationHash
 	previousSecretRotationTime := instance.Status.SynchronizationSecretRotationTime
@@ -249,7 +283,7 @@ func TestReconciler_UpdateAzureAdApplication_RotateAnnotation_ShouldRotateSecret
 }
 
 func TestReconciler_UpdateAzureAdApplication_NewSecretName_ShouldRotateCredentials(t *testing.T) {
-	instance := assertApplicationExists(t, az.ApplicationExists)
+	instance := assertApplicationExists(t, applicationExists)
 	assert.NotEmpty(t, instance.Status.SynchronizationSecretRotationTime)
 
 	previousSecretName := instance.Spec.SecretName
@@ -274,7 +308,7 @@ func TestReconciler_UpdateAzureAdApplication_NewSecretName_ShouldRotateCredentia
 }
 
 func TestReconciler_UpdateAzureAdApplication_SpecChangeAndNotExpiredSecret_ShouldNotRotateCredentials(t *testing.T) {
-	instance := assertApplicationExists(t, az.ApplicationExists)
+	instance := assertApplicationExists(t, applicationExists)
 	assert.NotEmpty(t, instance.Status.SynchronizationSecretRotationTime)
 
 	previousSecretName := instance.Spec.SecretName
@@ -296,7 +330,7 @@ func TestReconciler_UpdateAzureAdApplication_SpecChangeAndNotExpiredSecret_Shoul
 }
 
 func TestReconciler_UpdateAzureAdApplication_SpecChangeAndExpiredSecret_ShouldAddNewCredentials(t *testing.T) {
-	instance := assertApplicationExists(t, az.ApplicationExists)
+	instance := assertApplicationExists(t, applicationExists)
 	assert.NotEmpty(t, instance.Status.SynchronizationSecretRotationTime)
 
 	previousSecretName := instance.Spec.SecretName
@@ -324,7 +358,7 @@ func TestReconciler_UpdateAzureAdApplication_SpecChangeAndExpiredSecret_ShouldAd
 }
 
 func TestReconciler_UpdateAzureAdApplication_NewSecretNameAndExpired_ShouldAddNewCredentials(t *testing.T) {
-	instance := assertApplicationExists(t, az.ApplicationExists)
+	instance := assertApplicationExists(t, applicationExists)
 	assert.NotEmpty(t, instance.Status.SynchronizationSecretRotationTime)
 
 	previousSecretName := instance.Spec.SecretName
@@ -357,7 +391,7 @@ func TestReconciler_UpdateAzureAdApplication_NewSecretNameAndExpired_ShouldAddNe
 }
 
 func TestReconciler_UpdateAzureAdApplication_MissingSecretRotationTimeAndNewSecretName_ShouldRotateCredentials(t *testing.T) {
-	instance := assertApplicationExists(t, az.ApplicationExists)
+	instance := assertApplicationExists(t, applicationExists)
 	assert.NotEmpty(t, instance.Status.SynchronizationSecretRotationTime)
 
 	previousSecretName := instance.Spec.SecretName
@@ -385,7 +419,7 @@ func TestReconciler_UpdateAzureAdApplication_MissingSecretRotationTimeAndNewSecr
 }
 
 func TestReconciler_UpdateAzureAdApplication_MissingSecretRotationTime_ShouldNotRotateCredentials(t *testing.T) {
-	instance := assertApplicationExists(t, az.ApplicationExists)
+	instance := assertApplicationExists(t, applicationExists)
 	assert.NotEmpty(t, instance.Status.SynchronizationSecretRotationTime)
 
 	previousSecretName := instance.Spec.SecretName
@@ -410,20 +444,85 @@ func TestReconciler_UpdateAzureAdApplication_MissingSecretRotationTime_ShouldNot
 }
 
 func TestReconciler_DeleteAzureAdApplication(t *testing.T) {
-	instance := assertApplicationExists(t, az.ApplicationExists)
+	instance := assertApplicationExists(t, applicationExists)
 
 	t.Run("Delete existing AzureAdApplication", func(t *testing.T) {
 		err := cli.Delete(context.Background(), instance)
 		assert.NoError(t, err, "deleting existing AzureAdApplication should not return error")
 
 		key := client.ObjectKey{
-			Name:      az.ApplicationExists,
+			Name:      applicationExists,
 			Namespace: namespace,
 		}
 		assert.Eventually(t, resourceDoesNotExist(key, instance), timeout, interval)
 	})
 }
 
+func TestReconciler_DeleteAzureAdApplication_ShouldPurgeSecretSinks(t *testing.T) {
+	appName := "should-purge-secret-sinks"
+	clusterFixtures := fixtures.New(cli, fixtures.Config{
+		AzureAppName:     appName,
+		SecretName:       fmt.Sprintf("%s-%s", appName, alreadyInUseSecret),
+		UnusedSecretName: unusedSecret,
+		NamespaceName:    namespace,
+	}).WithMinimalConfig().WithAnnotations(map[string]string{
+		annotations.SecretSinksKey: sink.NameFile,
+	})
+
+	if err := clusterFixtures.Setup(); err != nil {
+		t.Fatalf("failed to set up cluster fixtures: %v", err)
+	}
+
+	instance := assertApplicationExists(t, appName)
+
+	sinkPath := filepath.Join(sinkDirectory, namespace, appName)
+	assert.Eventually(t, func() bool {
+		entries, err := os.ReadDir(sinkPath)
+		return err == nil && len(entries) > 0
+	}, timeout, interval, "Secret sink should contain secret data")
+
+	err := cli.Delete(context.Background(), instance)
+	assert.NoError(t, err, "deleting existing AzureAdApplication should not return error")
+
+	key := client.ObjectKey{
+		Name:      appName,
+		Namespace: namespace,
+	}
+	assert.Eventually(t, resourceDoesNotExist(key, instance), timeout, interval)
+	assert.NoDirExists(t, sinkPath, "Secret sink should be purged")
+}
+
+func TestReconciler_DeleteAzureAdApplication_InvalidAnnotation(t *testing.T) {
+	appName := "should-delete-with-invalid-annotation"
+	clusterFixtures := fixtures.New(cli, fixtures.Config{
+		AzureAppName:     appName,
+		SecretName:       fmt.Sprintf("%s-%s", appName, alreadyInUseSecret),
+		UnusedSecretName: unusedSecret,
+		NamespaceName:    namespace,
+	}).WithMinimalConfig().WithAnnotations(map[string]string{
+		annotations.KeyTypeKey: "invalid-key-type",
+	})
+
+	if err := clusterFixtures.Setup(); err != nil {
+		t.Fatalf("failed to set up cluster fixtures: %v", err)
+	}
+
+	key := client.ObjectKey{
+		Name:      appName,
+		Namespace: namespace,
+	}
+	instance := &v1.AzureAdApplication{}
+	assert.Eventually(t, func() bool {
+		err := cli.Get(context.Background(), key, instance)
+		return err == nil && controllerutil.ContainsFinalizer(instance, finalizer.Name)
+	}, timeout, interval, "AzureAdApplication should contain a finalizer")
+	assert.Empty(t, instance.Status.SynchronizationHash, "AzureAdApplication with invalid annotation should not be synchronized")
+
+	err := cli.Delete(context.Background(), instance)
+	assert.NoError(t, err, "deleting AzureAdApplication with invalid annotation should not return error")
+	assert.Eventually(t, resourceDoesNotExist(key, instance), timeout, interval)
+}
+
 // asserts that the application exists in the cluster and is valid
 func assertApplicationExists(t *testing.T, name string) *v1.AzureAdApplication {
 	instance := &v1.AzureAdApplication{}
@@ -462,7 +561,7 @@ func assertApplicationExists(t *testing.T, name string) *v1.AzureAdApplication {
 		instance.Status.SynchronizationSecretName,
 	})
 
-	assertPreAuthorizedAppsStatusIsValid(t, instance.Spec.PreAuthorizedApplications, instance.Status.PreAuthorizedApps)
+	assertPreAuthorizedAppsStatusIsValid(t, instance, instance.Status.PreAuthorizedApps)
 
 	assert.Equal(t, events.Synchronized, instance.Status.SynchronizationState, "AzureAdApplication should be synchronized")
 	return instance
@@ -593,11 +692,16 @@ func assertSecretsAreNotRotated(t *testing.T, previous *corev1.Secret, new *core
 	}
 }
 
-func assertPreAuthorizedAppsStatusIsValid(t *testing.T, expected []v1.AccessPolicyInboundRule, actual *v1.AzureAdPreAuthorizedAppsStatus) {
+func assertPreAuthorizedAppsStatusIsValid(t *testing.T, instance *v1.AzureAdApplication, actual *v1.AzureAdPreAuthorizedAppsStatus) {
 	expectedInvalid := make([]v1.AccessPolicyRule, 0)
-	expectedValid := make([]v1.AccessPolicyRule, 0)
+	// the application is always pre-authorized for itself
+	expectedValid := []v1.AccessPolicyRule{{
+		Application: instance.GetName(),
+		Namespace:   instance.GetNamespace(),
+		Cluster:     clusterName,
+	}}
 
-	for _, a := range expected {
+	for _, a := range instance.Spec.PreAuthorizedApplications {
 		if strings.HasPrefix(a.Application, "invalid") {
 			expectedInvalid = append(expectedInvalid, a.AccessPolicyRule)
 		} else {
@@ -705,10 +809,22 @@ func setup() (*envtest.Environment, error) {
 		return nil, err
 	}
 	azureratorCfg.SecretRotation.MaxAge = maxSecretAge
-	azureratorCfg.Azure.Tenant.Id = "some-id"
-	azureratorCfg.ClusterName = "test-cluster"
+	azureratorCfg.Azure.Tenant.Id = tenantId
+	azureratorCfg.ClusterName = clusterName
 	azureratorCfg.Controller.SweepInterval = 3 * time.Second
 
+	sinkDirectory, err = os.MkdirTemp("", "azurerator-sinks-")
+	if err != nil {
+		return nil, err
+	}
+	azureratorCfg.SecretSinks.File.Directory = sinkDirectory
+
+	// applications that exist in the directory before the tests start, i.e. applicationExists and the valid
+	// pre-authorized applications referenced by the tests
+	azureClient.AddApplication(kubernetes.UniformResourceName(&metav1.ObjectMeta{Name: applicationExists, Namespace: namespace}, clusterName))
+	azureClient.AddApplication(kubernetes.UniformResourceName(&metav1.ObjectMeta{Name: "some-other-app", Namespace: namespace}, clusterName))
+	azureClient.AddApplication(kubernetes.UniformResourceName(&metav1.ObjectMeta{Name: "valid-app", Namespace: "some-namespace"}, "some-cluster"))
+
 	azureOpenIDConfig := fake.AzureOpenIdConfig()
 	syncer := synchronizer.New(azureratorCfg.ClusterName, mgr.GetClient(), mgr.GetAPIReader())
 
@@ -733,6 +849,7 @@ func setup() (*envtest.Environment, error) {
 		azureClient,
 		azureratorCfg.Azure.Tenant.Id,
 		azureratorCfg.Controller.SweepInterval,
+		syncer.SkippedGroups(),
 	)); err != nil {
 		return nil, err
 	}
@@ -7,10 +7,35 @@ import (
 )
 
 const (
-	PreserveKey         = "azure.nais.io/preserve"
-	ResynchronizeKey    = "azure.nais.io/resync"
-	RotateKey           = "azure.nais.io/rotate"
-	StakaterReloaderKey = "reloader.stakater.com/match"
+	APIPermissionsKey           = "azure.nais.io/api-permissions"
+	AccessTokenVersionKey       = "azure.nais.io/access-token-version"
+	CertificateCredentialsKey   = "azure.nais.io/certificate-credentials"
+	ClaimsMappingPolicyKey      = "azure.nais.io/claims-mapping-policy"
+	CredentialModeKey           = "azure.nais.io/credential-mode"
+	FallbackPublicClientKey     = "azure.nais.io/fallback-public-client"
+	GroupRolesKey               = "azure.nais.io/group-roles"
+	HomePageUrlKey              = "azure.nais.io/home-page-url"
+	IdentifierUrisKey           = "azure.nais.io/identifier-uris"
+	ImplicitGrantKey            = "azure.nais.io/implicit-grant"
+	KeyTypeKey                  = "azure.nais.io/key-type"
+	KnownClientApplicationsKey  = "azure.nais.io/known-client-applications"
+	OptionalClaimsKey           = "azure.nais.io/optional-claims"
+	OwnersKey                   = "azure.nais.io/owners"
+	PreserveKey                 = "azure.nais.io/preserve"
+	PublicClientRedirectUrisKey = "azure.nais.io/public-client-redirect-uris"
+	ResynchronizeKey            = "azure.nais.io/resync"
+	RevokeKey                   = "azure.nais.io/revoke"
+	RotateKey                   = "azure.nais.io/rotate"
+	RotationMaxAgeKey           = "azure.nais.io/rotation-max-age"
+	SecretSinksKey              = "azure.nais.io/secret-sinks"
+	SecretTemplatesKey          = "azure.nais.io/secret-templates"
+	ServiceAccountKey           = "azure.nais.io/service-account"
+	StakaterReloaderKey         = "reloader.stakater.com/match"
+	TokenLifetimePolicyKey      = "azure.nais.io/token-lifetime-policy"
+
+	// SecretTemplatePrefix is the prefix for annotations holding templates for additional secret entries, where the
+	// name of the annotation is the secret key, e.g. 'template.azure.nais.io/application-azure.properties'.
+	SecretTemplatePrefix = "template.azure.nais.io/"
 )
 
 func SetAnnotation(resource client.Object, key, value string) {
@@ -61,6 +86,22 @@ func HasAnnotation(resource client.Object, key string) (string, bool) {
 	return value, found
 }
 
+// Values returns the comma-separated values for the given key, ignoring surrounding whitespace and empty values.
+func Values(resource client.Object, key string) []string {
+	value, found := HasAnnotation(resource, key)
+	if !found {
+		return nil
+	}
+
+	values := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
 func RemoveAnnotation(resource client.Object, key string) {
 	_, found := HasAnnotation(resource, key)
 	if found {
@@ -226,3 +226,18 @@ func TestSetAnnotation(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, "some-value", val)
 }
+
+func TestValues(t *testing.T) {
+	t.Run("annotation exists", func(t *testing.T) {
+		app := fixtures.MinimalApplication()
+		annotations.SetAnnotation(app, "some-key", " some-value,, some-other-value ,")
+
+		assert.Equal(t, []string{"some-value", "some-other-value"}, annotations.Values(app, "some-key"))
+	})
+
+	t.Run("no matching annotation", func(t *testing.T) {
+		app := fixtures.MinimalApplication()
+
+		assert.Nil(t, annotations.Values(app, "some-key"))
+	})
+}
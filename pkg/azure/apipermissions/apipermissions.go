@@ -0,0 +1,155 @@
+package apipermissions
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MicrosoftGraphAlias may be used in place of the client ID of Microsoft Graph.
+	MicrosoftGraphAlias = "graph"
+	// MicrosoftGraphClientId is the well-known client ID of Microsoft Graph, identical in all tenants.
+	MicrosoftGraphClientId = "00000003-0000-0000-c000-000000000000"
+
+	// Wildcard matches any permission of a resource in the allowlist.
+	Wildcard = "*"
+)
+
+// Type is the kind of access that a permission grants.
+type Type string
+
+const (
+	// TypeApplication is an app role of the resource, granted to the application itself through an app role assignment.
+	TypeApplication Type = "application"
+	// TypeDelegated is an OAuth2 permission scope of the resource, granted on behalf of signed-in users through an
+	// OAuth2 permission grant.
+	TypeDelegated Type = "delegated"
+)
+
+// Permission is a permission for an API, identified by the client ID of the API (the resource) and the value of the
+// app role or OAuth2 permission scope, e.g. 'application:graph/User.Read.All'.
+type Permission struct {
+	Type     Type
+	Resource string
+	Name     string
+}
+
+// Parse parses a permission in the form '<type>:<resource>/<name>', where type is either 'application' or 'delegated',
+// and resource is either the client ID of the API or 'graph' for Microsoft Graph.
+func Parse(value string) (Permission, error) {
+	typ, rest, found := strings.Cut(strings.TrimSpace(value), ":")
+	if !found {
+		return Permission{}, fmt.Errorf("invalid API permission '%s': must be in the form '<type>:<resource>/<name>'", value)
+	}
+
+	resource, name, found := strings.Cut(rest, "/")
+	if !found || len(name) == 0 || strings.ContainsAny(name, " /") {
+		return Permission{}, fmt.Errorf("invalid API permission '%s': must be in the form '<type>:<resource>/<name>'", value)
+	}
+
+	switch Type(typ) {
+	case TypeApplication, TypeDelegated:
+	default:
+		return Permission{}, fmt.Errorf("invalid API permission '%s': type must be one of '%s' or '%s'", value, TypeApplication, TypeDelegated)
+	}
+
+	if resource == MicrosoftGraphAlias {
+		resource = MicrosoftGraphClientId
+	}
+	clientId, err := uuid.Parse(resource)
+	if err != nil {
+		return Permission{}, fmt.Errorf("invalid API permission '%s': resource must be '%s' or a client ID", value, MicrosoftGraphAlias)
+	}
+
+	return Permission{
+		Type:     Type(typ),
+		Resource: clientId.String(),
+		Name:     name,
+	}, nil
+}
+
+func (p Permission) String() string {
+	resource := p.Resource
+	if resource == MicrosoftGraphClientId {
+		resource = MicrosoftGraphAlias
+	}
+	return fmt.Sprintf("%s:%s/%s", p.Type, resource, p.Name)
+}
+
+// Matches returns true if the given permission is matched by this permission, where a wildcard name matches any
+// permission of the same type for the resource.
+func (p Permission) Matches(other Permission) bool {
+	return p.Type == other.Type && p.Resource == other.Resource && (p.Name == Wildcard || p.Name == other.Name)
+}
+
+type Permissions []Permission
+
+// ParseAll parses the given permissions. The result is sorted and without duplicates.
+func ParseAll(values []string) (Permissions, error) {
+	result := make(Permissions, 0, len(values))
+	for _, value := range values {
+		permission, err := Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, permission)
+	}
+
+	slices.SortFunc(result, compare)
+	return slices.Compact(result), nil
+}
+
+func compare(a, b Permission) int {
+	return cmp.Or(cmp.Compare(a.Resource, b.Resource), cmp.Compare(a.Type, b.Type), cmp.Compare(a.Name, b.Name))
+}
+
+// Allows returns true if the given permission is matched by any of the permissions in the list.
+func (p Permissions) Allows(permission Permission) bool {
+	return slices.ContainsFunc(p, func(allowed Permission) bool {
+		return allowed.Matches(permission)
+	})
+}
+
+// Filter returns the permissions of the given type for the given resource.
+func (p Permissions) Filter(typ Type, resource string) Permissions {
+	result := make(Permissions, 0)
+	for _, permission := range p {
+		if permission.Type == typ && permission.Resource == resource {
+			result = append(result, permission)
+		}
+	}
+	return result
+}
+
+// Names returns the names of the permissions.
+func (p Permissions) Names() []string {
+	result := make([]string, 0, len(p))
+	for _, permission := range p {
+		result = append(result, permission.Name)
+	}
+	return result
+}
+
+// Resources returns the distinct client IDs of the resources in the list, sorted.
+func (p Permissions) Resources() []string {
+	result := make([]string, 0)
+	for _, permission := range p {
+		result = append(result, permission.Resource)
+	}
+
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// String returns the permissions as a comma-separated list.
+func (p Permissions) String() string {
+	values := make([]string, 0, len(p))
+	for _, permission := range p {
+		values = append(values, permission.String())
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,86 @@
+package apipermissions_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/azureator/pkg/azure/apipermissions"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		value    string
+		expected apipermissions.Permission
+		err      string
+	}{
+		{
+			value: "application:graph/User.Read.All",
+			expected: apipermissions.Permission{
+				Type:     apipermissions.TypeApplication,
+				Resource: apipermissions.MicrosoftGraphClientId,
+				Name:     "User.Read.All",
+			},
+		},
+		{
+			value: " delegated:6D4D7B60-9C7A-4F1B-A1E4-5B1C2E0B8A11/access_as_user ",
+			expected: apipermissions.Permission{
+				Type:     apipermissions.TypeDelegated,
+				Resource: "6d4d7b60-9c7a-4f1b-a1e4-5b1c2e0b8a11",
+				Name:     "access_as_user",
+			},
+		},
+		{value: "graph/User.Read.All", err: "must be in the form"},
+		{value: "application:graph", err: "must be in the form"},
+		{value: "application:graph/", err: "must be in the form"},
+		{value: "application:graph/User Read", err: "must be in the form"},
+		{value: "admin:graph/User.Read.All", err: "type must be one of"},
+		{value: "application:some-app/User.Read.All", err: "resource must be 'graph' or a client ID"},
+	} {
+		t.Run(tt.value, func(t *testing.T) {
+			permission, err := apipermissions.Parse(tt.value)
+			if len(tt.err) > 0 {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, permission)
+		})
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	permissions, err := apipermissions.ParseAll([]string{
+		"delegated:graph/User.Read",
+		"application:graph/User.Read.All",
+		"delegated:graph/User.Read",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "application:graph/User.Read.All,delegated:graph/User.Read", permissions.String())
+	assert.Equal(t, []string{apipermissions.MicrosoftGraphClientId}, permissions.Resources())
+	assert.Equal(t, []string{"User.Read"}, permissions.Filter(apipermissions.TypeDelegated, apipermissions.MicrosoftGraphClientId).Names())
+
+	_, err = apipermissions.ParseAll([]string{"application:graph/User.Read.All", "invalid"})
+	assert.Error(t, err)
+}
+
+func TestPermissions_Allows(t *testing.T) {
+	allowlist, err := apipermissions.ParseAll([]string{
+		"application:graph/User.Read.All",
+		"delegated:graph/*",
+	})
+	require.NoError(t, err)
+
+	for value, expected := range map[string]bool{
+		"application:graph/User.Read.All":                                true,
+		"application:graph/Group.Read.All":                               false,
+		"delegated:graph/User.Read.All":                                  true,
+		"delegated:graph/Mail.Send":                                      true,
+		"application:6d4d7b60-9c7a-4f1b-a1e4-5b1c2e0b8a11/User.Read.All": false,
+	} {
+		permission, err := apipermissions.Parse(value)
+		require.NoError(t, err)
+		assert.Equal(t, expected, allowlist.Allows(permission), value)
+	}
+}
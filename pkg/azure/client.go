@@ -24,6 +24,7 @@ type Client interface {
 	GetServicePrincipal(tx transaction.Transaction) (msgraph.ServicePrincipal, error)
 
 	PreAuthorizedAppClientID(ctx context.Context, rule v1.AccessPolicyRule) (clientID string, assignable bool, err error)
+	GroupExists(ctx context.Context, reference string) (bool, error)
 }
 
 type Credentials interface {
@@ -32,5 +33,5 @@ type Credentials interface {
 	DeleteUnused(tx transaction.Transaction) error
 	Purge(tx transaction.Transaction) error
 	Rotate(tx transaction.Transaction) (credentials.Set, error)
-	Validate(tx transaction.Transaction, existing credentials.Set) (bool, error)
+	Validate(tx transaction.Transaction, existing credentials.Set) (credentials.Validation, error)
 }
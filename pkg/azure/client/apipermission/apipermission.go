@@ -0,0 +1,336 @@
+package apipermission
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	msgraph "github.com/nais/msgraph.go/v1.0"
+
+	"github.com/nais/azureator/pkg/azure"
+	"github.com/nais/azureator/pkg/azure/apipermissions"
+	"github.com/nais/azureator/pkg/azure/client/application"
+	"github.com/nais/azureator/pkg/azure/client/application/requiredresourceaccess"
+	"github.com/nais/azureator/pkg/azure/client/approleassignment"
+	"github.com/nais/azureator/pkg/azure/client/oauth2permissiongrant"
+	"github.com/nais/azureator/pkg/azure/client/serviceprincipal"
+	"github.com/nais/azureator/pkg/azure/resource"
+	"github.com/nais/azureator/pkg/azure/util"
+	"github.com/nais/azureator/pkg/transaction"
+)
+
+const (
+	resourceAccessTypeRole   = "Role"
+	resourceAccessTypeScope  = "Scope"
+	consentTypeAllPrincipals = "AllPrincipals"
+)
+
+// APIPermissions reconciles the additional API permissions requested for an application, along with admin consent for
+// these permissions.
+//
+// Permissions are only revoked for resources in the configured allowlist, and only if the allowlist matches the
+// permission. Permissions granted by other means, e.g. pre-authorization of another application, are preserved.
+type APIPermissions interface {
+	Process(tx transaction.Transaction) error
+}
+
+type Client interface {
+	azure.RuntimeClient
+	Application() application.Application
+	ServicePrincipal() serviceprincipal.ServicePrincipal
+}
+
+type apiPermissions struct {
+	Client
+}
+
+func NewAPIPermissions(client Client) APIPermissions {
+	return apiPermissions{Client: client}
+}
+
+func (a apiPermissions) Process(tx transaction.Transaction) error {
+	desired := tx.Options.Process.Azure.APIPermissions
+
+	allowed, err := a.Config().APIPermissions.AllowedPermissions()
+	if err != nil {
+		return fmt.Errorf("parsing allowed API permissions: %w", err)
+	}
+
+	if len(desired) == 0 && len(allowed) == 0 {
+		return nil
+	}
+
+	resources, err := a.resources(tx.Ctx, desired, allowed)
+	if err != nil {
+		return err
+	}
+
+	if err := a.processRequiredResourceAccess(tx, desired, allowed, resources); err != nil {
+		return fmt.Errorf("processing required resource access: %w", err)
+	}
+
+	if err := a.processAppRoleAssignments(tx, desired, allowed, resources); err != nil {
+		return fmt.Errorf("processing application permissions: %w", err)
+	}
+
+	if err := a.processGrants(tx, desired, allowed, resources); err != nil {
+		return fmt.Errorf("processing delegated permissions: %w", err)
+	}
+
+	return nil
+}
+
+// resources returns the service principals for the resources of the desired and allowed permissions, keyed by client
+// ID. Allowed resources without a service principal in the tenant are skipped.
+func (a apiPermissions) resources(ctx context.Context, desired, allowed apipermissions.Permissions) (map[azure.ClientId]msgraph.ServicePrincipal, error) {
+	result := make(map[azure.ClientId]msgraph.ServicePrincipal)
+
+	for _, clientId := range slices.Concat(desired, allowed).Resources() {
+		exists, sp, err := a.ServicePrincipal().Exists(ctx, clientId)
+		if err != nil {
+			return nil, fmt.Errorf("looking up service principal for resource '%s': %w", clientId, err)
+		}
+
+		if exists {
+			result[clientId] = sp
+		} else if slices.Contains(desired.Resources(), clientId) {
+			return nil, fmt.Errorf("service principal for resource '%s' not found", clientId)
+		}
+	}
+
+	return result, nil
+}
+
+// processRequiredResourceAccess declares the desired permissions on the application, in addition to the default
+// permissions for Microsoft Graph. Declarations for resources that are not in the allowlist are preserved.
+func (a apiPermissions) processRequiredResourceAccess(tx transaction.Transaction, desired, allowed apipermissions.Permissions, resources map[azure.ClientId]msgraph.ServicePrincipal) error {
+	app, err := a.Application().GetByClientId(tx.Ctx, tx.Instance.GetClientId())
+	if err != nil {
+		return err
+	}
+
+	access := make([]msgraph.RequiredResourceAccess, 0)
+	for _, existing := range app.RequiredResourceAccess {
+		clientId := strings.ToLower(*existing.ResourceAppID)
+		if clientId != apipermissions.MicrosoftGraphClientId && !slices.Contains(allowed.Resources(), clientId) {
+			access = append(access, existing)
+		}
+	}
+
+	// the default permissions for Microsoft Graph are always declared
+	clientIds := slices.AppendSeq([]azure.ClientId{apipermissions.MicrosoftGraphClientId}, maps.Keys(resources))
+	slices.Sort(clientIds)
+
+	for _, clientId := range slices.Compact(clientIds) {
+		entry := msgraph.RequiredResourceAccess{
+			ResourceAppID:  new(clientId),
+			ResourceAccess: make([]msgraph.ResourceAccess, 0),
+		}
+		if clientId == apipermissions.MicrosoftGraphClientId {
+			entry = requiredresourceaccess.NewRequiredResourceAccess().MicrosoftGraph()
+		}
+
+		for _, permission := range desired {
+			if permission.Resource != clientId {
+				continue
+			}
+
+			id, err := permissionId(permission, resources[clientId])
+			if err != nil {
+				return err
+			}
+
+			accessType := resourceAccessTypeScope
+			if permission.Type == apipermissions.TypeApplication {
+				accessType = resourceAccessTypeRole
+			}
+
+			exists := slices.ContainsFunc(entry.ResourceAccess, func(ra msgraph.ResourceAccess) bool {
+				return *ra.ID == id && *ra.Type == accessType
+			})
+			if !exists {
+				entry.ResourceAccess = append(entry.ResourceAccess, msgraph.ResourceAccess{ID: &id, Type: new(accessType)})
+			}
+		}
+
+		if len(entry.ResourceAccess) > 0 {
+			access = append(access, entry)
+		}
+	}
+
+	return a.Application().Patch(tx.Ctx, tx.Instance.GetObjectId(), util.EmptyApplication().ResourceAccess(access))
+}
+
+// processAppRoleAssignments grants admin consent for the desired application permissions by assigning the app roles of
+// the resources to the application's service principal.
+func (a apiPermissions) processAppRoleAssignments(tx transaction.Transaction, desired, allowed apipermissions.Permissions, resources map[azure.ClientId]msgraph.ServicePrincipal) error {
+	servicePrincipalId := tx.Instance.GetServicePrincipalId()
+
+	existing, err := a.GraphClient().ServicePrincipals().ID(servicePrincipalId).AppRoleAssignments().Request().GetN(tx.Ctx, a.MaxNumberOfPagesToFetch())
+	if err != nil {
+		return fmt.Errorf("looking up app role assignments for service principal '%s': %w", servicePrincipalId, err)
+	}
+
+	// app roles of the resources by resource object ID and app role ID
+	type key struct{ resourceId, appRoleId msgraph.UUID }
+	names := make(map[key]apipermissions.Permission)
+	for clientId, sp := range resources {
+		for _, role := range sp.AppRoles {
+			if role.ID != nil && role.Value != nil {
+				names[key{msgraph.UUID(*sp.ID), *role.ID}] = apipermissions.Permission{Type: apipermissions.TypeApplication, Resource: clientId, Name: *role.Value}
+			}
+		}
+	}
+	nameOf := func(assignment msgraph.AppRoleAssignment) apipermissions.Permission {
+		return names[key{*assignment.ResourceID, *assignment.AppRoleID}]
+	}
+
+	// only assignments for allowed permissions are managed by us
+	managed := make(approleassignment.List, 0)
+	for _, assignment := range existing {
+		if permission, found := names[key{*assignment.ResourceID, *assignment.AppRoleID}]; found && allowed.Allows(permission) {
+			managed = append(managed, assignment)
+		}
+	}
+
+	desiredAssignments := make(approleassignment.List, 0)
+	for _, permission := range desired {
+		if permission.Type != apipermissions.TypeApplication {
+			continue
+		}
+
+		sp := resources[permission.Resource]
+		id, err := permissionId(permission, sp)
+		if err != nil {
+			return err
+		}
+
+		desiredAssignments = append(desiredAssignments, msgraph.AppRoleAssignment{
+			AppRoleID:     &id,
+			PrincipalID:   new(msgraph.UUID(servicePrincipalId)),
+			PrincipalType: new(string(resource.PrincipalTypeServicePrincipal)),
+			ResourceID:    new(msgraph.UUID(*sp.ID)),
+		})
+	}
+
+	for _, assignment := range approleassignment.ToAssign(managed, desiredAssignments) {
+		if _, err := a.GraphClient().ServicePrincipals().ID(servicePrincipalId).AppRoleAssignments().Request().Add(tx.Ctx, &assignment); err != nil {
+			return fmt.Errorf("assigning application permission '%s': %w", nameOf(assignment), err)
+		}
+		tx.Logger.Infof("granted application permission '%s'", nameOf(assignment))
+	}
+
+	for _, assignment := range approleassignment.ToRevoke(managed, desiredAssignments) {
+		if err := a.GraphClient().ServicePrincipals().ID(servicePrincipalId).AppRoleAssignments().ID(*assignment.ID).Request().Delete(tx.Ctx); err != nil {
+			return fmt.Errorf("revoking application permission '%s': %w", nameOf(assignment), err)
+		}
+		tx.Logger.Infof("revoked application permission '%s'", nameOf(assignment))
+	}
+
+	return nil
+}
+
+// processGrants grants admin consent for the desired delegated permissions through a single OAuth2 permission grant
+// for all principals per resource. The default scopes for Microsoft Graph are always retained.
+func (a apiPermissions) processGrants(tx transaction.Transaction, desired, allowed apipermissions.Permissions, resources map[azure.ClientId]msgraph.ServicePrincipal) error {
+	servicePrincipalId := tx.Instance.GetServicePrincipalId()
+
+	r := a.GraphClient().OAuth2PermissionGrants().Request()
+	r.Filter(util.FilterByClientId(servicePrincipalId))
+	grants, err := r.GetN(tx.Ctx, a.MaxNumberOfPagesToFetch())
+	if err != nil {
+		return fmt.Errorf("looking up oauth2 permission grants: %w", err)
+	}
+
+	for _, clientId := range slices.Sorted(maps.Keys(resources)) {
+		sp := resources[clientId]
+
+		scopes := desired.Filter(apipermissions.TypeDelegated, clientId).Names()
+		if clientId == apipermissions.MicrosoftGraphClientId {
+			scopes = append(scopes, oauth2permissiongrant.DefaultScopes...)
+		}
+		for _, permission := range desired.Filter(apipermissions.TypeDelegated, clientId) {
+			if _, err := permissionId(permission, sp); err != nil {
+				return err
+			}
+		}
+
+		idx := slices.IndexFunc(grants, func(grant msgraph.OAuth2PermissionGrant) bool {
+			return *grant.ResourceID == *sp.ID && *grant.ConsentType == consentTypeAllPrincipals
+		})
+		if idx < 0 {
+			if len(scopes) == 0 {
+				continue
+			}
+
+			grant := &msgraph.OAuth2PermissionGrant{
+				ClientID:    new(servicePrincipalId),
+				ConsentType: new(consentTypeAllPrincipals),
+				ResourceID:  sp.ID,
+				Scope:       new(joinScopes(scopes)),
+			}
+			if _, err := a.GraphClient().OAuth2PermissionGrants().Request().Add(tx.Ctx, grant); err != nil {
+				return fmt.Errorf("granting delegated permissions for resource '%s': %w", clientId, err)
+			}
+			tx.Logger.Infof("granted delegated permissions '%s' for resource '%s'", joinScopes(scopes), clientId)
+			continue
+		}
+
+		grant := grants[idx]
+		existing := strings.Fields(*grant.Scope)
+
+		// only scopes for allowed permissions are managed by us
+		for _, scope := range existing {
+			permission := apipermissions.Permission{Type: apipermissions.TypeDelegated, Resource: clientId, Name: scope}
+			if !allowed.Allows(permission) {
+				scopes = append(scopes, scope)
+			}
+		}
+
+		switch {
+		case len(scopes) == 0:
+			if err := a.GraphClient().OAuth2PermissionGrants().ID(*grant.ID).Request().Delete(tx.Ctx); err != nil {
+				return fmt.Errorf("revoking delegated permissions for resource '%s': %w", clientId, err)
+			}
+			tx.Logger.Infof("revoked delegated permissions for resource '%s'", clientId)
+		case joinScopes(scopes) != joinScopes(existing):
+			if err := a.GraphClient().OAuth2PermissionGrants().ID(*grant.ID).Request().Update(tx.Ctx, &msgraph.OAuth2PermissionGrant{Scope: new(joinScopes(scopes))}); err != nil {
+				return fmt.Errorf("updating delegated permissions for resource '%s': %w", clientId, err)
+			}
+			tx.Logger.Infof("updated delegated permissions for resource '%s' to '%s'", clientId, joinScopes(scopes))
+		}
+	}
+
+	return nil
+}
+
+// permissionId returns the ID of the app role or OAuth2 permission scope of the resource that the permission refers to.
+func permissionId(permission apipermissions.Permission, sp msgraph.ServicePrincipal) (msgraph.UUID, error) {
+	switch permission.Type {
+	case apipermissions.TypeApplication:
+		for _, role := range sp.AppRoles {
+			enabled := role.IsEnabled == nil || *role.IsEnabled
+			if role.Value != nil && *role.Value == permission.Name && enabled && slices.Contains(role.AllowedMemberTypes, "Application") {
+				return *role.ID, nil
+			}
+		}
+	case apipermissions.TypeDelegated:
+		for _, scope := range sp.OAuth2PermissionScopes {
+			enabled := scope.IsEnabled == nil || *scope.IsEnabled
+			if scope.Value != nil && *scope.Value == permission.Name && enabled {
+				return *scope.ID, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("API permission '%s' is not defined by the resource", permission)
+}
+
+// joinScopes returns the distinct scopes in a stable order, separated by spaces.
+func joinScopes(scopes []string) string {
+	sorted := slices.Clone(scopes)
+	slices.Sort(sorted)
+	return strings.Join(slices.Compact(sorted), " ")
+}
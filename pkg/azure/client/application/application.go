@@ -3,6 +3,8 @@ package application
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	cache "github.com/Code-Hex/go-generics-cache"
@@ -12,11 +14,14 @@ import (
 
 	"github.com/nais/azureator/pkg/azure"
 	"github.com/nais/azureator/pkg/azure/client/application/approle"
+	"github.com/nais/azureator/pkg/azure/client/application/federatedidentitycredential"
 	"github.com/nais/azureator/pkg/azure/client/application/identifieruri"
+	"github.com/nais/azureator/pkg/azure/client/application/marker"
 	"github.com/nais/azureator/pkg/azure/client/application/optionalclaims"
 	"github.com/nais/azureator/pkg/azure/client/application/permissionscope"
 	"github.com/nais/azureator/pkg/azure/client/application/redirecturi"
 	"github.com/nais/azureator/pkg/azure/client/application/requiredresourceaccess"
+	"github.com/nais/azureator/pkg/azure/client/application/tokenlifetimepolicy"
 	"github.com/nais/azureator/pkg/azure/permissions"
 	"github.com/nais/azureator/pkg/azure/util"
 	"github.com/nais/azureator/pkg/transaction"
@@ -33,13 +38,15 @@ var IsManagedCache = cache.New[azure.ClientId, bool]()
 
 type Application interface {
 	AppRoles() approle.AppRoles
+	FederatedIdentityCredentials() federatedidentitycredential.FederatedIdentityCredentials
 	IdentifierUri() identifieruri.IdentifierUri
 	OAuth2PermissionScopes() permissionscope.OAuth2PermissionScope
 	Owners() owners.Owners
 	RedirectUri() redirecturi.RedirectUri
+	TokenLifetimePolicies() tokenlifetimepolicy.TokenLifetimePolicies
 
 	Delete(tx transaction.Transaction) error
-	EnableAcceptMappedClaims(tx transaction.Transaction, application *msgraph.Application) error
+	SetAcceptMappedClaims(tx transaction.Transaction, application *msgraph.Application, enabled bool) error
 	Exists(tx transaction.Transaction) (*msgraph.Application, bool, error)
 	ExistsByFilter(ctx context.Context, filter azure.Filter) (*msgraph.Application, bool, error)
 	Get(tx transaction.Transaction) (msgraph.Application, error)
@@ -48,6 +55,7 @@ type Application interface {
 	Patch(ctx context.Context, id azure.ObjectId, application any) error
 	Register(tx transaction.Transaction) (*msgraph.Application, error)
 	RemoveDisabledPermissions(tx transaction.Transaction, application msgraph.Application) error
+	RemoveStaleMarkers(tx transaction.Transaction, application msgraph.Application) error
 	Update(tx transaction.Transaction) (*msgraph.Application, error)
 }
 
@@ -63,6 +71,10 @@ func (a application) AppRoles() approle.AppRoles {
 	return approle.NewAppRoles()
 }
 
+func (a application) FederatedIdentityCredentials() federatedidentitycredential.FederatedIdentityCredentials {
+	return federatedidentitycredential.NewFederatedIdentityCredentials(a.RuntimeClient)
+}
+
 func (a application) IdentifierUri() identifieruri.IdentifierUri {
 	return identifieruri.NewIdentifierUri(a)
 }
@@ -83,6 +95,10 @@ func (a application) RedirectUri() redirecturi.RedirectUri {
 	return redirecturi.NewRedirectUri(a)
 }
 
+func (a application) TokenLifetimePolicies() tokenlifetimepolicy.TokenLifetimePolicies {
+	return tokenlifetimepolicy.NewTokenLifetimePolicies(a.RuntimeClient)
+}
+
 func (a application) RequiredResourceAccess() requiredresourceaccess.RequiredResourceAccess {
 	return requiredresourceaccess.NewRequiredResourceAccess()
 }
@@ -104,7 +120,7 @@ func (a application) Register(tx transaction.Transaction) (*msgraph.Application,
 	}
 	desiredPermissions := permissions.GenerateDesiredPermissionSet(tx.Instance)
 
-	roles := a.AppRoles().DescribeCreate(desiredPermissions)
+	roles := a.AppRoles().DescribeCreate(desiredPermissions, tx.Options.Process.Azure.GroupRoles.Roles())
 	roles.Log(tx.Logger)
 
 	scopes := a.OAuth2PermissionScopes().DescribeCreate(desiredPermissions)
@@ -112,15 +128,18 @@ func (a application) Register(tx transaction.Transaction) (*msgraph.Application,
 
 	redirectUris := redirecturi.ReplyUrlsToStringSlice(tx.Instance)
 
-	optionalClaims := a.OptionalClaims().DescribeCreate()
+	optionalClaims := a.OptionalClaims().DescribeCreate(tx.Options.Process.Azure.OptionalClaims)
 
 	groupMembershipClaims, err := groupmembershipclaim.FromSpecOrDefault(tx.Instance, a.Config().Features.GroupMembershipClaim.Default)
 	if err != nil {
 		return nil, err
 	}
 
-	req := util.Application(a.defaultTemplate(tx)).
+	apiSettings := tx.Options.Process.Azure.APISettings
+	req := util.Application(a.defaultTemplate(tx, marker.Desired(tx.Options.Process.Azure))).
+		AccessTokenVersion(apiSettings.AccessTokenVersionOr(a.Config().APISettings.DefaultAccessTokenVersion())).
 		AppRoles(roles.GetResult()).
+		KnownClientApplications(apiSettings.KnownClientApplications).
 		GroupMembershipClaims(groupMembershipClaims).
 		OptionalClaims(optionalClaims).
 		PermissionScopes(scopes.GetResult()).
@@ -148,18 +167,29 @@ func (a application) Update(tx transaction.Transaction) (*msgraph.Application, e
 	desiredPermissions := permissions.GenerateDesiredPermissionSetPreserveExisting(tx.Instance, actualApp)
 
 	existingRoles := actualApp.AppRoles
-	roles := a.AppRoles().DescribeUpdate(desiredPermissions, existingRoles)
+	azureOptions := tx.Options.Process.Azure
+	roles := a.AppRoles().DescribeUpdate(desiredPermissions, azureOptions.GroupRoles.Roles(), existingRoles)
 	roles.Log(tx.Logger)
 
 	existingScopes := actualApp.API.OAuth2PermissionScopes
 	scopes := a.OAuth2PermissionScopes().DescribeUpdate(desiredPermissions, existingScopes)
 	scopes.Log(tx.Logger)
 
-	identifierUris := identifieruri.DescribeUpdate(tx.Instance, actualApp.IdentifierUris, tx.ClusterName)
-	optionalClaims := a.OptionalClaims().DescribeUpdate(actualApp)
-	builder := util.Application(a.defaultTemplate(tx)).
+	// settings previously applied by the operator keep their markers until the stale settings are removed
+	previous := marker.FromTags(actualApp.Tags)
+	previousClaims, err := optionalclaims.ParseAll(previous.Values(marker.OptionalClaim))
+	if err != nil {
+		return nil, fmt.Errorf("parsing optional claim markers: %w", err)
+	}
+
+	identifierUris := identifieruri.DescribeUpdate(tx.Instance, actualApp.IdentifierUris, tx.ClusterName, azureOptions.IdentifierUris, previous.Values(marker.IdentifierUri))
+	optionalClaims := a.OptionalClaims().DescribeUpdate(actualApp, azureOptions.OptionalClaims, previousClaims)
+	knownClients := knownClientApplications(actualApp.API, azureOptions.APISettings.KnownClientApplications, previous.Values(marker.KnownClientApplication))
+	builder := util.Application(a.defaultTemplate(tx, previous.Union(marker.Desired(azureOptions)))).
+		AccessTokenVersion(azureOptions.APISettings.AccessTokenVersionOr(a.Config().APISettings.DefaultAccessTokenVersion())).
 		AppRoles(roles.GetResult()).
 		IdentifierUriList(identifierUris).
+		KnownClientApplications(knownClients).
 		OptionalClaims(optionalClaims).
 		PermissionScopes(scopes.GetResult())
 
@@ -183,7 +213,46 @@ func (a application) Update(tx transaction.Transaction) (*msgraph.Application, e
 	}
 
 	app := builder.Build()
-	return app, a.Patch(tx.Ctx, objectId, app)
+	if err := a.Patch(tx.Ctx, objectId, app); err != nil {
+		return app, err
+	}
+
+	if len(app.API.KnownClientApplications) == 0 && actualApp.API != nil && len(actualApp.API.KnownClientApplications) > 0 {
+		// an empty list is omitted from the patch above, so removing the known client applications requires an explicit
+		// empty list
+		if err := a.clearKnownClientApplications(tx); err != nil {
+			return app, fmt.Errorf("removing known client applications: %w", err)
+		}
+	}
+
+	return app, nil
+}
+
+// knownClientApplications returns the desired known client applications, along with any existing known client
+// applications that were not previously applied by the operator, i.e. that have no marker and were added by other means.
+func knownClientApplications(actual *msgraph.APIApplication, desired, previous []string) []string {
+	known := slices.Clone(desired)
+	if actual != nil {
+		for _, clientId := range actual.KnownClientApplications {
+			id := strings.ToLower(string(clientId))
+			if !slices.Contains(previous, id) {
+				known = append(known, id)
+			}
+		}
+	}
+	slices.Sort(known)
+	return slices.Compact(known)
+}
+
+func (a application) clearKnownClientApplications(tx transaction.Transaction) error {
+	payload := struct {
+		API struct {
+			KnownClientApplications []msgraph.UUID `json:"knownClientApplications"`
+		} `json:"api"`
+	}{}
+	payload.API.KnownClientApplications = make([]msgraph.UUID, 0)
+
+	return a.Patch(tx.Ctx, tx.Instance.GetObjectId(), payload)
 }
 
 func (a application) Patch(ctx context.Context, id azure.ObjectId, application any) error {
@@ -229,6 +298,26 @@ func (a application) GetByClientId(ctx context.Context, id azure.ClientId) (msgr
 	return *application, nil
 }
 
+// RemoveStaleMarkers removes the markers for settings that are no longer requested. This is done after the settings
+// themselves have been removed, so that a failed synchronization is retried with the markers still in place.
+func (a application) RemoveStaleMarkers(tx transaction.Transaction, application msgraph.Application) error {
+	desired := marker.Desired(tx.Options.Process.Azure)
+	if marker.FromTags(application.Tags).Equal(desired) {
+		return nil
+	}
+
+	payload := struct {
+		Tags []string `json:"tags"`
+	}{
+		Tags: a.defaultTemplate(tx, desired).Tags,
+	}
+
+	if err := a.Patch(tx.Ctx, tx.Instance.GetObjectId(), payload); err != nil {
+		return fmt.Errorf("removing stale markers: %w", err)
+	}
+	return nil
+}
+
 // - we _CANNOT_ delete a disabled PermissionScope that has been granted to any pre-authorized app
 // - we _CAN_ however delete a disabled AppRole _without_ removing the associated approleassignments first
 func (a application) RemoveDisabledPermissions(tx transaction.Transaction, application msgraph.Application) error {
@@ -248,9 +337,9 @@ func (a application) RemoveDisabledPermissions(tx transaction.Transaction, appli
 	return nil
 }
 
-func (a application) EnableAcceptMappedClaims(tx transaction.Transaction, application *msgraph.Application) error {
-	if application.API != nil && application.API.AcceptMappedClaims != nil && *application.API.AcceptMappedClaims {
-		// skip if acceptMappedClaims is already enabled
+func (a application) SetAcceptMappedClaims(tx transaction.Transaction, application *msgraph.Application, enabled bool) error {
+	if application.API != nil && application.API.AcceptMappedClaims != nil && *application.API.AcceptMappedClaims == enabled {
+		// skip if acceptMappedClaims is already set as desired
 		return nil
 	}
 
@@ -261,7 +350,7 @@ func (a application) EnableAcceptMappedClaims(tx transaction.Transaction, applic
 	}{
 		struct {
 			AcceptMappedClaims bool `json:"acceptMappedClaims"`
-		}{true},
+		}{enabled},
 	}
 
 	return a.Patch(tx.Ctx, tx.Instance.GetObjectId(), payload)
@@ -283,23 +372,18 @@ func (a application) getAll(ctx context.Context, filters ...azure.Filter) ([]msg
 	return applications, nil
 }
 
-func (a application) defaultTemplate(tx transaction.Transaction) *msgraph.Application {
+// defaultTemplate returns the application with the default settings. The tags mark the application as managed by the
+// operator, along with the given markers for the settings applied by the operator.
+func (a application) defaultTemplate(tx transaction.Transaction, markers marker.Markers) *msgraph.Application {
 	return &msgraph.Application{
 		DisplayName:    new(tx.UniformResourceName),
 		SignInAudience: new("AzureADMyOrg"),
-		Tags: []string{
+		Tags: append([]string{
 			IaCAppTag,
 			IntegratedAppTag,
-		},
+		}, markers.Tags()...),
 		API: &msgraph.APIApplication{
-			RequestedAccessTokenVersion: new(2),
-		},
-		Web: &msgraph.WebApplication{
-			LogoutURL: new(tx.Instance.Spec.LogoutUrl),
-			ImplicitGrantSettings: &msgraph.ImplicitGrantSettings{
-				EnableIDTokenIssuance:     new(false),
-				EnableAccessTokenIssuance: new(false),
-			},
+			RequestedAccessTokenVersion: new(a.Config().APISettings.DefaultAccessTokenVersion()),
 		},
 	}
 }
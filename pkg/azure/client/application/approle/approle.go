@@ -1,15 +1,22 @@
 package approle
 
 import (
+	"slices"
+
 	"github.com/google/uuid"
 	msgraph "github.com/nais/msgraph.go/v1.0"
 
 	"github.com/nais/azureator/pkg/azure/permissions"
 )
 
+const (
+	memberTypeApplication = "Application"
+	memberTypeUser        = "User"
+)
+
 func New(id msgraph.UUID, name string) msgraph.AppRole {
 	return msgraph.AppRole{
-		AllowedMemberTypes: []string{"Application"},
+		AllowedMemberTypes: []string{memberTypeApplication},
 		Description:        new(name),
 		DisplayName:        new(name),
 		ID:                 &id,
@@ -31,6 +38,38 @@ func DefaultGroupRole() msgraph.AppRole {
 	return New(msgraph.UUID(permissions.DefaultGroupRoleId), permissions.DefaultGroupRoleValue)
 }
 
+// GroupRole returns the dedicated AppRole for Groups, used in place of DefaultGroupRole for applications that have
+// AppRoles assignable to users.
+func GroupRole() msgraph.AppRole {
+	role := New(msgraph.UUID(permissions.GroupRoleId), permissions.DefaultGroupRoleValue)
+	role.AllowedMemberTypes = []string{memberTypeUser}
+	return role
+}
+
+// GroupRoleFor returns the AppRole that Groups should be assigned to for the given application, i.e. the dedicated
+// group role if it is enabled for the application, or the default group role otherwise.
+func GroupRoleFor(application msgraph.Application) msgraph.AppRole {
+	for _, role := range application.AppRoles {
+		if isGroupRole(role) && role.IsEnabled != nil && *role.IsEnabled {
+			return GroupRole()
+		}
+	}
+	return DefaultGroupRole()
+}
+
+// RequiresGroupRole returns true if the dedicated AppRole for Groups should exist for the given AppRoles, i.e. if any
+// other AppRole is assignable to users. Once created, the dedicated role is kept to avoid migrating assignments back and
+// forth.
+func RequiresGroupRole(roles []msgraph.AppRole) bool {
+	return slices.ContainsFunc(roles, func(role msgraph.AppRole) bool {
+		return isGroupRole(role) || slices.Contains(role.AllowedMemberTypes, memberTypeUser)
+	})
+}
+
+func isGroupRole(role msgraph.AppRole) bool {
+	return role.ID != nil && *role.ID == msgraph.UUID(permissions.GroupRoleId)
+}
+
 func EnsureDefaultAppRoleIsEnabled(scopes []msgraph.AppRole) []msgraph.AppRole {
 	for i := range scopes {
 		if *scopes[i].Value == permissions.DefaultAppRoleValue && !*scopes[i].IsEnabled {
@@ -41,6 +80,9 @@ func EnsureDefaultAppRoleIsEnabled(scopes []msgraph.AppRole) []msgraph.AppRole {
 }
 
 func FromPermission(permission permissions.Permission) msgraph.AppRole {
+	if permission.ID == msgraph.UUID(permissions.GroupRoleId) {
+		return GroupRole()
+	}
 	return New(permission.ID, permission.Name)
 }
 
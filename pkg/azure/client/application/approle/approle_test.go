@@ -77,6 +77,44 @@ func TestDefaultGroupRole(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestGroupRole(t *testing.T) {
+	id := msgraph.UUID(permissions.GroupRoleId)
+	expected := msgraph.AppRole{
+		AllowedMemberTypes: []string{"User"},
+		Description:        ptr.String(permissions.DefaultGroupRoleValue),
+		DisplayName:        ptr.String(permissions.DefaultGroupRoleValue),
+		ID:                 &id,
+		IsEnabled:          new(true),
+		Value:              ptr.String(permissions.DefaultGroupRoleValue),
+	}
+	actual := approle.GroupRole()
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestGroupRoleFor(t *testing.T) {
+	app := msgraph.Application{AppRoles: []msgraph.AppRole{approle.DefaultRole()}}
+	assert.Equal(t, approle.DefaultGroupRole(), approle.GroupRoleFor(app))
+
+	disabledGroupRole := approle.GroupRole()
+	disabledGroupRole.IsEnabled = new(false)
+	app.AppRoles = append(app.AppRoles, disabledGroupRole)
+	assert.Equal(t, approle.DefaultGroupRole(), approle.GroupRoleFor(app))
+
+	app.AppRoles = []msgraph.AppRole{approle.DefaultRole(), approle.GroupRole()}
+	assert.Equal(t, approle.GroupRole(), approle.GroupRoleFor(app))
+}
+
+func TestRequiresGroupRole(t *testing.T) {
+	assert.False(t, approle.RequiresGroupRole([]msgraph.AppRole{approle.DefaultRole(), approle.NewGenerateId("role")}))
+
+	userRole := approle.NewGenerateId("user-role")
+	userRole.AllowedMemberTypes = []string{"Application", "User"}
+	assert.True(t, approle.RequiresGroupRole([]msgraph.AppRole{approle.DefaultRole(), userRole}))
+
+	assert.True(t, approle.RequiresGroupRole([]msgraph.AppRole{approle.DefaultRole(), approle.GroupRole()}))
+}
+
 func TestEnsureDefaultAppRoleIsEnabled(t *testing.T) {
 	defaultRole := approle.DefaultRole()
 	defaultRole.IsEnabled = new(false)
@@ -100,6 +138,9 @@ func TestFromPermission(t *testing.T) {
 	assert.Equal(t, "role", *role.DisplayName)
 	assert.Equal(t, "role", *role.Value)
 	assert.Equal(t, permission.ID, *role.ID)
+
+	groupPermission := permissions.FromAppRole(approle.GroupRole())
+	assert.Equal(t, approle.GroupRole(), approle.FromPermission(groupPermission))
 }
 
 func TestRemoveDisabled(t *testing.T) {
@@ -92,12 +92,29 @@ func (m Map) Unmodified(toCreate, toDisable Map) Map {
 			continue
 		}
 
-		unmodified[name] = New(id, name)
+		unmodified[name] = FromPermission(permissions.New(id, name, true))
 	}
 
 	return unmodified
 }
 
+// AllowUsers allows users to be assigned to the given roles, if present. Roles that are also desired as application
+// permissions remain assignable to applications.
+func (m Map) AllowUsers(userRoles []string, applicationPermissions permissions.Permissions) {
+	for _, name := range userRoles {
+		role, found := m[name]
+		if !found {
+			continue
+		}
+
+		role.AllowedMemberTypes = []string{memberTypeUser}
+		if _, found := applicationPermissions[name]; found {
+			role.AllowedMemberTypes = []string{memberTypeApplication, memberTypeUser}
+		}
+		m[name] = role
+	}
+}
+
 func (m Map) ToPermissionList() permissions.PermissionList {
 	result := make(permissions.PermissionList, 0)
 
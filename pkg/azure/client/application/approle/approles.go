@@ -1,14 +1,16 @@
 package approle
 
 import (
+	"maps"
+
 	msgraph "github.com/nais/msgraph.go/v1.0"
 
 	"github.com/nais/azureator/pkg/azure/permissions"
 )
 
 type AppRoles interface {
-	DescribeCreate(desired permissions.Permissions) Result
-	DescribeUpdate(desired permissions.Permissions, existing []msgraph.AppRole) Result
+	DescribeCreate(desired permissions.Permissions, userRoles []string) Result
+	DescribeUpdate(desired permissions.Permissions, userRoles []string, existing []msgraph.AppRole) Result
 }
 
 type appRoles struct{}
@@ -18,27 +20,50 @@ func NewAppRoles() AppRoles {
 }
 
 // DescribeCreate returns a slice describing the desired msgraph.AppRole to be created without actually creating them.
-func (a appRoles) DescribeCreate(desired permissions.Permissions) Result {
+// The given user roles are assignable to users, along with the dedicated group role.
+func (a appRoles) DescribeCreate(desired permissions.Permissions, userRoles []string) Result {
 	existingSet := make(Map)
-	return NewCreateResult(existingSet.ToCreate(desired))
+
+	toCreate := existingSet.ToCreate(withUserRoles(desired, userRoles, len(userRoles) > 0))
+	toCreate.AllowUsers(userRoles, desired)
+	return NewCreateResult(toCreate)
 }
 
 // DescribeUpdate returns a slice describing the desired state of both new (if any) and existing msgraph.AppRole, i.e:
 // 1) add any non-existing, desired roles.
 // 2) disable existing, non-desired roles.
+// 3) allow users to be assigned to the given user roles.
+// 4) add or keep the dedicated group role if there are user roles, or if any existing role is assignable to users.
 // It does not perform any modifying operations on the remote state in Azure AD.
-func (a appRoles) DescribeUpdate(desired permissions.Permissions, existing []msgraph.AppRole) Result {
+func (a appRoles) DescribeUpdate(desired permissions.Permissions, userRoles []string, existing []msgraph.AppRole) Result {
 	result := make([]msgraph.AppRole, 0)
 
 	existingSet := ToMap(existing)
+	desiredWithUserRoles := withUserRoles(desired, userRoles, len(userRoles) > 0 || RequiresGroupRole(existing))
 
-	toCreate := existingSet.ToCreate(desired)
-	toDisable := existingSet.ToDisable(desired)
+	toCreate := existingSet.ToCreate(desiredWithUserRoles)
+	toDisable := existingSet.ToDisable(desiredWithUserRoles)
 	unmodified := existingSet.Unmodified(toCreate, toDisable)
 
+	toCreate.AllowUsers(userRoles, desired)
+	unmodified.AllowUsers(userRoles, desired)
+
 	result = append(result, unmodified.ToSlice()...)
 	result = append(result, toCreate.ToSlice()...)
 	result = append(result, toDisable.ToSlice()...)
 	result = EnsureDefaultAppRoleIsEnabled(result)
 	return NewUpdateResult(toCreate, toDisable, unmodified, result)
 }
+
+// withUserRoles returns a copy of the desired permissions that includes the given user roles, and optionally the
+// dedicated group role. The IDs of existing roles are preserved when describing the roles, so new IDs are generated.
+func withUserRoles(desired permissions.Permissions, userRoles []string, groupRole bool) permissions.Permissions {
+	result := maps.Clone(desired)
+	for _, role := range userRoles {
+		result.Add(permissions.NewGenerateIdEnabled(role))
+	}
+	if groupRole {
+		result.Add(permissions.FromAppRole(GroupRole()))
+	}
+	return result
+}
@@ -15,7 +15,7 @@ import (
 func TestAppRoles_DescribeCreate(t *testing.T) {
 	t.Run("desired is empty should add default role", func(t *testing.T) {
 		desired := make(permissions.Permissions)
-		roles := approle.NewAppRoles().DescribeCreate(desired).GetResult()
+		roles := approle.NewAppRoles().DescribeCreate(desired, nil).GetResult()
 
 		assert.Len(t, roles, 1)
 		assertContainsDefaultRole(t, roles)
@@ -27,7 +27,7 @@ func TestAppRoles_DescribeCreate(t *testing.T) {
 		defaultRole.IsEnabled = new(false)
 		desired.Add(permissions.FromAppRole(defaultRole))
 
-		roles := approle.NewAppRoles().DescribeCreate(desired).GetResult()
+		roles := approle.NewAppRoles().DescribeCreate(desired, nil).GetResult()
 
 		assert.Len(t, roles, 1)
 		assertContainsDefaultRole(t, roles)
@@ -41,7 +41,7 @@ func TestAppRoles_DescribeCreate(t *testing.T) {
 		desired.Add(permissions.FromAppRole(role1))
 		desired.Add(permissions.FromAppRole(role2))
 
-		roles := approle.NewAppRoles().DescribeCreate(desired).GetResult()
+		roles := approle.NewAppRoles().DescribeCreate(desired, nil).GetResult()
 
 		assertContainsRole(t, role1, roles)
 		assertContainsRole(t, role2, roles)
@@ -50,13 +50,33 @@ func TestAppRoles_DescribeCreate(t *testing.T) {
 		// assert that length of AppRoles equals length of the resulting union set of (desired + Default AppRole)
 		assert.Len(t, roles, len(desired)+1)
 	})
+
+	t.Run("with user roles should add user-assignable roles and group role", func(t *testing.T) {
+		role1 := approle.NewGenerateId("role-1")
+
+		desired := make(permissions.Permissions)
+		desired.Add(permissions.FromAppRole(role1))
+
+		roles := approle.NewAppRoles().DescribeCreate(desired, []string{"role-1", "user-role"}).GetResult()
+
+		memberTypes := make(map[string][]string)
+		for _, role := range roles {
+			memberTypes[*role.Value] = role.AllowedMemberTypes
+		}
+		assert.Equal(t, map[string][]string{
+			permissions.DefaultAppRoleValue:   {"Application"},
+			permissions.DefaultGroupRoleValue: {"User"},
+			"role-1":                          {"Application", "User"},
+			"user-role":                       {"User"},
+		}, memberTypes)
+	})
 }
 
 func TestAppRoles_DescribeUpdate(t *testing.T) {
 	t.Run("default role not found in desired nor existing should add default role", func(t *testing.T) {
 		desired := make(permissions.Permissions)
 		existing := make([]msgraph.AppRole, 0)
-		roles := approle.NewAppRoles().DescribeUpdate(desired, existing).GetResult()
+		roles := approle.NewAppRoles().DescribeUpdate(desired, nil, existing).GetResult()
 
 		assert.Len(t, roles, 1)
 		assertContainsDefaultRole(t, roles)
@@ -67,7 +87,7 @@ func TestAppRoles_DescribeUpdate(t *testing.T) {
 		existing := []msgraph.AppRole{
 			approle.DefaultRole(),
 		}
-		roles := approle.NewAppRoles().DescribeUpdate(desired, existing).GetResult()
+		roles := approle.NewAppRoles().DescribeUpdate(desired, nil, existing).GetResult()
 
 		assert.Len(t, roles, 1)
 		assertContainsDefaultRole(t, roles)
@@ -84,7 +104,7 @@ func TestAppRoles_DescribeUpdate(t *testing.T) {
 				Value:              ptr.String(permissions.DefaultAppRoleValue),
 			},
 		}
-		roles = approle.NewAppRoles().DescribeUpdate(desired, existing).GetResult()
+		roles = approle.NewAppRoles().DescribeUpdate(desired, nil, existing).GetResult()
 
 		assert.Len(t, roles, 1)
 		assertContainsDefaultRoleWithLambda(t, roles, func(t assert.TestingT, expected, actual msgraph.AppRole) {
@@ -103,7 +123,7 @@ func TestAppRoles_DescribeUpdate(t *testing.T) {
 		desired.Add(permissions.NewGenerateIdDisabled(permissions.DefaultAppRoleValue))
 
 		existing := make([]msgraph.AppRole, 0)
-		roles := approle.NewAppRoles().DescribeUpdate(desired, existing).GetResult()
+		roles := approle.NewAppRoles().DescribeUpdate(desired, nil, existing).GetResult()
 
 		assert.Len(t, roles, 1)
 		assertContainsDefaultRole(t, roles)
@@ -125,7 +145,7 @@ func TestAppRoles_DescribeUpdate(t *testing.T) {
 			role3,
 		}
 
-		roles := approle.NewAppRoles().DescribeUpdate(desired, existing).GetResult()
+		roles := approle.NewAppRoles().DescribeUpdate(desired, nil, existing).GetResult()
 
 		// assert that role "role-1" still exists and is unmodified
 		assertContainsRole(t, role1, roles)
@@ -148,6 +168,55 @@ func TestAppRoles_DescribeUpdate(t *testing.T) {
 		// assert that length of AppRoles equals length of the resulting union set of (existing + desired)
 		assert.Len(t, roles, len(desired)+1)
 	})
+
+	t.Run("existing role assignable to users should add group role", func(t *testing.T) {
+		userRole := approle.NewGenerateId("user-role")
+		userRole.AllowedMemberTypes = []string{"User"}
+
+		desired := make(permissions.Permissions)
+		desired.Add(permissions.FromAppRole(userRole))
+
+		existing := []msgraph.AppRole{approle.DefaultRole(), userRole}
+		roles := approle.NewAppRoles().DescribeUpdate(desired, nil, existing).GetResult()
+
+		assertContainsDefaultRole(t, roles)
+		assert.Contains(t, roles, approle.GroupRole())
+		assert.Len(t, roles, 3)
+
+		// the desired permissions should not be modified
+		assert.Len(t, desired, 1)
+	})
+
+	t.Run("existing group role should be kept", func(t *testing.T) {
+		groupRole := approle.GroupRole()
+		groupRole.IsEnabled = new(false)
+
+		desired := make(permissions.Permissions)
+		existing := []msgraph.AppRole{approle.DefaultRole(), groupRole}
+		roles := approle.NewAppRoles().DescribeUpdate(desired, nil, existing).GetResult()
+
+		assertContainsDefaultRole(t, roles)
+		assert.Contains(t, roles, approle.GroupRole())
+		assert.Len(t, roles, 2)
+	})
+
+	t.Run("user roles should be assignable to users and preserve existing IDs", func(t *testing.T) {
+		existingRole := approle.NewGenerateId("user-role")
+		existing := []msgraph.AppRole{approle.DefaultRole(), existingRole}
+
+		desired := make(permissions.Permissions)
+		roles := approle.NewAppRoles().DescribeUpdate(desired, []string{"user-role"}, existing).GetResult()
+
+		assert.Len(t, roles, 3)
+		assert.Contains(t, roles, approle.GroupRole())
+		for _, role := range roles {
+			if *role.Value == "user-role" {
+				assert.Equal(t, *existingRole.ID, *role.ID)
+				assert.Equal(t, []string{"User"}, role.AllowedMemberTypes)
+				assert.True(t, *role.IsEnabled)
+			}
+		}
+	})
 }
 
 func defaultRoleAsserter() func(t assert.TestingT, expected, actual msgraph.AppRole) {
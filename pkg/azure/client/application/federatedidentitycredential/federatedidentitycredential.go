@@ -0,0 +1,162 @@
+package federatedidentitycredential
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	msgraph "github.com/nais/msgraph.go/v1.0"
+
+	"github.com/nais/azureator/pkg/azure"
+	"github.com/nais/azureator/pkg/azure/credentials"
+	"github.com/nais/azureator/pkg/transaction"
+)
+
+const description = "Workload identity federation for Kubernetes service account, managed by azurerator"
+
+type FederatedIdentityCredentials interface {
+	Process(tx transaction.Transaction) error
+	Purge(tx transaction.Transaction) error
+	Validate(tx transaction.Transaction) (bool, error)
+}
+
+type federatedIdentityCredentials struct {
+	azure.RuntimeClient
+}
+
+func NewFederatedIdentityCredentials(client azure.RuntimeClient) FederatedIdentityCredentials {
+	return federatedIdentityCredentials{RuntimeClient: client}
+}
+
+// Process ensures that the application has exactly one managed federated identity credential matching the desired
+// workload identity.
+func (f federatedIdentityCredentials) Process(tx transaction.Transaction) error {
+	existing, err := f.list(tx)
+	if err != nil {
+		return err
+	}
+
+	desired := Describe(tx.ClusterName, tx.Options.Process.Secret.WorkloadIdentity)
+	found := false
+
+	for _, cred := range existing {
+		if !IsManaged(cred) {
+			continue
+		}
+
+		if !found && Matches(cred, desired) {
+			found = true
+			continue
+		}
+
+		if err := f.remove(tx, cred); err != nil {
+			return err
+		}
+	}
+
+	if found {
+		return nil
+	}
+
+	return f.add(tx, desired)
+}
+
+// Purge removes all managed federated identity credentials for the application.
+func (f federatedIdentityCredentials) Purge(tx transaction.Transaction) error {
+	existing, err := f.list(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, cred := range existing {
+		if !IsManaged(cred) {
+			continue
+		}
+
+		if err := f.remove(tx, cred); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate returns true if the application has a managed federated identity credential matching the desired workload identity.
+func (f federatedIdentityCredentials) Validate(tx transaction.Transaction) (bool, error) {
+	existing, err := f.list(tx)
+	if err != nil {
+		return false, err
+	}
+
+	desired := Describe(tx.ClusterName, tx.Options.Process.Secret.WorkloadIdentity)
+	return slices.ContainsFunc(existing, func(cred msgraph.FederatedIdentityCredential) bool {
+		return IsManaged(cred) && Matches(cred, desired)
+	}), nil
+}
+
+func (f federatedIdentityCredentials) list(tx transaction.Transaction) ([]msgraph.FederatedIdentityCredential, error) {
+	objectId := tx.Instance.GetObjectId()
+
+	creds, err := f.GraphClient().Applications().ID(objectId).FederatedIdentityCredentials().Request().GetN(tx.Ctx, f.MaxNumberOfPagesToFetch())
+	if err != nil {
+		return nil, fmt.Errorf("listing federated identity credentials for application: %w", err)
+	}
+	return creds, nil
+}
+
+func (f federatedIdentityCredentials) add(tx transaction.Transaction, cred msgraph.FederatedIdentityCredential) error {
+	objectId := tx.Instance.GetObjectId()
+
+	_, err := f.GraphClient().Applications().ID(objectId).FederatedIdentityCredentials().Request().Add(tx.Ctx, &cred)
+	if err != nil {
+		return fmt.Errorf("adding federated identity credential for application: %w", err)
+	}
+
+	tx.Logger.Infof("added federated identity credential for subject %q", *cred.Subject)
+	return nil
+}
+
+func (f federatedIdentityCredentials) remove(tx transaction.Transaction, cred msgraph.FederatedIdentityCredential) error {
+	objectId := tx.Instance.GetObjectId()
+
+	err := f.GraphClient().Applications().ID(objectId).FederatedIdentityCredentials().ID(*cred.ID).Request().Delete(tx.Ctx)
+	if err != nil {
+		return fmt.Errorf("removing federated identity credential %q for application: %w", *cred.Name, err)
+	}
+
+	tx.Logger.Infof("removed federated identity credential %q", *cred.Name)
+	return nil
+}
+
+// Name returns the name of the federated identity credential managed by the operator in the given cluster.
+func Name(clusterName string) string {
+	return fmt.Sprintf("%s-%s", azure.AzureratorPrefix, clusterName)
+}
+
+// Describe returns the desired federated identity credential for the given workload identity.
+func Describe(clusterName string, workloadIdentity credentials.WorkloadIdentity) msgraph.FederatedIdentityCredential {
+	return msgraph.FederatedIdentityCredential{
+		Audiences:   []string{workloadIdentity.Audience},
+		Description: new(description),
+		Issuer:      new(workloadIdentity.Issuer),
+		Name:        new(Name(clusterName)),
+		Subject:     new(workloadIdentity.Subject),
+	}
+}
+
+// IsManaged returns true if the federated identity credential was registered by the operator.
+func IsManaged(cred msgraph.FederatedIdentityCredential) bool {
+	return cred.Name != nil && strings.HasPrefix(*cred.Name, azure.AzureratorPrefix+"-")
+}
+
+// Matches returns true if the federated identity credential trusts the same tokens as the desired credential.
+func Matches(actual, desired msgraph.FederatedIdentityCredential) bool {
+	equal := func(a, b *string) bool {
+		return a != nil && b != nil && *a == *b
+	}
+
+	return equal(actual.Name, desired.Name) &&
+		equal(actual.Issuer, desired.Issuer) &&
+		equal(actual.Subject, desired.Subject) &&
+		slices.Equal(actual.Audiences, desired.Audiences)
+}
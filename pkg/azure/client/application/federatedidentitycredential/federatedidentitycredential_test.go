@@ -0,0 +1,56 @@
+package federatedidentitycredential_test
+
+import (
+	"testing"
+
+	msgraph "github.com/nais/msgraph.go/v1.0"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nais/azureator/pkg/azure/client/application/federatedidentitycredential"
+	"github.com/nais/azureator/pkg/azure/credentials"
+)
+
+func TestDescribe(t *testing.T) {
+	workloadIdentity := credentials.WorkloadIdentity{
+		Audience: "api://AzureADTokenExchange",
+		Issuer:   "https://issuer.example.com",
+		Subject:  credentials.ServiceAccountSubject("test-namespace", "test-app"),
+	}
+
+	actual := federatedidentitycredential.Describe("test-cluster", workloadIdentity)
+
+	assert.Equal(t, "azurerator-test-cluster", *actual.Name)
+	assert.Equal(t, "https://issuer.example.com", *actual.Issuer)
+	assert.Equal(t, "system:serviceaccount:test-namespace:test-app", *actual.Subject)
+	assert.Equal(t, []string{"api://AzureADTokenExchange"}, actual.Audiences)
+	assert.True(t, federatedidentitycredential.IsManaged(actual))
+}
+
+func TestMatches(t *testing.T) {
+	desired := federatedidentitycredential.Describe("test-cluster", credentials.WorkloadIdentity{
+		Audience: "api://AzureADTokenExchange",
+		Issuer:   "https://issuer.example.com",
+		Subject:  "system:serviceaccount:test-namespace:test-app",
+	})
+
+	withSubject := desired
+	withSubject.Subject = new("system:serviceaccount:test-namespace:other")
+
+	withIssuer := desired
+	withIssuer.Issuer = new("https://other.example.com")
+
+	withAudience := desired
+	withAudience.Audiences = []string{"api://other"}
+
+	assert.True(t, federatedidentitycredential.Matches(desired, desired))
+	assert.False(t, federatedidentitycredential.Matches(withSubject, desired))
+	assert.False(t, federatedidentitycredential.Matches(withIssuer, desired))
+	assert.False(t, federatedidentitycredential.Matches(withAudience, desired))
+	assert.False(t, federatedidentitycredential.Matches(msgraph.FederatedIdentityCredential{}, desired))
+}
+
+func TestIsManaged(t *testing.T) {
+	assert.True(t, federatedidentitycredential.IsManaged(msgraph.FederatedIdentityCredential{Name: new("azurerator-some-cluster")}))
+	assert.False(t, federatedidentitycredential.IsManaged(msgraph.FederatedIdentityCredential{Name: new("github-actions")}))
+	assert.False(t, federatedidentitycredential.IsManaged(msgraph.FederatedIdentityCredential{}))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"slices"
+	"strings"
 
 	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 
@@ -40,18 +41,34 @@ func (i identifierUri) Set(tx transaction.Transaction, uris azure.IdentifierUris
 	return nil
 }
 
-func DescribeCreate(instance *v1.AzureAdApplication, clusterName string) azure.IdentifierUris {
-	return defaultUris(instance, clusterName)
+// DescribeCreate returns the identifier URIs for a new application, i.e. the default URIs and the given additional URIs.
+func DescribeCreate(instance *v1.AzureAdApplication, clusterName string, desired azure.IdentifierUris) azure.IdentifierUris {
+	return merge(defaultUris(instance, clusterName), desired)
 }
 
-func DescribeUpdate(instance *v1.AzureAdApplication, existing azure.IdentifierUris, clusterName string) azure.IdentifierUris {
+// DescribeUpdate returns the identifier URIs for an existing application. The default URIs and the given additional
+// URIs are added, while URIs previously added by the operator that are no longer desired are removed, i.e. the default
+// URIs for other cluster names and previously requested additional URIs. Any other existing URIs are preserved.
+func DescribeUpdate(instance *v1.AzureAdApplication, existing azure.IdentifierUris, clusterName string, desired, previous azure.IdentifierUris) azure.IdentifierUris {
+	defaults := defaultUris(instance, clusterName)
+
+	result := slices.DeleteFunc(slices.Clone(existing), func(uri string) bool {
+		if slices.Contains(defaults, uri) || slices.Contains(desired, uri) {
+			return false
+		}
+		return slices.Contains(previous, uri) || isDefaultForOtherCluster(instance, uri)
+	})
+
+	return merge(result, slices.Concat(defaults, desired))
+}
+
+// merge appends the URIs that are not already present.
+func merge(existing, uris azure.IdentifierUris) azure.IdentifierUris {
 	result := make(azure.IdentifierUris, len(existing))
 	copy(result, existing)
 
-	for _, uri := range defaultUris(instance, clusterName) {
-		seen := slices.Contains(existing, uri)
-
-		if !seen {
+	for _, uri := range uris {
+		if !slices.Contains(result, uri) {
 			result = append(result, uri)
 		}
 	}
@@ -59,6 +76,18 @@ func DescribeUpdate(instance *v1.AzureAdApplication, existing azure.IdentifierUr
 	return result
 }
 
+// isDefaultForOtherCluster returns true if the URI is the human-readable default URI for the application in any
+// cluster, e.g. from before the cluster was renamed.
+func isDefaultForOtherCluster(instance *v1.AzureAdApplication, uri string) bool {
+	suffix := fmt.Sprintf(".%s.%s", instance.GetNamespace(), instance.GetName())
+	clusterName, found := strings.CutPrefix(uri, "api://")
+	if !found {
+		return false
+	}
+	clusterName, found = strings.CutSuffix(clusterName, suffix)
+	return found && len(clusterName) > 0 && !strings.ContainsAny(clusterName, "./")
+}
+
 func uriClientId(id azure.ClientId) string {
 	return fmt.Sprintf("api://%s", id)
 }
@@ -13,13 +13,24 @@ import (
 func TestDescribeCreate(t *testing.T) {
 	spec := spec()
 	clusterName := "test-cluster"
-	actual := identifieruri.DescribeCreate(spec, clusterName)
+	actual := identifieruri.DescribeCreate(spec, clusterName, nil)
 	expected := azure.IdentifierUris{
 		"api://test-cluster.test-namespace.test",
 		"api://some-uuid",
 	}
 
 	assert.ElementsMatch(t, expected, actual)
+
+	t.Run("with additional uris", func(t *testing.T) {
+		actual := identifieruri.DescribeCreate(spec, clusterName, azure.IdentifierUris{"https://test.example.com"})
+		expected := azure.IdentifierUris{
+			"api://test-cluster.test-namespace.test",
+			"api://some-uuid",
+			"https://test.example.com",
+		}
+
+		assert.ElementsMatch(t, expected, actual)
+	})
 }
 
 func TestDescribeUpdate(t *testing.T) {
@@ -28,6 +39,8 @@ func TestDescribeUpdate(t *testing.T) {
 	for _, test := range []struct {
 		name     string
 		existing azure.IdentifierUris
+		desired  azure.IdentifierUris
+		previous azure.IdentifierUris
 		expected azure.IdentifierUris
 	}{
 		{
@@ -85,10 +98,89 @@ func TestDescribeUpdate(t *testing.T) {
 				"api://some-uuid",
 			},
 		},
+		{
+			name: "default uri for other cluster is removed",
+			existing: azure.IdentifierUris{
+				"api://old-cluster.test-namespace.test",
+				"api://test-cluster.test-namespace.test",
+				"api://some-uuid",
+			},
+			expected: azure.IdentifierUris{
+				"api://test-cluster.test-namespace.test",
+				"api://some-uuid",
+			},
+		},
+		{
+			name: "uris for other applications are preserved",
+			existing: azure.IdentifierUris{
+				"api://test-cluster.other-namespace.test",
+				"api://test-cluster.test-namespace.other-test",
+				"api://some.domain.test-namespace.test",
+			},
+			expected: azure.IdentifierUris{
+				"api://test-cluster.other-namespace.test",
+				"api://test-cluster.test-namespace.other-test",
+				"api://some.domain.test-namespace.test",
+				"api://test-cluster.test-namespace.test",
+				"api://some-uuid",
+			},
+		},
+		{
+			name: "additional uris are added",
+			existing: azure.IdentifierUris{
+				"api://test-cluster.test-namespace.test",
+				"api://some-uuid",
+			},
+			desired: azure.IdentifierUris{
+				"https://test.example.com",
+			},
+			expected: azure.IdentifierUris{
+				"api://test-cluster.test-namespace.test",
+				"api://some-uuid",
+				"https://test.example.com",
+			},
+		},
+		{
+			name: "additional uris that are no longer desired are removed",
+			existing: azure.IdentifierUris{
+				"api://test-cluster.test-namespace.test",
+				"api://some-uuid",
+				"api://some-other-uri",
+				"https://test.example.com",
+				"https://other.example.com",
+			},
+			desired: azure.IdentifierUris{
+				"https://other.example.com",
+			},
+			previous: azure.IdentifierUris{
+				"https://other.example.com",
+				"https://test.example.com",
+			},
+			expected: azure.IdentifierUris{
+				"api://test-cluster.test-namespace.test",
+				"api://some-uuid",
+				"api://some-other-uri",
+				"https://other.example.com",
+			},
+		},
+		{
+			name: "default uris are never removed",
+			existing: azure.IdentifierUris{
+				"api://test-cluster.test-namespace.test",
+				"api://some-uuid",
+			},
+			previous: azure.IdentifierUris{
+				"api://some-uuid",
+			},
+			expected: azure.IdentifierUris{
+				"api://test-cluster.test-namespace.test",
+				"api://some-uuid",
+			},
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			spec := spec()
-			actual := identifieruri.DescribeUpdate(spec, test.existing, clusterName)
+			actual := identifieruri.DescribeUpdate(spec, test.existing, clusterName, test.desired, test.previous)
 			assert.ElementsMatch(t, test.expected, actual)
 		})
 	}
@@ -0,0 +1,132 @@
+// Package marker records the settings that the operator has applied to an application as tags on the application in
+// Azure AD, e.g. 'azurerator_identifier_uri:https:%2F%2Fapp.example.com'. When a setting is no longer requested, it is
+// only removed from the application if it has a marker, so that settings added by other means are preserved. Unlike
+// annotations on the AzureAdApplication, the markers cannot be edited by the users of the resource.
+package marker
+
+import (
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/nais/azureator/pkg/transaction/options"
+)
+
+const prefix = "azurerator_"
+
+// Kind is a kind of setting that is recorded by markers.
+type Kind string
+
+const (
+	IdentifierUri          Kind = "identifier_uri"
+	KnownClientApplication Kind = "known_client_application"
+	OptionalClaim          Kind = "optional_claim"
+	Owner                  Kind = "owner"
+	PlatformSetting        Kind = "platform_setting"
+)
+
+// Platform settings are marked per setting, as values of the PlatformSetting kind.
+const (
+	HomePageUrl              = "home_page_url"
+	ImplicitGrant            = "implicit_grant"
+	PublicClientRedirectUris = "public_client_redirect_uris"
+	FallbackPublicClient     = "fallback_public_client"
+)
+
+// Markers maps kinds of settings to the values that are applied by the operator.
+type Markers map[Kind][]string
+
+// FromTags returns the markers among the given tags of an application. Any other tags are ignored.
+func FromTags(tags []string) Markers {
+	markers := make(Markers)
+	for _, tag := range tags {
+		rest, found := strings.CutPrefix(tag, prefix)
+		if !found {
+			continue
+		}
+		kind, escaped, found := strings.Cut(rest, ":")
+		if !found {
+			continue
+		}
+		value, err := url.PathUnescape(escaped)
+		if err != nil {
+			continue
+		}
+		markers.add(Kind(kind), value)
+	}
+	return markers
+}
+
+// Desired returns the markers for the settings requested for the application.
+func Desired(opts options.AzureOptions) Markers {
+	markers := make(Markers)
+	markers.add(IdentifierUri, opts.IdentifierUris...)
+	markers.add(KnownClientApplication, opts.APISettings.KnownClientApplications...)
+	for _, claim := range opts.OptionalClaims {
+		markers.add(OptionalClaim, claim.String())
+	}
+	markers.add(Owner, opts.Owners...)
+
+	settings := opts.PlatformSettings
+	if len(settings.HomePageUrl) > 0 {
+		markers.add(PlatformSetting, HomePageUrl)
+	}
+	if len(settings.ImplicitGrant.String()) > 0 {
+		markers.add(PlatformSetting, ImplicitGrant)
+	}
+	if len(settings.PublicClientRedirectUris) > 0 {
+		markers.add(PlatformSetting, PublicClientRedirectUris)
+	}
+	if settings.FallbackPublicClient {
+		markers.add(PlatformSetting, FallbackPublicClient)
+	}
+	return markers
+}
+
+// Values returns the values recorded for the given kind of setting.
+func (m Markers) Values(kind Kind) []string {
+	return slices.Clone(m[kind])
+}
+
+// Has returns true if the given value is recorded for the given kind of setting.
+func (m Markers) Has(kind Kind, value string) bool {
+	return slices.Contains(m[kind], value)
+}
+
+// Union returns the markers that are in either m or other.
+func (m Markers) Union(other Markers) Markers {
+	result := make(Markers)
+	for kind, values := range m {
+		result.add(kind, values...)
+	}
+	for kind, values := range other {
+		result.add(kind, values...)
+	}
+	return result
+}
+
+// Equal returns true if m and other record the same values.
+func (m Markers) Equal(other Markers) bool {
+	return slices.Equal(m.Tags(), other.Tags())
+}
+
+// Tags returns the markers as sorted tags.
+func (m Markers) Tags() []string {
+	tags := make([]string, 0)
+	for _, kind := range slices.Sorted(maps.Keys(m)) {
+		for _, value := range m[kind] {
+			tags = append(tags, prefix+string(kind)+":"+url.PathEscape(value))
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+func (m Markers) add(kind Kind, values ...string) {
+	for _, value := range values {
+		if !slices.Contains(m[kind], value) {
+			m[kind] = append(m[kind], value)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package marker_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/azureator/pkg/azure/client/application/marker"
+	"github.com/nais/azureator/pkg/azure/client/application/optionalclaims"
+	"github.com/nais/azureator/pkg/transaction/options"
+)
+
+func TestMarkers(t *testing.T) {
+	claims, err := optionalclaims.ParseAll([]string{"id:email", "access:groups+emit_as_roles"})
+	require.NoError(t, err)
+
+	desired := marker.Desired(options.AzureOptions{
+		APISettings:    options.APISettings{KnownClientApplications: []string{"6d4d7b60-9c7a-4f1b-a1e4-5b1c2e0b8a11"}},
+		IdentifierUris: options.IdentifierUris{"https://test.example.com"},
+		OptionalClaims: claims,
+		Owners:         options.Owners{"some.user@example.com", "Some Group"},
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"azurerator_identifier_uri:https:%2F%2Ftest.example.com",
+			"azurerator_known_client_application:6d4d7b60-9c7a-4f1b-a1e4-5b1c2e0b8a11",
+			"azurerator_optional_claim:access:groups+emit_as_roles",
+			"azurerator_optional_claim:id:email",
+			"azurerator_owner:Some%20Group",
+			"azurerator_owner:some.user@example.com",
+		}, desired.Tags())
+	})
+
+	t.Run("from tags", func(t *testing.T) {
+		tags := append([]string{"azurerator_appreg", "WindowsAzureActiveDirectoryIntegratedApp", "azurerator_owner:%zz"}, desired.Tags()...)
+
+		markers := marker.FromTags(tags)
+		assert.True(t, markers.Equal(desired))
+		assert.Equal(t, []string{"Some Group", "some.user@example.com"}, markers.Values(marker.Owner))
+		assert.True(t, markers.Has(marker.IdentifierUri, "https://test.example.com"))
+		assert.False(t, markers.Has(marker.IdentifierUri, "https://other.example.com"))
+	})
+
+	t.Run("platform settings", func(t *testing.T) {
+		markers := marker.Desired(options.AzureOptions{
+			PlatformSettings: options.PlatformSettings{
+				HomePageUrl:   "https://test.host",
+				ImplicitGrant: options.ImplicitGrant{IDToken: true},
+			},
+		})
+
+		assert.Equal(t, []string{
+			"azurerator_platform_setting:home_page_url",
+			"azurerator_platform_setting:implicit_grant",
+		}, markers.Tags())
+		assert.False(t, markers.Has(marker.PlatformSetting, marker.FallbackPublicClient))
+	})
+
+	t.Run("no markers", func(t *testing.T) {
+		markers := marker.FromTags([]string{"azurerator_appreg", "WindowsAzureActiveDirectoryIntegratedApp"})
+		assert.Empty(t, markers.Tags())
+		assert.True(t, markers.Equal(marker.Desired(options.AzureOptions{})))
+	})
+
+	t.Run("union", func(t *testing.T) {
+		previous := marker.FromTags([]string{"azurerator_owner:other.user@example.com", "azurerator_owner:some.user@example.com"})
+
+		union := previous.Union(desired)
+		assert.ElementsMatch(t, []string{"other.user@example.com", "some.user@example.com", "Some Group"}, union.Values(marker.Owner))
+		assert.Equal(t, desired.Values(marker.IdentifierUri), union.Values(marker.IdentifierUri))
+		assert.False(t, union.Equal(desired))
+	})
+}
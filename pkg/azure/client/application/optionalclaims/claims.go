@@ -0,0 +1,177 @@
+package optionalclaims
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	msgraph "github.com/nais/msgraph.go/v1.0"
+)
+
+// TokenType is the type of token that an optional claim is emitted in.
+type TokenType string
+
+const (
+	TokenTypeAccess TokenType = "access"
+	TokenTypeID     TokenType = "id"
+	TokenTypeSAML   TokenType = "saml"
+)
+
+// supportedClaims maps the supported optional claims to the token types that they may be emitted in.
+// See https://learn.microsoft.com/en-us/entra/identity-platform/optional-claims-reference.
+var supportedClaims = map[string][]TokenType{
+	"acct":                     {TokenTypeID, TokenTypeAccess, TokenTypeSAML},
+	"auth_time":                {TokenTypeID},
+	"ctry":                     {TokenTypeID, TokenTypeAccess},
+	"email":                    {TokenTypeID, TokenTypeAccess, TokenTypeSAML},
+	"family_name":              {TokenTypeID, TokenTypeAccess, TokenTypeSAML},
+	"fwd":                      {TokenTypeID, TokenTypeAccess},
+	"given_name":               {TokenTypeID, TokenTypeAccess, TokenTypeSAML},
+	"groups":                   {TokenTypeID, TokenTypeAccess, TokenTypeSAML},
+	"idtyp":                    {TokenTypeAccess},
+	"in_corp":                  {TokenTypeID, TokenTypeAccess},
+	"ipaddr":                   {TokenTypeID, TokenTypeAccess},
+	"login_hint":               {TokenTypeID},
+	"onprem_sid":               {TokenTypeID, TokenTypeAccess, TokenTypeSAML},
+	"preferred_username":       {TokenTypeID, TokenTypeAccess},
+	"sid":                      {TokenTypeID, TokenTypeAccess, TokenTypeSAML},
+	"tenant_ctry":              {TokenTypeID, TokenTypeAccess},
+	"tenant_region_scope":      {TokenTypeID, TokenTypeAccess},
+	"upn":                      {TokenTypeID, TokenTypeAccess, TokenTypeSAML},
+	"verified_primary_email":   {TokenTypeID},
+	"verified_secondary_email": {TokenTypeID},
+	"xms_pdl":                  {TokenTypeID, TokenTypeAccess},
+	"xms_tpl":                  {TokenTypeID, TokenTypeAccess},
+}
+
+// supportedAdditionalProperties maps optional claims to the additional properties that they support.
+var supportedAdditionalProperties = map[string][]string{
+	"groups": {
+		"cloud_displayname",
+		"dns_domain_and_sam_account_name",
+		"emit_as_roles",
+		"netbios_domain_and_sam_account_name",
+		"sam_account_name",
+	},
+	"upn": {
+		"include_externally_authenticated_upn",
+		"include_externally_authenticated_upn_without_hash",
+	},
+}
+
+// Claim is an optional claim requested for a type of token, e.g. 'id:groups+sam_account_name'.
+type Claim struct {
+	Token                TokenType
+	Name                 string
+	AdditionalProperties []string
+}
+
+// Parse parses an optional claim in the form '<token>:<name>[+<additional property>...]', where token is one of 'id',
+// 'access' or 'saml'. The claim and its additional properties must be supported for the type of token.
+func Parse(value string) (Claim, error) {
+	token, rest, found := strings.Cut(strings.TrimSpace(value), ":")
+	if !found || len(rest) == 0 {
+		return Claim{}, fmt.Errorf("invalid optional claim '%s': must be in the form '<token>:<name>'", value)
+	}
+
+	switch TokenType(token) {
+	case TokenTypeAccess, TokenTypeID, TokenTypeSAML:
+	default:
+		return Claim{}, fmt.Errorf("invalid optional claim '%s': token must be one of '%s', '%s' or '%s'", value, TokenTypeID, TokenTypeAccess, TokenTypeSAML)
+	}
+
+	parts := strings.Split(rest, "+")
+	claim := Claim{
+		Token:                TokenType(token),
+		Name:                 parts[0],
+		AdditionalProperties: parts[1:],
+	}
+
+	tokens, supported := supportedClaims[claim.Name]
+	if !supported {
+		return Claim{}, fmt.Errorf("invalid optional claim '%s': claim '%s' is not supported", value, claim.Name)
+	}
+	if !slices.Contains(tokens, claim.Token) {
+		return Claim{}, fmt.Errorf("invalid optional claim '%s': claim '%s' is not supported in %s tokens", value, claim.Name, claim.Token)
+	}
+
+	for _, property := range claim.AdditionalProperties {
+		if !slices.Contains(supportedAdditionalProperties[claim.Name], property) {
+			return Claim{}, fmt.Errorf("invalid optional claim '%s': additional property '%s' is not supported for claim '%s'", value, property, claim.Name)
+		}
+	}
+	slices.Sort(claim.AdditionalProperties)
+	claim.AdditionalProperties = slices.Compact(claim.AdditionalProperties)
+
+	return claim, nil
+}
+
+func (c Claim) String() string {
+	return fmt.Sprintf("%s:%s", c.Token, strings.Join(append([]string{c.Name}, c.AdditionalProperties...), "+"))
+}
+
+func (c Claim) toGraph() msgraph.OptionalClaim {
+	claim := msgraph.OptionalClaim{
+		Essential: new(false),
+		Name:      new(c.Name),
+	}
+	if len(c.AdditionalProperties) > 0 {
+		claim.AdditionalProperties = slices.Clone(c.AdditionalProperties)
+	}
+	return claim
+}
+
+type Claims []Claim
+
+// ParseAll parses the given optional claims. The result is sorted, and each claim occurs at most once per type of token.
+func ParseAll(values []string) (Claims, error) {
+	result := make(Claims, 0, len(values))
+	for _, value := range values {
+		claim, err := Parse(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if slices.ContainsFunc(result, func(c Claim) bool { return c.Token == claim.Token && c.Name == claim.Name }) {
+			return nil, fmt.Errorf("optional claim '%s' is requested more than once for %s tokens", claim.Name, claim.Token)
+		}
+		result = append(result, claim)
+	}
+
+	slices.SortFunc(result, func(a, b Claim) int {
+		return cmp.Or(cmp.Compare(a.Token, b.Token), cmp.Compare(a.Name, b.Name))
+	})
+	return result, nil
+}
+
+// Has returns true if the list contains a claim with the given name for the given type of token.
+func (c Claims) Has(token TokenType, name string) bool {
+	return slices.ContainsFunc(c, func(claim Claim) bool {
+		return claim.Token == token && claim.Name == name
+	})
+}
+
+// String returns the claims as a comma-separated list.
+func (c Claims) String() string {
+	values := make([]string, 0, len(c))
+	for _, claim := range c {
+		values = append(values, claim.String())
+	}
+	return strings.Join(values, ",")
+}
+
+func (c Claims) toGraph() *msgraph.OptionalClaims {
+	result := &msgraph.OptionalClaims{}
+	for _, claim := range c {
+		switch claim.Token {
+		case TokenTypeAccess:
+			result.AccessToken = append(result.AccessToken, claim.toGraph())
+		case TokenTypeID:
+			result.IDToken = append(result.IDToken, claim.toGraph())
+		case TokenTypeSAML:
+			result.Saml2Token = append(result.Saml2Token, claim.toGraph())
+		}
+	}
+	return result
+}
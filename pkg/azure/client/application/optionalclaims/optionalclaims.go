@@ -1,12 +1,14 @@
 package optionalclaims
 
 import (
+	"slices"
+
 	msgraph "github.com/nais/msgraph.go/v1.0"
 )
 
 type OptionalClaims interface {
-	DescribeCreate() *msgraph.OptionalClaims
-	DescribeUpdate(existing msgraph.Application) *msgraph.OptionalClaims
+	DescribeCreate(desired Claims) *msgraph.OptionalClaims
+	DescribeUpdate(existing msgraph.Application, desired, previous Claims) *msgraph.OptionalClaims
 }
 
 type optionalClaims struct{}
@@ -15,17 +17,24 @@ func NewOptionalClaims() OptionalClaims {
 	return optionalClaims{}
 }
 
-func (o optionalClaims) DescribeCreate() *msgraph.OptionalClaims {
-	return defaultClaims()
+// DescribeCreate returns the default optional claims along with the desired claims for the application.
+func (o optionalClaims) DescribeCreate(desired Claims) *msgraph.OptionalClaims {
+	return mergeClaims(desired.toGraph(), defaultClaims())
 }
 
-func (o optionalClaims) DescribeUpdate(existing msgraph.Application) *msgraph.OptionalClaims {
+// DescribeUpdate returns the existing optional claims for the application, merged with the default and desired claims.
+// Previously desired claims that are no longer desired are removed, while any other existing claims are preserved.
+func (o optionalClaims) DescribeUpdate(existing msgraph.Application, desired, previous Claims) *msgraph.OptionalClaims {
 	existingClaims := existing.OptionalClaims
 	if existingClaims == nil {
-		return defaultClaims()
+		existingClaims = &msgraph.OptionalClaims{}
 	}
 
-	return mergeClaims(existingClaims, defaultClaims())
+	result := removeClaims(existingClaims, func(token TokenType, name string) bool {
+		return previous.Has(token, name) && !desired.Has(token, name)
+	})
+	result = mergeClaims(result, desired.toGraph())
+	return mergeClaims(result, defaultClaims())
 }
 
 func defaultClaims() *msgraph.OptionalClaims {
@@ -85,3 +94,23 @@ func mergeClaims(existing, override *msgraph.OptionalClaims) *msgraph.OptionalCl
 
 	return &result
 }
+
+// removeClaims returns a copy of the given claims without the claims matched by the given function.
+func removeClaims(existing *msgraph.OptionalClaims, remove func(token TokenType, name string) bool) *msgraph.OptionalClaims {
+	result := *existing
+
+	filter := func(token TokenType, claims []msgraph.OptionalClaim) []msgraph.OptionalClaim {
+		if claims == nil {
+			return nil
+		}
+		return slices.DeleteFunc(slices.Clone(claims), func(claim msgraph.OptionalClaim) bool {
+			return claim.Name != nil && remove(token, *claim.Name)
+		})
+	}
+
+	result.AccessToken = filter(TokenTypeAccess, result.AccessToken)
+	result.IDToken = filter(TokenTypeID, result.IDToken)
+	result.Saml2Token = filter(TokenTypeSAML, result.Saml2Token)
+
+	return &result
+}
@@ -5,6 +5,7 @@ import (
 
 	msgraph "github.com/nais/msgraph.go/v1.0"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/nais/azureator/pkg/azure/client/application/optionalclaims"
 	"github.com/nais/azureator/pkg/azure/util"
@@ -26,14 +27,48 @@ func TestOptionalClaims_DescribeCreate(t *testing.T) {
 		},
 	}
 
-	create := optionalclaims.NewOptionalClaims().DescribeCreate()
+	create := optionalclaims.NewOptionalClaims().DescribeCreate(nil)
 	assert.Equal(t, desired, *create)
+
+	t.Run("with desired claims", func(t *testing.T) {
+		claims, err := optionalclaims.ParseAll([]string{"id:email", "saml:groups+sam_account_name", "access:idtyp"})
+		require.NoError(t, err)
+
+		create := optionalclaims.NewOptionalClaims().DescribeCreate(claims)
+		assert.Equal(t, msgraph.OptionalClaims{
+			AccessToken: []msgraph.OptionalClaim{
+				{
+					Essential: new(true),
+					Name:      new("idtyp"),
+				},
+			},
+			IDToken: []msgraph.OptionalClaim{
+				{
+					Essential: new(false),
+					Name:      new("email"),
+				},
+				{
+					Essential: new(true),
+					Name:      new("sid"),
+				},
+			},
+			Saml2Token: []msgraph.OptionalClaim{
+				{
+					AdditionalProperties: []string{"sam_account_name"},
+					Essential:            new(false),
+					Name:                 new("groups"),
+				},
+			},
+		}, *create)
+	})
 }
 
 func TestOptionalClaims_DescribeUpdate(t *testing.T) {
 	for _, test := range []struct {
 		name     string
 		existing msgraph.OptionalClaims
+		desired  []string
+		previous []string
 		want     msgraph.OptionalClaims
 	}{
 		{
@@ -124,11 +159,158 @@ func TestOptionalClaims_DescribeUpdate(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "desired claims are added or updated",
+			existing: msgraph.OptionalClaims{
+				IDToken: []msgraph.OptionalClaim{
+					{
+						Essential: new(false),
+						Name:      new("groups"),
+					},
+				},
+			},
+			desired: []string{"id:groups+emit_as_roles", "access:upn"},
+			want: msgraph.OptionalClaims{
+				AccessToken: []msgraph.OptionalClaim{
+					{
+						Essential: new(false),
+						Name:      new("upn"),
+					},
+					{
+						Essential: new(true),
+						Name:      new("idtyp"),
+					},
+				},
+				IDToken: []msgraph.OptionalClaim{
+					{
+						AdditionalProperties: []string{"emit_as_roles"},
+						Essential:            new(false),
+						Name:                 new("groups"),
+					},
+					{
+						Essential: new(true),
+						Name:      new("sid"),
+					},
+				},
+			},
+		},
+		{
+			name: "previously desired claims are removed, other claims are preserved",
+			existing: msgraph.OptionalClaims{
+				AccessToken: []msgraph.OptionalClaim{
+					{
+						Essential: new(true),
+						Name:      new("idtyp"),
+					},
+					{
+						Essential: new(false),
+						Name:      new("upn"),
+					},
+				},
+				IDToken: []msgraph.OptionalClaim{
+					{
+						Essential: new(true),
+						Name:      new("sid"),
+					},
+					{
+						Essential: new(false),
+						Name:      new("email"),
+					},
+					{
+						Essential: new(false),
+						Name:      new("upn"),
+					},
+				},
+			},
+			desired:  []string{"id:email"},
+			previous: []string{"id:email", "id:upn", "access:upn", "access:idtyp"},
+			want: msgraph.OptionalClaims{
+				AccessToken: []msgraph.OptionalClaim{
+					{
+						Essential: new(true),
+						Name:      new("idtyp"),
+					},
+				},
+				IDToken: []msgraph.OptionalClaim{
+					{
+						Essential: new(true),
+						Name:      new("sid"),
+					},
+					{
+						Essential: new(false),
+						Name:      new("email"),
+					},
+				},
+			},
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
+			desired, err := optionalclaims.ParseAll(test.desired)
+			require.NoError(t, err)
+			previous, err := optionalclaims.ParseAll(test.previous)
+			require.NoError(t, err)
+
 			existingApp := util.EmptyApplication().OptionalClaims(&test.existing).Build()
-			actual := optionalclaims.NewOptionalClaims().DescribeUpdate(*existingApp)
+			actual := optionalclaims.NewOptionalClaims().DescribeUpdate(*existingApp, desired, previous)
 			assert.Equal(t, test.want, *actual)
 		})
 	}
 }
+
+func TestParseAll(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		values []string
+		want   string
+		err    string
+	}{
+		{
+			name: "no claims",
+		},
+		{
+			name:   "valid claims",
+			values: []string{"saml:upn", " id:groups+sam_account_name+emit_as_roles", "access:upn", "id:auth_time"},
+			want:   "access:upn,id:auth_time,id:groups+emit_as_roles+sam_account_name,saml:upn",
+		},
+		{
+			name:   "invalid format",
+			values: []string{"email"},
+			err:    "must be in the form '<token>:<name>'",
+		},
+		{
+			name:   "unknown token type",
+			values: []string{"refresh:email"},
+			err:    "token must be one of",
+		},
+		{
+			name:   "unsupported claim",
+			values: []string{"id:some_claim"},
+			err:    "claim 'some_claim' is not supported",
+		},
+		{
+			name:   "claim not supported for token type",
+			values: []string{"saml:auth_time"},
+			err:    "claim 'auth_time' is not supported in saml tokens",
+		},
+		{
+			name:   "unsupported additional property",
+			values: []string{"id:email+sam_account_name"},
+			err:    "additional property 'sam_account_name' is not supported for claim 'email'",
+		},
+		{
+			name:   "duplicate claim",
+			values: []string{"id:groups", "id:groups+emit_as_roles"},
+			err:    "requested more than once",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := optionalclaims.ParseAll(test.values)
+			if len(test.err) > 0 {
+				assert.ErrorContains(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, claims.String())
+		})
+	}
+}
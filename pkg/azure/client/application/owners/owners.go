@@ -11,6 +11,7 @@ import (
 
 type Owners interface {
 	Process(tx transaction.Transaction, owner azure.ServicePrincipalId) error
+	Reconcile(tx transaction.Transaction, owner azure.ServicePrincipalId, users, stale []azure.ObjectId) error
 }
 
 type owners struct {
@@ -34,6 +35,35 @@ func (o owners) Process(tx transaction.Transaction, owner azure.ServicePrincipal
 	return o.add(tx, owner)
 }
 
+// Reconcile ensures that the given service principal and users are owners of the application, and removes the given stale
+// users as owners. The given service principal is never removed.
+func (o owners) Reconcile(tx transaction.Transaction, owner azure.ServicePrincipalId, users, stale []azure.ObjectId) error {
+	existing, err := o.get(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range append([]azure.ObjectId{owner}, users...) {
+		if directoryobject.ContainsOwner(existing, id) {
+			continue
+		}
+		if err := o.add(tx, id); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range stale {
+		if id == owner || !directoryobject.ContainsOwner(existing, id) {
+			continue
+		}
+		if err := o.remove(tx, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (o owners) get(tx transaction.Transaction) ([]msgraph.DirectoryObject, error) {
 	objectId := tx.Instance.GetObjectId()
 
@@ -58,3 +88,15 @@ func (o owners) add(tx transaction.Transaction, owner azure.ServicePrincipalId)
 	tx.Logger.Infof("assigned owner %q to application", owner)
 	return nil
 }
+
+func (o owners) remove(tx transaction.Transaction, owner azure.ObjectId) error {
+	objectId := tx.Instance.GetObjectId()
+
+	err := o.GraphClient().Applications().ID(objectId).Owners().ID(owner).Request().JSONRequest(tx.Ctx, "DELETE", "/$ref", nil, nil)
+	if err != nil {
+		return fmt.Errorf("removing owner %q from application: %w", owner, err)
+	}
+
+	tx.Logger.Infof("removed owner %q from application", owner)
+	return nil
+}
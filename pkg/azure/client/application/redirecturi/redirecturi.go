@@ -2,13 +2,16 @@ package redirecturi
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/asaskevich/govalidator"
 	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	msgraph "github.com/nais/msgraph.go/v1.0"
 
 	"github.com/nais/azureator/pkg/azure"
+	"github.com/nais/azureator/pkg/azure/client/application/marker"
 	"github.com/nais/azureator/pkg/transaction"
+	"github.com/nais/azureator/pkg/transaction/options"
 	stringutils "github.com/nais/azureator/pkg/util/strings"
 )
 
@@ -19,8 +22,41 @@ type emptiableRedirectUris struct {
 	RedirectUris []string `json:"redirectUris"`
 }
 
+// webApplication is the equivalent of msgraph.WebApplication where the redirect URIs and logout URL are always
+// included, so that removed values are cleared in the PATCH operation. The platform settings are only included if
+// managed by the operator.
+type webApplication struct {
+	RedirectUris          []string               `json:"redirectUris"`
+	HomePageURL           *clearableString       `json:"homePageUrl,omitempty"`
+	LogoutURL             *string                `json:"logoutUrl"`
+	ImplicitGrantSettings *implicitGrantSettings `json:"implicitGrantSettings,omitempty"`
+}
+
+// clearableString is a setting that is cleared in the PATCH operation if empty.
+type clearableString string
+
+func (s clearableString) MarshalJSON() ([]byte, error) {
+	if len(s) == 0 {
+		return []byte("null"), nil
+	}
+	return json.Marshal(string(s))
+}
+
+type implicitGrantSettings struct {
+	EnableIDTokenIssuance     bool `json:"enableIdTokenIssuance"`
+	EnableAccessTokenIssuance bool `json:"enableAccessTokenIssuance"`
+}
+
+type platforms struct {
+	msgraph.DirectoryObject
+	Web                    webApplication         `json:"web"`
+	Spa                    emptiableRedirectUris  `json:"spa"`
+	PublicClient           *emptiableRedirectUris `json:"publicClient,omitempty"`
+	IsFallbackPublicClient *bool                  `json:"isFallbackPublicClient,omitempty"`
+}
+
 type RedirectUri interface {
-	Update(tx transaction.Transaction) error
+	Update(tx transaction.Transaction, markers marker.Markers) error
 }
 
 type redirectUri struct {
@@ -35,20 +71,71 @@ func NewRedirectUri(application Application) RedirectUri {
 	return redirectUri{Application: application}
 }
 
-func (r redirectUri) Update(tx transaction.Transaction) error {
+// Update sets the platform settings for the application. The given markers record the platform settings that are
+// managed by the operator, i.e. the settings requested now and the settings applied by the operator before.
+func (r redirectUri) Update(tx transaction.Transaction, markers marker.Markers) error {
 	objectId := tx.Instance.GetObjectId()
-	app := App(tx.Instance)
+	app := App(tx.Instance, tx.Options.Process.Azure.PlatformSettings, markers)
 
 	return r.Application.Patch(tx.Ctx, objectId, app)
 }
 
-func App(instance *v1.AzureAdApplication) any {
+// App returns the desired platform settings for the application, i.e. the redirect URIs for either the web or
+// single-page application platform and the logout URL, along with the home page URL, the implicit grant settings and
+// the redirect URIs for public clients if marked as managed by the operator. Managed settings that are not requested
+// are cleared, while settings that are not managed are left out and thus preserved.
+func App(instance *v1.AzureAdApplication, settings options.PlatformSettings, markers marker.Markers) any {
 	redirectUris := ReplyUrlsToStringSlice(instance)
 
+	app := &platforms{
+		Web: webApplication{
+			RedirectUris: make([]string, 0),
+			LogoutURL:    optional(instance.Spec.LogoutUrl),
+		},
+		Spa: emptiableRedirectUris{
+			RedirectUris: make([]string, 0),
+		},
+	}
+
 	if instance.Spec.SinglePageApplication != nil && *instance.Spec.SinglePageApplication {
-		return singlePageApp(redirectUris)
+		app.Spa.RedirectUris = redirectUris
+	} else {
+		app.Web.RedirectUris = redirectUris
+	}
+
+	if markers.Has(marker.PlatformSetting, marker.HomePageUrl) {
+		app.Web.HomePageURL = new(clearableString(settings.HomePageUrl))
+	}
+
+	if markers.Has(marker.PlatformSetting, marker.ImplicitGrant) {
+		app.Web.ImplicitGrantSettings = &implicitGrantSettings{
+			EnableIDTokenIssuance:     settings.ImplicitGrant.IDToken,
+			EnableAccessTokenIssuance: settings.ImplicitGrant.AccessToken,
+		}
 	}
-	return webApp(redirectUris)
+
+	if markers.Has(marker.PlatformSetting, marker.PublicClientRedirectUris) {
+		app.PublicClient = &emptiableRedirectUris{
+			RedirectUris: make([]string, 0),
+		}
+		if len(settings.PublicClientRedirectUris) > 0 {
+			app.PublicClient.RedirectUris = settings.PublicClientRedirectUris
+		}
+	}
+
+	if markers.Has(marker.PlatformSetting, marker.FallbackPublicClient) {
+		app.IsFallbackPublicClient = new(settings.FallbackPublicClient)
+	}
+
+	return app
+}
+
+// optional returns nil for empty values, which clears the setting in the PATCH operation.
+func optional(value string) *string {
+	if len(value) == 0 {
+		return nil
+	}
+	return &value
 }
 
 func ReplyUrlsToStringSlice(resource *v1.AzureAdApplication) []string {
@@ -63,33 +150,3 @@ func ReplyUrlsToStringSlice(resource *v1.AzureAdApplication) []string {
 	}
 	return stringutils.RemoveDuplicates(replyUrls)
 }
-
-func webApp(redirectUris []string) any {
-	return &struct {
-		msgraph.DirectoryObject
-		Web emptiableRedirectUris `json:"web"`
-		Spa emptiableRedirectUris `json:"spa"`
-	}{
-		Web: emptiableRedirectUris{
-			RedirectUris: redirectUris,
-		},
-		Spa: emptiableRedirectUris{
-			RedirectUris: make([]string, 0),
-		},
-	}
-}
-
-func singlePageApp(redirectUris []string) any {
-	return &struct {
-		msgraph.DirectoryObject
-		Web emptiableRedirectUris `json:"web"`
-		Spa emptiableRedirectUris `json:"spa"`
-	}{
-		Web: emptiableRedirectUris{
-			RedirectUris: make([]string, 0),
-		},
-		Spa: emptiableRedirectUris{
-			RedirectUris: redirectUris,
-		},
-	}
-}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/nais/azureator/pkg/azure/client/application/marker"
 	"github.com/nais/azureator/pkg/azure/client/application/redirecturi"
+	"github.com/nais/azureator/pkg/transaction/options"
 
 	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	"github.com/stretchr/testify/assert"
@@ -13,13 +15,14 @@ import (
 func TestRedirectUriApp(t *testing.T) {
 	t.Run("web application, default", func(t *testing.T) {
 		app := azureAdApp()
-		a := redirecturi.App(app)
+		a := redirecturi.App(app, options.PlatformSettings{}, nil)
 		expected := `
 {
   "web": {
     "redirectUris": [
       "https://test.host/callback"
-    ]
+    ],
+    "logoutUrl": null
   },
   "spa": {
     "redirectUris": []
@@ -33,11 +36,12 @@ func TestRedirectUriApp(t *testing.T) {
 		app := azureAdApp()
 		app.Spec.ReplyUrls = make([]v1.AzureAdReplyUrl, 0)
 
-		a := redirecturi.App(app)
+		a := redirecturi.App(app, options.PlatformSettings{}, nil)
 		expected := `
 {
   "web": {
-    "redirectUris": []
+    "redirectUris": [],
+    "logoutUrl": null
   },
   "spa": {
     "redirectUris": []
@@ -51,11 +55,12 @@ func TestRedirectUriApp(t *testing.T) {
 		app := azureAdApp()
 		app.Spec.SinglePageApplication = new(true)
 
-		a := redirecturi.App(app)
+		a := redirecturi.App(app, options.PlatformSettings{}, nil)
 		expected := `
 {
   "web": {
-    "redirectUris": []
+    "redirectUris": [],
+    "logoutUrl": null
   },
   "spa": {
     "redirectUris": [
@@ -72,16 +77,92 @@ func TestRedirectUriApp(t *testing.T) {
 		app.Spec.SinglePageApplication = new(true)
 		app.Spec.ReplyUrls = make([]v1.AzureAdReplyUrl, 0)
 
-		a := redirecturi.App(app)
+		a := redirecturi.App(app, options.PlatformSettings{}, nil)
 		expected := `
 {
   "web": {
-    "redirectUris": []
+    "redirectUris": [],
+    "logoutUrl": null
   },
   "spa": {
     "redirectUris": []
   }
 }
+`
+		assertJson(t, a, expected)
+	})
+
+	t.Run("web application with platform settings", func(t *testing.T) {
+		app := azureAdApp()
+		app.Spec.LogoutUrl = "https://test.host/logout"
+
+		settings := options.PlatformSettings{
+			HomePageUrl:              "https://test.host",
+			ImplicitGrant:            options.ImplicitGrant{IDToken: true},
+			PublicClientRedirectUris: []string{"http://localhost", "myapp://auth"},
+			FallbackPublicClient:     true,
+		}
+
+		a := redirecturi.App(app, settings, marker.Desired(options.AzureOptions{PlatformSettings: settings}))
+		expected := `
+{
+  "web": {
+    "redirectUris": [
+      "https://test.host/callback"
+    ],
+    "homePageUrl": "https://test.host",
+    "logoutUrl": "https://test.host/logout",
+    "implicitGrantSettings": {
+      "enableIdTokenIssuance": true,
+      "enableAccessTokenIssuance": false
+    }
+  },
+  "spa": {
+    "redirectUris": []
+  },
+  "publicClient": {
+    "redirectUris": [
+      "http://localhost",
+      "myapp://auth"
+    ]
+  },
+  "isFallbackPublicClient": true
+}
+`
+		assertJson(t, a, expected)
+	})
+
+	t.Run("web application with platform settings that are no longer requested", func(t *testing.T) {
+		app := azureAdApp()
+		markers := marker.FromTags([]string{
+			"azurerator_platform_setting:home_page_url",
+			"azurerator_platform_setting:implicit_grant",
+			"azurerator_platform_setting:public_client_redirect_uris",
+			"azurerator_platform_setting:fallback_public_client",
+		})
+
+		a := redirecturi.App(app, options.PlatformSettings{}, markers)
+		expected := `
+{
+  "web": {
+    "redirectUris": [
+      "https://test.host/callback"
+    ],
+    "homePageUrl": null,
+    "logoutUrl": null,
+    "implicitGrantSettings": {
+      "enableIdTokenIssuance": false,
+      "enableAccessTokenIssuance": false
+    }
+  },
+  "spa": {
+    "redirectUris": []
+  },
+  "publicClient": {
+    "redirectUris": []
+  },
+  "isFallbackPublicClient": false
+}
 `
 		assertJson(t, a, expected)
 	})
@@ -0,0 +1,110 @@
+package tokenlifetimepolicy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	msgraph "github.com/nais/msgraph.go/v1.0"
+
+	"github.com/nais/azureator/pkg/azure"
+	"github.com/nais/azureator/pkg/transaction"
+)
+
+type TokenLifetimePolicies interface {
+	Process(tx transaction.Transaction, desiredPolicyID string, managedPolicyIDs []string) error
+}
+
+type tokenLifetimePolicies struct {
+	azure.RuntimeClient
+}
+
+type policyBody struct {
+	Content string `json:"@odata.id"`
+}
+
+func newPolicyBody(id string) policyBody {
+	return policyBody{
+		Content: fmt.Sprintf("https://graph.microsoft.com/v1.0/policies/tokenLifetimePolicies/%s", id),
+	}
+}
+
+func NewTokenLifetimePolicies(client azure.RuntimeClient) TokenLifetimePolicies {
+	return tokenLifetimePolicies{RuntimeClient: client}
+}
+
+// Process assigns the desired policy to the application. An application can only have a single token lifetime policy
+// assigned, so any other policy is replaced. If the desired policy is empty, any of the managed policies that are
+// assigned to the application are removed.
+func (t tokenLifetimePolicies) Process(tx transaction.Transaction, desiredPolicyID string, managedPolicyIDs []string) error {
+	objectID := tx.Instance.GetObjectId()
+	if len(objectID) == 0 {
+		return fmt.Errorf("token-lifetime-policies: application object ID is not set")
+	}
+
+	assignedPolicies, err := t.getAssignedPolicies(tx.Ctx, objectID)
+	if err != nil {
+		return fmt.Errorf("token-lifetime-policies: fetching existing policies for application '%s': %w", objectID, err)
+	}
+
+	assigned := false
+	for _, assignedPolicy := range assignedPolicies {
+		if assignedPolicy.ID == nil || len(*assignedPolicy.ID) == 0 {
+			continue
+		}
+		assignedPolicyID := *assignedPolicy.ID
+
+		if assignedPolicyID == desiredPolicyID {
+			assigned = true
+			continue
+		}
+
+		if len(desiredPolicyID) == 0 && !slices.Contains(managedPolicyIDs, assignedPolicyID) {
+			continue
+		}
+
+		if err := t.removePolicy(tx.Ctx, assignedPolicyID, objectID); err != nil {
+			return fmt.Errorf("token-lifetime-policies: removing '%s' from application '%s': %w", assignedPolicyID, objectID, err)
+		}
+		tx.Logger.Infof("token-lifetime-policies: successfully removed '%s' from application '%s'", assignedPolicyID, objectID)
+	}
+
+	if len(desiredPolicyID) == 0 || assigned {
+		return nil
+	}
+
+	if err := t.assignPolicy(tx.Ctx, desiredPolicyID, objectID); err != nil {
+		return fmt.Errorf("token-lifetime-policies: assigning '%s' to application '%s': %w", desiredPolicyID, objectID, err)
+	}
+	tx.Logger.Infof("token-lifetime-policies: successfully assigned '%s' to application '%s'", desiredPolicyID, objectID)
+	return nil
+}
+
+func (t tokenLifetimePolicies) assignPolicy(ctx context.Context, desiredPolicyID, objectID string) error {
+	return t.GraphClient().
+		Applications().
+		ID(objectID).
+		TokenLifetimePolicies().
+		Request().
+		JSONRequest(ctx, http.MethodPost, "/$ref", newPolicyBody(desiredPolicyID), nil)
+}
+
+func (t tokenLifetimePolicies) getAssignedPolicies(ctx context.Context, objectID string) ([]msgraph.TokenLifetimePolicy, error) {
+	return t.GraphClient().
+		Applications().
+		ID(objectID).
+		TokenLifetimePolicies().
+		Request().
+		Get(ctx)
+}
+
+func (t tokenLifetimePolicies) removePolicy(ctx context.Context, assignedPolicyID, objectID string) error {
+	return t.GraphClient().
+		Applications().
+		ID(objectID).
+		TokenLifetimePolicies().
+		ID(assignedPolicyID).
+		Request().
+		JSONRequest(ctx, http.MethodDelete, "/$ref", nil, nil)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
@@ -11,12 +13,15 @@ import (
 	"golang.org/x/oauth2"
 
 	"github.com/nais/azureator/pkg/azure"
+	"github.com/nais/azureator/pkg/azure/client/apipermission"
 	"github.com/nais/azureator/pkg/azure/client/application"
 	"github.com/nais/azureator/pkg/azure/client/application/identifieruri"
+	"github.com/nais/azureator/pkg/azure/client/application/marker"
 	"github.com/nais/azureator/pkg/azure/client/group"
 	"github.com/nais/azureator/pkg/azure/client/oauth2permissiongrant"
 	"github.com/nais/azureator/pkg/azure/client/preauthorizedapp"
 	"github.com/nais/azureator/pkg/azure/client/serviceprincipal"
+	"github.com/nais/azureator/pkg/azure/client/user"
 	"github.com/nais/azureator/pkg/azure/permissions"
 	"github.com/nais/azureator/pkg/azure/result"
 	"github.com/nais/azureator/pkg/azure/util"
@@ -24,6 +29,7 @@ import (
 	"github.com/nais/azureator/pkg/customresources"
 	"github.com/nais/azureator/pkg/retry"
 	"github.com/nais/azureator/pkg/transaction"
+	"github.com/nais/azureator/pkg/transaction/options"
 )
 
 const (
@@ -57,6 +63,10 @@ func (c Client) DelayIntervalBetweenModifications() time.Duration {
 	return c.config.Delay.BetweenModifications
 }
 
+func (c Client) APIPermissions() apipermission.APIPermissions {
+	return apipermission.NewAPIPermissions(c)
+}
+
 func (c Client) Application() application.Application {
 	return application.NewApplication(c)
 }
@@ -85,6 +95,10 @@ func (c Client) ServicePrincipal() serviceprincipal.ServicePrincipal {
 	return serviceprincipal.NewServicePrincipal(c)
 }
 
+func (c Client) Users() user.Users {
+	return user.NewUsers(c)
+}
+
 func New(ctx context.Context, cfg *config.AzureConfig) (azure.Client, error) {
 	var ts oauth2.TokenSource
 	var err error
@@ -99,13 +113,22 @@ func New(ctx context.Context, cfg *config.AzureConfig) (azure.Client, error) {
 	}
 
 	httpClient := oauth2.NewClient(ctx, ts)
+	return NewWithHttpClient(cfg, httpClient), nil
+}
+
+// NewWithHttpClient returns a client that performs all Graph API requests with the given HTTP client, i.e. without
+// acquiring any tokens on its own. Requests are sent to the configured Graph base URL, if any.
+func NewWithHttpClient(cfg *config.AzureConfig, httpClient *http.Client) azure.Client {
 	graphClient := msgraph.NewClient(httpClient)
+	if len(cfg.Graph.BaseURL) > 0 {
+		graphClient.SetURL(strings.TrimSuffix(cfg.Graph.BaseURL, "/"))
+	}
 
 	return Client{
 		config:      cfg,
 		httpClient:  httpClient,
 		graphClient: graphClient,
-	}, nil
+	}
 }
 
 // Create registers a new AAD application with the desired configuration
@@ -128,7 +151,7 @@ func (c Client) Create(tx transaction.Transaction) (*result.Application, error)
 
 	tx = tx.UpdateWithServicePrincipalID(servicePrincipal)
 
-	identifierUris := identifieruri.DescribeCreate(tx.Instance, tx.ClusterName)
+	identifierUris := identifieruri.DescribeCreate(tx.Instance, tx.ClusterName, tx.Options.Process.Azure.IdentifierUris)
 	err = doRetry(tx.Ctx, func(ctx context.Context) error {
 		err := c.Application().IdentifierUri().Set(tx, identifierUris)
 		return retry.RetryableError(err)
@@ -137,6 +160,15 @@ func (c Client) Create(tx transaction.Transaction) (*result.Application, error)
 		return nil, fmt.Errorf("setting identifier URIs for application: %w", err)
 	}
 
+	// platform settings such as the logout URL are managed by the same reconciler as for updates
+	err = doRetry(tx.Ctx, func(ctx context.Context) error {
+		err := c.Application().RedirectUri().Update(tx, marker.Desired(tx.Options.Process.Azure))
+		return retry.RetryableError(err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("setting platform settings for application: %w", err)
+	}
+
 	var res *processResult
 	err = doRetry(tx.Ctx, func(ctx context.Context) error {
 		res, err = c.process(tx, app)
@@ -152,7 +184,9 @@ func (c Client) Create(tx transaction.Transaction) (*result.Application, error)
 		ServicePrincipalId: *servicePrincipal.ID,
 		Permissions:        res.permissions,
 		PreAuthorizedApps:  res.preAuthorizedApps,
+		Groups:             res.groups,
 		Tenant:             c.config.Tenant.Id,
+		AccessTokenVersion: tx.Options.Process.Azure.APISettings.AccessTokenVersionOr(c.config.APISettings.DefaultAccessTokenVersion()),
 		Result:             result.OperationCreated,
 	}, nil
 }
@@ -222,6 +256,12 @@ func (c Client) PreAuthorizedAppClientID(ctx context.Context, rule v1.AccessPoli
 	return *app.AppID, true, nil
 }
 
+// GroupExists returns whether a group reference, i.e. an object ID, display name or mail nickname, resolves to exactly
+// one group in AAD.
+func (c Client) GroupExists(ctx context.Context, reference string) (bool, error) {
+	return c.Groups().Exists(ctx, reference)
+}
+
 // Update updates an existing AAD application. Should be an idempotent operation
 func (c Client) Update(tx transaction.Transaction) (*result.Application, error) {
 	clientId := tx.Instance.GetClientId()
@@ -233,8 +273,9 @@ func (c Client) Update(tx transaction.Transaction) (*result.Application, error)
 		return nil, fmt.Errorf("updating application resource: %w", err)
 	}
 
-	if err := c.Application().RedirectUri().Update(tx); err != nil {
-		return nil, fmt.Errorf("updating redirect URIs: %w", err)
+	// the markers of the updated application include both the settings requested now and the settings applied before
+	if err := c.Application().RedirectUri().Update(tx, marker.FromTags(app.Tags)); err != nil {
+		return nil, fmt.Errorf("updating platform settings: %w", err)
 	}
 
 	res, err := c.process(tx, app)
@@ -246,13 +287,19 @@ func (c Client) Update(tx transaction.Transaction) (*result.Application, error)
 		return nil, err
 	}
 
+	if err := c.Application().RemoveStaleMarkers(tx, *app); err != nil {
+		return nil, err
+	}
+
 	return &result.Application{
 		ClientId:           clientId,
 		ObjectId:           objectId,
 		ServicePrincipalId: servicePrincipalId,
 		Permissions:        res.permissions,
 		PreAuthorizedApps:  res.preAuthorizedApps,
+		Groups:             res.groups,
 		Tenant:             c.config.Tenant.Id,
+		AccessTokenVersion: tx.Options.Process.Azure.APISettings.AccessTokenVersionOr(c.config.APISettings.DefaultAccessTokenVersion()),
 		Result:             result.OperationUpdated,
 	}, nil
 }
@@ -260,6 +307,7 @@ func (c Client) Update(tx transaction.Transaction) (*result.Application, error)
 type processResult struct {
 	preAuthorizedApps result.PreAuthorizedApps
 	permissions       permissions.Permissions
+	groups            result.Groups
 }
 
 func (c Client) process(tx transaction.Transaction, app *msgraph.Application) (*processResult, error) {
@@ -267,16 +315,41 @@ func (c Client) process(tx transaction.Transaction, app *msgraph.Application) (*
 		return nil, fmt.Errorf("processing oauth2 permission grants: %w", err)
 	}
 
+	if err := c.APIPermissions().Process(tx); err != nil {
+		return nil, fmt.Errorf("processing API permissions: %w", err)
+	}
+
 	// ensure authenticated principal is an owner of application and service principal
 	ownerId, err := c.ServicePrincipal().GetIdByClientId(tx.Ctx, c.config.Auth.ClientId)
 	if err != nil {
 		return nil, fmt.Errorf("fetching authenticated service principal id: %w", err)
 	}
-	if err := c.Application().Owners().Process(tx, ownerId); err != nil {
-		return nil, fmt.Errorf("processing application owners: %w", err)
+	// the owners are reconciled if additional owners are requested now, or were applied by the operator before
+	previousOwners := marker.FromTags(app.Tags).Values(marker.Owner)
+	if len(tx.Options.Process.Azure.Owners) > 0 || len(previousOwners) > 0 {
+		users, stale, err := c.resolveOwners(tx, previousOwners)
+		if err != nil {
+			return nil, fmt.Errorf("resolving owners: %w", err)
+		}
+		if err := c.Application().Owners().Reconcile(tx, ownerId, users, stale); err != nil {
+			return nil, fmt.Errorf("processing application owners: %w", err)
+		}
+		if err := c.ServicePrincipal().Owners().Reconcile(tx, ownerId, users, stale); err != nil {
+			return nil, fmt.Errorf("processing service principal owners: %w", err)
+		}
+	} else {
+		if err := c.Application().Owners().Process(tx, ownerId); err != nil {
+			return nil, fmt.Errorf("processing application owners: %w", err)
+		}
+		if err := c.ServicePrincipal().Owners().Process(tx, ownerId); err != nil {
+			return nil, fmt.Errorf("processing service principal owners: %w", err)
+		}
 	}
-	if err := c.ServicePrincipal().Owners().Process(tx, ownerId); err != nil {
-		return nil, fmt.Errorf("processing service principal owners: %w", err)
+
+	if tx.Options.Process.Secret.CredentialMode.WorkloadIdentity() {
+		if err := c.Application().FederatedIdentityCredentials().Process(tx); err != nil {
+			return nil, fmt.Errorf("processing federated identity credentials: %w", err)
+		}
 	}
 
 	perms := permissions.ExtractPermissions(app)
@@ -286,21 +359,33 @@ func (c Client) process(tx transaction.Transaction, app *msgraph.Application) (*
 	}
 
 	if c.config.Features.ClaimsMappingPolicies.Enabled {
+		policyID := tx.Options.Process.Azure.ClaimsMappingPolicy.ID
+
 		// ensure that the application is configured to accept mapped claims, this is required for the claims mapping policies to work
 		// bug: cannot _register_ applications with acceptMappedClaims while only holding the `Application.ReadWrite.OwnedBy` permission, but we can _update_ them.
-		if err := c.Application().EnableAcceptMappedClaims(tx, app); err != nil {
-			return nil, fmt.Errorf("enabling acceptMappedClaims for application: %w", err)
+		if err := c.Application().SetAcceptMappedClaims(tx, app, len(policyID) > 0); err != nil {
+			return nil, fmt.Errorf("setting acceptMappedClaims for application: %w", err)
 		}
 
-		if err := c.ServicePrincipal().Policies().Process(tx, c.config.Features.ClaimsMappingPolicies.ID); err != nil {
+		if err := c.ServicePrincipal().Policies().Process(tx, policyID, c.config.Features.ClaimsMappingPolicies.PolicyIDs()); err != nil {
 			return nil, fmt.Errorf("processing service principal policies: %w", err)
 		}
 	}
 
+	if managedPolicyIDs := c.config.APISettings.TokenLifetimePolicyIDs(); len(managedPolicyIDs) > 0 {
+		policyID := tx.Options.Process.Azure.APISettings.TokenLifetimePolicy.ID
+		if err := c.Application().TokenLifetimePolicies().Process(tx, policyID, managedPolicyIDs); err != nil {
+			return nil, fmt.Errorf("processing application token lifetime policies: %w", err)
+		}
+	}
+
+	groups := result.Groups{}
 	if c.config.Features.GroupsAssignment.Enabled {
-		if err := c.Groups().Process(tx); err != nil {
+		groupsResult, err := c.Groups().Process(tx, app)
+		if err != nil {
 			return nil, fmt.Errorf("processing groups to service principal: %w", err)
 		}
+		groups = *groupsResult
 	}
 
 	if c.config.Features.AppRoleAssignmentRequired.Enabled {
@@ -319,9 +404,79 @@ func (c Client) process(tx transaction.Transaction, app *msgraph.Application) (*
 	return &processResult{
 		preAuthorizedApps: *preAuthApps,
 		permissions:       perms,
+		groups:            groups,
 	}, nil
 }
 
+// resolveOwners returns the object IDs of the users that are requested as additional owners, either directly by user
+// principal name or as transitive members of a group. It also returns the users of the given previous owners, i.e. the
+// owners applied by the operator before, that are no longer requested. Only these are removed as owners, so that owners
+// added by other means are preserved.
+func (c Client) resolveOwners(tx transaction.Transaction, previous []string) ([]azure.ObjectId, []azure.ObjectId, error) {
+	desired := make([]azure.ObjectId, 0)
+	for _, owner := range tx.Options.Process.Azure.Owners {
+		users, reason, err := c.resolveOwner(tx, owner)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(reason) > 0 && !options.IsUser(owner) {
+			// skipping the group would remove its members as owners
+			return nil, nil, fmt.Errorf("group '%s' %s", owner, reason)
+		}
+		if len(reason) > 0 {
+			tx.Logger.Warnf("owners: skipping '%s': %s", owner, reason)
+			continue
+		}
+		desired = append(desired, users...)
+	}
+
+	stale := make([]azure.ObjectId, 0)
+	for _, owner := range previous {
+		if slices.Contains(tx.Options.Process.Azure.Owners, owner) {
+			continue
+		}
+
+		users, reason, err := c.resolveOwner(tx, owner)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(reason) > 0 {
+			tx.Logger.Warnf("owners: not removing previous owner '%s': %s", owner, reason)
+			continue
+		}
+		stale = append(stale, users...)
+	}
+
+	slices.Sort(desired)
+	desired = slices.Compact(desired)
+	stale = slices.DeleteFunc(stale, func(id azure.ObjectId) bool {
+		return slices.Contains(desired, id)
+	})
+	slices.Sort(stale)
+	return desired, slices.Compact(stale), nil
+}
+
+// resolveOwner returns the object IDs of the users referenced by the given owner, i.e. a user principal name or a
+// group reference. If the owner cannot be resolved, the reason is returned instead.
+func (c Client) resolveOwner(tx transaction.Transaction, owner string) ([]azure.ObjectId, string, error) {
+	if !options.IsUser(owner) {
+		users, reason, err := c.Groups().UserMembers(tx, owner)
+		if err != nil {
+			return nil, "", fmt.Errorf("getting members of group '%s': %w", owner, err)
+		}
+		return users, reason, nil
+	}
+
+	id, exists, err := c.Users().GetIdByUserPrincipalName(tx.Ctx, owner)
+	if err != nil {
+		return nil, "", fmt.Errorf("getting user '%s': %w", owner, err)
+	}
+	if !exists {
+		return nil, "does not match any user", nil
+	}
+	return []azure.ObjectId{id}, "", nil
+}
+
 func doRetry(ctx context.Context, fn func(context.Context) error) error {
 	return retry.Fibonacci(RetryInitialDelay).
 		WithMaxDuration(RetryMaximumDuration).
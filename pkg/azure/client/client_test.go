@@ -0,0 +1,996 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/nais/liberator/pkg/kubernetes"
+	msgraph "github.com/nais/msgraph.go/v1.0"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/nais/azureator/pkg/azure"
+	"github.com/nais/azureator/pkg/azure/apipermissions"
+	"github.com/nais/azureator/pkg/azure/client"
+	"github.com/nais/azureator/pkg/azure/client/application/approle"
+	"github.com/nais/azureator/pkg/azure/client/application/groupmembershipclaim"
+	"github.com/nais/azureator/pkg/azure/credentials"
+	"github.com/nais/azureator/pkg/azure/fake/graph"
+	"github.com/nais/azureator/pkg/azure/grouproles"
+	"github.com/nais/azureator/pkg/azure/permissions"
+	"github.com/nais/azureator/pkg/azure/result"
+	"github.com/nais/azureator/pkg/config"
+	"github.com/nais/azureator/pkg/transaction"
+	"github.com/nais/azureator/pkg/transaction/options"
+	"github.com/nais/azureator/pkg/transaction/secrets"
+)
+
+const (
+	clusterName     = "test-cluster"
+	msGraphClientId = "00000003-0000-0000-c000-000000000000"
+)
+
+type directory struct {
+	server     *graph.Server
+	client     azure.Client
+	config     *config.AzureConfig
+	operatorId string
+	msGraphId  string
+	groupId    string
+	policyId   string
+}
+
+func setup(t *testing.T) directory {
+	server := graph.NewServer()
+	t.Cleanup(server.Close)
+
+	// the service principal ID of the operator is cached per client ID for the lifetime of the process
+	operatorClientId := "operator-client-id-" + t.Name()
+	operatorId := server.AddServicePrincipal(operatorClientId, "azurerator")
+	msGraphId := server.AddAPI(msGraphClientId, "Microsoft Graph",
+		[]string{"User.Read.All", "Group.Read.All"},
+		[]string{"openid", "User.Read", "GroupMember.Read.All", "Mail.Send"},
+	)
+	groupId := server.AddGroup("some-group")
+	policyId := server.AddClaimsMappingPolicy("some-policy")
+
+	cfg := &config.AzureConfig{
+		Auth: config.AzureAuth{
+			ClientId: operatorClientId,
+		},
+		Features: config.AzureFeatures{
+			AppRoleAssignmentRequired: config.AppRoleAssignmentRequired{Enabled: true},
+			ClaimsMappingPolicies:     config.ClaimsMappingPolicies{Enabled: true, ID: policyId},
+			GroupsAssignment:          config.GroupsAssignment{Enabled: true},
+			GroupMembershipClaim:      config.GroupMembershipClaim{Default: groupmembershipclaim.ApplicationGroup},
+		},
+		Graph: config.AzureGraph{
+			BaseURL: server.BaseURL(),
+		},
+		Pagination: config.AzurePagination{
+			MaxPages: 1000,
+		},
+		PermissionGrantResourceId: msGraphId,
+		Tenant: config.AzureTenant{
+			Id:   "some-tenant-id",
+			Name: "test.example.com",
+		},
+	}
+
+	return directory{
+		server:     server,
+		client:     client.NewWithHttpClient(cfg, server.Client()),
+		config:     cfg,
+		operatorId: operatorId,
+		msGraphId:  msGraphId,
+		groupId:    groupId,
+		policyId:   policyId,
+	}
+}
+
+func newTransaction(t *testing.T, name string, mutate func(app *v1.AzureAdApplication)) transaction.Transaction {
+	app := &v1.AzureAdApplication{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "test-namespace",
+		},
+		Spec: v1.AzureAdApplicationSpec{
+			SecretName: name,
+		},
+	}
+	mutate(app)
+
+	return transaction.Transaction{
+		Ctx:                 context.Background(),
+		ClusterName:         clusterName,
+		Instance:            app,
+		Logger:              *log.NewEntry(log.StandardLogger()),
+		UniformResourceName: kubernetes.UniformResourceName(app, clusterName),
+		ID:                  t.Name(),
+	}
+}
+
+func TestClient_Lifecycle(t *testing.T) {
+	d := setup(t)
+
+	other := newTransaction(t, "other", func(*v1.AzureAdApplication) {})
+	_, err := d.client.Create(other)
+	require.NoError(t, err)
+
+	tx := newTransaction(t, "test-app", func(app *v1.AzureAdApplication) {
+		app.Spec.Claims = &v1.AzureAdClaims{
+			Groups: []v1.AzureAdGroup{{ID: d.groupId}},
+		}
+	})
+	tx.Options.Process.Azure.ClaimsMappingPolicy = options.ClaimsMappingPolicy{ID: d.policyId}
+
+	t.Run("create", func(t *testing.T) {
+		res, err := d.client.Create(tx)
+		require.NoError(t, err)
+		assert.Equal(t, result.OperationCreated, res.Result)
+
+		app, found := d.server.Application(res.ObjectId)
+		require.True(t, found)
+		assert.Equal(t, tx.UniformResourceName, *app.DisplayName)
+		assert.Equal(t, res.ClientId, *app.AppID)
+		assert.Contains(t, app.IdentifierUris, "api://"+res.ClientId)
+		assert.True(t, *app.API.AcceptMappedClaims)
+
+		assert.Equal(t, []string{d.operatorId}, d.server.Owners(res.ObjectId))
+		assert.Equal(t, []string{d.operatorId}, d.server.Owners(res.ServicePrincipalId))
+		assert.Equal(t, []string{d.policyId}, d.server.ClaimsMappingPolicies(res.ServicePrincipalId))
+
+		sp, found := d.server.ServicePrincipal(res.ServicePrincipalId)
+		require.True(t, found)
+		assert.True(t, *sp.AppRoleAssignmentRequired)
+
+		// the application is always pre-authorized for itself, in addition to the group
+		principals := make([]string, 0)
+		for _, assignment := range d.server.AppRoleAssignments(res.ServicePrincipalId) {
+			principals = append(principals, string(*assignment.PrincipalID))
+		}
+		assert.ElementsMatch(t, []string{res.ServicePrincipalId, d.groupId}, principals)
+
+		grants := 0
+		for _, grant := range d.server.OAuth2PermissionGrants() {
+			if *grant.ClientID == res.ServicePrincipalId {
+				grants++
+			}
+		}
+		assert.Equal(t, 1, grants)
+
+		tx.Instance.Status.ClientId = res.ClientId
+		tx.Instance.Status.ObjectId = res.ObjectId
+		tx.Instance.Status.ServicePrincipalId = res.ServicePrincipalId
+	})
+
+	t.Run("add and rotate credentials", func(t *testing.T) {
+		set, err := d.client.Credentials().Add(tx)
+		require.NoError(t, err)
+
+		app, _ := d.server.Application(tx.Instance.GetObjectId())
+		assert.Len(t, app.PasswordCredentials, 2)
+		assert.Len(t, app.KeyCredentials, 2)
+
+		validation, err := d.client.Credentials().Validate(tx, set)
+		require.NoError(t, err)
+		assert.True(t, validation.Valid)
+		assert.True(t, validation.ExpiresAt.After(time.Now()), "earliest end date of the credentials should be reported")
+
+		tx.Secrets = secrets.Secrets{
+			LatestCredentials: secrets.Credentials{Set: &set, Valid: validation.Valid},
+		}
+
+		rotated, err := d.client.Credentials().Rotate(tx)
+		require.NoError(t, err)
+		assert.Equal(t, set.Next, rotated.Current)
+		assert.NotEqual(t, set.Next.Certificate.KeyId, rotated.Next.Certificate.KeyId)
+		assert.NotEqual(t, set.Next.Password.KeyId, rotated.Next.Password.KeyId)
+
+		validation, err = d.client.Credentials().Validate(tx, rotated)
+		require.NoError(t, err)
+		assert.True(t, validation.Valid)
+
+		tx.Secrets.LatestCredentials.Set = &rotated
+		tx.Secrets.KeyIDs.Used.Certificate = []string{rotated.Current.Certificate.KeyId}
+		tx.Secrets.KeyIDs.Used.Password = []string{rotated.Current.Password.KeyId}
+
+		err = d.client.Credentials().DeleteUnused(tx)
+		require.NoError(t, err)
+
+		app, _ = d.server.Application(tx.Instance.GetObjectId())
+		assert.Len(t, app.PasswordCredentials, 2)
+
+		validation, err = d.client.Credentials().Validate(tx, rotated)
+		require.NoError(t, err)
+		assert.True(t, validation.Valid)
+
+		validation, err = d.client.Credentials().Validate(tx, set)
+		require.NoError(t, err)
+		assert.False(t, validation.Valid)
+	})
+
+	t.Run("update with pre-authorized application", func(t *testing.T) {
+		tx.Instance.Spec.PreAuthorizedApplications = []v1.AccessPolicyInboundRule{
+			{
+				AccessPolicyRule: v1.AccessPolicyRule{
+					Application: "other",
+					Namespace:   "test-namespace",
+					Cluster:     clusterName,
+				},
+			},
+			{
+				AccessPolicyRule: v1.AccessPolicyRule{
+					Application: "non-existent",
+					Namespace:   "test-namespace",
+					Cluster:     clusterName,
+				},
+			},
+		}
+
+		res, err := d.client.Update(tx)
+		require.NoError(t, err)
+		assert.Equal(t, result.OperationUpdated, res.Result)
+		assert.Len(t, res.PreAuthorizedApps.Valid, 2)
+		assert.Len(t, res.PreAuthorizedApps.Invalid, 1)
+
+		otherApp, err := d.client.Get(other)
+		require.NoError(t, err)
+
+		app, _ := d.server.Application(tx.Instance.GetObjectId())
+		preAuthorized := make([]string, 0)
+		for _, preAuthApp := range app.API.PreAuthorizedApplications {
+			preAuthorized = append(preAuthorized, *preAuthApp.AppID)
+		}
+		assert.ElementsMatch(t, []string{tx.Instance.GetClientId(), *otherApp.AppID}, preAuthorized)
+		assert.Len(t, d.server.AppRoleAssignments(tx.Instance.GetServicePrincipalId()), 3)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		err := d.client.Delete(tx)
+		require.NoError(t, err)
+
+		_, found := d.server.Application(tx.Instance.GetObjectId())
+		assert.False(t, found)
+		_, found = d.server.ServicePrincipal(tx.Instance.GetServicePrincipalId())
+		assert.False(t, found)
+
+		_, exists, err := d.client.Exists(tx)
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		err = d.client.Delete(tx)
+		assert.Error(t, err)
+	})
+}
+
+func TestClient_CredentialValidity(t *testing.T) {
+	d := setup(t)
+
+	tx := newTransaction(t, "test-app", func(*v1.AzureAdApplication) {})
+	tx.Options.Process.Secret.Certificate.Validity = 90 * 24 * time.Hour
+
+	res, err := d.client.Create(tx)
+	require.NoError(t, err)
+	tx.Instance.Status.ClientId = res.ClientId
+	tx.Instance.Status.ObjectId = res.ObjectId
+	tx.Instance.Status.ServicePrincipalId = res.ServicePrincipalId
+
+	_, err = d.client.Credentials().Add(tx)
+	require.NoError(t, err)
+
+	expected := time.Now().Add(tx.Options.Process.Secret.Certificate.Validity)
+
+	app, _ := d.server.Application(res.ObjectId)
+	require.Len(t, app.PasswordCredentials, 2)
+	require.Len(t, app.KeyCredentials, 2)
+	for _, cred := range app.PasswordCredentials {
+		assert.WithinDuration(t, expected, *cred.EndDateTime, time.Minute)
+	}
+	for _, cred := range app.KeyCredentials {
+		assert.WithinDuration(t, expected, *cred.EndDateTime, time.Minute)
+	}
+}
+
+func TestClient_WorkloadIdentity(t *testing.T) {
+	d := setup(t)
+
+	tx := newTransaction(t, "test-app", func(*v1.AzureAdApplication) {})
+	tx.Options.Process.Secret.CredentialMode = credentials.ModeWorkloadIdentity
+	tx.Options.Process.Secret.WorkloadIdentity = credentials.WorkloadIdentity{
+		Audience: "api://AzureADTokenExchange",
+		Issuer:   "https://issuer.example.com",
+		Subject:  credentials.ServiceAccountSubject("test-namespace", "test-app"),
+	}
+
+	res, err := d.client.Create(tx)
+	require.NoError(t, err)
+	tx.Instance.Status.ClientId = res.ClientId
+	tx.Instance.Status.ObjectId = res.ObjectId
+	tx.Instance.Status.ServicePrincipalId = res.ServicePrincipalId
+
+	creds := d.server.FederatedIdentityCredentials(res.ObjectId)
+	require.Len(t, creds, 1)
+	assert.Equal(t, "azurerator-test-cluster", *creds[0].Name)
+	assert.Equal(t, "https://issuer.example.com", *creds[0].Issuer)
+	assert.Equal(t, "system:serviceaccount:test-namespace:test-app", *creds[0].Subject)
+	assert.Equal(t, []string{"api://AzureADTokenExchange"}, creds[0].Audiences)
+
+	set, err := d.client.Credentials().Add(tx)
+	require.NoError(t, err)
+	assert.Equal(t, credentials.Set{}, set)
+
+	app, _ := d.server.Application(res.ObjectId)
+	assert.Empty(t, app.KeyCredentials)
+	assert.Empty(t, app.PasswordCredentials)
+	assert.Len(t, d.server.FederatedIdentityCredentials(res.ObjectId), 1)
+
+	validation, err := d.client.Credentials().Validate(tx, set)
+	require.NoError(t, err)
+	assert.True(t, validation.Valid)
+
+	t.Run("changed service account replaces federated identity credential", func(t *testing.T) {
+		tx.Options.Process.Secret.WorkloadIdentity.Subject = credentials.ServiceAccountSubject("test-namespace", "other")
+
+		validation, err := d.client.Credentials().Validate(tx, set)
+		require.NoError(t, err)
+		assert.False(t, validation.Valid)
+
+		_, err = d.client.Update(tx)
+		require.NoError(t, err)
+
+		creds := d.server.FederatedIdentityCredentials(res.ObjectId)
+		require.Len(t, creds, 1)
+		assert.Equal(t, "system:serviceaccount:test-namespace:other", *creds[0].Subject)
+	})
+
+	t.Run("switching mode removes federated identity credential", func(t *testing.T) {
+		tx.Options.Process.Secret.CredentialMode = credentials.ModeBoth
+
+		_, err := d.client.Credentials().Add(tx)
+		require.NoError(t, err)
+
+		assert.Empty(t, d.server.FederatedIdentityCredentials(res.ObjectId))
+		app, _ := d.server.Application(res.ObjectId)
+		assert.Len(t, app.KeyCredentials, 2)
+		assert.Len(t, app.PasswordCredentials, 2)
+	})
+}
+
+func TestClient_APIPermissions(t *testing.T) {
+	d := setup(t)
+
+	apiClientId := "6d4d7b60-9c7a-4f1b-a1e4-5b1c2e0b8a11"
+	apiId := d.server.AddAPI(apiClientId, "some-api", []string{"read"}, []string{"access"})
+
+	d.config.APIPermissions.Allowed = []string{
+		"application:graph/User.Read.All",
+		"application:graph/Group.Read.All",
+		"delegated:graph/*",
+		"delegated:" + apiClientId + "/*",
+	}
+
+	desired := func(values ...string) apipermissions.Permissions {
+		permissions, err := apipermissions.ParseAll(values)
+		require.NoError(t, err)
+		return permissions
+	}
+
+	tx := newTransaction(t, "test-app", func(*v1.AzureAdApplication) {})
+	tx.Options.Process.Azure.APIPermissions = desired(
+		"application:graph/User.Read.All",
+		"application:graph/Group.Read.All",
+		"delegated:graph/Mail.Send",
+		"delegated:"+apiClientId+"/access",
+	)
+
+	res, err := d.client.Create(tx)
+	require.NoError(t, err)
+	tx.Instance.Status.ClientId = res.ClientId
+	tx.Instance.Status.ObjectId = res.ObjectId
+	tx.Instance.Status.ServicePrincipalId = res.ServicePrincipalId
+
+	resourceAccess := func() map[string]int {
+		app, _ := d.server.Application(res.ObjectId)
+		result := make(map[string]int)
+		for _, access := range app.RequiredResourceAccess {
+			result[*access.ResourceAppID] = len(access.ResourceAccess)
+		}
+		return result
+	}
+	assignedRoles := func() []string {
+		sp, _ := d.server.ServicePrincipal(d.msGraphId)
+		roles := make([]string, 0)
+		for _, assignment := range d.server.PrincipalAppRoleAssignments(res.ServicePrincipalId) {
+			for _, role := range sp.AppRoles {
+				if *role.ID == *assignment.AppRoleID {
+					roles = append(roles, *role.Value)
+				}
+			}
+		}
+		return roles
+	}
+	grantedScopes := func() map[string]string {
+		result := make(map[string]string)
+		for _, grant := range d.server.OAuth2PermissionGrants() {
+			if *grant.ClientID == res.ServicePrincipalId {
+				result[*grant.ResourceID] = *grant.Scope
+			}
+		}
+		return result
+	}
+
+	assert.Equal(t, map[string]int{msGraphClientId: 6, apiClientId: 1}, resourceAccess())
+	assert.ElementsMatch(t, []string{"User.Read.All", "Group.Read.All"}, assignedRoles())
+	assert.Equal(t, map[string]string{
+		d.msGraphId: "GroupMember.Read.All Mail.Send User.Read openid",
+		apiId:       "access",
+	}, grantedScopes())
+
+	t.Run("removed permissions are revoked", func(t *testing.T) {
+		// permissions that are no longer allowed are not managed, and thus preserved
+		d.config.APIPermissions.Allowed = slices.DeleteFunc(slices.Clone(d.config.APIPermissions.Allowed), func(s string) bool {
+			return s == "application:graph/Group.Read.All"
+		})
+		tx.Options.Process.Azure.APIPermissions = nil
+
+		_, err := d.client.Update(tx)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]int{msGraphClientId: 3}, resourceAccess())
+		assert.Equal(t, []string{"Group.Read.All"}, assignedRoles())
+		assert.Equal(t, map[string]string{
+			d.msGraphId: "GroupMember.Read.All User.Read openid",
+		}, grantedScopes())
+	})
+
+	t.Run("undefined permission", func(t *testing.T) {
+		tx.Options.Process.Azure.APIPermissions = desired("delegated:graph/Undefined.Scope")
+
+		_, err := d.client.Update(tx)
+		assert.ErrorContains(t, err, "API permission 'delegated:graph/Undefined.Scope' is not defined by the resource")
+	})
+}
+
+func TestClient_ClaimsMappingPolicies(t *testing.T) {
+	d := setup(t)
+
+	namedPolicyId := d.server.AddClaimsMappingPolicy("named-policy")
+	d.config.Features.ClaimsMappingPolicies.Named = []string{"named=" + namedPolicyId}
+
+	tx := newTransaction(t, "test-app", func(*v1.AzureAdApplication) {})
+	tx.Options.Process.Azure.ClaimsMappingPolicy = options.ClaimsMappingPolicy{ID: d.policyId}
+
+	res, err := d.client.Create(tx)
+	require.NoError(t, err)
+	tx.Instance.Status.ClientId = res.ClientId
+	tx.Instance.Status.ObjectId = res.ObjectId
+	tx.Instance.Status.ServicePrincipalId = res.ServicePrincipalId
+
+	acceptMappedClaims := func() bool {
+		app, found := d.server.Application(res.ObjectId)
+		require.True(t, found)
+		return app.API != nil && app.API.AcceptMappedClaims != nil && *app.API.AcceptMappedClaims
+	}
+
+	assert.Equal(t, []string{d.policyId}, d.server.ClaimsMappingPolicies(res.ServicePrincipalId))
+	assert.True(t, acceptMappedClaims())
+
+	t.Run("named policy replaces default policy", func(t *testing.T) {
+		tx.Options.Process.Azure.ClaimsMappingPolicy = options.ClaimsMappingPolicy{Name: "named", ID: namedPolicyId}
+
+		_, err := d.client.Update(tx)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{namedPolicyId}, d.server.ClaimsMappingPolicies(res.ServicePrincipalId))
+		assert.True(t, acceptMappedClaims())
+	})
+
+	t.Run("opting out removes policy", func(t *testing.T) {
+		tx.Options.Process.Azure.ClaimsMappingPolicy = options.ClaimsMappingPolicy{Name: config.ClaimsMappingPolicyNone}
+
+		_, err := d.client.Update(tx)
+		require.NoError(t, err)
+
+		assert.Empty(t, d.server.ClaimsMappingPolicies(res.ServicePrincipalId))
+		assert.False(t, acceptMappedClaims())
+	})
+
+	t.Run("policy that is no longer configured is preserved", func(t *testing.T) {
+		tx.Options.Process.Azure.ClaimsMappingPolicy = options.ClaimsMappingPolicy{Name: "named", ID: namedPolicyId}
+		_, err := d.client.Update(tx)
+		require.NoError(t, err)
+
+		d.config.Features.ClaimsMappingPolicies.Named = nil
+		tx.Options.Process.Azure.ClaimsMappingPolicy = options.ClaimsMappingPolicy{Name: config.ClaimsMappingPolicyNone}
+
+		_, err = d.client.Update(tx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{namedPolicyId}, d.server.ClaimsMappingPolicies(res.ServicePrincipalId))
+	})
+}
+
+func TestClient_APISettings(t *testing.T) {
+	d := setup(t)
+
+	policyId := d.server.AddTokenLifetimePolicy("short-lived")
+	d.config.APISettings.TokenLifetimePolicies = []string{"short-lived=" + policyId}
+
+	knownClientId := uuid.New().String()
+	tx := newTransaction(t, "test-app", func(*v1.AzureAdApplication) {})
+	tx.Options.Process.Azure.APISettings = options.APISettings{
+		AccessTokenVersion:      1,
+		KnownClientApplications: []string{knownClientId},
+		TokenLifetimePolicy:     options.TokenLifetimePolicy{Name: "short-lived", ID: policyId},
+	}
+
+	res, err := d.client.Create(tx)
+	require.NoError(t, err)
+	tx.Instance.Status.ClientId = res.ClientId
+	tx.Instance.Status.ObjectId = res.ObjectId
+	tx.Instance.Status.ServicePrincipalId = res.ServicePrincipalId
+
+	api := func() *msgraph.APIApplication {
+		app, found := d.server.Application(res.ObjectId)
+		require.True(t, found)
+		require.NotNil(t, app.API)
+		return app.API
+	}
+
+	assert.Equal(t, 1, res.AccessTokenVersion)
+	assert.Equal(t, 1, *api().RequestedAccessTokenVersion)
+	assert.Equal(t, []msgraph.UUID{msgraph.UUID(knownClientId)}, api().KnownClientApplications)
+	assert.Equal(t, []string{policyId}, d.server.TokenLifetimePolicies(res.ObjectId))
+
+	t.Run("defaults are restored when settings are removed", func(t *testing.T) {
+		// known client applications added by other means are preserved
+		externalClientId := uuid.New().String()
+		d.server.PatchApplication(res.ObjectId, map[string]any{
+			"api": map[string]any{
+				"knownClientApplications": []any{knownClientId, externalClientId},
+			},
+		})
+
+		tx.Options.Process.Azure.APISettings = options.APISettings{}
+
+		res, err := d.client.Update(tx)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, res.AccessTokenVersion)
+		assert.Equal(t, 2, *api().RequestedAccessTokenVersion)
+		assert.Equal(t, []msgraph.UUID{msgraph.UUID(externalClientId)}, api().KnownClientApplications)
+
+		// known client applications that were applied by the operator are cleared, even if they were added by other
+		// means before
+		tx.Options.Process.Azure.APISettings = options.APISettings{KnownClientApplications: []string{externalClientId}}
+		_, err = d.client.Update(tx)
+		require.NoError(t, err)
+
+		tx.Options.Process.Azure.APISettings = options.APISettings{}
+		_, err = d.client.Update(tx)
+		require.NoError(t, err)
+		assert.Empty(t, api().KnownClientApplications)
+		assert.Empty(t, d.server.TokenLifetimePolicies(res.ObjectId))
+	})
+}
+
+func TestClient_IdentifierUris(t *testing.T) {
+	d := setup(t)
+
+	tx := newTransaction(t, "test-app", func(*v1.AzureAdApplication) {})
+	tx.Options.Process.Azure.IdentifierUris = options.IdentifierUris{"https://test-app.example.com"}
+
+	res, err := d.client.Create(tx)
+	require.NoError(t, err)
+	tx.Instance.Status.ClientId = res.ClientId
+	tx.Instance.Status.ObjectId = res.ObjectId
+	tx.Instance.Status.ServicePrincipalId = res.ServicePrincipalId
+
+	application := func() msgraph.Application {
+		app, found := d.server.Application(res.ObjectId)
+		require.True(t, found)
+		return app
+	}
+	identifierUris := func() []string {
+		return application().IdentifierUris
+	}
+
+	defaults := []string{
+		"api://" + res.ClientId,
+		"api://test-cluster.test-namespace.test-app",
+	}
+	assert.ElementsMatch(t, append(slices.Clone(defaults), "https://test-app.example.com"), identifierUris())
+	assert.Contains(t, application().Tags, "azurerator_identifier_uri:https:%2F%2Ftest-app.example.com")
+
+	t.Run("uris that are no longer desired are removed", func(t *testing.T) {
+		// uris added by other means have no marker, and are preserved
+		d.server.PatchApplication(res.ObjectId, map[string]any{
+			"identifierUris": append(identifierUris(), "https://other.example.com"),
+		})
+		tx.Options.Process.Azure.IdentifierUris = nil
+
+		_, err := d.client.Update(tx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, append(slices.Clone(defaults), "https://other.example.com"), identifierUris())
+		assert.ElementsMatch(t, []string{"azurerator_appreg", "WindowsAzureActiveDirectoryIntegratedApp"}, application().Tags)
+	})
+}
+
+func TestClient_PlatformSettings(t *testing.T) {
+	d := setup(t)
+
+	tx := newTransaction(t, "test-app", func(app *v1.AzureAdApplication) {
+		app.Spec.LogoutUrl = "https://test.host/logout"
+		app.Spec.ReplyUrls = []v1.AzureAdReplyUrl{{Url: "https://test.host/callback"}}
+	})
+	tx.Options.Process.Azure.PlatformSettings = options.PlatformSettings{
+		HomePageUrl:              "https://test.host",
+		ImplicitGrant:            options.ImplicitGrant{IDToken: true},
+		PublicClientRedirectUris: []string{"http://localhost"},
+		FallbackPublicClient:     true,
+	}
+
+	res, err := d.client.Create(tx)
+	require.NoError(t, err)
+	tx.Instance.Status.ClientId = res.ClientId
+	tx.Instance.Status.ObjectId = res.ObjectId
+	tx.Instance.Status.ServicePrincipalId = res.ServicePrincipalId
+
+	application := func() msgraph.Application {
+		app, found := d.server.Application(res.ObjectId)
+		require.True(t, found)
+		require.NotNil(t, app.Web)
+		require.NotNil(t, app.Web.ImplicitGrantSettings)
+		return app
+	}
+
+	app := application()
+	assert.Equal(t, []string{"https://test.host/callback"}, app.Web.RedirectUris)
+	assert.Equal(t, "https://test.host/logout", *app.Web.LogoutURL)
+	assert.Equal(t, "https://test.host", *app.Web.HomePageURL)
+	assert.True(t, *app.Web.ImplicitGrantSettings.EnableIDTokenIssuance)
+	assert.False(t, *app.Web.ImplicitGrantSettings.EnableAccessTokenIssuance)
+	require.NotNil(t, app.PublicClient)
+	assert.Equal(t, []string{"http://localhost"}, app.PublicClient.RedirectUris)
+	assert.True(t, *app.IsFallbackPublicClient)
+
+	t.Run("removed settings are cleared", func(t *testing.T) {
+		tx.Instance.Spec.LogoutUrl = ""
+		tx.Options.Process.Azure.PlatformSettings = options.PlatformSettings{}
+
+		_, err := d.client.Update(tx)
+		require.NoError(t, err)
+
+		app := application()
+		assert.Equal(t, []string{"https://test.host/callback"}, app.Web.RedirectUris)
+		assert.Nil(t, app.Web.LogoutURL)
+		assert.Nil(t, app.Web.HomePageURL)
+		assert.False(t, *app.Web.ImplicitGrantSettings.EnableIDTokenIssuance)
+		assert.Empty(t, app.PublicClient.RedirectUris)
+		assert.False(t, *app.IsFallbackPublicClient)
+		assert.ElementsMatch(t, []string{"azurerator_appreg", "WindowsAzureActiveDirectoryIntegratedApp"}, app.Tags)
+	})
+
+	t.Run("settings that were not set by the operator are preserved", func(t *testing.T) {
+		d.server.PatchApplication(res.ObjectId, map[string]any{
+			"web":                    map[string]any{"homePageUrl": "https://other.host"},
+			"isFallbackPublicClient": true,
+		})
+
+		_, err := d.client.Update(tx)
+		require.NoError(t, err)
+
+		app := application()
+		assert.Equal(t, "https://other.host", *app.Web.HomePageURL)
+		assert.True(t, *app.IsFallbackPublicClient)
+	})
+}
+
+func TestClient_Owners(t *testing.T) {
+	d := setup(t)
+
+	userId := d.server.AddUser("some.user@example.com")
+	memberId := d.server.AddUser("some.member@example.com")
+	nestedGroupId := d.server.AddGroup("some-nested-group")
+	nestedMemberId := d.server.AddUser("some.nested.member@example.com")
+	manualId := d.server.AddUser("some.manual.owner@example.com")
+	d.server.AddGroupMember(d.groupId, memberId)
+	d.server.AddGroupMember(d.groupId, nestedGroupId)
+	d.server.AddGroupMember(nestedGroupId, nestedMemberId)
+
+	tx := newTransaction(t, "test-app", func(*v1.AzureAdApplication) {})
+	tx.Options.Process.Azure.Owners = options.Owners{"some.user@example.com", "unknown.user@example.com", d.groupId}
+
+	res, err := d.client.Create(tx)
+	require.NoError(t, err)
+	tx.Instance.Status.ClientId = res.ClientId
+	tx.Instance.Status.ObjectId = res.ObjectId
+	tx.Instance.Status.ServicePrincipalId = res.ServicePrincipalId
+
+	objectIds := []string{res.ObjectId, res.ServicePrincipalId}
+	for _, objectId := range objectIds {
+		assert.ElementsMatch(t, []string{d.operatorId, userId, memberId, nestedMemberId}, d.server.Owners(objectId))
+
+		// owners added by other means are never removed
+		d.server.AddOwner(objectId, manualId)
+	}
+
+	t.Run("owners that are no longer desired are removed", func(t *testing.T) {
+		tx.Options.Process.Azure.Owners = options.Owners{"some.user@example.com"}
+
+		_, err := d.client.Update(tx)
+		require.NoError(t, err)
+
+		for _, objectId := range objectIds {
+			assert.ElementsMatch(t, []string{d.operatorId, userId, manualId}, d.server.Owners(objectId))
+		}
+	})
+
+	t.Run("unresolved group is an error", func(t *testing.T) {
+		owners := tx.Options.Process.Azure.Owners
+		tx.Options.Process.Azure.Owners = options.Owners{"some.user@example.com", "unknown-group"}
+		t.Cleanup(func() {
+			tx.Options.Process.Azure.Owners = owners
+		})
+
+		_, err := d.client.Update(tx)
+		assert.ErrorContains(t, err, "group 'unknown-group' does not match any group")
+
+		for _, objectId := range objectIds {
+			assert.ElementsMatch(t, []string{d.operatorId, userId, manualId}, d.server.Owners(objectId))
+		}
+	})
+
+	t.Run("operator remains owner when all owners are removed", func(t *testing.T) {
+		tx.Options.Process.Azure.Owners = nil
+
+		_, err := d.client.Update(tx)
+		require.NoError(t, err)
+
+		for _, objectId := range objectIds {
+			assert.ElementsMatch(t, []string{d.operatorId, manualId}, d.server.Owners(objectId))
+		}
+	})
+}
+
+func TestClient_GroupRole(t *testing.T) {
+	d := setup(t)
+
+	tx := newTransaction(t, "test-app", func(app *v1.AzureAdApplication) {
+		app.Spec.Claims = &v1.AzureAdClaims{
+			Groups: []v1.AzureAdGroup{{ID: d.groupId}},
+		}
+	})
+
+	res, err := d.client.Create(tx)
+	require.NoError(t, err)
+	tx.Instance.Status.ClientId = res.ClientId
+	tx.Instance.Status.ObjectId = res.ObjectId
+	tx.Instance.Status.ServicePrincipalId = res.ServicePrincipalId
+
+	groupRoles := func() []string {
+		roles := make([]string, 0)
+		for _, assignment := range d.server.AppRoleAssignments(res.ServicePrincipalId) {
+			if *assignment.PrincipalType == "Group" {
+				roles = append(roles, string(*assignment.AppRoleID))
+			}
+		}
+		return roles
+	}
+
+	assert.Equal(t, []string{permissions.DefaultGroupRoleId}, groupRoles())
+
+	t.Run("user-assignable role migrates groups to dedicated group role", func(t *testing.T) {
+		app, found := d.server.Application(res.ObjectId)
+		require.True(t, found)
+
+		userRole := approle.NewGenerateId("user-role")
+		userRole.AllowedMemberTypes = []string{"User"}
+		patchApplication(t, d, res.ObjectId, map[string]any{
+			"appRoles": append(app.AppRoles, userRole),
+		})
+
+		_, err := d.client.Update(tx)
+		require.NoError(t, err)
+
+		app, found = d.server.Application(res.ObjectId)
+		require.True(t, found)
+		idx := slices.IndexFunc(app.AppRoles, func(role msgraph.AppRole) bool {
+			return *role.ID == msgraph.UUID(permissions.GroupRoleId)
+		})
+		require.GreaterOrEqual(t, idx, 0)
+		assert.Equal(t, []string{"User"}, app.AppRoles[idx].AllowedMemberTypes)
+		assert.True(t, *app.AppRoles[idx].IsEnabled)
+
+		assert.Equal(t, []string{permissions.GroupRoleId}, groupRoles())
+	})
+
+	t.Run("dedicated group role is kept", func(t *testing.T) {
+		_, err := d.client.Update(tx)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{permissions.GroupRoleId}, groupRoles())
+	})
+}
+
+func patchApplication(t *testing.T, d directory, objectId string, patch map[string]any) {
+	body, err := json.Marshal(patch)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPatch, d.server.BaseURL()+"/applications/"+objectId, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestClient_GroupRoles(t *testing.T) {
+	d := setup(t)
+
+	otherGroupId := d.server.AddGroup("other-group")
+
+	mappings := func(values ...string) grouproles.Mappings {
+		result, err := grouproles.ParseAll(values)
+		require.NoError(t, err)
+		return result
+	}
+
+	tx := newTransaction(t, "test-app", func(app *v1.AzureAdApplication) {
+		app.Spec.Claims = &v1.AzureAdClaims{
+			Groups: []v1.AzureAdGroup{{ID: d.groupId}},
+		}
+	})
+	tx.Options.Process.Azure.GroupRoles = mappings("admin="+d.groupId, "reader="+d.groupId, "reader="+otherGroupId)
+
+	res, err := d.client.Create(tx)
+	require.NoError(t, err)
+	tx.Instance.Status.ClientId = res.ClientId
+	tx.Instance.Status.ObjectId = res.ObjectId
+	tx.Instance.Status.ServicePrincipalId = res.ServicePrincipalId
+
+	appRoles := func() map[string]msgraph.AppRole {
+		app, found := d.server.Application(res.ObjectId)
+		require.True(t, found)
+
+		result := make(map[string]msgraph.AppRole)
+		for _, role := range app.AppRoles {
+			result[*role.Value] = role
+		}
+		return result
+	}
+	groupRoles := func() map[string][]string {
+		names := make(map[msgraph.UUID]string)
+		for name, role := range appRoles() {
+			names[*role.ID] = name
+		}
+
+		result := make(map[string][]string)
+		for _, assignment := range d.server.AppRoleAssignments(res.ServicePrincipalId) {
+			if *assignment.PrincipalType == "Group" {
+				groupId := string(*assignment.PrincipalID)
+				result[groupId] = append(result[groupId], names[*assignment.AppRoleID])
+				slices.Sort(result[groupId])
+			}
+		}
+		return result
+	}
+
+	roles := appRoles()
+	assert.Equal(t, []string{"User"}, roles["admin"].AllowedMemberTypes)
+	assert.Equal(t, []string{"User"}, roles["reader"].AllowedMemberTypes)
+	assert.Equal(t, []string{"User"}, roles[permissions.DefaultGroupRoleValue].AllowedMemberTypes)
+	assert.Equal(t, map[string][]string{
+		d.groupId:    {"admin", "defaultrole", "reader"},
+		otherGroupId: {"defaultrole", "reader"},
+	}, groupRoles())
+
+	t.Run("removed mappings are revoked", func(t *testing.T) {
+		tx.Options.Process.Azure.GroupRoles = mappings("reader=" + d.groupId)
+
+		_, err := d.client.Update(tx)
+		require.NoError(t, err)
+
+		// roles are disabled after revoking their assignments, and removed once disabled
+		roles := appRoles()
+		assert.NotContains(t, roles, "admin")
+		assert.True(t, *roles["reader"].IsEnabled)
+		assert.Equal(t, map[string][]string{
+			d.groupId: {"defaultrole", "reader"},
+		}, groupRoles())
+	})
+
+	t.Run("groups referenced by both ID and name are assigned once", func(t *testing.T) {
+		tx.Options.Process.Azure.GroupRoles = mappings("admin=some-group", "reader="+d.groupId)
+
+		res, err := d.client.Update(tx)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string][]string{
+			d.groupId: {"admin", "defaultrole", "reader"},
+		}, groupRoles())
+
+		assigned := make([]string, 0)
+		for _, group := range res.Groups.Assigned {
+			assigned = append(assigned, group.ObjectId)
+		}
+		assert.Equal(t, []string{d.groupId}, assigned)
+	})
+}
+
+func TestClient_GroupsByName(t *testing.T) {
+	d := setup(t)
+
+	byDisplayName := d.server.AddGroup("Team O'Brien")
+	byMailNickname := d.server.AddGroup("Team Names")
+	d.server.AddGroup("Team Duplicate")
+	d.server.AddGroup("Team Duplicate")
+
+	tx := newTransaction(t, "test-app", func(app *v1.AzureAdApplication) {
+		app.Spec.Claims = &v1.AzureAdClaims{
+			Groups: []v1.AzureAdGroup{
+				{ID: d.groupId},
+				{ID: "Team O'Brien"},
+				{ID: "team-names"},
+				{ID: "Team Names"},
+				{ID: "Team Duplicate"},
+				{ID: "team-missing"},
+			},
+		}
+	})
+
+	res, err := d.client.Create(tx)
+	require.NoError(t, err)
+
+	principals := make([]string, 0)
+	for _, assignment := range d.server.AppRoleAssignments(res.ServicePrincipalId) {
+		if *assignment.PrincipalType == "Group" {
+			principals = append(principals, string(*assignment.PrincipalID))
+		}
+	}
+	assert.ElementsMatch(t, []string{d.groupId, byDisplayName, byMailNickname}, principals)
+
+	assert.Equal(t, []result.Group{
+		{Reference: d.groupId, ObjectId: d.groupId, Name: "some-group"},
+		{Reference: "Team O'Brien", ObjectId: byDisplayName, Name: "Team O'Brien"},
+		{Reference: "team-names", ObjectId: byMailNickname, Name: "Team Names"},
+	}, res.Groups.Assigned)
+	assert.Equal(t, []result.Group{
+		{Reference: "Team Duplicate", Reason: "is ambiguous; matches 2 groups"},
+		{Reference: "team-missing", Reason: "does not match any group"},
+	}, res.Groups.Skipped)
+
+	t.Run("skipped group is assigned once it exists", func(t *testing.T) {
+		exists, err := d.client.GroupExists(tx.Ctx, "team-missing")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		missing := d.server.AddGroup("Team Missing")
+
+		exists, err = d.client.GroupExists(tx.Ctx, "team-missing")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		tx.Instance.Status.ClientId = res.ClientId
+		tx.Instance.Status.ObjectId = res.ObjectId
+		tx.Instance.Status.ServicePrincipalId = res.ServicePrincipalId
+
+		res, err := d.client.Update(tx)
+		require.NoError(t, err)
+		assert.Contains(t, res.Groups.Assigned, result.Group{Reference: "team-missing", ObjectId: missing, Name: "Team Missing"})
+		assert.Equal(t, []result.Group{
+			{Reference: "Team Duplicate", Reason: "is ambiguous; matches 2 groups"},
+		}, res.Groups.Skipped)
+	})
+}
@@ -23,52 +23,71 @@ func (c credentialsClient) PasswordCredential() passwordcredential.PasswordCrede
 	return passwordcredential.NewPasswordCredential(c)
 }
 
-// Add adds credentials for an existing AAD application
+// Add adds credentials for an existing AAD application.
+// Only the kinds of credentials enabled by the credential mode are added; any credentials of disabled kinds are purged.
+// For workload identity federation, the federated identity credential is registered and an empty set is returned.
 func (c credentialsClient) Add(tx transaction.Transaction) (credentials.Set, error) {
-	// sleep to prevent concurrent modification error from Microsoft
-	time.Sleep(c.DelayIntervalBetweenModifications())
-
-	currPasswordCredential, err := c.PasswordCredential().Add(tx)
-	if err != nil {
-		return credentials.Set{}, fmt.Errorf("adding current password credential: %w", err)
+	mode := tx.Options.Process.Secret.CredentialMode
+	set := credentials.Set{}
+
+	if mode.Password() {
+		// sleep to prevent concurrent modification error from Microsoft
+		time.Sleep(c.DelayIntervalBetweenModifications())
+
+		currPasswordCredential, err := c.PasswordCredential().Add(tx)
+		if err != nil {
+			return credentials.Set{}, fmt.Errorf("adding current password credential: %w", err)
+		}
+
+		time.Sleep(c.DelayIntervalBetweenModifications())
+
+		nextPasswordCredential, err := c.PasswordCredential().Add(tx)
+		if err != nil {
+			return credentials.Set{}, fmt.Errorf("adding next password credential: %w", err)
+		}
+
+		set.Current.Password = credentials.Password{
+			KeyId:        string(*currPasswordCredential.KeyID),
+			ClientSecret: *currPasswordCredential.SecretText,
+		}
+		set.Next.Password = credentials.Password{
+			KeyId:        string(*nextPasswordCredential.KeyID),
+			ClientSecret: *nextPasswordCredential.SecretText,
+		}
+	} else if err := c.PasswordCredential().Purge(tx); err != nil {
+		return credentials.Set{}, fmt.Errorf("purging password credentials: %w", err)
 	}
 
 	time.Sleep(c.DelayIntervalBetweenModifications())
 
-	nextPasswordCredential, err := c.PasswordCredential().Add(tx)
-	if err != nil {
-		return credentials.Set{}, fmt.Errorf("adding next password credential: %w", err)
+	if mode.Certificate() {
+		keyCredentialSet, err := c.KeyCredential().Add(tx)
+		if err != nil {
+			return credentials.Set{}, fmt.Errorf("adding key credential set: %w", err)
+		}
+
+		set.Current.Certificate = credentials.Certificate{
+			KeyId: string(*keyCredentialSet.Current.KeyCredential.KeyID),
+			Jwk:   keyCredentialSet.Current.Jwk,
+		}
+		set.Next.Certificate = credentials.Certificate{
+			KeyId: string(*keyCredentialSet.Next.KeyCredential.KeyID),
+			Jwk:   keyCredentialSet.Next.Jwk,
+		}
+	} else if err := c.KeyCredential().Purge(tx); err != nil {
+		return credentials.Set{}, fmt.Errorf("purging key credentials: %w", err)
 	}
 
-	time.Sleep(c.DelayIntervalBetweenModifications())
-
-	keyCredentialSet, err := c.KeyCredential().Add(tx)
-	if err != nil {
-		return credentials.Set{}, fmt.Errorf("adding key credential set: %w", err)
+	if mode.WorkloadIdentity() {
+		err := c.Application().FederatedIdentityCredentials().Process(tx)
+		if err != nil {
+			return credentials.Set{}, fmt.Errorf("processing federated identity credentials: %w", err)
+		}
+	} else if err := c.Application().FederatedIdentityCredentials().Purge(tx); err != nil {
+		return credentials.Set{}, fmt.Errorf("purging federated identity credentials: %w", err)
 	}
 
-	return credentials.Set{
-		Current: credentials.Credentials{
-			Certificate: credentials.Certificate{
-				KeyId: string(*keyCredentialSet.Current.KeyCredential.KeyID),
-				Jwk:   keyCredentialSet.Current.Jwk,
-			},
-			Password: credentials.Password{
-				KeyId:        string(*currPasswordCredential.KeyID),
-				ClientSecret: *currPasswordCredential.SecretText,
-			},
-		},
-		Next: credentials.Credentials{
-			Certificate: credentials.Certificate{
-				KeyId: string(*keyCredentialSet.Next.KeyCredential.KeyID),
-				Jwk:   keyCredentialSet.Next.Jwk,
-			},
-			Password: credentials.Password{
-				KeyId:        string(*nextPasswordCredential.KeyID),
-				ClientSecret: *nextPasswordCredential.SecretText,
-			},
-		},
-	}, nil
+	return set, nil
 }
 
 // DeleteExpired deletes all expired credentials for the application in Azure AD.
@@ -87,13 +106,25 @@ func (c credentialsClient) DeleteExpired(tx transaction.Transaction) error {
 }
 
 // DeleteUnused deletes unused credentials for an existing AAD application.
+// Credentials of kinds disabled by the credential mode are all unused, and are thus purged.
 func (c credentialsClient) DeleteUnused(tx transaction.Transaction) error {
-	err := c.KeyCredential().DeleteUnused(tx)
+	mode := tx.Options.Process.Secret.CredentialMode
+
+	var err error
+	if mode.Certificate() {
+		err = c.KeyCredential().DeleteUnused(tx)
+	} else {
+		err = c.KeyCredential().Purge(tx)
+	}
 	if err != nil {
 		return fmt.Errorf("deleting unused key credentials: %w", err)
 	}
 
-	err = c.PasswordCredential().DeleteUnused(tx)
+	if mode.Password() {
+		err = c.PasswordCredential().DeleteUnused(tx)
+	} else {
+		err = c.PasswordCredential().Purge(tx)
+	}
 	if err != nil {
 		return fmt.Errorf("deleting unused password credentials: %w", err)
 	}
@@ -113,53 +144,97 @@ func (c credentialsClient) Purge(tx transaction.Transaction) error {
 		return fmt.Errorf("purging key credentials: %w", err)
 	}
 
+	err = c.Application().FederatedIdentityCredentials().Purge(tx)
+	if err != nil {
+		return fmt.Errorf("purging federated identity credentials: %w", err)
+	}
+
 	return nil
 }
 
-// Rotate rotates credentials for an existing AAD application
+// Rotate rotates credentials for an existing AAD application.
+// Only the kinds of credentials enabled by the credential mode are rotated.
 func (c credentialsClient) Rotate(tx transaction.Transaction) (credentials.Set, error) {
-	time.Sleep(c.DelayIntervalBetweenModifications()) // sleep to prevent concurrent modification error from Microsoft
+	mode := tx.Options.Process.Secret.CredentialMode
+	next := credentials.Credentials{}
 
-	nextPasswordCredential, err := c.PasswordCredential().Rotate(tx)
-	if err != nil {
-		return credentials.Set{}, fmt.Errorf("rotating password credential: %w", err)
+	if mode.Password() {
+		time.Sleep(c.DelayIntervalBetweenModifications()) // sleep to prevent concurrent modification error from Microsoft
+
+		nextPasswordCredential, err := c.PasswordCredential().Rotate(tx)
+		if err != nil {
+			return credentials.Set{}, fmt.Errorf("rotating password credential: %w", err)
+		}
+
+		next.Password = credentials.Password{
+			KeyId:        string(*nextPasswordCredential.KeyID),
+			ClientSecret: *nextPasswordCredential.SecretText,
+		}
 	}
 
-	time.Sleep(c.DelayIntervalBetweenModifications())
+	if mode.Certificate() {
+		time.Sleep(c.DelayIntervalBetweenModifications())
 
-	nextKeyCredential, nextJwk, err := c.KeyCredential().Rotate(tx)
-	if err != nil {
-		return credentials.Set{}, fmt.Errorf("rotating key credential: %w", err)
+		nextKeyCredential, nextJwk, err := c.KeyCredential().Rotate(tx)
+		if err != nil {
+			return credentials.Set{}, fmt.Errorf("rotating key credential: %w", err)
+		}
+
+		next.Certificate = credentials.Certificate{
+			KeyId: string(*nextKeyCredential.KeyID),
+			Jwk:   *nextJwk,
+		}
 	}
 
 	return credentials.Set{
 		Current: tx.Secrets.LatestCredentials.Set.Next,
-		Next: credentials.Credentials{
-			Certificate: credentials.Certificate{
-				KeyId: string(*nextKeyCredential.KeyID),
-				Jwk:   *nextJwk,
-			},
-			Password: credentials.Password{
-				KeyId:        string(*nextPasswordCredential.KeyID),
-				ClientSecret: *nextPasswordCredential.SecretText,
-			},
-		},
+		Next:    next,
 	}, nil
 }
 
 // Validate validates the given credentials set against the actual state for the application in Azure AD.
-func (c credentialsClient) Validate(tx transaction.Transaction, existing credentials.Set) (bool, error) {
-	validPasswordCredentials, err := c.PasswordCredential().Validate(tx, existing)
-	if err != nil {
-		return false, fmt.Errorf("validating password credentials: %w", err)
+// The set is invalid if it does not hold exactly the kinds of credentials enabled by the credential mode.
+func (c credentialsClient) Validate(tx transaction.Transaction, existing credentials.Set) (credentials.Validation, error) {
+	mode := tx.Options.Process.Secret.CredentialMode
+	if !mode.Matches(existing) {
+		return credentials.Invalid, nil
 	}
 
-	validateKeyCredentials, err := c.KeyCredential().Validate(tx, existing)
-	if err != nil {
-		return false, fmt.Errorf("validating key credentials: %w", err)
+	validation := credentials.Validation{Valid: true}
+
+	if mode.WorkloadIdentity() {
+		valid, err := c.Application().FederatedIdentityCredentials().Validate(tx)
+		if err != nil {
+			return credentials.Invalid, fmt.Errorf("validating federated identity credentials: %w", err)
+		}
+		if !valid {
+			return credentials.Invalid, nil
+		}
+	}
+
+	if mode.Password() {
+		passwords, err := c.PasswordCredential().Validate(tx, existing)
+		if err != nil {
+			return credentials.Invalid, fmt.Errorf("validating password credentials: %w", err)
+		}
+		if !passwords.Valid {
+			return credentials.Invalid, nil
+		}
+		validation = validation.Merge(passwords)
+	}
+
+	if mode.Certificate() {
+		keys, err := c.KeyCredential().Validate(tx, existing)
+		if err != nil {
+			return credentials.Invalid, fmt.Errorf("validating key credentials: %w", err)
+		}
+		if !keys.Valid {
+			return credentials.Invalid, nil
+		}
+		validation = validation.Merge(keys)
 	}
 
-	return validPasswordCredentials && validateKeyCredentials, nil
+	return validation, nil
 }
 
 func NewCredentials(client Client) azure.Credentials {
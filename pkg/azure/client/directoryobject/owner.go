@@ -8,6 +8,9 @@ import (
 	msgraph "github.com/nais/msgraph.go/v1.0"
 )
 
+// ODataTypeUser is the OData type of directory objects that are users.
+const ODataTypeUser = "#microsoft.graph.user"
+
 type OwnerPayload struct {
 	Content string `json:"@odata.id"`
 }
@@ -23,3 +26,9 @@ func ContainsOwner(owners []msgraph.DirectoryObject, id azure.ServicePrincipalId
 		return obj.ID != nil && *obj.ID == id
 	})
 }
+
+// IsUser returns true if the given directory object is a user.
+func IsUser(obj msgraph.DirectoryObject) bool {
+	odataType, ok := obj.GetAdditionalData("@odata.type")
+	return ok && odataType == ODataTypeUser
+}
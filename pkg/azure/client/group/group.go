@@ -6,28 +6,45 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	cache "github.com/Code-Hex/go-generics-cache"
+	"github.com/google/uuid"
+	naisiov1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	"github.com/nais/msgraph.go/jsonx"
 	msgraph "github.com/nais/msgraph.go/v1.0"
+	log "github.com/sirupsen/logrus"
 
 	"github.com/nais/azureator/pkg/azure"
 	"github.com/nais/azureator/pkg/azure/client/application/approle"
+	"github.com/nais/azureator/pkg/azure/client/directoryobject"
 	"github.com/nais/azureator/pkg/azure/client/serviceprincipal"
 	"github.com/nais/azureator/pkg/azure/permissions"
 	"github.com/nais/azureator/pkg/azure/resource"
+	"github.com/nais/azureator/pkg/azure/result"
+	"github.com/nais/azureator/pkg/azure/util"
 	"github.com/nais/azureator/pkg/transaction"
 )
 
-const cacheExpiration = 24 * time.Hour
+const (
+	cacheExpiration = 24 * time.Hour
+	// nameCacheExpiration is shorter than cacheExpiration, as groups may be renamed or recreated with the same name.
+	nameCacheExpiration = 10 * time.Minute
+)
 
-var groupCache = cache.New[azure.ObjectId, *msgraph.Group]()
+var (
+	groupCache     = cache.New[azure.ObjectId, *msgraph.Group]()
+	groupNameCache = cache.New[string, *msgraph.Group]()
+)
 
 var ErrBadRequest = errors.New("BadRequest")
 
 type Groups interface {
-	Process(tx transaction.Transaction) error
+	Process(tx transaction.Transaction, app *msgraph.Application) (*result.Groups, error)
+	Exists(ctx context.Context, reference string) (bool, error)
+	UserMembers(tx transaction.Transaction, reference string) ([]azure.ObjectId, string, error)
 }
 
 type Client interface {
@@ -43,57 +60,165 @@ func NewGroup(client Client) Groups {
 	return group{Client: client}
 }
 
-func (g group) Process(tx transaction.Transaction) error {
+// Process assigns the desired groups to the application's service principal. Groups are assigned to the default group
+// role, or to the dedicated group role if the application has AppRoles that are assignable to users. Assignments to the
+// other group role are revoked, which migrates existing assignments when the dedicated group role is introduced.
+// Groups that are mapped to user roles are additionally assigned to these roles, and assignments for removed mappings
+// are revoked. The result lists the assigned groups, and the groups that were skipped along with the reason.
+func (g group) Process(tx transaction.Transaction, app *msgraph.Application) (*result.Groups, error) {
 	servicePrincipalId := tx.Instance.GetServicePrincipalId()
 
-	groups, err := g.getGroups(tx)
+	groups, groupsResult, err := g.getGroups(tx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// TODO(tronghn): if there exists an AppRole where AllowedMemberTypes includes "User", then we cannot use the default AppRole `00000000-0000-0000-0000-000000000000`.
-	//  Should ensure that a default group role is created and used for this case.
 	roles := make(permissions.Permissions)
-	roles.Add(permissions.FromAppRole(approle.DefaultGroupRole()))
+	roles.Add(permissions.FromAppRole(approle.GroupRoleFor(*app)))
+
+	userRoles := tx.Options.Process.Azure.GroupRoles.Roles()
+	for _, role := range app.AppRoles {
+		if role.Value != nil && slices.Contains(userRoles, *role.Value) && role.IsEnabled != nil && *role.IsEnabled {
+			roles.Add(permissions.FromAppRole(role))
+		}
+	}
 
 	err = g.AppRoleAssignments(tx, servicePrincipalId).
 		ProcessForGroups(groups, roles)
 	if err != nil {
-		return fmt.Errorf("updating app roles for groups: %w", err)
+		return nil, fmt.Errorf("updating app roles for groups: %w", err)
 	}
 
-	return nil
+	return groupsResult, nil
 }
 
-func (g group) getGroups(tx transaction.Transaction) (resource.Resources, error) {
-	groups, err := g.getGroupsFromClaims(tx)
+// Exists returns whether the given group reference resolves to exactly one group.
+func (g group) Exists(ctx context.Context, reference string) (bool, error) {
+	tx := transaction.Transaction{
+		Ctx:    ctx,
+		Logger: *log.WithField("subsystem", "groups"),
+	}
+
+	groupResult, _, err := g.resolve(tx, reference)
 	if err != nil {
-		return nil, fmt.Errorf("mapping group claims to resources: %w", err)
+		return false, err
+	}
+	return groupResult != nil, nil
+}
+
+// UserMembers returns the object IDs of the users that are transitive members of the referenced group. If the group
+// cannot be resolved, the reason is returned instead.
+func (g group) UserMembers(tx transaction.Transaction, reference string) ([]azure.ObjectId, string, error) {
+	groupResult, reason, err := g.resolve(tx, reference)
+	if err != nil || groupResult == nil {
+		return nil, reason, err
+	}
+
+	members, err := g.GraphClient().Groups().ID(*groupResult.ID).TransitiveMembers().Request().GetN(tx.Ctx, g.MaxNumberOfPagesToFetch())
+	if err != nil {
+		return nil, "", fmt.Errorf("listing members of group '%s': %w", reference, err)
+	}
+
+	ids := make([]azure.ObjectId, 0)
+	for _, member := range members {
+		if member.ID != nil && directoryobject.IsUser(member) {
+			ids = append(ids, *member.ID)
+		}
+	}
+	return ids, "", nil
+}
+
+func (g group) getGroups(tx transaction.Transaction) (resource.Resources, *result.Groups, error) {
+	groupsResult := &result.Groups{
+		Assigned: make([]result.Group, 0),
+		Skipped:  make([]result.Group, 0),
+	}
+
+	groups, err := g.getGroupsFromClaims(tx, groupsResult)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mapping group claims to resources: %w", err)
+	}
+
+	groups, err = g.withGroupRoles(tx, groups, groupsResult)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mapping group roles to resources: %w", err)
 	}
 
 	undefinedAllUsersGroupID := len(g.Config().Features.GroupsAssignment.AllUsersGroupId) == 0
 	appRoleAssignmentNotRequired := !g.Config().Features.AppRoleAssignmentRequired.Enabled
 
 	if undefinedAllUsersGroupID || appRoleAssignmentNotRequired {
-		return groups, nil
+		return groups, groupsResult, nil
 	}
 
 	allowAllUsersEnabled := tx.Instance.Spec.AllowAllUsers != nil && *tx.Instance.Spec.AllowAllUsers
 	if allowAllUsersEnabled {
 		allUsersGroups, err := g.getAllUsersGroups(tx)
 		if err != nil {
-			return nil, fmt.Errorf("mapping all-users group to resources: %w", err)
+			return nil, nil, fmt.Errorf("mapping all-users group to resources: %w", err)
+		}
+
+		for _, allUsersGroup := range allUsersGroups {
+			if len(allUsersGroup.ObjectId) == 0 || groups.Has(allUsersGroup) {
+				continue
+			}
+			groups.Add(allUsersGroup)
+			groupsResult.Assigned = append(groupsResult.Assigned, assigned(allUsersGroup.ObjectId, allUsersGroup))
+		}
+	}
+
+	return groups, groupsResult, nil
+}
+
+// withGroupRoles adds the groups that are mapped to user roles, along with the roles that each group should be
+// assigned to. Groups are matched by their resolved object ID, so that a group that is referenced both by ID and by
+// name is only added once.
+func (g group) withGroupRoles(tx transaction.Transaction, groups resource.Resources, groupsResult *result.Groups) (resource.Resources, error) {
+	mappings := tx.Options.Process.Azure.GroupRoles
+
+	for _, reference := range mappings.Groups() {
+		roles := make([]naisiov1.AccessPolicyPermission, 0)
+		for _, role := range mappings.RolesFor(reference) {
+			roles = append(roles, naisiov1.AccessPolicyPermission(role))
+		}
+
+		groupResult, reason, err := g.resolve(tx, reference)
+		if err != nil {
+			return nil, fmt.Errorf("getting group '%s': %w", reference, err)
+		}
+
+		if groupResult == nil {
+			tx.Logger.Debugf("groups: skipping role assignment: '%s' %s", reference, reason)
+			groupsResult.Skipped = append(groupsResult.Skipped, skipped(reference, reason))
+			continue
 		}
 
-		if allUsersGroups != nil {
-			groups.AddAll(allUsersGroups...)
+		idx := slices.IndexFunc(groups, func(r resource.Resource) bool {
+			return strings.EqualFold(r.ObjectId, *groupResult.ID)
+		})
+		if idx >= 0 {
+			// the group may already be mapped to other roles through another reference
+			if groups[idx].Permissions != nil {
+				roles = append(roles, groups[idx].Permissions.Roles...)
+				slices.Sort(roles)
+				roles = slices.Compact(roles)
+			}
+			groups[idx].Permissions = &naisiov1.AccessPolicyPermissions{Roles: roles}
+			continue
 		}
+
+		group := g.mapToResource(*groupResult)
+		group.Permissions = &naisiov1.AccessPolicyPermissions{Roles: roles}
+		groups = append(groups, group)
+		groupsResult.Assigned = append(groupsResult.Assigned, assigned(reference, group))
 	}
 
 	return groups, nil
 }
 
-func (g group) getGroupsFromClaims(tx transaction.Transaction) (resource.Resources, error) {
+// getGroupsFromClaims resolves the groups in the spec to resources. Groups are referenced either by object ID, or by
+// display name or mail nickname. References that do not match exactly one group are skipped.
+func (g group) getGroupsFromClaims(tx transaction.Transaction, groupsResult *result.Groups) (resource.Resources, error) {
 	seen := make(map[string]bool)
 	resources := make(resource.Resources, 0)
 
@@ -102,29 +227,77 @@ func (g group) getGroupsFromClaims(tx transaction.Transaction) (resource.Resourc
 	}
 
 	for _, group := range tx.Instance.Spec.Claims.Groups {
-		exists, groupResult, err := g.getById(tx, group.ID)
+		groupResult, reason, err := g.resolve(tx, group.ID)
 		if err != nil {
-			if errors.Is(err, ErrBadRequest) {
-				tx.Logger.Warnf("groups: skipping assignment %s: %+v", group, err)
-				continue
-			}
-			return nil, fmt.Errorf("getting group '%s': %w", group, err)
+			return nil, fmt.Errorf("getting group '%s': %w", group.ID, err)
 		}
 
-		if !exists {
-			tx.Logger.Debugf("groups: skipping assignment: '%s' does not exist", group.ID)
+		if groupResult == nil {
+			tx.Logger.Debugf("groups: skipping assignment: '%s' %s", group.ID, reason)
+			groupsResult.Skipped = append(groupsResult.Skipped, skipped(group.ID, reason))
 			continue
 		}
 
-		if !seen[group.ID] {
-			resources = append(resources, g.mapToResource(*groupResult))
-			seen[group.ID] = true
+		if !seen[*groupResult.ID] {
+			groupResource := g.mapToResource(*groupResult)
+			resources = append(resources, groupResource)
+			groupsResult.Assigned = append(groupsResult.Assigned, assigned(group.ID, groupResource))
+			seen[*groupResult.ID] = true
 		}
 	}
 
 	return resources, nil
 }
 
+func assigned(reference string, group resource.Resource) result.Group {
+	return result.Group{
+		Reference: reference,
+		ObjectId:  group.ObjectId,
+		Name:      group.Name,
+	}
+}
+
+func skipped(reference, reason string) result.Group {
+	return result.Group{
+		Reference: reference,
+		Reason:    reason,
+	}
+}
+
+// resolve looks up the group for the given reference. If the group cannot be resolved, the reason is returned instead.
+func (g group) resolve(tx transaction.Transaction, reference string) (*msgraph.Group, string, error) {
+	if _, err := uuid.Parse(reference); err == nil || len(reference) == 0 {
+		exists, groupResult, err := g.getById(tx, reference)
+		if err != nil {
+			if errors.Is(err, ErrBadRequest) {
+				tx.Logger.Warnf("groups: skipping assignment '%s': %+v", reference, err)
+				return nil, "is not a valid group reference", nil
+			}
+			return nil, "", err
+		}
+
+		if !exists {
+			return nil, "does not exist", nil
+		}
+
+		return groupResult, "", nil
+	}
+
+	groups, err := g.getByName(tx, reference)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case len(groups) == 0:
+		return nil, "does not match any group", nil
+	case len(groups) > 1:
+		return nil, fmt.Sprintf("is ambiguous; matches %d groups", len(groups)), nil
+	default:
+		return &groups[0], "", nil
+	}
+}
+
 func (g group) getAllUsersGroups(tx transaction.Transaction) ([]resource.Resource, error) {
 	allUsersGroupIDs := g.Config().Features.GroupsAssignment.AllUsersGroupId
 	groups := make([]resource.Resource, len(allUsersGroupIDs))
@@ -191,6 +364,32 @@ func (g group) getById(tx transaction.Transaction, id azure.ObjectId) (bool, *ms
 	return true, group, nil
 }
 
+// getByName returns the groups with the given display name or mail nickname. A single match is cached by name for a
+// short while, so that the lookup is skipped on subsequent reconciliations without keeping stale matches for long.
+func (g group) getByName(tx transaction.Transaction, name string) ([]msgraph.Group, error) {
+	if val, found := groupNameCache.Get(name); found {
+		tx.Logger.Debugf("groups: cache hit for '%s'", name)
+		return []msgraph.Group{*val}, nil
+	}
+	tx.Logger.Debugf("groups: cache miss for '%s'", name)
+
+	r := g.GraphClient().Groups().Request()
+	r.Filter(util.FilterByGroupName(name))
+	groups, err := r.GetN(tx.Ctx, g.MaxNumberOfPagesToFetch())
+	if err != nil {
+		return nil, fmt.Errorf("looking up groups by name: %w", err)
+	}
+
+	groups = slices.DeleteFunc(groups, func(group msgraph.Group) bool {
+		return group.ID == nil || group.DisplayName == nil
+	})
+
+	if len(groups) == 1 {
+		groupNameCache.Set(name, &groups[0], cache.WithExpiration(nameCacheExpiration))
+	}
+	return groups, nil
+}
+
 func (g group) toGetRequestWithContext(ctx context.Context, r *msgraph.GroupRequest) (*http.Request, error) {
 	req, err := r.NewJSONRequest("GET", "", nil)
 	if err != nil {
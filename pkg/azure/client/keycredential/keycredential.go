@@ -24,7 +24,7 @@ type KeyCredential interface {
 	DeleteUnused(tx transaction.Transaction) error
 	Purge(tx transaction.Transaction) error
 	Rotate(tx transaction.Transaction) (*msgraph.KeyCredential, *crypto.Jwk, error)
-	Validate(tx transaction.Transaction, existing credentials.Set) (bool, error)
+	Validate(tx transaction.Transaction, existing credentials.Set) (credentials.Validation, error)
 }
 
 type keyCredential struct {
@@ -150,15 +150,21 @@ func (k keyCredential) Purge(tx transaction.Transaction) error {
 	return k.Application().Patch(tx.Ctx, tx.Instance.GetObjectId(), app)
 }
 
-func (k keyCredential) Validate(tx transaction.Transaction, existing credentials.Set) (bool, error) {
+// Validate returns whether the current and next key credentials in the given set are registered and not expired,
+// along with the earliest end date of the two and all registered key credentials.
+func (k keyCredential) Validate(tx transaction.Transaction, existing credentials.Set) (credentials.Validation, error) {
 	app, err := k.Application().Get(tx)
 	if err != nil {
-		return false, err
+		return credentials.Invalid, err
 	}
 
 	currentIsValid := false
 	nextIsValid := false
+	var expiresAt time.Time
+	registrations := make([]credentials.Registration, 0, len(app.KeyCredentials))
 	for _, cred := range app.KeyCredentials {
+		registrations = append(registrations, credentials.NewRegistration(credentials.KindCertificate, string(*cred.KeyID), cred.StartDateTime, cred.EndDateTime, existing))
+
 		notExpired := cred.EndDateTime.After(time.Now())
 
 		currentIdMatches := string(*cred.KeyID) == existing.Current.Certificate.KeyId
@@ -170,9 +176,17 @@ func (k keyCredential) Validate(tx transaction.Transaction, existing credentials
 		if nextIdMatches && notExpired {
 			nextIsValid = true
 		}
+
+		if (currentIdMatches || nextIdMatches) && (expiresAt.IsZero() || cred.EndDateTime.Before(expiresAt)) {
+			expiresAt = *cred.EndDateTime
+		}
 	}
 
-	return currentIsValid && nextIsValid, nil
+	return credentials.Validation{
+		Valid:         currentIsValid && nextIsValid,
+		ExpiresAt:     expiresAt,
+		Registrations: registrations,
+	}, nil
 }
 
 func (k keyCredential) filterRevokedKeys(tx transaction.Transaction) ([]msgraph.KeyCredential, error) {
@@ -226,7 +240,7 @@ func (k keyCredential) filterRevokedKeys(tx transaction.Transaction) ([]msgraph.
 }
 
 func (k keyCredential) new(tx transaction.Transaction) (*msgraph.KeyCredential, *crypto.Jwk, error) {
-	jwkPair, err := crypto.GenerateJwk(tx.Instance, tx.ClusterName)
+	jwkPair, err := crypto.GenerateJwk(tx.Instance, tx.ClusterName, tx.Options.Process.Secret.Certificate)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to generate JWK pair for application: %w", err)
 	}
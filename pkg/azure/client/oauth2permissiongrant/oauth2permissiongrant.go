@@ -2,6 +2,7 @@ package oauth2permissiongrant
 
 import (
 	"fmt"
+	"strings"
 
 	msgraph "github.com/nais/msgraph.go/v1.0"
 
@@ -10,6 +11,9 @@ import (
 	"github.com/nais/azureator/pkg/transaction"
 )
 
+// DefaultScopes are the delegated permissions for Microsoft Graph that are granted to all applications.
+var DefaultScopes = []string{"openid", "User.Read", "GroupMember.Read.All"}
+
 type OAuth2PermissionGrant interface {
 	Process(tx transaction.Transaction) error
 }
@@ -61,6 +65,6 @@ func (o oAuth2PermissionGrant) toGrant(servicePrincipalId azure.ServicePrincipal
 		ClientID:    new(servicePrincipalId),
 		ConsentType: new("AllPrincipals"),
 		ResourceID:  new(o.Config().PermissionGrantResourceId),
-		Scope:       new("openid User.Read GroupMember.Read.All"),
+		Scope:       new(strings.Join(DefaultScopes, " ")),
 	}
 }
@@ -14,6 +14,7 @@ import (
 	"github.com/nais/azureator/pkg/azure/credentials"
 	"github.com/nais/azureator/pkg/azure/util"
 	"github.com/nais/azureator/pkg/transaction"
+	"github.com/nais/azureator/pkg/util/crypto"
 	stringutils "github.com/nais/azureator/pkg/util/strings"
 )
 
@@ -23,7 +24,7 @@ type PasswordCredential interface {
 	DeleteUnused(tx transaction.Transaction) error
 	Purge(tx transaction.Transaction) error
 	Rotate(tx transaction.Transaction) (*msgraph.PasswordCredential, error)
-	Validate(tx transaction.Transaction, existing credentials.Set) (bool, error)
+	Validate(tx transaction.Transaction, existing credentials.Set) (credentials.Validation, error)
 }
 
 type passwordCredential struct {
@@ -136,15 +137,21 @@ func (p passwordCredential) Purge(tx transaction.Transaction) error {
 	return nil
 }
 
-func (p passwordCredential) Validate(tx transaction.Transaction, existing credentials.Set) (bool, error) {
+// Validate returns whether the current and next password credentials in the given set are registered and not expired,
+// along with the earliest end date of the two and all registered password credentials.
+func (p passwordCredential) Validate(tx transaction.Transaction, existing credentials.Set) (credentials.Validation, error) {
 	app, err := p.Application().Get(tx)
 	if err != nil {
-		return false, err
+		return credentials.Invalid, err
 	}
 
 	currentIsValid := false
 	nextIsValid := false
+	var expiresAt time.Time
+	registrations := make([]credentials.Registration, 0, len(app.PasswordCredentials))
 	for _, cred := range app.PasswordCredentials {
+		registrations = append(registrations, credentials.NewRegistration(credentials.KindPassword, string(*cred.KeyID), cred.StartDateTime, cred.EndDateTime, existing))
+
 		notExpired := cred.EndDateTime.After(time.Now())
 
 		currentIdMatches := string(*cred.KeyID) == existing.Current.Password.KeyId
@@ -156,9 +163,17 @@ func (p passwordCredential) Validate(tx transaction.Transaction, existing creden
 		if nextIdMatches && notExpired {
 			nextIsValid = true
 		}
+
+		if (currentIdMatches || nextIdMatches) && (expiresAt.IsZero() || cred.EndDateTime.Before(expiresAt)) {
+			expiresAt = *cred.EndDateTime
+		}
 	}
 
-	return currentIsValid && nextIsValid, nil
+	return credentials.Validation{
+		Valid:         currentIsValid && nextIsValid,
+		ExpiresAt:     expiresAt,
+		Registrations: registrations,
+	}, nil
 }
 
 func (p passwordCredential) remove(tx transaction.Transaction, id azure.ClientId, keyId *msgraph.UUID) error {
@@ -176,7 +191,12 @@ func (p passwordCredential) remove(tx transaction.Transaction, id azure.ClientId
 func (p passwordCredential) toAddRequest(tx transaction.Transaction) *msgraph.ApplicationAddPasswordRequestParameter {
 	startDateTime := time.Now()
 
-	endDateTime := startDateTime.AddDate(1, 0, 0)
+	// password credentials share the validity of certificates, so that both are replaced ahead of their expiry alike
+	validity := tx.Options.Process.Secret.Certificate.Validity
+	if validity <= 0 {
+		validity = crypto.DefaultCertificateValidity
+	}
+	endDateTime := startDateTime.Add(validity)
 
 	keyId := msgraph.UUID(uuid.New().String())
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"slices"
 
 	msgraph "github.com/nais/msgraph.go/v1.0"
 
@@ -12,7 +13,7 @@ import (
 )
 
 type Policies interface {
-	Process(tx transaction.Transaction, policyID string) error
+	Process(tx transaction.Transaction, desiredPolicyID string, managedPolicyIDs []string) error
 }
 
 type policies struct {
@@ -35,12 +36,9 @@ func newPolicies(client azure.RuntimeClient) Policies {
 	}
 }
 
-func (p *policies) Process(tx transaction.Transaction, desiredPolicyID string) error {
-	if desiredPolicyID == "" {
-		tx.Logger.Debug("claims-mapping-policies: desiredPolicyID is empty; skipping...")
-		return nil
-	}
-
+// Process assigns the desired policy to the service principal. If the desired policy is empty, any of the managed
+// policies that are assigned to the service principal are removed.
+func (p *policies) Process(tx transaction.Transaction, desiredPolicyID string, managedPolicyIDs []string) error {
 	servicePrincipalID := tx.Instance.GetServicePrincipalId()
 	if len(servicePrincipalID) == 0 {
 		return fmt.Errorf("claims-mapping-policies: service principal ID is not set")
@@ -51,6 +49,22 @@ func (p *policies) Process(tx transaction.Transaction, desiredPolicyID string) e
 		return fmt.Errorf("claims-mapping-policies: fetching existing policies for service principal '%s': %w", servicePrincipalID, err)
 	}
 
+	if desiredPolicyID == "" {
+		for _, assignedPolicy := range assignedPolicies {
+			assignedPolicyID, ok := policyID(assignedPolicy)
+			if !ok || !slices.Contains(managedPolicyIDs, assignedPolicyID) {
+				continue
+			}
+
+			err := p.removePolicy(tx.Ctx, assignedPolicyID, servicePrincipalID)
+			if err != nil {
+				return fmt.Errorf("claims-mapping-policies: removing '%s' from service principal '%s': %w", assignedPolicyID, servicePrincipalID, err)
+			}
+			tx.Logger.Infof("claims-mapping-policies: successfully removed '%s' from service principal '%s'", assignedPolicyID, servicePrincipalID)
+		}
+		return nil
+	}
+
 	// a ServicePrincipal can only have one assignedPolicy assigned at any given time, so we must first revoke any existing, non-matching policies
 	for _, assignedPolicy := range assignedPolicies {
 		assignedPolicyID, ok := policyID(assignedPolicy)
@@ -0,0 +1,42 @@
+package user
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nais/azureator/pkg/azure"
+	"github.com/nais/azureator/pkg/azure/util"
+)
+
+type Users interface {
+	GetIdByUserPrincipalName(ctx context.Context, upn string) (azure.ObjectId, bool, error)
+}
+
+type users struct {
+	azure.RuntimeClient
+}
+
+func NewUsers(client azure.RuntimeClient) Users {
+	return users{RuntimeClient: client}
+}
+
+// GetIdByUserPrincipalName returns the object ID of the user with the given user principal name, if it exists.
+func (u users) GetIdByUserPrincipalName(ctx context.Context, upn string) (azure.ObjectId, bool, error) {
+	r := u.GraphClient().Users().Request()
+	r.Filter(util.FilterByUserPrincipalName(upn))
+	matches, err := r.GetN(ctx, u.MaxNumberOfPagesToFetch())
+	if err != nil {
+		return "", false, fmt.Errorf("looking up user by user principal name: %w", err)
+	}
+
+	switch {
+	case len(matches) == 0:
+		return "", false, nil
+	case len(matches) > 1:
+		return "", false, fmt.Errorf("found %d users with user principal name '%s'", len(matches), upn)
+	case matches[0].ID == nil:
+		return "", false, nil
+	}
+
+	return *matches[0].ID, true, nil
+}
@@ -1,6 +1,9 @@
 package credentials
 
 import (
+	"slices"
+	"time"
+
 	msgraph "github.com/nais/msgraph.go/v1.0"
 
 	"github.com/nais/azureator/pkg/util/crypto"
@@ -21,6 +24,22 @@ type KeyID struct {
 	Password    []string `json:"password"`
 }
 
+// WithCredentials returns a copy of the key IDs with the key IDs of the given credentials appended.
+// Key IDs for kinds of credentials that are not registered, i.e. empty key IDs, are skipped.
+func (k KeyID) WithCredentials(c Credentials) KeyID {
+	result := KeyID{
+		Certificate: slices.Clone(k.Certificate),
+		Password:    slices.Clone(k.Password),
+	}
+	if len(c.Certificate.KeyId) > 0 {
+		result.Certificate = append(result.Certificate, c.Certificate.KeyId)
+	}
+	if len(c.Password.KeyId) > 0 {
+		result.Password = append(result.Password, c.Password.KeyId)
+	}
+	return result
+}
+
 type Credentials struct {
 	Certificate Certificate `json:"certificate"`
 	Password    Password    `json:"password"`
@@ -45,3 +64,84 @@ type AddedKeyCredential struct {
 	KeyCredential msgraph.KeyCredential
 	Jwk           crypto.Jwk
 }
+
+// Validation is the result of validating a set of credentials against the actual state in Azure AD.
+type Validation struct {
+	Valid bool
+	// ExpiresAt is the earliest end date of the credentials in the set, or the zero time if none of them expire.
+	ExpiresAt time.Time
+	// Registrations lists the credentials registered in Azure AD, as seen during validation.
+	Registrations []Registration
+}
+
+// Invalid is the result for a set of credentials that is not in sync with Azure AD.
+var Invalid = Validation{}
+
+// Merge returns the combined result of both validations: valid only if both are valid, expiring at the earliest
+// end date of the two, and holding the registrations of both.
+func (v Validation) Merge(other Validation) Validation {
+	merged := Validation{
+		Valid:         v.Valid && other.Valid,
+		ExpiresAt:     v.ExpiresAt,
+		Registrations: slices.Concat(v.Registrations, other.Registrations),
+	}
+	if !other.ExpiresAt.IsZero() && (merged.ExpiresAt.IsZero() || other.ExpiresAt.Before(merged.ExpiresAt)) {
+		merged.ExpiresAt = other.ExpiresAt
+	}
+	return merged
+}
+
+type Kind string
+
+const (
+	KindCertificate Kind = "certificate"
+	KindPassword    Kind = "password"
+)
+
+type State string
+
+const (
+	StateCurrent State = "current"
+	StateNext    State = "next"
+	// StateLingering is a credential that is registered in Azure AD, but not part of the latest set of credentials.
+	// Such credentials are either still in use by older secrets, or about to be revoked.
+	StateLingering State = "lingering"
+)
+
+// Registration describes a credential registered for the application in Azure AD.
+type Registration struct {
+	Kind      Kind      `json:"kind"`
+	KeyId     string    `json:"keyId"`
+	State     State     `json:"state"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+}
+
+// NewRegistration describes a credential of the given kind, with its state relative to the given set.
+func NewRegistration(kind Kind, keyId string, startTime, endTime *time.Time, set Set) Registration {
+	current, next := set.Current.Password.KeyId, set.Next.Password.KeyId
+	if kind == KindCertificate {
+		current, next = set.Current.Certificate.KeyId, set.Next.Certificate.KeyId
+	}
+
+	state := StateLingering
+	switch keyId {
+	case current:
+		state = StateCurrent
+	case next:
+		state = StateNext
+	}
+
+	registration := Registration{
+		Kind:  kind,
+		KeyId: keyId,
+		State: state,
+	}
+	if startTime != nil {
+		registration.StartTime = startTime.UTC()
+	}
+	if endTime != nil {
+		registration.EndTime = endTime.UTC()
+	}
+	return registration
+}
@@ -0,0 +1,53 @@
+package credentials
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Mode determines the kinds of credentials registered for an application.
+type Mode string
+
+const (
+	ModeBoth        Mode = "both"
+	ModeCertificate Mode = "certificate"
+	ModePassword    Mode = "password"
+	// ModeWorkloadIdentity registers a federated identity credential for the application's service account instead of
+	// certificates or passwords. The credentials are short-lived tokens issued by the cluster, so there is nothing to rotate.
+	ModeWorkloadIdentity Mode = "workload-identity"
+
+	DefaultMode = ModeBoth
+)
+
+var SupportedModes = []Mode{ModeBoth, ModeCertificate, ModePassword, ModeWorkloadIdentity}
+
+func ParseMode(s string) (Mode, error) {
+	mode := Mode(s)
+	if !slices.Contains(SupportedModes, mode) {
+		return "", fmt.Errorf("unsupported credential mode '%s', must be one of %v", s, SupportedModes)
+	}
+	return mode, nil
+}
+
+// Certificate returns true if key credentials should be registered. An empty mode yields the DefaultMode.
+func (m Mode) Certificate() bool {
+	return m != ModePassword && m != ModeWorkloadIdentity
+}
+
+// Password returns true if password credentials (client secrets) should be registered. An empty mode yields the DefaultMode.
+func (m Mode) Password() bool {
+	return m != ModeCertificate && m != ModeWorkloadIdentity
+}
+
+// WorkloadIdentity returns true if a federated identity credential should be registered instead of certificates and
+// passwords.
+func (m Mode) WorkloadIdentity() bool {
+	return m == ModeWorkloadIdentity
+}
+
+// Matches returns true if the set holds exactly the kinds of credentials enabled by the mode.
+func (m Mode) Matches(set Set) bool {
+	hasCertificate := len(set.Current.Certificate.KeyId) > 0 || len(set.Next.Certificate.KeyId) > 0
+	hasPassword := len(set.Current.Password.KeyId) > 0 || len(set.Next.Password.KeyId) > 0
+	return hasCertificate == m.Certificate() && hasPassword == m.Password()
+}
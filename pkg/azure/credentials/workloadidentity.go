@@ -0,0 +1,20 @@
+package credentials
+
+import (
+	"fmt"
+)
+
+// WorkloadIdentity describes the federated identity credential that allows a Kubernetes service account to
+// authenticate as the application.
+type WorkloadIdentity struct {
+	Audience string
+	Issuer   string
+	Subject  string
+	// TokenFile is the path to the projected service account token in the application's pods.
+	TokenFile string
+}
+
+// ServiceAccountSubject returns the subject of tokens issued to the given service account.
+func ServiceAccountSubject(namespace, serviceAccount string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccount)
+}
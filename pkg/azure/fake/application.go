@@ -33,12 +33,12 @@ func AzureApplicationResult(instance *v1.AzureAdApplication, operation result.Op
 }
 
 func AzureCredentialsSet(instance *v1.AzureAdApplication, clusterName string) credentials.Set {
-	currJwk, err := crypto.GenerateJwk(instance, clusterName)
+	currJwk, err := crypto.GenerateJwk(instance, clusterName, crypto.CertificateOptions{})
 	if err != nil {
 		panic(err)
 	}
 
-	nextJwk, err := crypto.GenerateJwk(instance, clusterName)
+	nextJwk, err := crypto.GenerateJwk(instance, clusterName, crypto.CertificateOptions{})
 	if err != nil {
 		panic(err)
 	}
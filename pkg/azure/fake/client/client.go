@@ -82,8 +82,8 @@ func (a fakeAzureCredentialsClient) Purge(tx transaction.Transaction) error {
 	return nil
 }
 
-func (a fakeAzureCredentialsClient) Validate(tx transaction.Transaction, existing credentials.Set) (bool, error) {
-	return true, nil
+func (a fakeAzureCredentialsClient) Validate(tx transaction.Transaction, existing credentials.Set) (credentials.Validation, error) {
+	return credentials.Validation{Valid: true}, nil
 }
 
 func (a fakeAzureClient) Update(tx transaction.Transaction) (*result.Application, error) {
@@ -104,6 +104,11 @@ func (a fakeAzureClient) PreAuthorizedAppClientID(_ context.Context, rule v1.Acc
 	return fake.ClientIDForRule(rule), true, nil
 }
 
+func (a fakeAzureClient) GroupExists(_ context.Context, reference string) (bool, error) {
+	// References containing "resync" simulate groups that have since been created in Azure.
+	return strings.Contains(reference, "resync"), nil
+}
+
 func NewFakeAzureClient() azure.Client {
 	return fakeAzureClient{}
 }
@@ -0,0 +1,411 @@
+package graph
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// readOnlyProperties are ignored when present in create or update requests.
+var readOnlyProperties = []string{"id", "appId", "createdDateTime"}
+
+func defaultApplication() object {
+	return object{
+		"api": map[string]any{
+			"acceptMappedClaims":          nil,
+			"knownClientApplications":     []any{},
+			"oauth2PermissionScopes":      []any{},
+			"preAuthorizedApplications":   []any{},
+			"requestedAccessTokenVersion": nil,
+		},
+		"appRoles":               []any{},
+		"groupMembershipClaims":  nil,
+		"identifierUris":         []any{},
+		"keyCredentials":         []any{},
+		"optionalClaims":         nil,
+		"passwordCredentials":    []any{},
+		"publicClient":           map[string]any{"redirectUris": []any{}},
+		"requiredResourceAccess": []any{},
+		"signInAudience":         "AzureADMyOrg",
+		"spa":                    map[string]any{"redirectUris": []any{}},
+		"tags":                   []any{},
+		"web": map[string]any{
+			"homePageUrl":  nil,
+			"logoutUrl":    nil,
+			"redirectUris": []any{},
+			"implicitGrantSettings": map[string]any{
+				"enableAccessTokenIssuance": false,
+				"enableIdTokenIssuance":     false,
+			},
+		},
+	}
+}
+
+func (s *Server) listApplications(w http.ResponseWriter, r *http.Request) {
+	s.writeFilteredCollection(w, r, s.applications.list())
+}
+
+func (s *Server) createApplication(w http.ResponseWriter, r *http.Request) {
+	body, err := readObject(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	stripReadOnly(body)
+
+	if len(body.string("displayName")) == 0 {
+		writeBadRequest(w, fmt.Errorf("property 'displayName' is required"))
+		return
+	}
+
+	if err := normalizeKeyCredentials(body); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	app := defaultApplication()
+	app.merge(body)
+	app["id"] = newID()
+	app["appId"] = newID()
+	app["createdDateTime"] = now()
+
+	s.applications.add(app)
+	writeJSON(w, http.StatusCreated, app.clone())
+}
+
+func (s *Server) getApplication(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	app, found := s.applications.get(id)
+	if !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, app.clone())
+}
+
+func (s *Server) patchApplication(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	app, found := s.applications.get(id)
+	if !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	body, err := readObject(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	stripReadOnly(body)
+
+	if err := normalizeKeyCredentials(body); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	app.merge(body)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteApplication(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	app, found := s.applications.get(id)
+	if !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	// deleting an application also deletes its service principal
+	for _, sp := range s.servicePrincipals.filter(byAppId(app.string("appId"))) {
+		s.deleteServicePrincipal(sp.string("id"))
+	}
+
+	s.applications.remove(id)
+	s.owners.purge(id)
+	s.assignedTokenLifetimePolicies.purge(id)
+	delete(s.federatedIdentityCredentials, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) addPassword(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	app, found := s.applications.get(id)
+	if !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	body, err := readObject(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	requested, _ := body["passwordCredential"].(map[string]any)
+	cred := object{
+		"customKeyIdentifier": nil,
+		"displayName":         nil,
+		"endDateTime":         time.Now().AddDate(2, 0, 0).UTC().Format(time.RFC3339Nano),
+		"keyId":               newID(),
+		"startDateTime":       now(),
+	}
+	for key, value := range requested {
+		if value != nil {
+			cred[key] = value
+		}
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalServerError", err.Error())
+		return
+	}
+	cred["hint"] = secret[:3]
+
+	app.setObjects("passwordCredentials", append(app.objects("passwordCredentials"), cred.clone()))
+
+	cred["secretText"] = secret
+	writeJSON(w, http.StatusOK, cred)
+}
+
+func (s *Server) removePassword(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	app, found := s.applications.get(id)
+	if !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	body, err := readObject(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	keyId := body.string("keyId")
+	existing := app.objects("passwordCredentials")
+	remaining := make([]object, 0, len(existing))
+	for _, cred := range existing {
+		if cred.string("keyId") != keyId {
+			remaining = append(remaining, cred)
+		}
+	}
+
+	if len(remaining) == len(existing) {
+		writeBadRequest(w, fmt.Errorf("no password credential found with keyId '%s'", keyId))
+		return
+	}
+
+	app.setObjects("passwordCredentials", remaining)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listFederatedIdentityCredentials(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, found := s.applications.get(id); !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	creds := make([]object, 0)
+	if existing, found := s.federatedIdentityCredentials[id]; found {
+		creds = existing.list()
+	}
+	s.writeCollection(w, r, creds)
+}
+
+func (s *Server) createFederatedIdentityCredential(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, found := s.applications.get(id); !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	body, err := readObject(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	stripReadOnly(body)
+
+	for _, property := range []string{"name", "issuer", "subject"} {
+		if len(body.string(property)) == 0 {
+			writeBadRequest(w, fmt.Errorf("property '%s' is required", property))
+			return
+		}
+	}
+
+	if audiences, ok := body["audiences"].([]any); !ok || len(audiences) != 1 {
+		writeBadRequest(w, fmt.Errorf("property 'audiences' must contain exactly one value"))
+		return
+	}
+
+	creds, found := s.federatedIdentityCredentials[id]
+	if !found {
+		creds = newCollection()
+		s.federatedIdentityCredentials[id] = creds
+	}
+
+	for _, existing := range creds.list() {
+		if existing.string("name") == body.string("name") {
+			writeBadRequest(w, fmt.Errorf("federated identity credential with name '%s' already exists", body.string("name")))
+			return
+		}
+		if existing.string("issuer") == body.string("issuer") && existing.string("subject") == body.string("subject") {
+			writeBadRequest(w, fmt.Errorf("federated identity credential with the same issuer and subject already exists"))
+			return
+		}
+	}
+
+	cred := object{"description": nil}
+	cred.merge(body)
+	cred["id"] = newID()
+
+	creds.add(cred)
+	writeJSON(w, http.StatusCreated, cred.clone())
+}
+
+func (s *Server) deleteFederatedIdentityCredential(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	credentialId := r.PathValue("credentialId")
+
+	creds, found := s.federatedIdentityCredentials[id]
+	if !found || !creds.remove(credentialId) {
+		writeNotFound(w, credentialId)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// normalizeKeyCredentials assigns key IDs and derives the validity period from the certificate, as Graph does.
+func normalizeKeyCredentials(body object) error {
+	if _, found := body["keyCredentials"]; !found {
+		return nil
+	}
+
+	creds := body.objects("keyCredentials")
+	for _, cred := range creds {
+		if len(cred.string("keyId")) == 0 {
+			cred["keyId"] = newID()
+		}
+
+		if len(cred.string("startDateTime")) > 0 && len(cred.string("endDateTime")) > 0 {
+			continue
+		}
+
+		cert, err := parseCertificate(cred.string("key"))
+		if err != nil {
+			return fmt.Errorf("key credential '%s': %w", cred.string("keyId"), err)
+		}
+
+		cred["startDateTime"] = cert.NotBefore.UTC().Format(time.RFC3339Nano)
+		cred["endDateTime"] = cert.NotAfter.UTC().Format(time.RFC3339Nano)
+	}
+
+	body.setObjects("keyCredentials", creds)
+	return nil
+}
+
+func parseCertificate(key string) (*x509.Certificate, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("key value is not valid base64: %w", err)
+	}
+
+	if block, _ := pem.Decode(raw); block != nil {
+		raw = block.Bytes
+	}
+
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, fmt.Errorf("key value is an invalid certificate: %w", err)
+	}
+	return cert, nil
+}
+
+func stripReadOnly(body object) {
+	for _, key := range readOnlyProperties {
+		delete(body, key)
+	}
+}
+
+func byAppId(appId string) func(object) bool {
+	return func(obj object) bool {
+		return obj.string("appId") == appId
+	}
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 30)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating secret: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+func (s *Server) listAssignedTokenLifetimePolicies(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, found := s.applications.get(id); !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	policies := make([]object, 0)
+	for _, policyId := range s.assignedTokenLifetimePolicies.get(id) {
+		if policy, found := s.tokenLifetimePolicies.get(policyId); found {
+			policies = append(policies, policy)
+		}
+	}
+
+	s.writeCollection(w, r, policies)
+}
+
+func (s *Server) assignTokenLifetimePolicy(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, found := s.applications.get(id); !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	policyId, err := referencedID(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	if _, found := s.tokenLifetimePolicies.get(policyId); !found {
+		writeNotFound(w, policyId)
+		return
+	}
+
+	// an application may only have a single token lifetime policy assigned
+	if len(s.assignedTokenLifetimePolicies.get(id)) > 0 {
+		writeBadRequest(w, fmt.Errorf("application '%s' already has a token lifetime policy assigned", id))
+		return
+	}
+
+	s.assignedTokenLifetimePolicies.add(id, policyId)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeTokenLifetimePolicy(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	policyId := r.PathValue("policyId")
+
+	if !s.assignedTokenLifetimePolicies.remove(id, policyId) {
+		writeNotFound(w, policyId)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
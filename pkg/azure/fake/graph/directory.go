@@ -0,0 +1,189 @@
+package graph
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+)
+
+// object is the schemaless representation of a directory entity, as seen on the wire.
+type object map[string]any
+
+func (o object) string(key string) string {
+	if s, ok := o[key].(string); ok {
+		return s
+	}
+	return ""
+}
+
+func (o object) strings(key string) []string {
+	raw, ok := o[key].([]any)
+	if !ok {
+		return nil
+	}
+
+	result := make([]string, 0, len(raw))
+	for _, item := range raw {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func (o object) objects(key string) []object {
+	raw, ok := o[key].([]any)
+	if !ok {
+		return nil
+	}
+
+	result := make([]object, 0, len(raw))
+	for _, item := range raw {
+		if m, ok := item.(map[string]any); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func (o object) setObjects(key string, values []object) {
+	raw := make([]any, 0, len(values))
+	for _, v := range values {
+		raw = append(raw, map[string]any(v))
+	}
+	o[key] = raw
+}
+
+// clone returns a deep copy of the object.
+func (o object) clone() object {
+	return deepCopy(map[string]any(o)).(map[string]any)
+}
+
+// merge applies the given patch to the object. Nested objects are merged recursively, while any other values
+// (including arrays) replace the existing value.
+func (o object) merge(patch object) {
+	for key, value := range patch {
+		patchObj, patchIsObj := value.(map[string]any)
+		existingObj, existingIsObj := o[key].(map[string]any)
+
+		if patchIsObj && existingIsObj {
+			object(existingObj).merge(patchObj)
+			continue
+		}
+
+		o[key] = deepCopy(value)
+	}
+}
+
+func deepCopy(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, val := range v {
+			out[key] = deepCopy(val)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, val := range v {
+			out[i] = deepCopy(val)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// decodeInto converts the object to the given typed Graph entity.
+func (o object) decodeInto(target any) error {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, target)
+}
+
+// collection is an insertion-ordered set of directory entities keyed by their object ID.
+type collection struct {
+	ids     []string
+	objects map[string]object
+}
+
+func newCollection() *collection {
+	return &collection{
+		ids:     make([]string, 0),
+		objects: make(map[string]object),
+	}
+}
+
+func (c *collection) add(obj object) {
+	id := obj.string("id")
+	if _, found := c.objects[id]; !found {
+		c.ids = append(c.ids, id)
+	}
+	c.objects[id] = obj
+}
+
+func (c *collection) get(id string) (object, bool) {
+	obj, found := c.objects[id]
+	return obj, found
+}
+
+func (c *collection) remove(id string) bool {
+	if _, found := c.objects[id]; !found {
+		return false
+	}
+
+	delete(c.objects, id)
+	c.ids = slices.DeleteFunc(c.ids, func(s string) bool {
+		return s == id
+	})
+	return true
+}
+
+func (c *collection) list() []object {
+	result := make([]object, 0, len(c.ids))
+	for _, id := range c.ids {
+		result = append(result, c.objects[id])
+	}
+	return result
+}
+
+func (c *collection) filter(fn func(object) bool) []object {
+	return slices.DeleteFunc(c.list(), func(obj object) bool {
+		return !fn(obj)
+	})
+}
+
+// references is a set of directory object references (e.g. owners) keyed by the ID of the referencing entity.
+type references map[string][]string
+
+func (r references) add(id, ref string) bool {
+	if slices.Contains(r[id], ref) {
+		return false
+	}
+	r[id] = append(r[id], ref)
+	return true
+}
+
+func (r references) remove(id, ref string) bool {
+	if !slices.Contains(r[id], ref) {
+		return false
+	}
+	r[id] = slices.DeleteFunc(r[id], func(s string) bool {
+		return s == ref
+	})
+	return true
+}
+
+func (r references) get(id string) []string {
+	return slices.Clone(r[id])
+}
+
+// purge removes all references from and to the given ID.
+func (r references) purge(id string) {
+	delete(r, id)
+	for key := range maps.Keys(r) {
+		r.remove(key, id)
+	}
+}
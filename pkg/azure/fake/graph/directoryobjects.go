@@ -0,0 +1,231 @@
+package graph
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+func (s *Server) listOwners(c *collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if _, found := c.get(id); !found {
+			writeNotFound(w, id)
+			return
+		}
+
+		owners := make([]object, 0)
+		for _, ownerId := range s.owners.get(id) {
+			if owner, found := s.directoryObject(ownerId); found {
+				owners = append(owners, owner)
+			}
+		}
+
+		s.writeCollection(w, r, owners)
+	}
+}
+
+func (s *Server) addOwner(c *collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if _, found := c.get(id); !found {
+			writeNotFound(w, id)
+			return
+		}
+
+		ownerId, err := referencedID(r)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		if _, found := s.directoryObject(ownerId); !found {
+			writeNotFound(w, ownerId)
+			return
+		}
+
+		if !s.owners.add(id, ownerId) {
+			writeBadRequest(w, fmt.Errorf("one or more added object references already exist for the following modified properties: 'owners'"))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) removeOwner(c *collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if _, found := c.get(id); !found {
+			writeNotFound(w, id)
+			return
+		}
+
+		ownerId := r.PathValue("ownerId")
+		if !s.owners.remove(id, ownerId) {
+			writeNotFound(w, ownerId)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// directoryObject returns the given user or service principal as a directory object, as listed in e.g. owners.
+func (s *Server) directoryObject(id string) (object, bool) {
+	if user, found := s.users.get(id); found {
+		return object{
+			"@odata.type":       "#microsoft.graph.user",
+			"id":                id,
+			"displayName":       user["displayName"],
+			"userPrincipalName": user["userPrincipalName"],
+		}, true
+	}
+
+	if sp, found := s.servicePrincipals.get(id); found {
+		return object{
+			"@odata.type": "#microsoft.graph.servicePrincipal",
+			"id":          id,
+			"displayName": s.servicePrincipalView(sp)["displayName"],
+		}, true
+	}
+
+	return nil, false
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	s.writeFilteredCollection(w, r, s.users.list())
+}
+
+func (s *Server) listGroups(w http.ResponseWriter, r *http.Request) {
+	s.writeFilteredCollection(w, r, s.groups.list())
+}
+
+func (s *Server) getGroup(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := uuid.Parse(id); err != nil {
+		writeBadRequest(w, fmt.Errorf("invalid object identifier '%s'", id))
+		return
+	}
+
+	group, found := s.groups.get(id)
+	if !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, group.clone())
+}
+
+// listTransitiveMembers lists the members of the group, including the members of nested groups.
+func (s *Server) listTransitiveMembers(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, found := s.groups.get(id); !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	members := make([]object, 0)
+	seen := map[string]bool{id: true}
+	queue := s.groupMembers.get(id)
+	for len(queue) > 0 {
+		memberId := queue[0]
+		queue = queue[1:]
+		if seen[memberId] {
+			continue
+		}
+		seen[memberId] = true
+
+		if group, found := s.groups.get(memberId); found {
+			members = append(members, object{
+				"@odata.type": "#microsoft.graph.group",
+				"id":          memberId,
+				"displayName": group["displayName"],
+			})
+			queue = append(queue, s.groupMembers.get(memberId)...)
+			continue
+		}
+
+		if member, found := s.directoryObject(memberId); found {
+			members = append(members, member)
+		}
+	}
+
+	s.writeCollection(w, r, members)
+}
+
+func (s *Server) listOAuth2PermissionGrants(w http.ResponseWriter, r *http.Request) {
+	s.writeFilteredCollection(w, r, s.oauth2PermissionGrants.list())
+}
+
+func (s *Server) createOAuth2PermissionGrant(w http.ResponseWriter, r *http.Request) {
+	body, err := readObject(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	clientId := body.string("clientId")
+	resourceId := body.string("resourceId")
+	for _, spId := range []string{clientId, resourceId} {
+		if _, found := s.servicePrincipals.get(spId); !found {
+			writeBadRequest(w, fmt.Errorf("service principal '%s' does not exist", spId))
+			return
+		}
+	}
+
+	duplicate := slices.ContainsFunc(s.oauth2PermissionGrants.list(), func(obj object) bool {
+		return obj.string("clientId") == clientId &&
+			obj.string("resourceId") == resourceId &&
+			obj.string("consentType") == body.string("consentType") &&
+			obj.string("principalId") == body.string("principalId")
+	})
+	if duplicate {
+		writeError(w, http.StatusConflict, "Request_MultipleObjectsWithSameKeyValue", "permission entry already exists")
+		return
+	}
+
+	stripReadOnly(body)
+	body["id"] = newID()
+
+	s.oauth2PermissionGrants.add(body)
+	writeJSON(w, http.StatusCreated, body.clone())
+}
+
+func (s *Server) patchOAuth2PermissionGrant(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	grant, found := s.oauth2PermissionGrants.get(id)
+	if !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	body, err := readObject(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	// only the scope of a grant may be updated
+	for key := range body {
+		if key != "scope" {
+			writeBadRequest(w, fmt.Errorf("property '%s' of an oauth2 permission grant cannot be updated", key))
+			return
+		}
+	}
+
+	grant.merge(body)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteOAuth2PermissionGrant(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !s.oauth2PermissionGrants.remove(id) {
+		writeNotFound(w, id)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,122 @@
+package graph
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var equalityClause = regexp.MustCompile(`^(\w+) eq '((?:[^']|'')*)'$`)
+
+// predicate matches directory entities against an OData $filter expression.
+type predicate func(object) bool
+
+// parseFilter supports the subset of OData filter expressions used by azurerator, i.e. one or more equality
+// comparisons of string properties joined by 'and', optionally combined with 'or'. As in OData, 'and' binds tighter
+// than 'or'.
+func parseFilter(filter string) (predicate, error) {
+	filter = strings.TrimSpace(filter)
+	if len(filter) == 0 {
+		return func(object) bool { return true }, nil
+	}
+
+	type clause struct {
+		property string
+		value    string
+	}
+
+	alternatives := make([][]clause, 0)
+	for _, alternative := range strings.Split(filter, " or ") {
+		clauses := make([]clause, 0)
+		for _, expr := range strings.Split(alternative, " and ") {
+			match := equalityClause.FindStringSubmatch(strings.TrimSpace(expr))
+			if match == nil {
+				return nil, fmt.Errorf("unsupported filter expression: %q", expr)
+			}
+
+			clauses = append(clauses, clause{
+				property: match[1],
+				value:    strings.ReplaceAll(match[2], "''", "'"),
+			})
+		}
+		alternatives = append(alternatives, clauses)
+	}
+
+	return func(obj object) bool {
+		for _, clauses := range alternatives {
+			matches := true
+			for _, c := range clauses {
+				if obj.string(c.property) != c.value {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// writeCollection writes a single page of the given entities, including a next link if there are more pages.
+func (s *Server) writeCollection(w http.ResponseWriter, r *http.Request, objects []object) {
+	query := r.URL.Query()
+
+	pageSize := s.PageSize
+	if top := query.Get("$top"); len(top) > 0 {
+		n, err := strconv.Atoi(top)
+		if err != nil || n <= 0 {
+			writeBadRequest(w, fmt.Errorf("invalid $top value: %q", top))
+			return
+		}
+		pageSize = min(n, pageSize)
+	}
+
+	offset := 0
+	if token := query.Get("$skiptoken"); len(token) > 0 {
+		n, err := strconv.Atoi(token)
+		if err != nil || n < 0 {
+			writeBadRequest(w, fmt.Errorf("invalid $skiptoken value: %q", token))
+			return
+		}
+		offset = min(n, len(objects))
+	}
+
+	end := min(offset+pageSize, len(objects))
+	page := make([]any, 0, end-offset)
+	for _, obj := range objects[offset:end] {
+		page = append(page, map[string]any(obj.clone()))
+	}
+
+	body := map[string]any{
+		"value": page,
+	}
+
+	if end < len(objects) {
+		query.Set("$skiptoken", strconv.Itoa(end))
+		body["@odata.nextLink"] = fmt.Sprintf("%s%s?%s", s.URL, r.URL.Path, query.Encode())
+	}
+
+	writeJSON(w, http.StatusOK, body)
+}
+
+// writeFilteredCollection writes the entities matching the request's $filter parameter.
+func (s *Server) writeFilteredCollection(w http.ResponseWriter, r *http.Request, objects []object) {
+	match, err := parseFilter(r.URL.Query().Get("$filter"))
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	filtered := make([]object, 0)
+	for _, obj := range objects {
+		if match(obj) {
+			filtered = append(filtered, obj)
+		}
+	}
+
+	s.writeCollection(w, r, filtered)
+}
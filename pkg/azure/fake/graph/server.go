@@ -0,0 +1,467 @@
+// Package graph provides an in-process stand-in for the subset of the Microsoft Graph v1.0 API used by azurerator,
+// backed by an in-memory directory. Point [config.AzureConfig] Graph.BaseURL at [Server.BaseURL] and use
+// [client.NewWithHttpClient] to exercise the real Graph client without network access.
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	msgraph "github.com/nais/msgraph.go/v1.0"
+)
+
+const (
+	// DefaultPageSize is the number of entities returned per page for collection requests.
+	DefaultPageSize = 100
+
+	apiVersionPath = "/v1.0"
+)
+
+// Request is a record of a single request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Body   []byte
+}
+
+type Server struct {
+	*httptest.Server
+
+	// PageSize is the maximum number of entities returned per page for collection requests.
+	PageSize int
+
+	mu                     sync.Mutex
+	applications           *collection
+	servicePrincipals      *collection
+	groups                 *collection
+	users                  *collection
+	claimsMappingPolicies  *collection
+	tokenLifetimePolicies  *collection
+	oauth2PermissionGrants *collection
+	appRoleAssignments     *collection
+	owners                 references
+	assignedPolicies       references
+	// groupMembers holds the direct members of groups, i.e. users or other groups, keyed by the object ID of the group.
+	groupMembers references
+	// assignedTokenLifetimePolicies holds the token lifetime policies keyed by the object ID of the application.
+	assignedTokenLifetimePolicies references
+	// federatedIdentityCredentials holds the federated identity credentials keyed by the object ID of the application.
+	federatedIdentityCredentials map[string]*collection
+	requests                     []Request
+}
+
+// NewServer starts and returns a new server with an empty directory. The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		PageSize:                      DefaultPageSize,
+		applications:                  newCollection(),
+		servicePrincipals:             newCollection(),
+		groups:                        newCollection(),
+		users:                         newCollection(),
+		claimsMappingPolicies:         newCollection(),
+		tokenLifetimePolicies:         newCollection(),
+		oauth2PermissionGrants:        newCollection(),
+		appRoleAssignments:            newCollection(),
+		owners:                        make(references),
+		assignedPolicies:              make(references),
+		groupMembers:                  make(references),
+		assignedTokenLifetimePolicies: make(references),
+		federatedIdentityCredentials:  make(map[string]*collection),
+		requests:                      make([]Request, 0),
+	}
+	s.Server = httptest.NewServer(s.routes())
+	return s
+}
+
+// BaseURL returns the base URL of the emulated Graph API, i.e. the equivalent of https://graph.microsoft.com/v1.0.
+func (s *Server) BaseURL() string {
+	return s.URL + apiVersionPath
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	handle := func(pattern string, handler http.HandlerFunc) {
+		method, path, _ := strings.Cut(pattern, " ")
+		mux.HandleFunc(method+" "+apiVersionPath+path, handler)
+	}
+
+	handle("GET /applications", s.listApplications)
+	handle("POST /applications", s.createApplication)
+	handle("GET /applications/{id}", s.getApplication)
+	handle("PATCH /applications/{id}", s.patchApplication)
+	handle("DELETE /applications/{id}", s.deleteApplication)
+	handle("POST /applications/{id}/addPassword", s.addPassword)
+	handle("POST /applications/{id}/removePassword", s.removePassword)
+	handle("GET /applications/{id}/federatedIdentityCredentials", s.listFederatedIdentityCredentials)
+	handle("POST /applications/{id}/federatedIdentityCredentials", s.createFederatedIdentityCredential)
+	handle("DELETE /applications/{id}/federatedIdentityCredentials/{credentialId}", s.deleteFederatedIdentityCredential)
+	handle("GET /applications/{id}/owners", s.listOwners(s.applications))
+	handle("POST /applications/{id}/owners/$ref", s.addOwner(s.applications))
+	handle("DELETE /applications/{id}/owners/{ownerId}/$ref", s.removeOwner(s.applications))
+	handle("GET /applications/{id}/tokenLifetimePolicies", s.listAssignedTokenLifetimePolicies)
+	handle("POST /applications/{id}/tokenLifetimePolicies/$ref", s.assignTokenLifetimePolicy)
+	handle("DELETE /applications/{id}/tokenLifetimePolicies/{policyId}/$ref", s.removeTokenLifetimePolicy)
+
+	handle("GET /servicePrincipals", s.listServicePrincipals)
+	handle("POST /servicePrincipals", s.createServicePrincipal)
+	handle("GET /servicePrincipals/{id}", s.getServicePrincipal)
+	handle("PATCH /servicePrincipals/{id}", s.patchServicePrincipal)
+	handle("GET /servicePrincipals/{id}/owners", s.listOwners(s.servicePrincipals))
+	handle("POST /servicePrincipals/{id}/owners/$ref", s.addOwner(s.servicePrincipals))
+	handle("DELETE /servicePrincipals/{id}/owners/{ownerId}/$ref", s.removeOwner(s.servicePrincipals))
+	handle("GET /servicePrincipals/{id}/appRoleAssignedTo", s.listAppRoleAssignments)
+	handle("POST /servicePrincipals/{id}/appRoleAssignedTo", s.createAppRoleAssignment)
+	handle("DELETE /servicePrincipals/{id}/appRoleAssignedTo/{assignmentId}", s.deleteAppRoleAssignment)
+	handle("GET /servicePrincipals/{id}/appRoleAssignments", s.listPrincipalAppRoleAssignments)
+	handle("POST /servicePrincipals/{id}/appRoleAssignments", s.createPrincipalAppRoleAssignment)
+	handle("DELETE /servicePrincipals/{id}/appRoleAssignments/{assignmentId}", s.deletePrincipalAppRoleAssignment)
+	handle("GET /servicePrincipals/{id}/claimsMappingPolicies", s.listAssignedPolicies)
+	handle("POST /servicePrincipals/{id}/claimsMappingPolicies/$ref", s.assignPolicy)
+	handle("DELETE /servicePrincipals/{id}/claimsMappingPolicies/{policyId}/$ref", s.removePolicy)
+
+	handle("GET /groups", s.listGroups)
+	handle("GET /groups/{id}", s.getGroup)
+	handle("GET /groups/{id}/transitiveMembers", s.listTransitiveMembers)
+
+	handle("GET /users", s.listUsers)
+
+	handle("GET /oauth2PermissionGrants", s.listOAuth2PermissionGrants)
+	handle("POST /oauth2PermissionGrants", s.createOAuth2PermissionGrant)
+	handle("PATCH /oauth2PermissionGrants/{id}", s.patchOAuth2PermissionGrant)
+	handle("DELETE /oauth2PermissionGrants/{id}", s.deleteOAuth2PermissionGrant)
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("%s %s is not supported by the fake Graph API", r.Method, r.URL.Path))
+	})
+
+	return s.record(mux)
+}
+
+// record stores every incoming request and serializes access to the directory.
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Request_BadRequest", err.Error())
+			return
+		}
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.requests = append(s.requests, Request{
+			Method: r.Method,
+			Path:   strings.TrimPrefix(r.URL.Path, apiVersionPath),
+			Query:  r.URL.RawQuery,
+			Body:   body,
+		})
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Requests returns all requests received by the server so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// AddServicePrincipal seeds the directory with a service principal for an application that is not managed by
+// azurerator, e.g. the service principal that the operator itself authenticates as. Returns the object ID.
+func (s *Server) AddServicePrincipal(clientId, displayName string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := newID()
+	s.servicePrincipals.add(object{
+		"id":          id,
+		"appId":       clientId,
+		"displayName": displayName,
+		"tags":        []any{},
+	})
+	return id
+}
+
+// AddAPI seeds the directory with a service principal for an API that is not managed by azurerator, e.g. Microsoft
+// Graph, which defines the given application permissions (app roles) and delegated permissions (OAuth2 permission
+// scopes). Returns the object ID.
+func (s *Server) AddAPI(clientId, displayName string, appRoles, scopes []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	roles := make([]object, 0, len(appRoles))
+	for _, value := range appRoles {
+		roles = append(roles, object{
+			"id":                 newID(),
+			"allowedMemberTypes": []any{"Application"},
+			"isEnabled":          true,
+			"value":              value,
+		})
+	}
+
+	permissionScopes := make([]object, 0, len(scopes))
+	for _, value := range scopes {
+		permissionScopes = append(permissionScopes, object{
+			"id":        newID(),
+			"isEnabled": true,
+			"type":      "Admin",
+			"value":     value,
+		})
+	}
+
+	id := newID()
+	sp := object{
+		"id":          id,
+		"appId":       clientId,
+		"displayName": displayName,
+		"tags":        []any{},
+	}
+	sp.setObjects("appRoles", roles)
+	sp.setObjects("oauth2PermissionScopes", permissionScopes)
+
+	s.servicePrincipals.add(sp)
+	return id
+}
+
+// AddGroup seeds the directory with a group. Returns the object ID.
+func (s *Server) AddGroup(displayName string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := newID()
+	s.groups.add(object{
+		"id":           id,
+		"displayName":  displayName,
+		"mailNickname": strings.ReplaceAll(strings.ToLower(displayName), " ", "-"),
+	})
+	return id
+}
+
+// AddGroupMember seeds the given group with a member, i.e. a user or another group.
+func (s *Server) AddGroupMember(groupId, memberId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groupMembers.add(groupId, memberId)
+}
+
+// AddOwner seeds the given application or service principal with an owner, e.g. a user added outside of azurerator.
+func (s *Server) AddOwner(objectId, ownerId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.owners.add(objectId, ownerId)
+}
+
+// AddUser seeds the directory with a user. Returns the object ID.
+func (s *Server) AddUser(userPrincipalName string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := newID()
+	name, _, _ := strings.Cut(userPrincipalName, "@")
+	s.users.add(object{
+		"id":                id,
+		"displayName":       name,
+		"userPrincipalName": userPrincipalName,
+	})
+	return id
+}
+
+// AddClaimsMappingPolicy seeds the directory with a claims-mapping policy. Returns the object ID.
+func (s *Server) AddClaimsMappingPolicy(displayName string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := newID()
+	s.claimsMappingPolicies.add(object{
+		"id":          id,
+		"displayName": displayName,
+		"definition":  []any{},
+	})
+	return id
+}
+
+// AddTokenLifetimePolicy seeds the directory with a token lifetime policy. Returns the object ID.
+func (s *Server) AddTokenLifetimePolicy(displayName string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := newID()
+	s.tokenLifetimePolicies.add(object{
+		"id":          id,
+		"displayName": displayName,
+		"definition":  []any{},
+	})
+	return id
+}
+
+// PatchApplication applies the given patch to the application with the given object ID, e.g. to simulate changes made
+// outside of azurerator.
+func (s *Server) PatchApplication(objectId string, patch map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if app, found := s.applications.get(objectId); found {
+		app.merge(patch)
+	}
+}
+
+// Application returns the application with the given object ID.
+func (s *Server) Application(objectId string) (msgraph.Application, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var app msgraph.Application
+	obj, found := s.applications.get(objectId)
+	if !found {
+		return app, false
+	}
+	return app, obj.decodeInto(&app) == nil
+}
+
+// Applications returns all applications in the directory.
+func (s *Server) Applications() []msgraph.Application {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return decodeAll[msgraph.Application](s.applications.list())
+}
+
+// ServicePrincipal returns the service principal with the given object ID.
+func (s *Server) ServicePrincipal(objectId string) (msgraph.ServicePrincipal, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sp msgraph.ServicePrincipal
+	obj, found := s.servicePrincipals.get(objectId)
+	if !found {
+		return sp, false
+	}
+	return sp, s.servicePrincipalView(obj).decodeInto(&sp) == nil
+}
+
+// AppRoleAssignments returns all app role assignments granted for the given resource service principal.
+// See [Server.PrincipalAppRoleAssignments] for the assignments granted to a service principal.
+func (s *Server) AppRoleAssignments(resourceId string) []msgraph.AppRoleAssignment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return decodeAll[msgraph.AppRoleAssignment](s.appRoleAssignmentsFor(resourceId))
+}
+
+// PrincipalAppRoleAssignments returns all app role assignments granted to the given principal.
+func (s *Server) PrincipalAppRoleAssignments(principalId string) []msgraph.AppRoleAssignment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return decodeAll[msgraph.AppRoleAssignment](s.appRoleAssignments.filter(func(obj object) bool {
+		return obj.string("principalId") == principalId
+	}))
+}
+
+// OAuth2PermissionGrants returns all delegated permission grants in the directory.
+func (s *Server) OAuth2PermissionGrants() []msgraph.OAuth2PermissionGrant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return decodeAll[msgraph.OAuth2PermissionGrant](s.oauth2PermissionGrants.list())
+}
+
+// Owners returns the object IDs of the owners of the given application or service principal.
+func (s *Server) Owners(objectId string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.owners.get(objectId)
+}
+
+// FederatedIdentityCredentials returns the federated identity credentials of the given application.
+func (s *Server) FederatedIdentityCredentials(objectId string) []msgraph.FederatedIdentityCredential {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	creds, found := s.federatedIdentityCredentials[objectId]
+	if !found {
+		return make([]msgraph.FederatedIdentityCredential, 0)
+	}
+	return decodeAll[msgraph.FederatedIdentityCredential](creds.list())
+}
+
+// ClaimsMappingPolicies returns the IDs of the claims-mapping policies assigned to the given service principal.
+func (s *Server) ClaimsMappingPolicies(servicePrincipalId string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.assignedPolicies.get(servicePrincipalId)
+}
+
+// TokenLifetimePolicies returns the IDs of the token lifetime policies assigned to the given application.
+func (s *Server) TokenLifetimePolicies(objectId string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.assignedTokenLifetimePolicies.get(objectId)
+}
+
+func decodeAll[T any](objects []object) []T {
+	result := make([]T, 0, len(objects))
+	for _, obj := range objects {
+		var t T
+		if err := obj.decodeInto(&t); err == nil {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func newID() string {
+	return uuid.New().String()
+}
+
+func readObject(r *http.Request) (object, error) {
+	obj := make(object)
+	if err := json.NewDecoder(r.Body).Decode(&obj); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("decoding request body: %w", err)
+	}
+	return obj, nil
+}
+
+// referencedID extracts the object ID from an OData reference, e.g. https://graph.microsoft.com/v1.0/directoryObjects/{id}.
+func referencedID(r *http.Request) (string, error) {
+	body, err := readObject(r)
+	if err != nil {
+		return "", err
+	}
+
+	ref := body.string("@odata.id")
+	if len(ref) == 0 {
+		return "", fmt.Errorf("missing '@odata.id' in request body")
+	}
+
+	return ref[strings.LastIndex(ref, "/")+1:], nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
+
+func writeNotFound(w http.ResponseWriter, id string) {
+	writeError(w, http.StatusNotFound, "Request_ResourceNotFound", fmt.Sprintf("Resource '%s' does not exist or one of its queried reference-property objects are not present.", id))
+}
+
+func writeBadRequest(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusBadRequest, "Request_BadRequest", err.Error())
+}
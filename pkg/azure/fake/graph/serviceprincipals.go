@@ -0,0 +1,346 @@
+package graph
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// DefaultAccessAppRoleId is the ID of the implicit default app role, which may be assigned without being defined.
+const DefaultAccessAppRoleId = "00000000-0000-0000-0000-000000000000"
+
+func (s *Server) listServicePrincipals(w http.ResponseWriter, r *http.Request) {
+	views := make([]object, 0)
+	for _, sp := range s.servicePrincipals.list() {
+		views = append(views, s.servicePrincipalView(sp))
+	}
+
+	s.writeFilteredCollection(w, r, views)
+}
+
+func (s *Server) createServicePrincipal(w http.ResponseWriter, r *http.Request) {
+	body, err := readObject(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	appId := body.string("appId")
+	apps := s.applications.filter(byAppId(appId))
+	if len(apps) == 0 {
+		writeBadRequest(w, fmt.Errorf("the appId '%s' of the service principal does not reference a valid application object", appId))
+		return
+	}
+
+	if len(s.servicePrincipals.filter(byAppId(appId))) > 0 {
+		writeError(w, http.StatusConflict, "Request_MultipleObjectsWithSameKeyValue", fmt.Sprintf("a service principal with appId '%s' already exists", appId))
+		return
+	}
+
+	stripReadOnly(body)
+
+	sp := object{
+		"appRoleAssignmentRequired": false,
+		"servicePrincipalType":      "Application",
+		"tags":                      []any{},
+	}
+	sp.merge(body)
+	sp["id"] = newID()
+	sp["appId"] = appId
+
+	s.servicePrincipals.add(sp)
+	writeJSON(w, http.StatusCreated, s.servicePrincipalView(sp))
+}
+
+func (s *Server) getServicePrincipal(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	sp, found := s.servicePrincipals.get(id)
+	if !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.servicePrincipalView(sp))
+}
+
+func (s *Server) patchServicePrincipal(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	sp, found := s.servicePrincipals.get(id)
+	if !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	body, err := readObject(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	stripReadOnly(body)
+
+	sp.merge(body)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// servicePrincipalView returns the service principal with the properties that Graph derives from the backing
+// application, if any.
+func (s *Server) servicePrincipalView(sp object) object {
+	view := sp.clone()
+
+	apps := s.applications.filter(byAppId(sp.string("appId")))
+	if len(apps) == 0 {
+		return view
+	}
+
+	app := apps[0].clone()
+	view["displayName"] = app["displayName"]
+	view["appRoles"] = app["appRoles"]
+	if api, ok := app["api"].(map[string]any); ok {
+		view["oauth2PermissionScopes"] = api["oauth2PermissionScopes"]
+	}
+	return view
+}
+
+// deleteServicePrincipal removes the service principal along with all assignments, grants and references to it.
+func (s *Server) deleteServicePrincipal(id string) {
+	s.servicePrincipals.remove(id)
+	s.owners.purge(id)
+	s.assignedPolicies.purge(id)
+
+	for _, assignment := range s.appRoleAssignments.filter(func(obj object) bool {
+		return obj.string("resourceId") == id || obj.string("principalId") == id
+	}) {
+		s.appRoleAssignments.remove(assignment.string("id"))
+	}
+
+	for _, grant := range s.oauth2PermissionGrants.filter(func(obj object) bool {
+		return obj.string("clientId") == id || obj.string("resourceId") == id
+	}) {
+		s.oauth2PermissionGrants.remove(grant.string("id"))
+	}
+}
+
+func (s *Server) appRoleAssignmentsFor(resourceId string) []object {
+	return s.appRoleAssignments.filter(func(obj object) bool {
+		return obj.string("resourceId") == resourceId
+	})
+}
+
+func (s *Server) listAppRoleAssignments(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, found := s.servicePrincipals.get(id); !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	s.writeCollection(w, r, s.appRoleAssignmentsFor(id))
+}
+
+func (s *Server) createAppRoleAssignment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, found := s.servicePrincipals.get(id); !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	body, err := readObject(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	if resourceId := body.string("resourceId"); resourceId != id {
+		writeBadRequest(w, fmt.Errorf("resourceId '%s' does not match the target service principal '%s'", resourceId, id))
+		return
+	}
+
+	s.addAppRoleAssignment(w, body)
+}
+
+// listPrincipalAppRoleAssignments lists the app role assignments granted to the service principal, as opposed to
+// assignments granted for the service principal as a resource.
+func (s *Server) listPrincipalAppRoleAssignments(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, found := s.servicePrincipals.get(id); !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	s.writeCollection(w, r, s.appRoleAssignments.filter(func(obj object) bool {
+		return obj.string("principalId") == id
+	}))
+}
+
+func (s *Server) createPrincipalAppRoleAssignment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, found := s.servicePrincipals.get(id); !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	body, err := readObject(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	if principalId := body.string("principalId"); principalId != id {
+		writeBadRequest(w, fmt.Errorf("principalId '%s' does not match the service principal '%s'", principalId, id))
+		return
+	}
+
+	s.addAppRoleAssignment(w, body)
+}
+
+func (s *Server) deletePrincipalAppRoleAssignment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	assignmentId := r.PathValue("assignmentId")
+
+	assignment, found := s.appRoleAssignments.get(assignmentId)
+	if !found || assignment.string("principalId") != id {
+		writeNotFound(w, assignmentId)
+		return
+	}
+
+	s.appRoleAssignments.remove(assignmentId)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) addAppRoleAssignment(w http.ResponseWriter, body object) {
+	resourceId := body.string("resourceId")
+	resource, found := s.servicePrincipals.get(resourceId)
+	if !found {
+		writeNotFound(w, resourceId)
+		return
+	}
+
+	principalId := body.string("principalId")
+	principal, principalType, found := s.principal(principalId)
+	if !found {
+		writeNotFound(w, principalId)
+		return
+	}
+
+	appRoleId := body.string("appRoleId")
+	if !s.hasAppRole(s.servicePrincipalView(resource), appRoleId) {
+		writeBadRequest(w, fmt.Errorf("permission being assigned was not found on application: '%s'", appRoleId))
+		return
+	}
+
+	duplicate := slices.ContainsFunc(s.appRoleAssignmentsFor(resourceId), func(obj object) bool {
+		return obj.string("principalId") == principalId && obj.string("appRoleId") == appRoleId
+	})
+	if duplicate {
+		writeBadRequest(w, fmt.Errorf("permission being assigned already exists on the object"))
+		return
+	}
+
+	assignment := object{
+		"id":                   newID(),
+		"appRoleId":            appRoleId,
+		"createdDateTime":      now(),
+		"principalDisplayName": principal["displayName"],
+		"principalId":          principalId,
+		"principalType":        principalType,
+		"resourceDisplayName":  s.servicePrincipalView(resource)["displayName"],
+		"resourceId":           resourceId,
+	}
+
+	s.appRoleAssignments.add(assignment)
+	writeJSON(w, http.StatusCreated, assignment.clone())
+}
+
+func (s *Server) deleteAppRoleAssignment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	assignmentId := r.PathValue("assignmentId")
+
+	assignment, found := s.appRoleAssignments.get(assignmentId)
+	if !found || assignment.string("resourceId") != id {
+		writeNotFound(w, assignmentId)
+		return
+	}
+
+	s.appRoleAssignments.remove(assignmentId)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) hasAppRole(sp object, appRoleId string) bool {
+	if appRoleId == DefaultAccessAppRoleId {
+		// the default access role is not available for applications with app roles that are assignable to users
+		return !slices.ContainsFunc(sp.objects("appRoles"), func(role object) bool {
+			return slices.Contains(role.strings("allowedMemberTypes"), "User")
+		})
+	}
+
+	return slices.ContainsFunc(sp.objects("appRoles"), func(role object) bool {
+		return role.string("id") == appRoleId
+	})
+}
+
+// principal looks up a directory object that may be assigned app roles.
+func (s *Server) principal(id string) (object, string, bool) {
+	if sp, found := s.servicePrincipals.get(id); found {
+		return s.servicePrincipalView(sp), "ServicePrincipal", true
+	}
+	if group, found := s.groups.get(id); found {
+		return group, "Group", true
+	}
+	return nil, "", false
+}
+
+func (s *Server) listAssignedPolicies(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, found := s.servicePrincipals.get(id); !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	policies := make([]object, 0)
+	for _, policyId := range s.assignedPolicies.get(id) {
+		if policy, found := s.claimsMappingPolicies.get(policyId); found {
+			policies = append(policies, policy)
+		}
+	}
+
+	s.writeCollection(w, r, policies)
+}
+
+func (s *Server) assignPolicy(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, found := s.servicePrincipals.get(id); !found {
+		writeNotFound(w, id)
+		return
+	}
+
+	policyId, err := referencedID(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	if _, found := s.claimsMappingPolicies.get(policyId); !found {
+		writeNotFound(w, policyId)
+		return
+	}
+
+	// a service principal may only have a single claims-mapping policy assigned
+	if len(s.assignedPolicies.get(id)) > 0 {
+		writeBadRequest(w, fmt.Errorf("service principal '%s' already has a claims-mapping policy assigned", id))
+		return
+	}
+
+	s.assignedPolicies.add(id, policyId)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removePolicy(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	policyId := r.PathValue("policyId")
+
+	if !s.assignedPolicies.remove(id, policyId) {
+		writeNotFound(w, policyId)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,480 @@
+// Package memory provides a stateful, in-memory implementation of [azure.Client] and [azure.Credentials].
+//
+// Unlike the stateless fake in [github.com/nais/azureator/pkg/azure/fake/client], applications, credentials and
+// pre-authorized applications are stored and survive across calls, and failures can be injected per operation.
+// It is intended for tests and as a local development backend; see the 'azure.in-memory.enabled' flag.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	msgraph "github.com/nais/msgraph.go/v1.0"
+
+	"github.com/nais/azureator/pkg/azure"
+	"github.com/nais/azureator/pkg/azure/client/application"
+	"github.com/nais/azureator/pkg/azure/resource"
+	"github.com/nais/azureator/pkg/azure/result"
+	"github.com/nais/azureator/pkg/config"
+	"github.com/nais/azureator/pkg/customresources"
+	"github.com/nais/azureator/pkg/transaction"
+)
+
+type Client struct {
+	mu           sync.Mutex
+	tenantId     string
+	clock        func() time.Time
+	apiSettings  config.AzureAPISettings
+	applications map[azure.ObjectId]*app
+	groups       map[azure.ObjectId]string
+	faults       map[Operation][]*Fault
+	calls        map[Operation]int
+}
+
+type app struct {
+	name               azure.DisplayName
+	objectId           azure.ObjectId
+	clientId           azure.ClientId
+	servicePrincipalId azure.ServicePrincipalId
+	managed            bool
+
+	// preAuthorizedApps holds the client IDs of the applications pre-authorized during the last create or update.
+	preAuthorizedApps            []azure.ClientId
+	keyCredentials               []msgraph.KeyCredential
+	passwordCredentials          []msgraph.PasswordCredential
+	federatedIdentityCredentials []msgraph.FederatedIdentityCredential
+}
+
+var _ azure.Client = &Client{}
+
+func NewClient(tenantId string) *Client {
+	return &Client{
+		tenantId:     tenantId,
+		clock:        time.Now,
+		applications: make(map[azure.ObjectId]*app),
+		groups:       make(map[azure.ObjectId]string),
+		faults:       make(map[Operation][]*Fault),
+		calls:        make(map[Operation]int),
+	}
+}
+
+// WithClock overrides the clock used to timestamp credentials and evaluate their expiry.
+func (c *Client) WithClock(clock func() time.Time) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clock
+	return c
+}
+
+// WithAPISettings overrides the API settings, e.g. the default access token version for applications that do not
+// request one.
+func (c *Client) WithAPISettings(settings config.AzureAPISettings) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apiSettings = settings
+	return c
+}
+
+// AddApplication registers an application and service principal that is not managed by azurerator, e.g. to
+// simulate a pre-authorized application appearing in the tenant. Returns the client ID.
+func (c *Client) AddApplication(name azure.DisplayName) azure.ClientId {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.register(name, false).clientId
+}
+
+// AddGroup registers a group with the given display name, e.g. to simulate a group appearing in the tenant. Returns
+// the object ID.
+func (c *Client) AddGroup(name string) azure.ObjectId {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := uuid.New().String()
+	c.groups[id] = name
+	return id
+}
+
+// Application returns a snapshot of the application with the given display name.
+func (c *Client) Application(name azure.DisplayName) (msgraph.Application, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, found := c.findByName(name)
+	if !found {
+		return msgraph.Application{}, false
+	}
+	return a.toGraph(), true
+}
+
+// Applications returns snapshots of all applications in the directory.
+func (c *Client) Applications() []msgraph.Application {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	apps := make([]msgraph.Application, 0, len(c.applications))
+	for _, a := range c.applications {
+		apps = append(apps, a.toGraph())
+	}
+	slices.SortFunc(apps, func(a, b msgraph.Application) int {
+		return strings.Compare(*a.DisplayName, *b.DisplayName)
+	})
+	return apps
+}
+
+func (c *Client) Create(tx transaction.Transaction) (*result.Application, error) {
+	if err := c.inject(tx.Ctx, OperationCreate); err != nil {
+		return nil, fmt.Errorf("registering application resource: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, found := c.findByName(tx.UniformResourceName); found {
+		return nil, fmt.Errorf("registering application resource: application '%s' already exists", tx.UniformResourceName)
+	}
+
+	a := c.register(tx.UniformResourceName, true)
+	tx.Instance.Status.ClientId = a.clientId
+	tx.Instance.Status.ObjectId = a.objectId
+	tx.Instance.Status.ServicePrincipalId = a.servicePrincipalId
+
+	return c.process(tx, a, result.OperationCreated), nil
+}
+
+func (c *Client) Delete(tx transaction.Transaction) error {
+	if err := c.inject(tx.Ctx, OperationDelete); err != nil {
+		return fmt.Errorf("failed to delete application: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, found := c.findByName(tx.UniformResourceName)
+	if !found {
+		return fmt.Errorf("application does not exist: %s (clientId: %s, objectId: %s)", tx.UniformResourceName, tx.Instance.GetClientId(), tx.Instance.GetObjectId())
+	}
+
+	delete(c.applications, a.objectId)
+	return nil
+}
+
+func (c *Client) Exists(tx transaction.Transaction) (*msgraph.Application, bool, error) {
+	if err := c.inject(tx.Ctx, OperationExists); err != nil {
+		return nil, false, fmt.Errorf("failed to get list applications: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, found := c.findByName(tx.UniformResourceName)
+	if !found {
+		return nil, false, nil
+	}
+
+	graphApp := a.toGraph()
+	return &graphApp, true, nil
+}
+
+func (c *Client) Get(tx transaction.Transaction) (msgraph.Application, error) {
+	if err := c.inject(tx.Ctx, OperationGet); err != nil {
+		return msgraph.Application{}, fmt.Errorf("fetching application with name '%s': %w", tx.UniformResourceName, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, found := c.findByName(tx.UniformResourceName)
+	if !found {
+		return msgraph.Application{}, fmt.Errorf("fetching application with name '%s': no matching azure applications found", tx.UniformResourceName)
+	}
+	return a.toGraph(), nil
+}
+
+func (c *Client) Update(tx transaction.Transaction) (*result.Application, error) {
+	if err := c.inject(tx.Ctx, OperationUpdate); err != nil {
+		return nil, fmt.Errorf("updating application resource: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, found := c.applications[tx.Instance.GetObjectId()]
+	if !found {
+		return nil, fmt.Errorf("updating application resource: application with object ID '%s' not found", tx.Instance.GetObjectId())
+	}
+	a.name = tx.UniformResourceName
+
+	return c.process(tx, a, result.OperationUpdated), nil
+}
+
+func (c *Client) GetServicePrincipal(tx transaction.Transaction) (msgraph.ServicePrincipal, error) {
+	if err := c.inject(tx.Ctx, OperationGetServicePrincipal); err != nil {
+		return msgraph.ServicePrincipal{}, fmt.Errorf("looking up existence of service principal: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, found := c.findByClientId(tx.Instance.GetClientId())
+	if !found {
+		return msgraph.ServicePrincipal{}, fmt.Errorf("registering service principal that did not exist: application with client ID '%s' not found", tx.Instance.GetClientId())
+	}
+
+	return msgraph.ServicePrincipal{
+		DirectoryObject: msgraph.DirectoryObject{
+			Entity: msgraph.Entity{ID: new(a.servicePrincipalId)},
+		},
+		AppID:       new(a.clientId),
+		DisplayName: new(a.name),
+	}, nil
+}
+
+func (c *Client) GetPreAuthorizedApps(tx transaction.Transaction) (*result.PreAuthorizedApps, error) {
+	if err := c.inject(tx.Ctx, OperationGetPreAuthorizedApps); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, found := c.findByName(tx.UniformResourceName)
+	if !found {
+		return nil, fmt.Errorf("fetching application with name '%s': no matching azure applications found", tx.UniformResourceName)
+	}
+
+	desired := c.desiredPreAuthorizedApps(tx)
+
+	// only applications that were pre-authorized during the last create or update are actually assigned
+	assigned := make([]resource.Resource, 0)
+	unassigned := desired.Invalid
+	for _, valid := range desired.Valid {
+		if slices.Contains(a.preAuthorizedApps, valid.ClientId) {
+			assigned = append(assigned, valid)
+		} else {
+			unassigned = append(unassigned, valid)
+		}
+	}
+
+	return &result.PreAuthorizedApps{
+		Valid:   assigned,
+		Invalid: unassigned,
+	}, nil
+}
+
+func (c *Client) PreAuthorizedAppClientID(ctx context.Context, rule v1.AccessPolicyRule) (string, bool, error) {
+	if err := c.inject(ctx, OperationPreAuthorizedAppClientID); err != nil {
+		return "", false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, found := c.findByName(customresources.GetUniqueName(rule))
+	if !found {
+		return "", false, nil
+	}
+	return a.clientId, true, nil
+}
+
+func (c *Client) GroupExists(ctx context.Context, reference string) (bool, error) {
+	if err := c.inject(ctx, OperationGroupExists); err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, _, found := c.findGroup(reference)
+	return found, nil
+}
+
+func (c *Client) Credentials() azure.Credentials {
+	return credentialsClient{Client: c}
+}
+
+// process pre-authorizes the desired applications that exist in the directory. It also registers the federated identity
+// credential, if enabled by the credential mode. The caller must hold the lock.
+func (c *Client) process(tx transaction.Transaction, a *app, operation result.Operation) *result.Application {
+	preAuthorizedApps := c.desiredPreAuthorizedApps(tx)
+
+	if tx.Options.Process.Secret.CredentialMode.WorkloadIdentity() {
+		a.processFederatedIdentityCredentials(tx)
+	}
+
+	a.preAuthorizedApps = make([]azure.ClientId, 0, len(preAuthorizedApps.Valid))
+	for _, valid := range preAuthorizedApps.Valid {
+		a.preAuthorizedApps = append(a.preAuthorizedApps, valid.ClientId)
+	}
+
+	return &result.Application{
+		ClientId:           a.clientId,
+		ObjectId:           a.objectId,
+		ServicePrincipalId: a.servicePrincipalId,
+		PreAuthorizedApps:  preAuthorizedApps,
+		Groups:             c.desiredGroups(tx),
+		Tenant:             c.tenantId,
+		AccessTokenVersion: tx.Options.Process.Azure.APISettings.AccessTokenVersionOr(c.apiSettings.DefaultAccessTokenVersion()),
+		Result:             operation,
+	}
+}
+
+// desiredGroups partitions the groups in the spec by whether they exist in the directory. The caller must hold the lock.
+func (c *Client) desiredGroups(tx transaction.Transaction) result.Groups {
+	groups := result.Groups{
+		Assigned: make([]result.Group, 0),
+		Skipped:  make([]result.Group, 0),
+	}
+	if tx.Instance.Spec.Claims == nil {
+		return groups
+	}
+
+	for _, group := range tx.Instance.Spec.Claims.Groups {
+		id, name, found := c.findGroup(group.ID)
+		if !found {
+			groups.Skipped = append(groups.Skipped, result.Group{Reference: group.ID, Reason: "does not exist"})
+			continue
+		}
+		groups.Assigned = append(groups.Assigned, result.Group{Reference: group.ID, ObjectId: id, Name: name})
+	}
+	return groups
+}
+
+// desiredPreAuthorizedApps partitions the pre-authorized applications in the spec by whether they exist in the
+// directory. As with the Graph client, the application is always pre-authorized for itself. The caller must hold the lock.
+func (c *Client) desiredPreAuthorizedApps(tx transaction.Transaction) result.PreAuthorizedApps {
+	seen := make(map[string]bool)
+	valid := make([]resource.Resource, 0)
+	invalid := make([]resource.Resource, 0)
+
+	for _, rule := range tx.Instance.Spec.PreAuthorizedApplications {
+		if len(rule.Cluster) == 0 {
+			rule.Cluster = tx.ClusterName
+		}
+		if len(rule.Namespace) == 0 {
+			rule.Namespace = tx.Instance.GetNamespace()
+		}
+
+		name := customresources.GetUniqueName(rule.AccessPolicyRule)
+		res := resource.Resource{
+			Name:                    name,
+			PrincipalType:           resource.PrincipalTypeServicePrincipal,
+			AccessPolicyInboundRule: rule,
+		}
+
+		a, found := c.findByName(name)
+		if !found {
+			invalid = append(invalid, res)
+			continue
+		}
+
+		if !seen[name] {
+			seen[name] = true
+			res.ClientId = a.clientId
+			res.ObjectId = a.servicePrincipalId
+			valid = append(valid, res)
+		}
+	}
+
+	if !seen[tx.UniformResourceName] {
+		valid = append(valid, resource.Resource{
+			Name:          tx.UniformResourceName,
+			ClientId:      tx.Instance.GetClientId(),
+			ObjectId:      tx.Instance.GetServicePrincipalId(),
+			PrincipalType: resource.PrincipalTypeServicePrincipal,
+			AccessPolicyInboundRule: v1.AccessPolicyInboundRule{
+				AccessPolicyRule: v1.AccessPolicyRule{
+					Application: tx.Instance.GetName(),
+					Namespace:   tx.Instance.GetNamespace(),
+					Cluster:     tx.ClusterName,
+				},
+			},
+		})
+	}
+
+	return result.PreAuthorizedApps{
+		Valid:   valid,
+		Invalid: invalid,
+	}
+}
+
+// register adds a new application with a service principal. The caller must hold the lock.
+func (c *Client) register(name azure.DisplayName, managed bool) *app {
+	a := &app{
+		name:                name,
+		objectId:            uuid.New().String(),
+		clientId:            uuid.New().String(),
+		servicePrincipalId:  uuid.New().String(),
+		managed:             managed,
+		preAuthorizedApps:   make([]azure.ClientId, 0),
+		keyCredentials:      make([]msgraph.KeyCredential, 0),
+		passwordCredentials: make([]msgraph.PasswordCredential, 0),
+	}
+	c.applications[a.objectId] = a
+	return a
+}
+
+func (c *Client) findByName(name azure.DisplayName) (*app, bool) {
+	for _, a := range c.applications {
+		if a.name == name {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// findGroup looks up a group by object ID or display name. The caller must hold the lock.
+func (c *Client) findGroup(reference string) (azure.ObjectId, string, bool) {
+	if name, found := c.groups[reference]; found {
+		return reference, name, true
+	}
+	for id, name := range c.groups {
+		if name == reference {
+			return id, name, true
+		}
+	}
+	return "", "", false
+}
+
+func (c *Client) findByClientId(clientId azure.ClientId) (*app, bool) {
+	for _, a := range c.applications {
+		if a.clientId == clientId {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+func (a *app) toGraph() msgraph.Application {
+	preAuthorizedApps := make([]msgraph.PreAuthorizedApplication, 0, len(a.preAuthorizedApps))
+	for _, clientId := range a.preAuthorizedApps {
+		preAuthorizedApps = append(preAuthorizedApps, msgraph.PreAuthorizedApplication{
+			AppID: new(clientId),
+		})
+	}
+
+	tags := make([]string, 0)
+	if a.managed {
+		tags = append(tags, application.IaCAppTag, application.IntegratedAppTag)
+	}
+
+	return msgraph.Application{
+		DirectoryObject: msgraph.DirectoryObject{
+			Entity: msgraph.Entity{ID: new(a.objectId)},
+		},
+		AppID:       new(a.clientId),
+		DisplayName: new(a.name),
+		Tags:        tags,
+		API: &msgraph.APIApplication{
+			PreAuthorizedApplications: preAuthorizedApps,
+		},
+		KeyCredentials:               slices.Clone(a.keyCredentials),
+		PasswordCredentials:          slices.Clone(a.passwordCredentials),
+		FederatedIdentityCredentials: slices.Clone(a.federatedIdentityCredentials),
+	}
+}
@@ -0,0 +1,388 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/nais/liberator/pkg/kubernetes"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/nais/azureator/pkg/azure/credentials"
+	"github.com/nais/azureator/pkg/azure/fake/memory"
+	"github.com/nais/azureator/pkg/azure/result"
+	"github.com/nais/azureator/pkg/config"
+	"github.com/nais/azureator/pkg/transaction"
+	"github.com/nais/azureator/pkg/transaction/secrets"
+)
+
+const clusterName = "test-cluster"
+
+func newTransaction(name string, preAuthorizedApps ...string) transaction.Transaction {
+	app := &v1.AzureAdApplication{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "test-namespace",
+		},
+		Spec: v1.AzureAdApplicationSpec{
+			SecretName: name,
+		},
+	}
+	for _, preAuthorizedApp := range preAuthorizedApps {
+		app.Spec.PreAuthorizedApplications = append(app.Spec.PreAuthorizedApplications, v1.AccessPolicyInboundRule{
+			AccessPolicyRule: v1.AccessPolicyRule{Application: preAuthorizedApp},
+		})
+	}
+
+	return transaction.Transaction{
+		Ctx:                 context.Background(),
+		ClusterName:         clusterName,
+		Instance:            app,
+		Logger:              *log.NewEntry(log.StandardLogger()),
+		UniformResourceName: kubernetes.UniformResourceName(app, clusterName),
+	}
+}
+
+func TestClient_Lifecycle(t *testing.T) {
+	c := memory.NewClient("some-tenant")
+	tx := newTransaction("test-app", "other")
+
+	res, err := c.Create(tx)
+	require.NoError(t, err)
+	assert.Equal(t, result.OperationCreated, res.Result)
+	assert.Equal(t, "some-tenant", res.Tenant)
+	assert.Equal(t, 2, res.AccessTokenVersion, "the default access token version should be used")
+	assert.Equal(t, res.ClientId, tx.Instance.GetClientId())
+	assert.Len(t, res.PreAuthorizedApps.Valid, 1, "only self is pre-authorized")
+	assert.Len(t, res.PreAuthorizedApps.Invalid, 1)
+
+	_, err = c.Create(tx)
+	assert.Error(t, err)
+
+	app, exists, err := c.Exists(tx)
+	require.NoError(t, err)
+	require.True(t, exists)
+	assert.Equal(t, res.ObjectId, *app.ID)
+
+	// the pre-authorized application appears in the tenant, but is only assigned on the next update
+	otherClientId := c.AddApplication(kubernetes.UniformResourceName(&metav1.ObjectMeta{Name: "other", Namespace: "test-namespace"}, clusterName))
+
+	preAuthorizedApps, err := c.GetPreAuthorizedApps(tx)
+	require.NoError(t, err)
+	assert.Len(t, preAuthorizedApps.Valid, 1)
+	assert.Len(t, preAuthorizedApps.Invalid, 1)
+
+	res, err = c.Update(tx)
+	require.NoError(t, err)
+	assert.Equal(t, result.OperationUpdated, res.Result)
+	assert.Len(t, res.PreAuthorizedApps.Valid, 2)
+	assert.Empty(t, res.PreAuthorizedApps.Invalid)
+
+	preAuthorizedApps, err = c.GetPreAuthorizedApps(tx)
+	require.NoError(t, err)
+	assert.Len(t, preAuthorizedApps.Valid, 2)
+	assert.Empty(t, preAuthorizedApps.Invalid)
+
+	clientId, found, err := c.PreAuthorizedAppClientID(tx.Ctx, v1.AccessPolicyRule{Application: "other", Namespace: "test-namespace", Cluster: clusterName})
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, otherClientId, clientId)
+
+	sp, err := c.GetServicePrincipal(tx)
+	require.NoError(t, err)
+	assert.Equal(t, res.ServicePrincipalId, *sp.ID)
+
+	err = c.Delete(tx)
+	require.NoError(t, err)
+
+	_, exists, err = c.Exists(tx)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	err = c.Delete(tx)
+	assert.Error(t, err)
+}
+
+func TestClient_AccessTokenVersion(t *testing.T) {
+	c := memory.NewClient("some-tenant").WithAPISettings(config.AzureAPISettings{AccessTokenVersion: 1})
+	tx := newTransaction("test-app")
+
+	res, err := c.Create(tx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.AccessTokenVersion, "the configured default should be used")
+
+	tx.Options.Process.Azure.APISettings.AccessTokenVersion = 2
+	res, err = c.Update(tx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.AccessTokenVersion, "the requested version should override the default")
+}
+
+func TestClient_Credentials(t *testing.T) {
+	now := time.Now()
+	c := memory.NewClient("some-tenant").WithClock(func() time.Time { return now })
+	tx := newTransaction("test-app")
+
+	_, err := c.Create(tx)
+	require.NoError(t, err)
+
+	set, err := c.Credentials().Add(tx)
+	require.NoError(t, err)
+
+	validation, err := c.Credentials().Validate(tx, set)
+	require.NoError(t, err)
+	assert.True(t, validation.Valid)
+	assert.WithinDuration(t, now.Add(365*24*time.Hour), validation.ExpiresAt, 0)
+
+	tx.Secrets = secrets.Secrets{
+		LatestCredentials: secrets.Credentials{Set: &set, Valid: validation.Valid},
+	}
+
+	rotated, err := c.Credentials().Rotate(tx)
+	require.NoError(t, err)
+	assert.Equal(t, set.Next, rotated.Current)
+	assert.NotEqual(t, set.Next.Certificate.KeyId, rotated.Next.Certificate.KeyId)
+
+	app, _ := c.Application(tx.UniformResourceName)
+	assert.Len(t, app.KeyCredentials, 3, "credentials in use should be kept when rotating")
+	assert.Len(t, app.PasswordCredentials, 3)
+
+	validation, err = c.Credentials().Validate(tx, rotated)
+	require.NoError(t, err)
+	states := make(map[string]credentials.State)
+	for _, registration := range validation.Registrations {
+		states[registration.KeyId] = registration.State
+	}
+	assert.Len(t, validation.Registrations, 6)
+	assert.Equal(t, map[string]credentials.State{
+		set.Current.Certificate.KeyId:     credentials.StateLingering,
+		set.Current.Password.KeyId:        credentials.StateLingering,
+		rotated.Current.Certificate.KeyId: credentials.StateCurrent,
+		rotated.Current.Password.KeyId:    credentials.StateCurrent,
+		rotated.Next.Certificate.KeyId:    credentials.StateNext,
+		rotated.Next.Password.KeyId:       credentials.StateNext,
+	}, states)
+
+	tx.Secrets.LatestCredentials.Set = &rotated
+	err = c.Credentials().DeleteUnused(tx)
+	require.NoError(t, err)
+
+	app, _ = c.Application(tx.UniformResourceName)
+	assert.Len(t, app.KeyCredentials, 2)
+	assert.Len(t, app.PasswordCredentials, 2)
+
+	validation, err = c.Credentials().Validate(tx, rotated)
+	require.NoError(t, err)
+	assert.True(t, validation.Valid)
+
+	validation, err = c.Credentials().Validate(tx, set)
+	require.NoError(t, err)
+	assert.False(t, validation.Valid)
+
+	t.Run("expired credentials are invalid and deleted", func(t *testing.T) {
+		now = now.Add(366 * 24 * time.Hour)
+
+		validation, err := c.Credentials().Validate(tx, rotated)
+		require.NoError(t, err)
+		assert.False(t, validation.Valid)
+
+		err = c.Credentials().DeleteExpired(tx)
+		require.NoError(t, err)
+
+		app, _ := c.Application(tx.UniformResourceName)
+		assert.Empty(t, app.KeyCredentials)
+		assert.Empty(t, app.PasswordCredentials)
+	})
+}
+
+func TestClient_CredentialMode(t *testing.T) {
+	c := memory.NewClient("some-tenant")
+	tx := newTransaction("test-app")
+
+	_, err := c.Create(tx)
+	require.NoError(t, err)
+
+	set, err := c.Credentials().Add(tx)
+	require.NoError(t, err)
+
+	tx.Options.Process.Secret.CredentialMode = credentials.ModeCertificate
+
+	validation, err := c.Credentials().Validate(tx, set)
+	require.NoError(t, err)
+	assert.False(t, validation.Valid, "credentials with client secrets should be invalid in certificate mode")
+
+	certificateOnly, err := c.Credentials().Add(tx)
+	require.NoError(t, err)
+	assert.Empty(t, certificateOnly.Current.Password)
+	assert.Empty(t, certificateOnly.Next.Password)
+	assert.NotEmpty(t, certificateOnly.Next.Certificate.KeyId)
+
+	app, _ := c.Application(tx.UniformResourceName)
+	assert.Empty(t, app.PasswordCredentials, "existing client secrets should be revoked")
+
+	validation, err = c.Credentials().Validate(tx, certificateOnly)
+	require.NoError(t, err)
+	assert.True(t, validation.Valid)
+
+	tx.Secrets = secrets.Secrets{
+		LatestCredentials: secrets.Credentials{Set: &certificateOnly, Valid: validation.Valid},
+	}
+
+	rotated, err := c.Credentials().Rotate(tx)
+	require.NoError(t, err)
+	assert.Empty(t, rotated.Next.Password)
+
+	app, _ = c.Application(tx.UniformResourceName)
+	assert.Empty(t, app.PasswordCredentials)
+
+	tx.Options.Process.Secret.CredentialMode = credentials.ModePassword
+
+	passwordOnly, err := c.Credentials().Add(tx)
+	require.NoError(t, err)
+	assert.Empty(t, passwordOnly.Current.Certificate.KeyId)
+
+	app, _ = c.Application(tx.UniformResourceName)
+	assert.Empty(t, app.KeyCredentials, "existing key credentials should be revoked")
+	assert.Len(t, app.PasswordCredentials, 2)
+}
+
+func TestClient_WorkloadIdentity(t *testing.T) {
+	c := memory.NewClient("some-tenant")
+	tx := newTransaction("test-app")
+	tx.Options.Process.Secret.CredentialMode = credentials.ModeWorkloadIdentity
+	tx.Options.Process.Secret.WorkloadIdentity = credentials.WorkloadIdentity{
+		Audience: "api://AzureADTokenExchange",
+		Issuer:   "https://issuer.example.com",
+		Subject:  credentials.ServiceAccountSubject("test-namespace", "test-app"),
+	}
+
+	_, err := c.Create(tx)
+	require.NoError(t, err)
+
+	app, _ := c.Application(tx.UniformResourceName)
+	require.Len(t, app.FederatedIdentityCredentials, 1)
+	assert.Equal(t, "system:serviceaccount:test-namespace:test-app", *app.FederatedIdentityCredentials[0].Subject)
+
+	set, err := c.Credentials().Add(tx)
+	require.NoError(t, err)
+	assert.Equal(t, credentials.Set{}, set)
+
+	app, _ = c.Application(tx.UniformResourceName)
+	assert.Len(t, app.FederatedIdentityCredentials, 1)
+	assert.Empty(t, app.KeyCredentials)
+	assert.Empty(t, app.PasswordCredentials)
+
+	validation, err := c.Credentials().Validate(tx, set)
+	require.NoError(t, err)
+	assert.True(t, validation.Valid)
+
+	t.Run("changed service account invalidates credentials", func(t *testing.T) {
+		tx := tx
+		tx.Options.Process.Secret.WorkloadIdentity.Subject = credentials.ServiceAccountSubject("test-namespace", "other")
+
+		validation, err := c.Credentials().Validate(tx, set)
+		require.NoError(t, err)
+		assert.False(t, validation.Valid)
+	})
+
+	t.Run("switching mode removes federated identity credential", func(t *testing.T) {
+		tx := tx
+		tx.Options.Process.Secret.CredentialMode = credentials.ModeBoth
+
+		validation, err := c.Credentials().Validate(tx, set)
+		require.NoError(t, err)
+		assert.False(t, validation.Valid)
+
+		_, err = c.Credentials().Add(tx)
+		require.NoError(t, err)
+
+		app, _ := c.Application(tx.UniformResourceName)
+		assert.Empty(t, app.FederatedIdentityCredentials)
+		assert.Len(t, app.KeyCredentials, 2)
+		assert.Len(t, app.PasswordCredentials, 2)
+	})
+}
+
+func TestClient_InjectFault(t *testing.T) {
+	t.Run("throttling", func(t *testing.T) {
+		c := memory.NewClient("some-tenant")
+		tx := newTransaction("test-app")
+		c.InjectFault(memory.OperationCreate, memory.Throttle(2))
+
+		for range 2 {
+			_, err := c.Create(tx)
+			assert.ErrorIs(t, err, memory.ErrThrottled)
+		}
+
+		_, exists, err := c.Exists(tx)
+		require.NoError(t, err)
+		assert.False(t, exists, "failed calls should not modify the directory")
+
+		_, err = c.Create(tx)
+		require.NoError(t, err)
+		assert.Equal(t, 3, c.Calls(memory.OperationCreate))
+	})
+
+	t.Run("latency respects context", func(t *testing.T) {
+		c := memory.NewClient("some-tenant")
+		tx := newTransaction("test-app")
+		c.InjectFault(memory.OperationExists, memory.Fault{Latency: time.Minute})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		tx.Ctx = ctx
+
+		_, _, err := c.Exists(tx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("persistent fault until cleared", func(t *testing.T) {
+		c := memory.NewClient("some-tenant")
+		tx := newTransaction("test-app")
+		c.InjectFault(memory.OperationGet, memory.Fault{Err: assert.AnError})
+
+		for range 3 {
+			_, err := c.Get(tx)
+			assert.ErrorIs(t, err, assert.AnError)
+		}
+
+		c.ClearFaults()
+		_, err := c.Get(tx)
+		assert.NotErrorIs(t, err, assert.AnError)
+	})
+}
+
+func TestClient_Groups(t *testing.T) {
+	c := memory.NewClient("some-tenant")
+	groupId := c.AddGroup("some-group")
+
+	tx := newTransaction("test-app")
+	tx.Instance.Spec.Claims = &v1.AzureAdClaims{
+		Groups: []v1.AzureAdGroup{{ID: groupId}, {ID: "other-group"}},
+	}
+
+	res, err := c.Create(tx)
+	require.NoError(t, err)
+	assert.Equal(t, []result.Group{{Reference: groupId, ObjectId: groupId, Name: "some-group"}}, res.Groups.Assigned)
+	assert.Equal(t, []result.Group{{Reference: "other-group", Reason: "does not exist"}}, res.Groups.Skipped)
+
+	exists, err := c.GroupExists(tx.Ctx, "other-group")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// the group appears in the tenant, and is assigned on the next update
+	otherGroupId := c.AddGroup("other-group")
+
+	exists, err = c.GroupExists(tx.Ctx, "other-group")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	res, err = c.Update(tx)
+	require.NoError(t, err)
+	assert.Len(t, res.Groups.Assigned, 2)
+	assert.Equal(t, otherGroupId, res.Groups.Assigned[1].ObjectId)
+	assert.Empty(t, res.Groups.Skipped)
+}
@@ -0,0 +1,353 @@
+package memory
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	msgraph "github.com/nais/msgraph.go/v1.0"
+
+	"github.com/nais/azureator/pkg/azure"
+	"github.com/nais/azureator/pkg/azure/client/application/federatedidentitycredential"
+	"github.com/nais/azureator/pkg/azure/credentials"
+	"github.com/nais/azureator/pkg/azure/util"
+	"github.com/nais/azureator/pkg/transaction"
+	"github.com/nais/azureator/pkg/util/crypto"
+)
+
+type credentialsClient struct {
+	*Client
+}
+
+var _ azure.Credentials = credentialsClient{}
+
+func (c credentialsClient) Add(tx transaction.Transaction) (credentials.Set, error) {
+	if err := c.inject(tx.Ctx, OperationCredentialsAdd); err != nil {
+		return credentials.Set{}, fmt.Errorf("adding current password credential: %w", err)
+	}
+
+	mode := tx.Options.Process.Secret.CredentialMode
+
+	currentJwk, err := generateJwk(tx)
+	if err != nil {
+		return credentials.Set{}, fmt.Errorf("adding key credential set: %w", err)
+	}
+
+	nextJwk, err := generateJwk(tx)
+	if err != nil {
+		return credentials.Set{}, fmt.Errorf("adding key credential set: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, err := c.appFor(tx)
+	if err != nil {
+		return credentials.Set{}, err
+	}
+
+	a.purgeDisabled(mode)
+
+	if mode.WorkloadIdentity() {
+		a.processFederatedIdentityCredentials(tx)
+	} else {
+		a.purgeFederatedIdentityCredentials()
+	}
+
+	return credentials.Set{
+		Current: a.addCredentials(c.clock(), currentJwk, mode, validity(tx)),
+		Next:    a.addCredentials(c.clock(), nextJwk, mode, validity(tx)),
+	}, nil
+}
+
+func (c credentialsClient) DeleteExpired(tx transaction.Transaction) error {
+	if err := c.inject(tx.Ctx, OperationCredentialsDeleteExpired); err != nil {
+		return fmt.Errorf("deleting expired key credentials: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, err := c.appFor(tx)
+	if err != nil {
+		return err
+	}
+
+	now := c.clock()
+	a.keyCredentials = slices.DeleteFunc(a.keyCredentials, func(cred msgraph.KeyCredential) bool {
+		return cred.EndDateTime.Before(now)
+	})
+	a.passwordCredentials = slices.DeleteFunc(a.passwordCredentials, func(cred msgraph.PasswordCredential) bool {
+		return cred.EndDateTime.Before(now)
+	})
+	return nil
+}
+
+func (c credentialsClient) DeleteUnused(tx transaction.Transaction) error {
+	if err := c.inject(tx.Ctx, OperationCredentialsDeleteUnused); err != nil {
+		return fmt.Errorf("deleting unused key credentials: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, err := c.appFor(tx)
+	if err != nil {
+		return err
+	}
+
+	a.purgeDisabled(tx.Options.Process.Secret.CredentialMode)
+	a.revokeUnused(tx)
+	return nil
+}
+
+func (c credentialsClient) Purge(tx transaction.Transaction) error {
+	if err := c.inject(tx.Ctx, OperationCredentialsPurge); err != nil {
+		return fmt.Errorf("purging password credentials: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, err := c.appFor(tx)
+	if err != nil {
+		return err
+	}
+
+	a.keyCredentials = make([]msgraph.KeyCredential, 0)
+	a.passwordCredentials = make([]msgraph.PasswordCredential, 0)
+	a.purgeFederatedIdentityCredentials()
+	return nil
+}
+
+// Rotate revokes credentials that are not in use and registers a new set of next credentials, keeping the
+// previous next credentials as the current credentials.
+func (c credentialsClient) Rotate(tx transaction.Transaction) (credentials.Set, error) {
+	if err := c.inject(tx.Ctx, OperationCredentialsRotate); err != nil {
+		return credentials.Set{}, fmt.Errorf("rotating password credential: %w", err)
+	}
+
+	nextJwk, err := generateJwk(tx)
+	if err != nil {
+		return credentials.Set{}, fmt.Errorf("rotating key credential: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, err := c.appFor(tx)
+	if err != nil {
+		return credentials.Set{}, err
+	}
+
+	a.revokeUnused(tx)
+
+	return credentials.Set{
+		Current: tx.Secrets.LatestCredentials.Set.Next,
+		Next:    a.addCredentials(c.clock(), nextJwk, tx.Options.Process.Secret.CredentialMode, validity(tx)),
+	}, nil
+}
+
+func (c credentialsClient) Validate(tx transaction.Transaction, existing credentials.Set) (credentials.Validation, error) {
+	if err := c.inject(tx.Ctx, OperationCredentialsValidate); err != nil {
+		return credentials.Invalid, fmt.Errorf("validating password credentials: %w", err)
+	}
+
+	mode := tx.Options.Process.Secret.CredentialMode
+	if !mode.Matches(existing) {
+		return credentials.Invalid, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, err := c.appFor(tx)
+	if err != nil {
+		return credentials.Invalid, err
+	}
+
+	now := c.clock()
+	validation := credentials.Validation{Valid: true}
+	validate := func(endDateTime *time.Time) {
+		if endDateTime == nil || !endDateTime.After(now) {
+			validation.Valid = false
+			return
+		}
+		validation = validation.Merge(credentials.Validation{Valid: true, ExpiresAt: *endDateTime})
+	}
+	keyEndDateTime := func(keyId string) *time.Time {
+		for _, cred := range a.keyCredentials {
+			if string(*cred.KeyID) == keyId {
+				return cred.EndDateTime
+			}
+		}
+		return nil
+	}
+	passwordEndDateTime := func(keyId string) *time.Time {
+		for _, cred := range a.passwordCredentials {
+			if string(*cred.KeyID) == keyId {
+				return cred.EndDateTime
+			}
+		}
+		return nil
+	}
+
+	if mode.WorkloadIdentity() {
+		desired := federatedidentitycredential.Describe(tx.ClusterName, tx.Options.Process.Secret.WorkloadIdentity)
+		if !slices.ContainsFunc(a.federatedIdentityCredentials, func(cred msgraph.FederatedIdentityCredential) bool {
+			return federatedidentitycredential.Matches(cred, desired)
+		}) {
+			return credentials.Invalid, nil
+		}
+	}
+
+	if mode.Certificate() {
+		validate(keyEndDateTime(existing.Current.Certificate.KeyId))
+		validate(keyEndDateTime(existing.Next.Certificate.KeyId))
+		for _, cred := range a.keyCredentials {
+			validation.Registrations = append(validation.Registrations, credentials.NewRegistration(credentials.KindCertificate, string(*cred.KeyID), cred.StartDateTime, cred.EndDateTime, existing))
+		}
+	}
+
+	if mode.Password() {
+		validate(passwordEndDateTime(existing.Current.Password.KeyId))
+		validate(passwordEndDateTime(existing.Next.Password.KeyId))
+		for _, cred := range a.passwordCredentials {
+			validation.Registrations = append(validation.Registrations, credentials.NewRegistration(credentials.KindPassword, string(*cred.KeyID), cred.StartDateTime, cred.EndDateTime, existing))
+		}
+	}
+
+	if !validation.Valid {
+		return credentials.Invalid, nil
+	}
+	return validation, nil
+}
+
+// generateJwk generates a JWK for a new key credential, or returns an empty JWK if key credentials are disabled.
+func generateJwk(tx transaction.Transaction) (crypto.Jwk, error) {
+	if !tx.Options.Process.Secret.CredentialMode.Certificate() {
+		return crypto.Jwk{}, nil
+	}
+	return crypto.GenerateJwk(tx.Instance, tx.ClusterName, tx.Options.Process.Secret.Certificate)
+}
+
+// appFor looks up the application for the transaction. The caller must hold the lock.
+func (c *Client) appFor(tx transaction.Transaction) (*app, error) {
+	a, found := c.findByName(tx.UniformResourceName)
+	if !found {
+		return nil, fmt.Errorf("fetching application with name '%s': no matching azure applications found", tx.UniformResourceName)
+	}
+	return a, nil
+}
+
+// validity matches the lifetime of credentials registered by the Graph client.
+func validity(tx transaction.Transaction) time.Duration {
+	if validity := tx.Options.Process.Secret.Certificate.Validity; validity > 0 {
+		return validity
+	}
+	return crypto.DefaultCertificateValidity
+}
+
+// addCredentials registers a credential of each kind enabled by the given mode.
+func (a *app) addCredentials(now time.Time, jwk crypto.Jwk, mode credentials.Mode, validity time.Duration) credentials.Credentials {
+	startDateTime := now
+	endDateTime := now.Add(validity)
+	displayName := util.DisplayName(now)
+
+	var result credentials.Credentials
+
+	if mode.Certificate() {
+		keyId := msgraph.UUID(uuid.New().String())
+		key := msgraph.Binary(jwk.PublicPem)
+		a.keyCredentials = append(a.keyCredentials, msgraph.KeyCredential{
+			KeyID:         &keyId,
+			DisplayName:   new(displayName),
+			Type:          new("AsymmetricX509Cert"),
+			Usage:         new("Verify"),
+			Key:           &key,
+			StartDateTime: &startDateTime,
+			EndDateTime:   &endDateTime,
+		})
+
+		result.Certificate = credentials.Certificate{
+			KeyId: string(keyId),
+			Jwk:   jwk,
+		}
+	}
+
+	if mode.Password() {
+		passwordId := msgraph.UUID(uuid.New().String())
+		secret := uuid.New().String()
+		a.passwordCredentials = append(a.passwordCredentials, msgraph.PasswordCredential{
+			KeyID:         &passwordId,
+			DisplayName:   new(displayName),
+			Hint:          new(secret[:3]),
+			StartDateTime: &startDateTime,
+			EndDateTime:   &endDateTime,
+		})
+
+		result.Password = credentials.Password{
+			KeyId:        string(passwordId),
+			ClientSecret: secret,
+		}
+	}
+
+	return result
+}
+
+// purgeDisabled removes all credentials of the kinds disabled by the given mode.
+func (a *app) purgeDisabled(mode credentials.Mode) {
+	if !mode.Certificate() {
+		a.keyCredentials = make([]msgraph.KeyCredential, 0)
+	}
+	if !mode.Password() {
+		a.passwordCredentials = make([]msgraph.PasswordCredential, 0)
+	}
+}
+
+// processFederatedIdentityCredentials replaces any managed federated identity credentials with the desired one.
+func (a *app) processFederatedIdentityCredentials(tx transaction.Transaction) {
+	a.purgeFederatedIdentityCredentials()
+
+	desired := federatedidentitycredential.Describe(tx.ClusterName, tx.Options.Process.Secret.WorkloadIdentity)
+	desired.ID = new(uuid.New().String())
+	a.federatedIdentityCredentials = append(a.federatedIdentityCredentials, desired)
+}
+
+// purgeFederatedIdentityCredentials removes all managed federated identity credentials.
+func (a *app) purgeFederatedIdentityCredentials() {
+	a.federatedIdentityCredentials = slices.DeleteFunc(a.federatedIdentityCredentials, federatedidentitycredential.IsManaged)
+}
+
+// revokeUnused removes all credentials that are neither in use nor the newest registered credential.
+func (a *app) revokeUnused(tx transaction.Transaction) {
+	inUse := func(used []string, current, next string) []string {
+		ids := slices.Clone(used)
+		if tx.Secrets.LatestCredentials.Set != nil {
+			ids = append(ids, current, next)
+		}
+		return ids
+	}
+
+	var latest credentials.Set
+	if tx.Secrets.LatestCredentials.Set != nil {
+		latest = *tx.Secrets.LatestCredentials.Set
+	}
+
+	keysInUse := inUse(tx.Secrets.KeyIDs.Used.Certificate, latest.Current.Certificate.KeyId, latest.Next.Certificate.KeyId)
+	if len(a.keyCredentials) > 0 {
+		newest := a.keyCredentials[len(a.keyCredentials)-1]
+		a.keyCredentials = slices.DeleteFunc(a.keyCredentials, func(cred msgraph.KeyCredential) bool {
+			return *cred.KeyID != *newest.KeyID && !slices.Contains(keysInUse, string(*cred.KeyID))
+		})
+	}
+
+	passwordsInUse := inUse(tx.Secrets.KeyIDs.Used.Password, latest.Current.Password.KeyId, latest.Next.Password.KeyId)
+	if len(a.passwordCredentials) > 0 {
+		newest := a.passwordCredentials[len(a.passwordCredentials)-1]
+		a.passwordCredentials = slices.DeleteFunc(a.passwordCredentials, func(cred msgraph.PasswordCredential) bool {
+			return *cred.KeyID != *newest.KeyID && !slices.Contains(passwordsInUse, string(*cred.KeyID))
+		})
+	}
+}
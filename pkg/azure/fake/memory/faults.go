@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Operation identifies a single method of [azure.Client] or [azure.Credentials].
+type Operation string
+
+const (
+	OperationCreate                   Operation = "Create"
+	OperationDelete                   Operation = "Delete"
+	OperationExists                   Operation = "Exists"
+	OperationGet                      Operation = "Get"
+	OperationUpdate                   Operation = "Update"
+	OperationGetPreAuthorizedApps     Operation = "GetPreAuthorizedApps"
+	OperationGetServicePrincipal      Operation = "GetServicePrincipal"
+	OperationPreAuthorizedAppClientID Operation = "PreAuthorizedAppClientID"
+	OperationGroupExists              Operation = "GroupExists"
+
+	OperationCredentialsAdd           Operation = "Credentials.Add"
+	OperationCredentialsDeleteExpired Operation = "Credentials.DeleteExpired"
+	OperationCredentialsDeleteUnused  Operation = "Credentials.DeleteUnused"
+	OperationCredentialsPurge         Operation = "Credentials.Purge"
+	OperationCredentialsRotate        Operation = "Credentials.Rotate"
+	OperationCredentialsValidate      Operation = "Credentials.Validate"
+)
+
+// ErrThrottled mimics the error returned by the Graph API when the request rate limit has been exceeded.
+var ErrThrottled = errors.New("429 Too Many Requests: application request limit has been reached")
+
+// Fault describes a failure to inject into calls to an [Operation].
+type Fault struct {
+	// Err is returned from the operation, if set. The directory is not modified.
+	Err error
+	// Latency delays the operation by the given duration, or until the transaction's context is done.
+	Latency time.Duration
+	// Times is the number of calls affected by the fault. Zero means all subsequent calls.
+	Times int
+}
+
+// Throttle returns a fault that rejects the given number of calls with [ErrThrottled].
+func Throttle(times int) Fault {
+	return Fault{Err: ErrThrottled, Times: times}
+}
+
+// InjectFault registers a fault for the given operation. Faults are applied in the order they were registered.
+func (c *Client) InjectFault(op Operation, fault Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults[op] = append(c.faults[op], &fault)
+}
+
+// ClearFaults removes all registered faults.
+func (c *Client) ClearFaults() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = make(map[Operation][]*Fault)
+}
+
+// Calls returns the number of calls made to the given operation, including calls that failed due to injected faults.
+func (c *Client) Calls(op Operation) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[op]
+}
+
+// inject records a call to the given operation and applies the next registered fault, if any.
+func (c *Client) inject(ctx context.Context, op Operation) error {
+	fault := c.nextFault(op)
+	if fault == nil {
+		return nil
+	}
+
+	if fault.Latency > 0 {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		select {
+		case <-time.After(fault.Latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return fault.Err
+}
+
+func (c *Client) nextFault(op Operation) *Fault {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls[op]++
+
+	faults := c.faults[op]
+	if len(faults) == 0 {
+		return nil
+	}
+
+	fault := *faults[0]
+	if faults[0].Times > 0 {
+		faults[0].Times--
+		if faults[0].Times == 0 {
+			c.faults[op] = faults[1:]
+		}
+	}
+
+	return &fault
+}
@@ -0,0 +1,111 @@
+package grouproles
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/nais/azureator/pkg/azure/permissions"
+)
+
+// Mapping assigns the members of a group to an app role of the application, e.g. 'admin=<group object ID>'.
+// The app role is assignable to users, and is emitted in the 'roles' claim for members of the group.
+// The group is referenced either by object ID, or by display name or mail nickname.
+type Mapping struct {
+	Role  string
+	Group string
+}
+
+// Parse parses a mapping in the form '<role>=<group>', where role is the value of the app role and group is a reference
+// to the group, i.e. its object ID, display name or mail nickname. Object IDs are normalized.
+func Parse(value string) (Mapping, error) {
+	role, group, found := strings.Cut(strings.TrimSpace(value), "=")
+	if !found || len(role) == 0 || len(group) == 0 {
+		return Mapping{}, fmt.Errorf("invalid group role '%s': must be in the form '<role>=<group>'", value)
+	}
+
+	if strings.ContainsAny(role, " ") {
+		return Mapping{}, fmt.Errorf("invalid group role '%s': role must not contain spaces", value)
+	}
+
+	if role == permissions.DefaultAppRoleValue || role == permissions.DefaultGroupRoleValue {
+		return Mapping{}, fmt.Errorf("invalid group role '%s': role '%s' is reserved", value, role)
+	}
+
+	if groupId, err := uuid.Parse(group); err == nil {
+		group = groupId.String()
+	}
+
+	return Mapping{
+		Role:  role,
+		Group: group,
+	}, nil
+}
+
+func (m Mapping) String() string {
+	return fmt.Sprintf("%s=%s", m.Role, m.Group)
+}
+
+type Mappings []Mapping
+
+// ParseAll parses the given mappings. The result is sorted and without duplicates.
+func ParseAll(values []string) (Mappings, error) {
+	result := make(Mappings, 0, len(values))
+	for _, value := range values {
+		mapping, err := Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mapping)
+	}
+
+	slices.SortFunc(result, func(a, b Mapping) int {
+		return cmp.Or(cmp.Compare(a.Role, b.Role), cmp.Compare(a.Group, b.Group))
+	})
+	return slices.Compact(result), nil
+}
+
+// Roles returns the distinct roles in the list, sorted.
+func (m Mappings) Roles() []string {
+	result := make([]string, 0)
+	for _, mapping := range m {
+		result = append(result, mapping.Role)
+	}
+
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// Groups returns the distinct group IDs in the list, sorted.
+func (m Mappings) Groups() []string {
+	result := make([]string, 0)
+	for _, mapping := range m {
+		result = append(result, mapping.Group)
+	}
+
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// RolesFor returns the roles that the given group is mapped to, sorted.
+func (m Mappings) RolesFor(group string) []string {
+	result := make([]string, 0)
+	for _, mapping := range m {
+		if mapping.Group == group {
+			result = append(result, mapping.Role)
+		}
+	}
+	return result
+}
+
+// String returns the mappings as a comma-separated list.
+func (m Mappings) String() string {
+	values := make([]string, 0, len(m))
+	for _, mapping := range m {
+		values = append(values, mapping.String())
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,70 @@
+package grouproles_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/azureator/pkg/azure/grouproles"
+)
+
+const (
+	groupA = "6d4d7b60-9c7a-4f1b-a1e4-5b1c2e0b8a11"
+	groupB = "0b2f4c1e-3f0e-4b8a-9a43-6f2f3b0c9d22"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		value    string
+		expected grouproles.Mapping
+		err      string
+	}{
+		{
+			value:    "admin=" + groupA,
+			expected: grouproles.Mapping{Role: "admin", Group: groupA},
+		},
+		{
+			value:    " reader=6D4D7B60-9C7A-4F1B-A1E4-5B1C2E0B8A11 ",
+			expected: grouproles.Mapping{Role: "reader", Group: groupA},
+		},
+		{value: "admin", err: "must be in the form"},
+		{value: "=" + groupA, err: "must be in the form"},
+		{value: "admin=", err: "must be in the form"},
+		{value: "some admin=" + groupA, err: "role must not contain spaces"},
+		{value: "access_as_application=" + groupA, err: "role 'access_as_application' is reserved"},
+		{value: "defaultrole=" + groupA, err: "role 'defaultrole' is reserved"},
+		{
+			value:    "admin=Some Group",
+			expected: grouproles.Mapping{Role: "admin", Group: "Some Group"},
+		},
+	} {
+		t.Run(tt.value, func(t *testing.T) {
+			mapping, err := grouproles.Parse(tt.value)
+			if len(tt.err) > 0 {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, mapping)
+		})
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	mappings, err := grouproles.ParseAll([]string{
+		"reader=" + groupA,
+		"admin=" + groupA,
+		"reader=" + groupB,
+		"admin=" + groupA,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "admin="+groupA+",reader="+groupB+",reader="+groupA, mappings.String())
+	assert.Equal(t, []string{"admin", "reader"}, mappings.Roles())
+	assert.Equal(t, []string{groupB, groupA}, mappings.Groups())
+	assert.Equal(t, []string{"admin", "reader"}, mappings.RolesFor(groupA))
+	assert.Equal(t, []string{"reader"}, mappings.RolesFor(groupB))
+
+	_, err = grouproles.ParseAll([]string{"admin=" + groupA, "invalid"})
+	assert.Error(t, err)
+}
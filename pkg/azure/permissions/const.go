@@ -18,4 +18,8 @@ const (
 	// DefaultGroupRoleId is the ID that denotes that the group should be assigned to the application without any special
 	// AppRole: https://docs.microsoft.com/en-us/graph/api/group-post-approleassignments?view=graph-rest-1.0&tabs=http#request-body
 	DefaultGroupRoleId string = "00000000-0000-0000-0000-000000000000"
+	// GroupRoleId is the unique (per application) ID for the dedicated AppRole for Groups. Entra ID does not allow
+	// assignments to the default group role for applications with AppRoles that are assignable to users, so Groups are
+	// assigned to this role instead for such applications.
+	GroupRoleId string = "00000002-abcd-9001-0000-000000000000"
 )
@@ -87,8 +87,8 @@ func (r Resources) FilterByPrincipalType(principalType PrincipalType) Resources
 func (r Resources) ExtractDesiredAssignees(principalType PrincipalType, role permissions.Permission) Resources {
 	switch principalType {
 	case PrincipalTypeGroup:
-		// ensure that default group role is assigned to all Groups
-		if role.ID == msgraph.UUID(permissions.DefaultGroupRoleId) {
+		// ensure that default or dedicated group role is assigned to all Groups
+		if role.ID == msgraph.UUID(permissions.DefaultGroupRoleId) || role.ID == msgraph.UUID(permissions.GroupRoleId) {
 			return r
 		}
 	case PrincipalTypeServicePrincipal:
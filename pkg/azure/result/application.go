@@ -10,7 +10,9 @@ type Application struct {
 	ServicePrincipalId string                  `json:"servicePrincipalId"`
 	Permissions        permissions.Permissions `json:"permissions"`
 	PreAuthorizedApps  PreAuthorizedApps       `json:"preAuthorizedApps"`
+	Groups             Groups                  `json:"groups"`
 	Tenant             string                  `json:"tenant"`
+	AccessTokenVersion int                     `json:"accessTokenVersion"`
 	Result             Operation               `json:"result"`
 }
 
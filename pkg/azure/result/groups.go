@@ -0,0 +1,19 @@
+package result
+
+type Groups struct {
+	// Assigned is the list of groups that are assigned to the application in Azure AD.
+	Assigned []Group `json:"assigned"`
+	// Skipped is the list of groups that could not be assigned to the application, e.g. groups that do not exist.
+	Skipped []Group `json:"skipped"`
+}
+
+type Group struct {
+	// Reference is the group reference as given in the spec, i.e. an object ID, display name or mail nickname.
+	Reference string `json:"reference"`
+	// ObjectId is the object ID of the group in Azure AD, if resolved.
+	ObjectId string `json:"objectId,omitempty"`
+	// Name is the display name of the group in Azure AD, if resolved.
+	Name string `json:"name,omitempty"`
+	// Reason describes why the group was skipped.
+	Reason string `json:"reason,omitempty"`
+}
@@ -81,6 +81,26 @@ func (a ApplicationBuilder) PermissionScopes(scopes []msgraph.PermissionScope) A
 	return a
 }
 
+func (a ApplicationBuilder) AccessTokenVersion(version int) ApplicationBuilder {
+	if a.API == nil {
+		a.API = &msgraph.APIApplication{}
+	}
+	a.API.RequestedAccessTokenVersion = new(version)
+	return a
+}
+
+func (a ApplicationBuilder) KnownClientApplications(clientIds []string) ApplicationBuilder {
+	if a.API == nil {
+		a.API = &msgraph.APIApplication{}
+	}
+	knownClientApplications := make([]msgraph.UUID, 0, len(clientIds))
+	for _, clientId := range clientIds {
+		knownClientApplications = append(knownClientApplications, msgraph.UUID(clientId))
+	}
+	a.API.KnownClientApplications = knownClientApplications
+	return a
+}
+
 func (a ApplicationBuilder) OptionalClaims(optionalClaims *msgraph.OptionalClaims) ApplicationBuilder {
 	a.Application.OptionalClaims = optionalClaims
 	return a
@@ -20,6 +20,17 @@ func FilterByName(name azure.DisplayName) azure.Filter {
 	return fmt.Sprintf("displayName eq '%s'", name)
 }
 
+// FilterByGroupName matches groups with the given display name or mail nickname.
+func FilterByGroupName(name string) azure.Filter {
+	escaped := strings.ReplaceAll(name, "'", "''")
+	return fmt.Sprintf("displayName eq '%s' or mailNickname eq '%s'", escaped, escaped)
+}
+
+// FilterByUserPrincipalName matches users with the given user principal name.
+func FilterByUserPrincipalName(upn string) azure.Filter {
+	return fmt.Sprintf("userPrincipalName eq '%s'", strings.ReplaceAll(upn, "'", "''"))
+}
+
 func FilterByAppId(clientId azure.ClientId) azure.Filter {
 	return fmt.Sprintf("appId eq '%s'", clientId)
 }
@@ -40,6 +40,11 @@ func TestFilters(t *testing.T) {
 			fn:       FilterByName,
 			expected: fmt.Sprintf("displayName eq '%s'", p),
 		},
+		{
+			name:     "Filter by UserPrincipalName",
+			fn:       FilterByUserPrincipalName,
+			expected: fmt.Sprintf("userPrincipalName eq '%s'", p),
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
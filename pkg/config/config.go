@@ -1,37 +1,54 @@
 package config
 
 import (
+	"crypto/x509/pkix"
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
+	// embeds the time zone database for rotation windows, as minimal images do not provide one
+	_ "time/tzdata"
 
+	"github.com/asaskevich/govalidator"
 	"github.com/nais/liberator/pkg/conftools"
 	log "github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
+	"github.com/nais/azureator/pkg/annotations"
+	"github.com/nais/azureator/pkg/azure/apipermissions"
 	"github.com/nais/azureator/pkg/azure/client/application/groupmembershipclaim"
+	"github.com/nais/azureator/pkg/util/cron"
+	"github.com/nais/azureator/pkg/util/crypto"
 )
 
 type Config struct {
 	Azure          AzureConfig    `json:"azure"`
+	Certificate    Certificate    `json:"certificate"`
 	ClusterName    string         `json:"cluster-name"`
 	Controller     Controller     `json:"controller"`
 	LeaderElection LeaderElection `json:"leader-election"`
 	MetricsAddr    string         `json:"metrics-address"`
 	ProbesAddr     string         `json:"probes-address"`
 	SecretRotation SecretRotation `json:"secret-rotation"`
+	SecretSinks    SecretSinks    `json:"secret-sinks"`
 	Validations    Validations    `json:"validations"`
 }
 
 type AzureConfig struct {
-	Auth                      AzureAuth       `json:"auth"`
-	Delay                     AzureDelay      `json:"delay"`
-	Features                  AzureFeatures   `json:"features"`
-	Pagination                AzurePagination `json:"pagination"`
-	PermissionGrantResourceId string          `json:"permissiongrant-resource-id"`
-	Tenant                    AzureTenant     `json:"tenant"`
+	APIPermissions            AzureAPIPermissions `json:"api-permissions"`
+	APISettings               AzureAPISettings    `json:"api-settings"`
+	Auth                      AzureAuth           `json:"auth"`
+	Delay                     AzureDelay          `json:"delay"`
+	Features                  AzureFeatures       `json:"features"`
+	Graph                     AzureGraph          `json:"graph"`
+	IdentifierUris            AzureIdentifierUris `json:"identifier-uris"`
+	InMemory                  AzureInMemory       `json:"in-memory"`
+	Pagination                AzurePagination     `json:"pagination"`
+	PermissionGrantResourceId string              `json:"permissiongrant-resource-id"`
+	Tenant                    AzureTenant         `json:"tenant"`
+	WorkloadIdentity          WorkloadIdentity    `json:"workload-identity"`
 }
 
 type AzureTenant struct {
@@ -49,10 +66,92 @@ type AzureAuth struct {
 	Google       GoogleAuth `json:"google"`
 }
 
+// AzureAPIPermissions configures the additional API permissions that applications may request.
+type AzureAPIPermissions struct {
+	// Allowed lists the permissions that applications may request, in the form '<type>:<resource>/<name>'.
+	// The name may be '*' to allow any permission of the given type for the resource.
+	Allowed []string `json:"allowed"`
+}
+
+// AllowedPermissions returns the parsed allowlist.
+func (a AzureAPIPermissions) AllowedPermissions() (apipermissions.Permissions, error) {
+	return apipermissions.ParseAll(a.Allowed)
+}
+
+// AzureAPISettings configures the API settings of applications, i.e. the tokens issued for them.
+type AzureAPISettings struct {
+	// AccessTokenVersion is the version of access tokens issued for applications that do not request a version.
+	AccessTokenVersion int `json:"access-token-version"`
+	// TokenLifetimePolicies lists the token lifetime policies that applications may select by name, in the form
+	// '<name>=<policy ID>'.
+	TokenLifetimePolicies []string `json:"token-lifetime-policies"`
+}
+
+// DefaultAccessTokenVersion returns the configured access token version, or version 2 if not configured.
+func (a AzureAPISettings) DefaultAccessTokenVersion() int {
+	if a.AccessTokenVersion == 0 {
+		return 2
+	}
+	return a.AccessTokenVersion
+}
+
+// NamedTokenLifetimePolicies returns the token lifetime policies, keyed by name.
+func (a AzureAPISettings) NamedTokenLifetimePolicies() (map[string]string, error) {
+	return parseNamedPolicies("token lifetime", a.TokenLifetimePolicies)
+}
+
+// TokenLifetimePolicyIDs returns the IDs of the named token lifetime policies, i.e. the policies managed by the
+// operator.
+func (a AzureAPISettings) TokenLifetimePolicyIDs() []string {
+	named, _ := a.NamedTokenLifetimePolicies()
+	result := make([]string, 0, len(named))
+	for _, id := range named {
+		result = append(result, id)
+	}
+	return result
+}
+
+// AzureIdentifierUris configures the additional identifier URIs that applications may request.
+type AzureIdentifierUris struct {
+	// AllowedDomains lists the verified domains of the tenant that applications may use in additional identifier URIs.
+	// Subdomains of the allowed domains are also allowed.
+	AllowedDomains []string `json:"allowed-domains"`
+}
+
+// Allows returns true if the given host is one of the allowed domains, or a subdomain of one.
+func (a AzureIdentifierUris) Allows(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range a.AllowedDomains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
 type AzureDelay struct {
 	BetweenModifications time.Duration `json:"between-modifications"`
 }
 
+type AzureGraph struct {
+	BaseURL string `json:"base-url"`
+}
+
+type AzureInMemory struct {
+	Enabled bool `json:"enabled"`
+}
+
+// WorkloadIdentity configures federated identity credentials for applications using workload identity federation.
+type WorkloadIdentity struct {
+	// Audience is the audience of the service account tokens exchanged for Entra ID tokens.
+	Audience string `json:"audience"`
+	// Issuer is the OIDC issuer of service account tokens in the cluster.
+	Issuer string `json:"issuer"`
+	// TokenFile is the path to the projected service account token in the application's pods.
+	TokenFile string `json:"token-file"`
+}
+
 type AzurePagination struct {
 	MaxPages int `json:"max-pages"`
 }
@@ -64,6 +163,7 @@ type AzureFeatures struct {
 	CustomSecurityAttributes  CustomSecurityAttributes  `json:"custom-security-attributes"`
 	GroupsAssignment          GroupsAssignment          `json:"groups-assignment"`
 	GroupMembershipClaim      GroupMembershipClaim      `json:"group-membership-claim"`
+	Owners                    Owners                    `json:"owners"`
 }
 
 type AppRoleAssignmentRequired struct {
@@ -71,8 +171,56 @@ type AppRoleAssignmentRequired struct {
 }
 
 type ClaimsMappingPolicies struct {
-	Enabled bool   `json:"enabled"`
-	ID      string `json:"id"`
+	Enabled bool `json:"enabled"`
+	// ID is the default policy, assigned to applications that do not select a named policy.
+	ID string `json:"id"`
+	// Named lists the policies that applications may select by name, in the form '<name>=<policy ID>'.
+	Named []string `json:"named"`
+}
+
+// PolicyNone is the reserved policy name that applications may select to opt out of a kind of policy altogether.
+const PolicyNone = "none"
+
+// ClaimsMappingPolicyNone is the reserved policy name that applications may select to opt out of claims-mapping
+// policies altogether.
+const ClaimsMappingPolicyNone = PolicyNone
+
+// NamedPolicies returns the named policies, keyed by name.
+func (c ClaimsMappingPolicies) NamedPolicies() (map[string]string, error) {
+	return parseNamedPolicies("claims-mapping", c.Named)
+}
+
+// parseNamedPolicies parses policies in the form '<name>=<policy ID>', keyed by name. The name 'none' is reserved for
+// opting out of policies.
+func parseNamedPolicies(kind string, values []string) (map[string]string, error) {
+	result := make(map[string]string, len(values))
+	for _, value := range values {
+		name, id, found := strings.Cut(strings.TrimSpace(value), "=")
+		if !found || len(name) == 0 || len(id) == 0 {
+			return nil, fmt.Errorf("invalid %s policy '%s': must be in the form '<name>=<policy ID>'", kind, value)
+		}
+		if name == PolicyNone {
+			return nil, fmt.Errorf("invalid %s policy '%s': name '%s' is reserved", kind, value, PolicyNone)
+		}
+		if _, duplicate := result[name]; duplicate {
+			return nil, fmt.Errorf("invalid %s policy '%s': name '%s' is defined more than once", kind, value, name)
+		}
+		result[name] = id
+	}
+	return result, nil
+}
+
+// PolicyIDs returns the IDs of the default and named policies, i.e. the policies managed by the operator.
+func (c ClaimsMappingPolicies) PolicyIDs() []string {
+	result := make([]string, 0, len(c.Named)+1)
+	if len(c.ID) > 0 {
+		result = append(result, c.ID)
+	}
+	named, _ := c.NamedPolicies()
+	for _, id := range named {
+		result = append(result, id)
+	}
+	return result
 }
 
 type CleanupOrphans struct {
@@ -83,6 +231,10 @@ type CustomSecurityAttributes struct {
 	Enabled bool `json:"enabled"`
 }
 
+type Owners struct {
+	Enabled bool `json:"enabled"`
+}
+
 type GroupsAssignment struct {
 	Enabled         bool     `json:"enabled"`
 	AllUsersGroupId []string `json:"all-users-group-id"`
@@ -97,6 +249,31 @@ type GoogleAuth struct {
 	ProjectID string `json:"project-id"`
 }
 
+type Certificate struct {
+	KeyType      crypto.KeyType      `json:"key-type"`
+	SerialNumber crypto.SerialNumber `json:"serial-number"`
+	Subject      CertificateSubject  `json:"subject"`
+	Validity     time.Duration       `json:"validity"`
+}
+
+type CertificateSubject struct {
+	Country            []string `json:"country"`
+	Locality           []string `json:"locality"`
+	Organization       []string `json:"organization"`
+	OrganizationalUnit []string `json:"organizational-unit"`
+	Province           []string `json:"province"`
+}
+
+func (s CertificateSubject) Name() pkix.Name {
+	return pkix.Name{
+		Country:            s.Country,
+		Locality:           s.Locality,
+		Organization:       s.Organization,
+		OrganizationalUnit: s.OrganizationalUnit,
+		Province:           s.Province,
+	}
+}
+
 type Controller struct {
 	ContextTimeout          time.Duration `json:"context-timeout"`
 	MaxConcurrentReconciles int           `json:"max-concurrent-reconciles"`
@@ -109,8 +286,101 @@ type LeaderElection struct {
 }
 
 type SecretRotation struct {
-	MaxAge  time.Duration `json:"max-age"`
-	Cleanup bool          `json:"cleanup"`
+	MaxAge       time.Duration  `json:"max-age"`
+	MaxAgeLimits MaxAgeLimits   `json:"max-age-limits"`
+	Cleanup      bool           `json:"cleanup"`
+	Window       RotationWindow `json:"window"`
+	Expiry       Expiry         `json:"expiry"`
+}
+
+// Expiry configures how far ahead of the end date of credentials in Azure AD the operator warns and rotates.
+type Expiry struct {
+	Margin  time.Duration `json:"margin"`
+	Warning time.Duration `json:"warning"`
+}
+
+// RotationWindow restricts rotations triggered by the max age to periods starting at the given cron schedules.
+type RotationWindow struct {
+	Schedule string        `json:"schedule"`
+	Duration time.Duration `json:"duration"`
+	TimeZone string        `json:"time-zone"`
+	// Namespaces overrides Schedule for specific namespaces, in the form '<namespace>=<schedule>'.
+	// An empty schedule disables the window for the namespace.
+	Namespaces []string `json:"namespaces"`
+}
+
+// ForNamespace returns the window for rotations of applications in the given namespace, or nil if rotations may happen
+// at any time.
+func (w RotationWindow) ForNamespace(namespace string) (*cron.Window, error) {
+	expression := w.Schedule
+	for _, entry := range w.Namespaces {
+		ns, schedule, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("'%s' must be of the form '<namespace>=<schedule>'", entry)
+		}
+		if strings.TrimSpace(ns) == namespace {
+			expression = schedule
+		}
+	}
+
+	if len(strings.TrimSpace(expression)) == 0 {
+		return nil, nil
+	}
+
+	schedule, err := cron.Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("loading time zone: %w", err)
+	}
+
+	return &cron.Window{
+		Schedule: schedule,
+		Duration: w.Duration,
+		Location: location,
+	}, nil
+}
+
+// MaxAgeLimits bounds the max age that applications may request for their own credentials.
+type MaxAgeLimits struct {
+	Min time.Duration `json:"min"`
+	Max time.Duration `json:"max"`
+}
+
+// UpperMaxAge returns the longest max age allowed for any application. Unless configured, applications may only
+// shorten the max age.
+func (s SecretRotation) UpperMaxAge() time.Duration {
+	if s.MaxAgeLimits.Max > 0 {
+		return s.MaxAgeLimits.Max
+	}
+	return s.MaxAge
+}
+
+// LimitMaxAge returns the requested max age bounded by the configured limits.
+func (s SecretRotation) LimitMaxAge(requested time.Duration) time.Duration {
+	return min(max(requested, s.MaxAgeLimits.Min), s.UpperMaxAge())
+}
+
+// SecretSinks configures external secret stores that applications may opt in to, in addition to the Kubernetes Secret.
+// A sink is available only if configured.
+type SecretSinks struct {
+	File  FileSink  `json:"file"`
+	Vault VaultSink `json:"vault"`
+}
+
+type FileSink struct {
+	Directory string `json:"directory"`
+}
+
+type VaultSink struct {
+	Address    string        `json:"address"`
+	Mount      string        `json:"mount"`
+	PathPrefix string        `json:"path-prefix"`
+	Timeout    time.Duration `json:"timeout"`
+	TokenFile  string        `json:"token-file"`
 }
 
 type Validations struct {
@@ -123,6 +393,10 @@ type Validation struct {
 
 // Configuration options
 const (
+	AzureAPIPermissionsAllowed                    = "azure.api-permissions.allowed"
+	AzureAPISettingsAccessTokenVersion            = "azure.api-settings.access-token-version"
+	AzureAPISettingsTokenLifetimePolicies         = "azure.api-settings.token-lifetime-policies"
+	AzureIdentifierUrisAllowedDomains             = "azure.identifier-uris.allowed-domains"
 	AzureClientId                                 = "azure.auth.client-id"
 	AzureClientSecret                             = "azure.auth.client-secret"
 	AzureAuthGoogleEnabled                        = "azure.auth.google.enabled"
@@ -132,14 +406,30 @@ const (
 	AzurePermissionGrantResourceId                = "azure.permissiongrant-resource-id"
 	AzureFeaturesClaimsMappingPoliciesEnabled     = "azure.features.claims-mapping-policies.enabled"
 	AzureFeaturesClaimsMappingPoliciesID          = "azure.features.claims-mapping-policies.id"
+	AzureFeaturesClaimsMappingPoliciesNamed       = "azure.features.claims-mapping-policies.named"
 	AzureFeaturesCustomSecurityAttributesEnabled  = "azure.features.custom-security-attributes.enabled"
 	AzureFeaturesGroupsAssignmentEnabled          = "azure.features.groups-assignment.enabled"
 	AzureFeaturesGroupsAllUsersGroupId            = "azure.features.groups-assignment.all-users-group-id"
 	AzureFeaturesGroupMembershipClaimDefault      = "azure.features.group-membership-claim.default"
 	AzureFeaturesAppRoleAssignmentRequiredEnabled = "azure.features.app-role-assignment-required.enabled"
 	AzureFeaturesCleanupOrphansEnabled            = "azure.features.cleanup-orphans.enabled"
+	AzureFeaturesOwnersEnabled                    = "azure.features.owners.enabled"
 	AzureDelayBetweenModifications                = "azure.delay.between-modifications"
+	AzureGraphBaseURL                             = "azure.graph.base-url"
+	AzureInMemoryEnabled                          = "azure.in-memory.enabled"
 	AzurePaginationMaxPages                       = "azure.pagination.max-pages"
+	AzureWorkloadIdentityAudience                 = "azure.workload-identity.audience"
+	AzureWorkloadIdentityIssuer                   = "azure.workload-identity.issuer"
+	AzureWorkloadIdentityTokenFile                = "azure.workload-identity.token-file"
+
+	CertificateKeyType                   = "certificate.key-type"
+	CertificateSerialNumber              = "certificate.serial-number"
+	CertificateSubjectCountry            = "certificate.subject.country"
+	CertificateSubjectLocality           = "certificate.subject.locality"
+	CertificateSubjectOrganization       = "certificate.subject.organization"
+	CertificateSubjectOrganizationalUnit = "certificate.subject.organizational-unit"
+	CertificateSubjectProvince           = "certificate.subject.province"
+	CertificateValidity                  = "certificate.validity"
 
 	ControllerContextTimeout          = "controller.context-timeout"
 	ControllerMaxConcurrentReconciles = "controller.max-concurrent-reconciles"
@@ -154,7 +444,24 @@ const (
 
 	ValidationsTenantRequired = "validations.tenant.required"
 	SecretRotationMaxAge      = "secret-rotation.max-age"
+	SecretRotationMaxAgeMin   = "secret-rotation.max-age-limits.min"
+	SecretRotationMaxAgeMax   = "secret-rotation.max-age-limits.max"
 	SecretRotationCleanup     = "secret-rotation.cleanup"
+
+	SecretRotationExpiryMargin  = "secret-rotation.expiry.margin"
+	SecretRotationExpiryWarning = "secret-rotation.expiry.warning"
+
+	SecretRotationWindowDuration   = "secret-rotation.window.duration"
+	SecretRotationWindowNamespaces = "secret-rotation.window.namespaces"
+	SecretRotationWindowSchedule   = "secret-rotation.window.schedule"
+	SecretRotationWindowTimeZone   = "secret-rotation.window.time-zone"
+
+	SecretSinksFileDirectory   = "secret-sinks.file.directory"
+	SecretSinksVaultAddress    = "secret-sinks.vault.address"
+	SecretSinksVaultMount      = "secret-sinks.vault.mount"
+	SecretSinksVaultPathPrefix = "secret-sinks.vault.path-prefix"
+	SecretSinksVaultTimeout    = "secret-sinks.vault.timeout"
+	SecretSinksVaultTokenFile  = "secret-sinks.vault.token-file"
 )
 
 func init() {
@@ -171,34 +478,75 @@ func init() {
 
 	flag.String(AzurePermissionGrantResourceId, "", "Object ID for Graph API permissions grant ('GraphAggregatorService' or 'Microsoft Graph' in Enterprise Applications under 'Microsoft Applications')")
 
+	flag.StringSlice(AzureAPIPermissionsAllowed, []string{}, "List of API permissions that applications may request, in the form '<type>:<resource>/<name>', e.g. 'application:graph/User.Read.All'. The name may be '*' to allow all permissions of the type for the resource.")
+
+	flag.Int(AzureAPISettingsAccessTokenVersion, 2, "Version of access tokens issued for applications that do not request a version, i.e. 1 or 2.")
+	flag.StringSlice(AzureAPISettingsTokenLifetimePolicies, []string{}, "List of token lifetime policies that applications may select by name, in the form '<name>=<policy ID>'.")
+	flag.StringSlice(AzureIdentifierUrisAllowedDomains, []string{}, "List of verified domains in the tenant that applications may use in additional identifier URIs, including subdomains.")
+
 	flag.Bool(AzureFeaturesAppRoleAssignmentRequiredEnabled, false, "Enable 'appRoleAssignmentRequired' for service principals.")
 	flag.Bool(AzureFeaturesClaimsMappingPoliciesEnabled, false, "Assign custom claims-mapping policies to a service principal")
-	flag.String(AzureFeaturesClaimsMappingPoliciesID, "", "Claims-mapping policy ID for custom claims mapping")
+	flag.String(AzureFeaturesClaimsMappingPoliciesID, "", "Default claims-mapping policy ID for custom claims mapping. Assigned to applications that do not select a named policy.")
+	flag.StringSlice(AzureFeaturesClaimsMappingPoliciesNamed, []string{}, "List of claims-mapping policies that applications may select by name, in the form '<name>=<policy ID>'.")
 	flag.Bool(AzureFeaturesCustomSecurityAttributesEnabled, false, "Set custom security attributes on service principals (attribute set of 'Applications':'ManagedBy':'NAIS')")
 	flag.Bool(AzureFeaturesGroupsAssignmentEnabled, false, "Assign groups to applications")
 	flag.StringSlice(AzureFeaturesGroupsAllUsersGroupId, []string{}, "List of Group IDs that contains all users in the tenant. Assigned to all applications by default unless 'allowAllUsers' is set to false in the custom resource.")
 	flag.String(AzureFeaturesGroupMembershipClaimDefault, groupmembershipclaim.ApplicationGroup, "Default group membership claim for Azure AD apps. Only affects new registrations.")
+	flag.Bool(AzureFeaturesOwnersEnabled, false, "Allow applications to request additional owners, i.e. users or members of groups, for their application and service principal.")
 
 	flag.Bool(AzureFeaturesCleanupOrphansEnabled, false, "Feature toggle to enable cleanup of orphaned resources.")
 
 	flag.Duration(AzureDelayBetweenModifications, 10*time.Second, "Delay between modification operations to the Graph API.")
 
+	flag.String(AzureGraphBaseURL, "", "Base URL for the Graph API. Uses the public Microsoft Graph v1.0 endpoint if empty.")
+
+	flag.Bool(AzureInMemoryEnabled, false, "Use an in-memory Azure AD directory instead of the Graph API. Intended for local development only.")
+
 	flag.Int(AzurePaginationMaxPages, 1000, "Max number of pages to fetch when fetching paginated resources from the Graph API.")
 
+	flag.String(AzureWorkloadIdentityAudience, "api://AzureADTokenExchange", "Audience of service account tokens used for workload identity federation.")
+	flag.String(AzureWorkloadIdentityIssuer, "", "OIDC issuer URL for service account tokens in the cluster. Required for applications using workload identity federation.")
+	flag.String(AzureWorkloadIdentityTokenFile, "/var/run/secrets/azure/tokens/azure-identity-token", "Path to the projected service account token in pods using workload identity federation.")
+
 	flag.String(MetricsAddress, ":8080", "The address the metric endpoint binds to.")
 	flag.String(ProbesAddress, ":8081", "The address the health probe listener binds to.")
 	flag.String(ClusterName, "", "The cluster in which this application should run")
 	flag.Bool(ValidationsTenantRequired, false, "If true, will only process resources that have a tenant defined in the spec")
 
+	flag.String(CertificateKeyType, string(crypto.DefaultKeyType), fmt.Sprintf("Key type for new certificate credentials, one of %v. Can be overridden per application with the '%s' annotation.", crypto.SupportedKeyTypes, annotations.KeyTypeKey))
+	flag.String(CertificateSerialNumber, string(crypto.SerialNumberRandom), fmt.Sprintf("Strategy for assigning serial numbers to new certificates, one of %v.", crypto.SupportedSerialNumbers))
+	flag.StringSlice(CertificateSubjectCountry, []string{"NO"}, "Country (C) in the subject of new certificates.")
+	flag.StringSlice(CertificateSubjectLocality, []string{"Oslo"}, "Locality (L) in the subject of new certificates.")
+	flag.StringSlice(CertificateSubjectOrganization, []string{"NAV (Arbeids- og velferdsdirektoratet)"}, "Organization (O) in the subject of new certificates.")
+	flag.StringSlice(CertificateSubjectOrganizationalUnit, []string{"NAV IT"}, "Organizational unit (OU) in the subject of new certificates.")
+	flag.StringSlice(CertificateSubjectProvince, []string{"Oslo"}, "Province (ST) in the subject of new certificates.")
+	flag.Duration(CertificateValidity, 0, fmt.Sprintf("Validity of new certificates. Must cover two of the longest rotation periods ('%s' or '%s'), as the next certificate is kept as the current one until the following rotation. Defaults to two rotation periods of the application plus %s if zero.", SecretRotationMaxAge, SecretRotationMaxAgeMax, certificateValidityMargin))
+
 	flag.Duration(ControllerContextTimeout, 5*time.Minute, "Context timeout for the reconciliation loop in the controller.")
 	flag.Int(ControllerMaxConcurrentReconciles, 10, "Max concurrent reconciles.")
-	flag.Duration(ControllerSweepInterval, 5*time.Minute, "Interval between periodic sweeps for apps with unassigned preAuthorizedApps.")
+	flag.Duration(ControllerSweepInterval, 5*time.Minute, "Interval between periodic sweeps for apps with unassigned preAuthorizedApps or skipped groups.")
 
 	flag.Bool(LeaderElectionEnabled, false, "Leader election toggle.")
 	flag.String(LeaderElectionNamespace, "", "Leader election namespace.")
 
 	flag.Duration(SecretRotationMaxAge, 120*24*time.Hour, "Maximum duration since last rotation before triggering rotation on next reconciliation, regardless of secret name being changed.")
+	flag.Duration(SecretRotationMaxAgeMin, 24*time.Hour, "Lower limit for the max age requested by applications.")
+	flag.Duration(SecretRotationMaxAgeMax, 0, fmt.Sprintf("Upper limit for the max age requested by applications. Defaults to '%s' if zero.", SecretRotationMaxAge))
 	flag.Bool(SecretRotationCleanup, true, "Clean up unused credentials in Azure AD after rotation.")
+	flag.Duration(SecretRotationExpiryMargin, 14*24*time.Hour, "Rotate credentials regardless of max age and rotation windows when they expire in Azure AD within this duration.")
+	flag.Duration(SecretRotationExpiryWarning, 30*24*time.Hour, "Emit a warning event when credentials expire in Azure AD within this duration.")
+
+	flag.String(SecretRotationWindowSchedule, "", "Cron schedule for the start of windows in which credentials exceeding the max age are rotated. Rotations are not restricted if empty.")
+	flag.Duration(SecretRotationWindowDuration, 2*time.Hour, "Duration of each rotation window.")
+	flag.String(SecretRotationWindowTimeZone, "UTC", "Time zone for the rotation window schedules.")
+	flag.StringArray(SecretRotationWindowNamespaces, []string{}, fmt.Sprintf("Rotation window schedule for a namespace in the form '<namespace>=<schedule>', overriding '%s'. May be repeated.", SecretRotationWindowSchedule))
+
+	flag.String(SecretSinksFileDirectory, "", "Directory for the 'file' secret sink. The sink is disabled if empty.")
+	flag.String(SecretSinksVaultAddress, "", "Address of the Vault server for the 'vault' secret sink. The sink is disabled if empty.")
+	flag.String(SecretSinksVaultMount, "secret", "Mount path of the KV version 2 secrets engine in Vault.")
+	flag.String(SecretSinksVaultPathPrefix, "azurerator", "Path prefix for secrets written to Vault. Secrets are written to '<prefix>/<cluster>/<namespace>/<name>'.")
+	flag.Duration(SecretSinksVaultTimeout, 30*time.Second, "Timeout for requests to the Vault server.")
+	flag.String(SecretSinksVaultTokenFile, "", "Path to a file containing the Vault token. The file is read again if Vault rejects the current token.")
 }
 
 func (c Config) Validate(required []string) error {
@@ -226,13 +574,100 @@ func (c Config) Validate(required []string) error {
 		return errors.New("missing configuration values")
 	}
 
-	if c.Azure.Features.ClaimsMappingPolicies.Enabled && len(c.Azure.Features.ClaimsMappingPolicies.ID) == 0 {
-		return fmt.Errorf("'%s' cannot be empty when '%s' is true", AzureFeaturesClaimsMappingPoliciesID, AzureFeaturesClaimsMappingPoliciesEnabled)
+	claimsMappingPolicies := c.Azure.Features.ClaimsMappingPolicies
+	if claimsMappingPolicies.Enabled && len(claimsMappingPolicies.ID) == 0 && len(claimsMappingPolicies.Named) == 0 {
+		return fmt.Errorf("'%s' and '%s' cannot both be empty when '%s' is true", AzureFeaturesClaimsMappingPoliciesID, AzureFeaturesClaimsMappingPoliciesNamed, AzureFeaturesClaimsMappingPoliciesEnabled)
+	}
+
+	if _, err := claimsMappingPolicies.NamedPolicies(); err != nil {
+		return fmt.Errorf("'%s': %w", AzureFeaturesClaimsMappingPoliciesNamed, err)
+	}
+
+	if _, err := c.Azure.APIPermissions.AllowedPermissions(); err != nil {
+		return fmt.Errorf("'%s': %w", AzureAPIPermissionsAllowed, err)
+	}
+
+	if version := c.Azure.APISettings.AccessTokenVersion; version != 1 && version != 2 {
+		return fmt.Errorf("'%s' (%d) must be either 1 or 2", AzureAPISettingsAccessTokenVersion, version)
+	}
+
+	if _, err := c.Azure.APISettings.NamedTokenLifetimePolicies(); err != nil {
+		return fmt.Errorf("'%s': %w", AzureAPISettingsTokenLifetimePolicies, err)
+	}
+
+	for _, domain := range c.Azure.IdentifierUris.AllowedDomains {
+		if !govalidator.IsDNSName(strings.TrimSpace(domain)) {
+			return fmt.Errorf("'%s': '%s' must be a domain name", AzureIdentifierUrisAllowedDomains, domain)
+		}
+	}
+
+	if _, err := crypto.ParseKeyType(string(c.Certificate.KeyType)); err != nil {
+		return fmt.Errorf("'%s': %w", CertificateKeyType, err)
+	}
+
+	if _, err := crypto.ParseSerialNumber(string(c.Certificate.SerialNumber)); err != nil {
+		return fmt.Errorf("'%s': %w", CertificateSerialNumber, err)
+	}
+
+	if limits := c.SecretRotation.MaxAgeLimits; limits.Min > c.SecretRotation.MaxAge || (limits.Max > 0 && limits.Max < c.SecretRotation.MaxAge) {
+		return fmt.Errorf("'%s' (%s) must be within '%s' (%s) and '%s' (%s)", SecretRotationMaxAge, c.SecretRotation.MaxAge, SecretRotationMaxAgeMin, limits.Min, SecretRotationMaxAgeMax, limits.Max)
+	}
+
+	if err := c.SecretRotation.Window.Validate(); err != nil {
+		return err
+	}
+
+	if len(c.SecretSinks.Vault.Address) > 0 && len(c.SecretSinks.Vault.TokenFile) == 0 {
+		return fmt.Errorf("'%s' cannot be empty when '%s' is set", SecretSinksVaultTokenFile, SecretSinksVaultAddress)
+	}
+
+	if validity, minimum := c.Certificate.Validity, 2*c.SecretRotation.UpperMaxAge(); validity > 0 && validity < minimum {
+		return fmt.Errorf("'%s' (%s) must be at least twice the longest max age for secret rotation (%s)", CertificateValidity, validity, minimum)
 	}
 
 	return nil
 }
 
+// Validate returns an error if any of the configured windows cannot be parsed.
+func (w RotationWindow) Validate() error {
+	if _, err := time.LoadLocation(w.TimeZone); err != nil {
+		return fmt.Errorf("'%s': %w", SecretRotationWindowTimeZone, err)
+	}
+
+	if _, err := w.ForNamespace(""); err != nil {
+		return fmt.Errorf("'%s': %w", SecretRotationWindowSchedule, err)
+	}
+
+	for _, entry := range w.Namespaces {
+		namespace, _, _ := strings.Cut(entry, "=")
+		if _, err := w.ForNamespace(strings.TrimSpace(namespace)); err != nil {
+			return fmt.Errorf("'%s': %w", SecretRotationWindowNamespaces, err)
+		}
+	}
+
+	if len(w.Schedule) > 0 || len(w.Namespaces) > 0 {
+		if w.Duration <= 0 {
+			return fmt.Errorf("'%s' must be positive", SecretRotationWindowDuration)
+		}
+	}
+
+	return nil
+}
+
+// certificateValidityMargin is added to the default certificate validity to tolerate delayed rotations.
+const certificateValidityMargin = 30 * 24 * time.Hour
+
+// CertificateValidity returns the validity for new certificates of an application with the given max age for secret
+// rotation. Unless configured, the validity covers two rotation periods plus a margin: a certificate is registered as
+// the next certificate, becomes the current certificate at the following rotation and is revoked at the rotation after
+// that.
+func (c Config) CertificateValidity(maxAge time.Duration) time.Duration {
+	if c.Certificate.Validity > 0 {
+		return c.Certificate.Validity
+	}
+	return 2*maxAge + certificateValidityMargin
+}
+
 func New() (*Config, error) {
 	cfg := new(Config)
 
@@ -263,7 +698,9 @@ func DefaultConfig() (*Config, error) {
 		ClusterName,
 	}
 
-	if cfg.Azure.Auth.Google.Enabled {
+	if cfg.Azure.InMemory.Enabled {
+		required = []string{ClusterName}
+	} else if cfg.Azure.Auth.Google.Enabled {
 		required = append(required, AzureAuthGoogleProjectID)
 	} else {
 		required = append(required, AzureClientSecret)
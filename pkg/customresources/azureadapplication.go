@@ -2,35 +2,72 @@ package customresources
 
 import (
 	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
 	"time"
 
 	nais_io_v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 
 	"github.com/nais/azureator/pkg/annotations"
+	"github.com/nais/azureator/pkg/azure/credentials"
 )
 
-func IsHashChanged(in *nais_io_v1.AzureAdApplication) (bool, error) {
-	newHash, err := in.Hash()
+// RevokeRestart is the value of the revoke annotation that also requests a restart of the workloads using the secret.
+const RevokeRestart = "restart"
+
+// IsHashChanged returns true if the hash of the application and the given settings differs from the hash recorded when
+// the application was last synchronized.
+func IsHashChanged(in *nais_io_v1.AzureAdApplication, settings ...string) (bool, error) {
+	newHash, err := Hash(in, settings...)
 	if err != nil {
-		return false, fmt.Errorf("calculating application hash: %w", err)
+		return false, err
 	}
 	return in.Status.SynchronizationHash != newHash, nil
 }
 
+// Hash returns the hash of the application spec, combined with the given settings that are requested outside the spec,
+// e.g. by annotations. Without any settings, the hash of the spec is returned as is, so that applications that do not
+// request any such settings keep their hash.
+func Hash(in *nais_io_v1.AzureAdApplication, settings ...string) (string, error) {
+	hash, err := in.Hash()
+	if err != nil {
+		return "", fmt.Errorf("calculating application hash: %w", err)
+	}
+	if !slices.ContainsFunc(settings, func(setting string) bool { return len(setting) > 0 }) {
+		return hash, nil
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(hash))
+	for _, setting := range settings {
+		h.Write([]byte{0})
+		h.Write([]byte(setting))
+	}
+	return fmt.Sprintf("%x", h.Sum64()), nil
+}
+
 func SecretNameChanged(in *nais_io_v1.AzureAdApplication) bool {
 	return in.Status.SynchronizationSecretName != in.Spec.SecretName
 }
 
 func HasExpiredSecrets(in *nais_io_v1.AzureAdApplication, maxSecretAge time.Duration) bool {
-	if in.Status.SynchronizationSecretRotationTime == nil {
+	nextRotation, found := NextRotation(in, maxSecretAge)
+	if !found {
 		return false
 	}
 
-	lastRotationTime := *in.Status.SynchronizationSecretRotationTime
-	diff := time.Since(lastRotationTime.Time)
-	secretExpired := diff >= maxSecretAge
+	return !time.Now().Before(nextRotation)
+}
+
+// NextRotation returns the time at which the secrets for the application expire, if the secrets have been rotated.
+func NextRotation(in *nais_io_v1.AzureAdApplication, maxSecretAge time.Duration) (time.Time, bool) {
+	if in.Status.SynchronizationSecretRotationTime == nil {
+		return time.Time{}, false
+	}
 
-	return secretExpired
+	lastRotationTime := *in.Status.SynchronizationSecretRotationTime
+	return lastRotationTime.Add(maxSecretAge), true
 }
 
 func HasResynchronizeAnnotation(in *nais_io_v1.AzureAdApplication) bool {
@@ -42,3 +79,166 @@ func HasRotateAnnotation(in *nais_io_v1.AzureAdApplication) bool {
 	_, found := annotations.HasAnnotation(in, annotations.RotateKey)
 	return found
 }
+
+func HasRevokeAnnotation(in *nais_io_v1.AzureAdApplication) bool {
+	_, found := annotations.HasAnnotation(in, annotations.RevokeKey)
+	return found
+}
+
+// RevokeRestartsWorkloads returns true if the revoke annotation requests a restart of the workloads that use the
+// secret for the application.
+func RevokeRestartsWorkloads(in *nais_io_v1.AzureAdApplication) bool {
+	value, _ := annotations.HasAnnotation(in, annotations.RevokeKey)
+	return value == RevokeRestart
+}
+
+// RevokeFieldManager returns the field manager that last set the revoke annotation, and when, as recorded in the managed
+// fields of the resource. The field manager names the client that applied the annotation, e.g. 'kubectl-annotate', and
+// not the user or service account that made the request.
+func RevokeFieldManager(in *nais_io_v1.AzureAdApplication) (string, time.Time, bool) {
+	field := fmt.Sprintf(`"f:%s"`, annotations.RevokeKey)
+
+	var manager string
+	var at time.Time
+	for _, entry := range in.GetManagedFields() {
+		if entry.FieldsV1 == nil || !strings.Contains(string(entry.FieldsV1.Raw), field) {
+			continue
+		}
+		if len(manager) == 0 || (entry.Time != nil && entry.Time.After(at)) {
+			manager = entry.Manager
+			if entry.Time != nil {
+				at = entry.Time.Time
+			}
+		}
+	}
+	return manager, at, len(manager) > 0
+}
+
+func HasCertificateCredentialsAnnotation(in *nais_io_v1.AzureAdApplication) bool {
+	_, found := annotations.HasAnnotation(in, annotations.CertificateCredentialsKey)
+	return found
+}
+
+// CredentialMode returns the kinds of credentials requested for the application, defaulting to credentials.DefaultMode.
+func CredentialMode(in *nais_io_v1.AzureAdApplication) (credentials.Mode, error) {
+	value, found := annotations.HasAnnotation(in, annotations.CredentialModeKey)
+	if !found {
+		return credentials.DefaultMode, nil
+	}
+
+	mode, err := credentials.ParseMode(value)
+	if err != nil {
+		return "", fmt.Errorf("parsing annotation '%s': %w", annotations.CredentialModeKey, err)
+	}
+	return mode, nil
+}
+
+// ServiceAccount returns the name of the service account used for workload identity federation, defaulting to the
+// name of the application.
+func ServiceAccount(in *nais_io_v1.AzureAdApplication) string {
+	value, found := annotations.HasAnnotation(in, annotations.ServiceAccountKey)
+	if found && len(value) > 0 {
+		return value
+	}
+	return in.GetName()
+}
+
+// RotationMaxAge returns the max age requested for the secrets of the application, if any.
+func RotationMaxAge(in *nais_io_v1.AzureAdApplication) (string, bool) {
+	return annotations.HasAnnotation(in, annotations.RotationMaxAgeKey)
+}
+
+// KeyType returns the key type requested for new certificate credentials, if any.
+func KeyType(in *nais_io_v1.AzureAdApplication) (string, bool) {
+	return annotations.HasAnnotation(in, annotations.KeyTypeKey)
+}
+
+// AccessTokenVersion returns the version of access tokens requested for the application, if any.
+func AccessTokenVersion(in *nais_io_v1.AzureAdApplication) (string, bool) {
+	value, found := annotations.HasAnnotation(in, annotations.AccessTokenVersionKey)
+	return strings.TrimSpace(value), found && len(strings.TrimSpace(value)) > 0
+}
+
+// APIPermissions returns the additional API permissions requested for the application, if any.
+func APIPermissions(in *nais_io_v1.AzureAdApplication) []string {
+	return annotations.Values(in, annotations.APIPermissionsKey)
+}
+
+// ClaimsMappingPolicy returns the name of the claims-mapping policy selected for the application, if any.
+func ClaimsMappingPolicy(in *nais_io_v1.AzureAdApplication) (string, bool) {
+	value, found := annotations.HasAnnotation(in, annotations.ClaimsMappingPolicyKey)
+	return strings.TrimSpace(value), found && len(strings.TrimSpace(value)) > 0
+}
+
+// GroupRoles returns the mappings of groups to app roles requested for the application, if any.
+func GroupRoles(in *nais_io_v1.AzureAdApplication) []string {
+	return annotations.Values(in, annotations.GroupRolesKey)
+}
+
+// HomePageUrl returns the home page URL requested for the application, if any.
+func HomePageUrl(in *nais_io_v1.AzureAdApplication) (string, bool) {
+	value, found := annotations.HasAnnotation(in, annotations.HomePageUrlKey)
+	return strings.TrimSpace(value), found && len(strings.TrimSpace(value)) > 0
+}
+
+// IdentifierUris returns the additional identifier URIs requested for the application, if any.
+func IdentifierUris(in *nais_io_v1.AzureAdApplication) []string {
+	return annotations.Values(in, annotations.IdentifierUrisKey)
+}
+
+// ImplicitGrant returns the kinds of tokens requested to be issued with the implicit grant flow, if any.
+func ImplicitGrant(in *nais_io_v1.AzureAdApplication) []string {
+	return annotations.Values(in, annotations.ImplicitGrantKey)
+}
+
+// KnownClientApplications returns the client IDs of the known client applications requested for the application, if
+// any.
+func KnownClientApplications(in *nais_io_v1.AzureAdApplication) []string {
+	return annotations.Values(in, annotations.KnownClientApplicationsKey)
+}
+
+// OptionalClaims returns the optional claims requested for the application, if any.
+func OptionalClaims(in *nais_io_v1.AzureAdApplication) []string {
+	return annotations.Values(in, annotations.OptionalClaimsKey)
+}
+
+// TokenLifetimePolicy returns the name of the token lifetime policy selected for the application, if any.
+func TokenLifetimePolicy(in *nais_io_v1.AzureAdApplication) (string, bool) {
+	value, found := annotations.HasAnnotation(in, annotations.TokenLifetimePolicyKey)
+	return strings.TrimSpace(value), found && len(strings.TrimSpace(value)) > 0
+}
+
+// Owners returns the additional owners requested for the application, i.e. user principal names or group references,
+// if any.
+func Owners(in *nais_io_v1.AzureAdApplication) []string {
+	return annotations.Values(in, annotations.OwnersKey)
+}
+
+// PublicClientRedirectUris returns the redirect URIs requested for public clients of the application, if any.
+func PublicClientRedirectUris(in *nais_io_v1.AzureAdApplication) []string {
+	return annotations.Values(in, annotations.PublicClientRedirectUrisKey)
+}
+
+// FallbackPublicClient returns the value of the annotation requesting that the application is treated as a public
+// client when the client type cannot be determined, if any.
+func FallbackPublicClient(in *nais_io_v1.AzureAdApplication) (string, bool) {
+	value, found := annotations.HasAnnotation(in, annotations.FallbackPublicClientKey)
+	return strings.TrimSpace(value), found && len(strings.TrimSpace(value)) > 0
+}
+
+// SecretSinks returns the names of the external secret sinks requested for the application, if any.
+func SecretSinks(in *nais_io_v1.AzureAdApplication) []string {
+	return annotations.Values(in, annotations.SecretSinksKey)
+}
+
+// SecretTemplates returns the templates for additional secret entries requested for the application, keyed by the
+// secret key.
+func SecretTemplates(in *nais_io_v1.AzureAdApplication) map[string]string {
+	templates := make(map[string]string)
+	for key, value := range in.GetAnnotations() {
+		if name, found := strings.CutPrefix(key, annotations.SecretTemplatePrefix); found {
+			templates[name] = value
+		}
+	}
+	return templates
+}
@@ -46,6 +46,47 @@ func TestIsHashChanged(t *testing.T) {
 	}
 }
 
+func TestHash(t *testing.T) {
+	app := fixtures.MinimalApplication()
+	specHash, err := app.Hash()
+	assert.NoError(t, err)
+
+	t.Run("without settings, the hash of the spec is kept", func(t *testing.T) {
+		for _, settings := range [][]string{nil, {"", ""}} {
+			actual, err := customresources.Hash(app, settings...)
+			assert.NoError(t, err)
+			assert.Equal(t, specHash, actual)
+		}
+	})
+
+	t.Run("settings change the hash", func(t *testing.T) {
+		first, err := customresources.Hash(app, "a", "")
+		assert.NoError(t, err)
+		second, err := customresources.Hash(app, "", "a")
+		assert.NoError(t, err)
+		again, err := customresources.Hash(app, "a", "")
+		assert.NoError(t, err)
+
+		assert.NotEqual(t, specHash, first)
+		assert.NotEqual(t, first, second, "settings are positional")
+		assert.Equal(t, first, again)
+	})
+
+	t.Run("settings are recorded in the synchronization hash", func(t *testing.T) {
+		app := fixtures.MinimalApplication()
+		app.Status.SynchronizationHash, err = customresources.Hash(app, "a")
+		assert.NoError(t, err)
+
+		changed, err := customresources.IsHashChanged(app, "a")
+		assert.NoError(t, err)
+		assert.False(t, changed)
+
+		changed, err = customresources.IsHashChanged(app)
+		assert.NoError(t, err)
+		assert.True(t, changed)
+	})
+}
+
 func TestIsSecretNameChanged(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -133,6 +174,21 @@ func TestHasExpiredSecrets(t *testing.T) {
 	}
 }
 
+func TestNextRotation(t *testing.T) {
+	maxAge := 10 * time.Minute
+
+	app := fixtures.MinimalApplication()
+	app.Status.SynchronizationSecretRotationTime = nil
+	_, found := customresources.NextRotation(app, maxAge)
+	assert.False(t, found)
+
+	rotationTime := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	app.Status.SynchronizationSecretRotationTime = new(metav1.NewTime(rotationTime))
+	nextRotation, found := customresources.NextRotation(app, maxAge)
+	assert.True(t, found)
+	assert.Equal(t, rotationTime.Add(maxAge), nextRotation)
+}
+
 func TestAnnotationChecks(t *testing.T) {
 	checks := []struct {
 		name  string
@@ -141,6 +197,8 @@ func TestAnnotationChecks(t *testing.T) {
 	}{
 		{"HasResynchronizeAnnotation", annotations.ResynchronizeKey, customresources.HasResynchronizeAnnotation},
 		{"HasRotateAnnotation", annotations.RotateKey, customresources.HasRotateAnnotation},
+		{"HasRevokeAnnotation", annotations.RevokeKey, customresources.HasRevokeAnnotation},
+		{"HasCertificateCredentialsAnnotation", annotations.CertificateCredentialsKey, customresources.HasCertificateCredentialsAnnotation},
 	}
 	for _, c := range checks {
 		t.Run(c.name, func(t *testing.T) {
@@ -179,3 +237,42 @@ func TestAnnotationChecks(t *testing.T) {
 		})
 	}
 }
+
+func TestRevokeFieldManager(t *testing.T) {
+	revokeField := func(manager string, at time.Time) metav1.ManagedFieldsEntry {
+		return metav1.ManagedFieldsEntry{
+			Manager:   manager,
+			Operation: metav1.ManagedFieldsOperationUpdate,
+			Time:      &metav1.Time{Time: at},
+			FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{"f:azure.nais.io/revoke":{}}}}`)},
+		}
+	}
+	otherField := metav1.ManagedFieldsEntry{
+		Manager:  "some-controller",
+		Time:     &metav1.Time{Time: time.Now()},
+		FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:secretName":{}}}`)},
+	}
+
+	t.Run("not set", func(t *testing.T) {
+		app := fixtures.MinimalApplication()
+		app.SetManagedFields([]metav1.ManagedFieldsEntry{otherField})
+
+		_, _, found := customresources.RevokeFieldManager(app)
+		assert.False(t, found)
+	})
+
+	t.Run("latest manager", func(t *testing.T) {
+		at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+		app := fixtures.MinimalApplication()
+		app.SetManagedFields([]metav1.ManagedFieldsEntry{
+			revokeField("kubectl-annotate", at),
+			otherField,
+			revokeField("kubectl-edit", at.Add(-time.Hour)),
+		})
+
+		manager, appliedAt, found := customresources.RevokeFieldManager(app)
+		assert.True(t, found)
+		assert.Equal(t, "kubectl-annotate", manager)
+		assert.Equal(t, at, appliedAt)
+	})
+}
@@ -157,6 +157,11 @@ func (c ClusterFixtures) WithPods() ClusterFixtures {
 	return c
 }
 
+func (c ClusterFixtures) WithAnnotations(annotations map[string]string) ClusterFixtures {
+	c.azureAdApplication.SetAnnotations(annotations)
+	return c
+}
+
 func (c ClusterFixtures) WithTenant(tenant string) ClusterFixtures {
 	c.azureAdApplication.Spec.Tenant = tenant
 	return c
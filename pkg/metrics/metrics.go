@@ -12,11 +12,13 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/nais/azureator/pkg/azure/credentials"
 	"github.com/nais/azureator/pkg/labels"
 	"github.com/nais/azureator/pkg/retry"
 )
 
 const (
+	labelName      = "name"
 	labelNamespace = "namespace"
 )
 
@@ -66,6 +68,13 @@ var (
 		},
 		[]string{labelNamespace},
 	)
+	AzureAppsRevokedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "azureadapp_revoked_count",
+			Help: "Number of azureadapps successfully revoked credentials",
+		},
+		[]string{labelNamespace},
+	)
 	AzureAppsProcessedCount = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "azureadapp_processed_count",
@@ -94,6 +103,41 @@ var (
 		},
 		[]string{labelNamespace},
 	)
+	AzureAppCredentialsExpiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azureadapp_credentials_expiry_timestamp_seconds",
+			Help: "Earliest end date in Azure AD of the credentials in the latest secret for the azureadapp, as a Unix timestamp",
+		},
+		[]string{labelNamespace, labelName},
+	)
+	AzureAppCredentials = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azureadapp_credentials",
+			Help: "Number of credentials registered in Azure AD for the azureadapp, by kind and state relative to the latest secret",
+		},
+		[]string{labelNamespace, labelName, "kind", "state"},
+	)
+	AzureAppSecretAge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azureadapp_secret_age_seconds",
+			Help: "Time since the credentials in the latest secret for the azureadapp were rotated",
+		},
+		[]string{labelNamespace, labelName},
+	)
+	AzureAppNextRotation = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azureadapp_next_rotation_timestamp_seconds",
+			Help: "Time at which the credentials for the azureadapp are next due for rotation, as a Unix timestamp",
+		},
+		[]string{labelNamespace, labelName},
+	)
+	AzureAppSkippedGroups = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azureadapp_skipped_groups",
+			Help: "Number of groups that were skipped during the latest synchronization of the azureadapp",
+		},
+		[]string{labelNamespace, labelName},
+	)
 	ResyncEventsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "azureadapp_resync_events_total",
@@ -135,8 +179,14 @@ var AllMetrics = []prometheus.Collector{
 	AzureAppsCreatedCount,
 	AzureAppsUpdatedCount,
 	AzureAppsRotatedCount,
+	AzureAppsRevokedCount,
 	AzureAppsDeletedCount,
 	AzureAppsSkippedCount,
+	AzureAppCredentialsExpiry,
+	AzureAppCredentials,
+	AzureAppSecretAge,
+	AzureAppNextRotation,
+	AzureAppSkippedGroups,
 	ResyncEventsTotal,
 	ResyncCandidatesTotal,
 	ResyncFailedTotal,
@@ -149,6 +199,7 @@ var AllLabeledCounters = []*prometheus.CounterVec{
 	AzureAppsCreatedCount,
 	AzureAppsUpdatedCount,
 	AzureAppsRotatedCount,
+	AzureAppsRevokedCount,
 	AzureAppsDeletedCount,
 	AzureAppsSkippedCount,
 }
@@ -157,6 +208,54 @@ func IncWithNamespaceLabel(metric *prometheus.CounterVec, namespace string) {
 	metric.WithLabelValues(namespace).Inc()
 }
 
+// SetCredentialsExpiry records the earliest end date of the credentials for the given application, or removes the
+// record if the credentials do not expire.
+func SetCredentialsExpiry(app *v1.AzureAdApplication, expiresAt time.Time) {
+	if expiresAt.IsZero() {
+		AzureAppCredentialsExpiry.DeleteLabelValues(app.GetNamespace(), app.GetName())
+		return
+	}
+	AzureAppCredentialsExpiry.WithLabelValues(app.GetNamespace(), app.GetName()).Set(float64(expiresAt.Unix()))
+}
+
+// SetCredentials records the number of credentials registered for the given application by kind and state, replacing
+// any previous record.
+func SetCredentials(app *v1.AzureAdApplication, registrations []credentials.Registration) {
+	AzureAppCredentials.DeletePartialMatch(prometheus.Labels{labelNamespace: app.GetNamespace(), labelName: app.GetName()})
+	for _, registration := range registrations {
+		AzureAppCredentials.WithLabelValues(app.GetNamespace(), app.GetName(), string(registration.Kind), string(registration.State)).Inc()
+	}
+}
+
+// SetNextRotation records the time at which the credentials for the given application are next due for rotation, or
+// removes the record if the credentials are not rotated.
+func SetNextRotation(app *v1.AzureAdApplication, nextRotation time.Time) {
+	if nextRotation.IsZero() {
+		AzureAppNextRotation.DeleteLabelValues(app.GetNamespace(), app.GetName())
+		return
+	}
+	AzureAppNextRotation.WithLabelValues(app.GetNamespace(), app.GetName()).Set(float64(nextRotation.Unix()))
+}
+
+// SetSkippedGroups records the number of groups that were skipped for the given application, or removes the record if
+// there are none.
+func SetSkippedGroups(app *v1.AzureAdApplication, count int) {
+	if count == 0 {
+		AzureAppSkippedGroups.DeleteLabelValues(app.GetNamespace(), app.GetName())
+		return
+	}
+	AzureAppSkippedGroups.WithLabelValues(app.GetNamespace(), app.GetName()).Set(float64(count))
+}
+
+// DeleteApplication removes the metrics recorded for the given application.
+func DeleteApplication(app *v1.AzureAdApplication) {
+	AzureAppCredentialsExpiry.DeleteLabelValues(app.GetNamespace(), app.GetName())
+	AzureAppCredentials.DeletePartialMatch(prometheus.Labels{labelNamespace: app.GetNamespace(), labelName: app.GetName()})
+	AzureAppSecretAge.DeleteLabelValues(app.GetNamespace(), app.GetName())
+	AzureAppNextRotation.DeleteLabelValues(app.GetNamespace(), app.GetName())
+	AzureAppSkippedGroups.DeleteLabelValues(app.GetNamespace(), app.GetName())
+}
+
 type Metrics interface {
 	Refresh(ctx context.Context)
 }
@@ -223,5 +322,12 @@ func (m metrics) Refresh(ctx context.Context) {
 			log.Errorf("failed to list azure apps: %v", err)
 		}
 		AzureAppsTotal.Set(float64(len(azureAdAppList.Items)))
+
+		AzureAppSecretAge.Reset()
+		for _, app := range azureAdAppList.Items {
+			if rotationTime := app.Status.SynchronizationSecretRotationTime; rotationTime != nil {
+				AzureAppSecretAge.WithLabelValues(app.GetNamespace(), app.GetName()).Set(time.Since(rotationTime.Time).Seconds())
+			}
+		}
 	}
 }
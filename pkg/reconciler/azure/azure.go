@@ -9,11 +9,13 @@ import (
 	log "github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/nais/azureator/pkg/azure"
 	"github.com/nais/azureator/pkg/azure/credentials"
 	"github.com/nais/azureator/pkg/azure/result"
 	"github.com/nais/azureator/pkg/config"
+	"github.com/nais/azureator/pkg/customresources"
 	"github.com/nais/azureator/pkg/metrics"
 	"github.com/nais/azureator/pkg/reconciler"
 	"github.com/nais/azureator/pkg/retry"
@@ -62,6 +64,7 @@ func (a azureReconciler) Process(tx transaction.Transaction) (*result.Applicatio
 
 	if applicationResult.IsModified() {
 		a.reportPreAuthorizedApplicationStatus(tx, applicationResult.PreAuthorizedApps)
+		a.reportGroupStatus(tx, applicationResult.Groups)
 	}
 
 	go a.produceEvent(tx, applicationResult)
@@ -112,6 +115,7 @@ func (a azureReconciler) notModified(tx transaction.Transaction) (*result.Applic
 		ServicePrincipalId: tx.Instance.Status.ServicePrincipalId,
 		PreAuthorizedApps:  *apps,
 		Tenant:             a.config.Azure.Tenant.Id,
+		AccessTokenVersion: tx.Options.Process.Azure.APISettings.AccessTokenVersionOr(a.config.Azure.APISettings.DefaultAccessTokenVersion()),
 		Result:             result.OperationNotModified,
 	}, nil
 }
@@ -159,9 +163,8 @@ func (a azureReconciler) AddCredentials(tx transaction.Transaction) (*credential
 
 	tx.Logger.Info("successfully added credentials for Azure application")
 
-	keyIDsInUse := tx.Secrets.KeyIDs.Used
-	keyIDsInUse.Certificate = append(keyIDsInUse.Certificate, credentialsSet.Current.Certificate.KeyId)
-	keyIDsInUse.Password = append(keyIDsInUse.Password, credentialsSet.Current.Password.KeyId)
+	keyIDsInUse := tx.Secrets.KeyIDs.Used.WithCredentials(credentialsSet.Current)
+	a.updateCredentialsMetrics(tx, credentialsSet)
 	return &credentialsSet, keyIDsInUse, nil
 }
 
@@ -197,15 +200,64 @@ func (a azureReconciler) RotateCredentials(tx transaction.Transaction) (*credent
 
 	tx.Logger.Info("successfully rotated credentials for Azure application")
 
-	keyIDsInUse := tx.Secrets.KeyIDs.Used
-	keyIDsInUse.Certificate = append(keyIDsInUse.Certificate, credentialsSet.Current.Certificate.KeyId)
-	keyIDsInUse.Password = append(keyIDsInUse.Password, credentialsSet.Current.Password.KeyId)
+	keyIDsInUse := tx.Secrets.KeyIDs.Used.WithCredentials(credentialsSet.Current)
 
 	metrics.IncWithNamespaceLabel(metrics.AzureAppsRotatedCount, tx.Instance.Namespace)
 	a.ReportEvent(tx, corev1.EventTypeNormal, v1.EventRotatedInAzure, "Azure credentials is rotated")
+	a.updateCredentialsMetrics(tx, credentialsSet)
 	return &credentialsSet, keyIDsInUse, nil
 }
 
+// RevokeCredentials removes all credentials for the application in Azure AD and adds a new set. Unlike rotation, no
+// existing credentials are kept, so workloads using any previous secret lose access immediately.
+func (a azureReconciler) RevokeCredentials(tx transaction.Transaction) (*credentials.Set, credentials.KeyID, error) {
+	tx.Logger.Warn("revoking all credentials for Azure application...")
+
+	if err := a.PurgeCredentials(tx); err != nil {
+		return nil, credentials.KeyID{}, fmt.Errorf("purging credentials: %w", err)
+	}
+
+	credentialsSet, err := a.azureClient.Credentials().Add(tx)
+	if err != nil {
+		return nil, credentials.KeyID{}, err
+	}
+
+	keyIDsInUse := credentials.KeyID{}.WithCredentials(credentialsSet.Current)
+
+	fieldManager := "unknown"
+	appliedAt := time.Now()
+	if manager, at, found := customresources.RevokeFieldManager(tx.Instance); found {
+		fieldManager = manager
+		if !at.IsZero() {
+			appliedAt = at
+		}
+	}
+
+	message := fmt.Sprintf("Azure credentials are revoked and replaced; annotation applied with field manager '%s' at %s", fieldManager, appliedAt.UTC().Format(time.RFC3339))
+	tx.Logger.WithFields(log.Fields{
+		"event_type":    "credentials_revoked",
+		"field_manager": fieldManager,
+		"applied_at":    appliedAt,
+	}).Warn(message)
+	a.recorder.Eventf(tx.Instance, nil, corev1.EventTypeWarning, "RevokedInAzure", "RevokedInAzure", message)
+	metrics.IncWithNamespaceLabel(metrics.AzureAppsRevokedCount, tx.Instance.Namespace)
+	a.updateCredentialsMetrics(tx, credentialsSet)
+
+	return &credentialsSet, keyIDsInUse, nil
+}
+
+// updateCredentialsMetrics records the expiry and registrations of a set of credentials that replaced the existing
+// set. The end dates of the new credentials are only known to Azure AD, so the set is validated to find them.
+func (a azureReconciler) updateCredentialsMetrics(tx transaction.Transaction, set credentials.Set) {
+	validation, err := a.azureClient.Credentials().Validate(tx, set)
+	if err != nil {
+		tx.Logger.Warnf("validating replaced credentials: %+v", err)
+		validation = credentials.Invalid
+	}
+	metrics.SetCredentialsExpiry(tx.Instance, validation.ExpiresAt)
+	metrics.SetCredentials(tx.Instance, validation.Registrations)
+}
+
 func (a azureReconciler) PurgeCredentials(tx transaction.Transaction) error {
 	if !tx.ExistsInAzure {
 		return nil
@@ -215,23 +267,40 @@ func (a azureReconciler) PurgeCredentials(tx transaction.Transaction) error {
 	return a.azureClient.Credentials().Purge(tx)
 }
 
-func (a azureReconciler) ValidateCredentials(tx transaction.Transaction) (bool, error) {
+// ValidateCredentials validates the existing credentials against Azure AD. Credentials that expire in Azure AD within
+// the configured margin are reported as invalid, so that they are replaced ahead of expiry.
+func (a azureReconciler) ValidateCredentials(tx transaction.Transaction) (credentials.Validation, error) {
 	if !tx.ExistsInAzure || !tx.Options.Process.Secret.Valid {
-		return false, nil
+		return credentials.Invalid, nil
 	}
 
-	valid, err := a.azureClient.Credentials().Validate(tx, *tx.Secrets.LatestCredentials.Set)
+	validation, err := a.azureClient.Credentials().Validate(tx, *tx.Secrets.LatestCredentials.Set)
 	if err != nil {
-		return false, err
+		return credentials.Invalid, err
 	}
 
-	if valid {
-		tx.Logger.Debug("existing credentials are valid and in sync with Azure")
-	} else {
+	if !validation.Valid {
 		tx.Logger.Warnf("existing credentials are not in sync with Azure")
+		return validation, nil
+	}
+
+	if expiresAt := validation.ExpiresAt; !expiresAt.IsZero() {
+		expiresIn := time.Until(expiresAt)
+		if expiresIn < a.config.SecretRotation.Expiry.Warning {
+			message := fmt.Sprintf("Azure credentials expire at %s", expiresAt.UTC().Format(time.RFC3339))
+			a.recorder.Eventf(tx.Instance, nil, corev1.EventTypeWarning, "CredentialsExpiring", "CredentialsExpiring", message)
+			tx.Logger.Warnf("existing credentials expire at %s", expiresAt)
+		}
+
+		if expiresIn < a.config.SecretRotation.Expiry.Margin {
+			tx.Logger.Infof("existing credentials expire within %s; replacing credentials...", a.config.SecretRotation.Expiry.Margin)
+			validation.Valid = false
+			return validation, nil
+		}
 	}
 
-	return valid, nil
+	tx.Logger.Debug("existing credentials are valid and in sync with Azure")
+	return validation, nil
 }
 
 func (a azureReconciler) Delete(tx transaction.Transaction) error {
@@ -332,3 +401,24 @@ func (a azureReconciler) reportPreAuthorizedApplicationStatus(tx transaction.Tra
 		UnassignedCount: new(len(unassigned)),
 	}
 }
+
+// reportGroupStatus reports the assigned and skipped groups in events and metrics. The status of the resource has no
+// field for groups, so the skipped groups are only recorded in memory for the sweeper to re-check.
+func (a azureReconciler) reportGroupStatus(tx transaction.Transaction, groups result.Groups) {
+	for _, group := range groups.Assigned {
+		message := fmt.Sprintf("assigned group '%s' (%s)", group.Name, group.ObjectId)
+		tx.Logger.WithField("event_type", "group_assigned").Debug(message)
+		a.recorder.Eventf(tx.Instance, nil, corev1.EventTypeNormal, "GroupAssigned", "GroupAssigned", message)
+	}
+
+	skipped := make([]string, 0, len(groups.Skipped))
+	for _, group := range groups.Skipped {
+		message := fmt.Sprintf("skipped group '%s'; %s in tenant (%s)", group.Reference, group.Reason, a.config.Azure.Tenant.String())
+		tx.Logger.WithField("event_type", "group_skipped").Info(message)
+		a.recorder.Eventf(tx.Instance, nil, corev1.EventTypeWarning, "GroupSkipped", "GroupSkipped", message)
+		skipped = append(skipped, group.Reference)
+	}
+
+	metrics.SetSkippedGroups(tx.Instance, len(skipped))
+	a.synchronizer.SkippedGroups().Set(client.ObjectKeyFromObject(tx.Instance), skipped)
+}
@@ -66,6 +66,10 @@ func (f finalizer) register(tx transaction.Transaction) error {
 func (f finalizer) finalize(tx transaction.Transaction) error {
 	tx.Logger.Debug("finalizer triggered, deleting resources...")
 
+	if err := f.Secrets().Purge(tx); err != nil {
+		return fmt.Errorf("purging secret sinks: %w", err)
+	}
+
 	_, shouldPreserve := annotations.HasAnnotation(tx.Instance, annotations.PreserveKey)
 	if shouldPreserve {
 		err := f.Azure().PurgeCredentials(tx)
@@ -92,5 +96,6 @@ func (f finalizer) finalize(tx transaction.Transaction) error {
 	}
 
 	metrics.IncWithNamespaceLabel(metrics.AzureAppsDeletedCount, tx.Instance.Namespace)
+	metrics.DeleteApplication(tx.Instance)
 	return nil
 }
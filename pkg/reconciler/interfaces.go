@@ -30,8 +30,9 @@ type Azure interface {
 	DeleteExpiredCredentials(tx transaction.Transaction) error
 	DeleteUnusedCredentials(tx transaction.Transaction) error
 	RotateCredentials(tx transaction.Transaction) (*credentials.Set, credentials.KeyID, error)
+	RevokeCredentials(tx transaction.Transaction) (*credentials.Set, credentials.KeyID, error)
 	PurgeCredentials(tx transaction.Transaction) error
-	ValidateCredentials(tx transaction.Transaction) (bool, error)
+	ValidateCredentials(tx transaction.Transaction) (credentials.Validation, error)
 }
 
 type Finalizer interface {
@@ -42,4 +43,5 @@ type Secrets interface {
 	Prepare(ctx context.Context, instance *v1.AzureAdApplication) (*secrets.Secrets, error)
 	Process(tx transaction.Transaction, applicationResult *result.Application) error
 	DeleteUnused(tx transaction.Transaction) error
+	Purge(tx transaction.Transaction) error
 }
@@ -3,6 +3,9 @@ package secrets
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	"github.com/nais/liberator/pkg/kubernetes"
@@ -17,11 +20,14 @@ import (
 	"github.com/nais/azureator/pkg/azure/credentials"
 	"github.com/nais/azureator/pkg/azure/result"
 	"github.com/nais/azureator/pkg/config"
+	"github.com/nais/azureator/pkg/customresources"
 	"github.com/nais/azureator/pkg/labels"
 	"github.com/nais/azureator/pkg/reconciler"
 	"github.com/nais/azureator/pkg/secrets"
+	"github.com/nais/azureator/pkg/secrets/sink"
 	"github.com/nais/azureator/pkg/transaction"
 	transactionSecrets "github.com/nais/azureator/pkg/transaction/secrets"
+	"github.com/nais/azureator/pkg/workloads"
 )
 
 // +kubebuilder:rbac:groups=*,resources=secrets,verbs=get;list;watch;create;delete;update;patch
@@ -32,6 +38,7 @@ type secretsReconciler struct {
 	client            client.Client
 	reader            client.Reader
 	scheme            *runtime.Scheme
+	sinks             sink.Sinks
 }
 
 func NewSecretsReconciler(
@@ -40,6 +47,7 @@ func NewSecretsReconciler(
 	client client.Client,
 	reader client.Reader,
 	scheme *runtime.Scheme,
+	sinks sink.Sinks,
 ) reconciler.Secrets {
 	return secretsReconciler{
 		AzureAdApplication: azureAdApplication,
@@ -47,17 +55,27 @@ func NewSecretsReconciler(
 		client:             client,
 		reader:             reader,
 		scheme:             scheme,
+		sinks:              sinks,
 	}
 }
 
 func (s secretsReconciler) Prepare(ctx context.Context, instance *v1.AzureAdApplication) (*transactionSecrets.Secrets, error) {
 	dataKeys := secrets.NewSecretDataKeys(instance.Spec.SecretKeyPrefix)
+	if customresources.HasCertificateCredentialsAnnotation(instance) {
+		dataKeys = dataKeys.WithCertificateKeys()
+	}
+
+	credentialMode, err := customresources.CredentialMode(instance)
+	if err != nil {
+		return nil, err
+	}
 
 	managedSecrets, err := s.getManaged(ctx, instance)
 	if err != nil {
 		return nil, fmt.Errorf("getting managed secrets: %w", err)
 	}
 
+	// existing secrets may hold any kind of credentials, regardless of the current credential mode
 	secretsExtractor := secrets.NewExtractor(managedSecrets, dataKeys)
 
 	keyIDs := func() credentials.KeyIDs {
@@ -77,7 +95,7 @@ func (s secretsReconciler) Prepare(ctx context.Context, instance *v1.AzureAdAppl
 			Set:   credentialsSet,
 			Valid: validCredentials,
 		},
-		DataKeys:       dataKeys,
+		DataKeys:       dataKeys.WithCredentialMode(credentialMode),
 		KeyIDs:         keyIDs,
 		ManagedSecrets: managedSecrets,
 	}, nil
@@ -85,7 +103,7 @@ func (s secretsReconciler) Prepare(ctx context.Context, instance *v1.AzureAdAppl
 
 func (s secretsReconciler) Process(tx transaction.Transaction, applicationResult *result.Application) error {
 	// return early if no operations needed
-	if tx.Options.Process.Secret.Valid && !tx.Options.Process.Secret.Rotate && applicationResult.IsNotModified() {
+	if tx.Options.Process.Secret.Valid && !tx.Options.Process.Secret.Rotate && !tx.Options.Process.Secret.KeysChanged && !tx.Options.Process.Secret.SinksChanged && !tx.Options.Process.Secret.TemplatesChanged && applicationResult.IsNotModified() {
 		return nil
 	}
 
@@ -95,6 +113,11 @@ func (s secretsReconciler) Process(tx transaction.Transaction, applicationResult
 	keyIdsInUse := tx.Secrets.KeyIDs.Used
 
 	switch {
+	case tx.Options.Process.Secret.Revoke:
+		credentialsSet, keyIdsInUse, err = s.Azure().RevokeCredentials(tx)
+		if err != nil {
+			return fmt.Errorf("revoking azure credentials: %w", err)
+		}
 	case !tx.Options.Process.Secret.Valid:
 		credentialsSet, keyIdsInUse, err = s.Azure().AddCredentials(tx)
 		if err != nil {
@@ -111,6 +134,10 @@ func (s secretsReconciler) Process(tx transaction.Transaction, applicationResult
 		return err
 	}
 
+	if tx.Options.Process.Secret.RestartWorkloads {
+		s.restartWorkloads(tx)
+	}
+
 	if !tx.Options.Process.Secret.Valid || tx.Options.Process.Secret.Rotate {
 		tx.Instance.Status.CertificateKeyIds = keyIdsInUse.Certificate
 		tx.Instance.Status.PasswordKeyIds = keyIdsInUse.Password
@@ -127,6 +154,14 @@ func (s secretsReconciler) createOrUpdate(tx transaction.Transaction, result res
 	objectMeta.SetAnnotations(map[string]string{
 		annotations.StakaterReloaderKey: "true",
 	})
+	if sinks := tx.Options.Process.Secret.Sinks; len(sinks) > 0 {
+		// records the sinks holding a copy of the secret data, so that they can be cleaned up when no longer requested
+		objectMeta.Annotations[annotations.SecretSinksKey] = strings.Join(sinks, ",")
+	}
+	if digest := tx.Options.Process.Secret.Templates.Digest(); len(digest) > 0 {
+		// records the templates that rendered the secret, so that changes to the templates can be detected
+		objectMeta.Annotations[annotations.SecretTemplatesKey] = digest
+	}
 
 	secret := &corev1.Secret{
 		ObjectMeta: objectMeta,
@@ -141,8 +176,24 @@ func (s secretsReconciler) createOrUpdate(tx transaction.Transaction, result res
 	if err != nil {
 		return fmt.Errorf("creating secret data for secret '%s': %w", secretName, err)
 	}
+	maps.Copy(stringData, secrets.WorkloadIdentityData(tx.Secrets.DataKeys, tx.Options.Process.Secret.WorkloadIdentity))
+
+	rendered, err := tx.Options.Process.Secret.Templates.Render(stringData)
+	if err != nil {
+		return fmt.Errorf("creating secret data for secret '%s': %w", secretName, err)
+	}
+	maps.Copy(stringData, rendered)
+
+	// external sinks are written first, so that the Kubernetes Secret only records sinks that hold the latest data
+	for _, name := range tx.Options.Process.Secret.Sinks {
+		if err := s.writeSink(tx, name, stringData); err != nil {
+			return err
+		}
+	}
 
 	secretMutateFn := func() error {
+		// replace all existing data so that keys no longer in use, e.g. disabled optional keys, are removed
+		secret.Data = nil
 		secret.StringData = stringData
 		return ctrl.SetControllerReference(tx.Instance, secret, s.scheme)
 	}
@@ -153,9 +204,85 @@ func (s secretsReconciler) createOrUpdate(tx transaction.Transaction, result res
 	}
 
 	tx.Logger.Infof("secret '%s' %s", secretName, res)
+
+	for _, name := range tx.Options.Process.Secret.StaleSinks {
+		if err := s.deleteSink(tx, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s secretsReconciler) sinkTarget(tx transaction.Transaction) sink.Target {
+	return sink.Target{
+		Cluster:   tx.ClusterName,
+		Namespace: tx.Instance.GetNamespace(),
+		Name:      tx.Instance.GetName(),
+	}
+}
+
+func (s secretsReconciler) writeSink(tx transaction.Transaction, name string, data map[string]string) error {
+	target, err := s.sinks.Get(name)
+	if err != nil {
+		return err
+	}
+
+	if err := target.Write(tx.Ctx, s.sinkTarget(tx), data); err != nil {
+		return fmt.Errorf("writing to secret sink '%s': %w", name, err)
+	}
+
+	tx.Logger.Infof("secret data written to sink '%s'", name)
+	return nil
+}
+
+func (s secretsReconciler) deleteSink(tx transaction.Transaction, name string) error {
+	target, err := s.sinks.Get(name)
+	if err != nil {
+		// the sink is no longer configured, so there is nothing we can clean up
+		tx.Logger.Warnf("skipping cleanup of secret sink: %+v", err)
+		return nil
+	}
+
+	if err := target.Delete(tx.Ctx, s.sinkTarget(tx)); err != nil {
+		return fmt.Errorf("deleting from secret sink '%s': %w", name, err)
+	}
+
+	tx.Logger.Infof("secret data deleted from sink '%s'", name)
 	return nil
 }
 
+// Purge deletes the secret data from all external sinks that it has been written to.
+func (s secretsReconciler) Purge(tx transaction.Transaction) error {
+	for _, name := range slices.Concat(tx.Options.Process.Secret.Sinks, tx.Options.Process.Secret.StaleSinks) {
+		if err := s.deleteSink(tx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restartWorkloads restarts the workloads using the secret, so that they pick up the new credentials right away.
+// Failures are reported but not returned, as the credentials have already been replaced and a retry would revoke them
+// once more.
+func (s secretsReconciler) restartWorkloads(tx transaction.Transaction) {
+	secretName := tx.Instance.Spec.SecretName
+
+	found, err := workloads.UsingSecret(tx.Ctx, s.reader, tx.Instance.GetNamespace(), secretName)
+	if err == nil {
+		err = workloads.Restart(tx.Ctx, s.client, tx.Instance.GetNamespace(), found)
+	}
+	if err != nil {
+		tx.Logger.Errorf("restarting workloads using secret '%s': %+v", secretName, err)
+		s.ReportEvent(tx, corev1.EventTypeWarning, "FailedRestart", fmt.Sprintf("Failed to restart workloads using secret '%s'", secretName))
+		return
+	}
+
+	for _, workload := range found {
+		tx.Logger.Infof("restarted %s using secret '%s'", workload, secretName)
+	}
+}
+
 func (s secretsReconciler) getManaged(ctx context.Context, instance *v1.AzureAdApplication) (kubernetes.SecretLists, error) {
 	objectKey := client.ObjectKey{
 		Name:      instance.GetName(),
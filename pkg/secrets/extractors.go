@@ -147,41 +147,48 @@ func extractCredentials(secret corev1.Secret, keys extractCredentialsKeys) (*cre
 	var clientJwk jose.JSONWebKey
 	var err error
 
-	clientSecret, exists := secret.Data[keys.clientSecretKey]
-	if !isValidSecretData(clientSecret, exists) {
-		return nil, false, nil
-	}
+	clientSecret, hasClientSecret := secret.Data[keys.clientSecretKey]
+	hasClientSecret = isValidSecretData(clientSecret, hasClientSecret)
 
-	passwordId, exists := secret.Data[keys.passwordIdKey]
-	if !isValidSecretData(passwordId, exists) {
-		return nil, false, nil
-	}
+	passwordId, hasPasswordId := secret.Data[keys.passwordIdKey]
+	hasPasswordId = isValidSecretData(passwordId, hasPasswordId)
 
-	jwkSecret, exists := secret.Data[keys.jwkSecretKey]
-	if !isValidSecretData(jwkSecret, exists) {
-		return nil, false, nil
-	}
+	jwkSecret, hasJwk := secret.Data[keys.jwkSecretKey]
+	hasJwk = isValidSecretData(jwkSecret, hasJwk)
+
+	certificateId, hasCertificateId := secret.Data[keys.certificateIdKey]
+	hasCertificateId = isValidSecretData(certificateId, hasCertificateId)
 
-	certificateId, exists := secret.Data[keys.certificateIdKey]
-	if !isValidSecretData(certificateId, exists) {
+	// either kind of credential may be absent depending on the credential mode, but must be complete if present.
+	// both are absent for applications using workload identity federation.
+	hasPassword := hasClientSecret && hasPasswordId
+	hasCertificate := hasJwk && hasCertificateId
+	if hasPassword != (hasClientSecret || hasPasswordId) || hasCertificate != (hasJwk || hasCertificateId) {
 		return nil, false, nil
 	}
 
-	err = clientJwk.UnmarshalJSON(jwkSecret)
-	if err != nil {
-		return nil, false, err
-	}
+	result := &credentials.Credentials{}
 
-	return &credentials.Credentials{
-		Certificate: credentials.Certificate{
+	if hasCertificate {
+		err = clientJwk.UnmarshalJSON(jwkSecret)
+		if err != nil {
+			return nil, false, err
+		}
+
+		result.Certificate = credentials.Certificate{
 			KeyId: string(certificateId),
 			Jwk:   crypto.FromJwk(clientJwk),
-		},
-		Password: credentials.Password{
+		}
+	}
+
+	if hasPassword {
+		result.Password = credentials.Password{
 			KeyId:        string(passwordId),
 			ClientSecret: string(clientSecret),
-		},
-	}, true, nil
+		}
+	}
+
+	return result, true, nil
 }
 
 func isValidSecretData(data []byte, found bool) bool {
@@ -3,11 +3,15 @@ package secrets
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
+	corev1 "k8s.io/api/core/v1"
+
 	"github.com/nais/azureator/pkg/azure/credentials"
 	"github.com/nais/azureator/pkg/azure/result"
 	"github.com/nais/azureator/pkg/config"
+	"github.com/nais/azureator/pkg/util/crypto"
 )
 
 const (
@@ -29,12 +33,26 @@ const (
 	nextJwkSuffix           = "_APP_NEXT_JWK"
 	nextPasswordIdSuffix    = "_APP_NEXT_PASSWORD_KEY_ID"
 
-	openIDConfigIssuerKey        = "_OPENID_CONFIG_ISSUER"
-	openIDConfigJwksUriKey       = "_OPENID_CONFIG_JWKS_URI"
-	openIDConfigTokenEndpointKey = "_OPENID_CONFIG_TOKEN_ENDPOINT"
+	certificatePemSuffix                  = "_APP_CERTIFICATE_PEM"
+	certificateThumbprintSuffix           = "_APP_CERTIFICATE_X5T"
+	certificateThumbprintSHA256Suffix     = "_APP_CERTIFICATE_X5T_S256"
+	privateKeyPemSuffix                   = "_APP_PRIVATE_KEY_PEM"
+	nextCertificatePemSuffix              = "_APP_NEXT_CERTIFICATE_PEM"
+	nextCertificateThumbprintSuffix       = "_APP_NEXT_CERTIFICATE_X5T"
+	nextCertificateThumbprintSHA256Suffix = "_APP_NEXT_CERTIFICATE_X5T_S256"
+	nextPrivateKeyPemSuffix               = "_APP_NEXT_PRIVATE_KEY_PEM"
+
+	federatedTokenFileSuffix = "_APP_FEDERATED_TOKEN_FILE"
+
+	openIDConfigIssuerKey            = "_OPENID_CONFIG_ISSUER"
+	openIDConfigAccessTokenIssuerKey = "_OPENID_CONFIG_ACCESS_TOKEN_ISSUER"
+	openIDConfigJwksUriKey           = "_OPENID_CONFIG_JWKS_URI"
+	openIDConfigTokenEndpointKey     = "_OPENID_CONFIG_TOKEN_ENDPOINT"
 )
 
 type SecretDataKeys struct {
+	prefix string
+
 	ClientId           string
 	CurrentCredentials CredentialKeys
 	NextCredentials    CredentialKeys
@@ -42,6 +60,7 @@ type SecretDataKeys struct {
 	TenantId           string
 	WellKnownUrl       string
 	OpenId             OpenIdConfigKeys
+	WorkloadIdentity   WorkloadIdentityKeys
 }
 
 func NewSecretDataKeys(keyPrefix ...string) SecretDataKeys {
@@ -54,6 +73,7 @@ func NewSecretDataKeys(keyPrefix ...string) SecretDataKeys {
 	}
 
 	return SecretDataKeys{
+		prefix:   prefix,
 		ClientId: prefix + clientIdSuffix,
 		CurrentCredentials: CredentialKeys{
 			CertificateKeyId: prefix + certificateIdSuffix,
@@ -72,15 +92,59 @@ func NewSecretDataKeys(keyPrefix ...string) SecretDataKeys {
 		TenantId:     prefix + tenantIdSuffix,
 		WellKnownUrl: prefix + wellKnownUrlSuffix,
 		OpenId: OpenIdConfigKeys{
-			Issuer:        prefix + openIDConfigIssuerKey,
-			JwksUri:       prefix + openIDConfigJwksUriKey,
-			TokenEndpoint: prefix + openIDConfigTokenEndpointKey,
+			Issuer:            prefix + openIDConfigIssuerKey,
+			AccessTokenIssuer: prefix + openIDConfigAccessTokenIssuerKey,
+			JwksUri:           prefix + openIDConfigJwksUriKey,
+			TokenEndpoint:     prefix + openIDConfigTokenEndpointKey,
 		},
 	}
 }
 
+// WithCertificateKeys returns a copy of the keys that includes the optional keys for the certificate, private key and
+// certificate thumbprints.
+func (s SecretDataKeys) WithCertificateKeys() SecretDataKeys {
+	s.CurrentCredentials.Certificate = CertificateKeys{
+		CertificatePem:              s.prefix + certificatePemSuffix,
+		PrivateKeyPem:               s.prefix + privateKeyPemSuffix,
+		CertificateThumbprint:       s.prefix + certificateThumbprintSuffix,
+		CertificateThumbprintSHA256: s.prefix + certificateThumbprintSHA256Suffix,
+	}
+	s.NextCredentials.Certificate = CertificateKeys{
+		CertificatePem:              s.prefix + nextCertificatePemSuffix,
+		PrivateKeyPem:               s.prefix + nextPrivateKeyPemSuffix,
+		CertificateThumbprint:       s.prefix + nextCertificateThumbprintSuffix,
+		CertificateThumbprintSHA256: s.prefix + nextCertificateThumbprintSHA256Suffix,
+	}
+	return s
+}
+
+// WithCredentialMode returns a copy of the keys without the keys for kinds of credentials that are disabled by the
+// given mode, and with the keys for workload identity federation if enabled by the mode.
+func (s SecretDataKeys) WithCredentialMode(mode credentials.Mode) SecretDataKeys {
+	for _, credentialKeys := range []*CredentialKeys{&s.CurrentCredentials, &s.NextCredentials} {
+		if !mode.Password() {
+			credentialKeys.ClientSecret = ""
+			credentialKeys.PasswordKeyId = ""
+		}
+		if !mode.Certificate() {
+			credentialKeys.CertificateKeyId = ""
+			credentialKeys.Jwk = ""
+			credentialKeys.Jwks = ""
+			credentialKeys.Certificate = CertificateKeys{}
+		}
+	}
+
+	if mode.WorkloadIdentity() {
+		s.WorkloadIdentity = WorkloadIdentityKeys{
+			TokenFile: s.prefix + federatedTokenFileSuffix,
+		}
+	}
+	return s
+}
+
+// AllKeys returns all keys written to the secret, including optional keys if enabled.
 func (s SecretDataKeys) AllKeys() []string {
-	return []string{
+	keys := []string{
 		s.ClientId,
 		s.CurrentCredentials.CertificateKeyId,
 		s.CurrentCredentials.ClientSecret,
@@ -95,9 +159,48 @@ func (s SecretDataKeys) AllKeys() []string {
 		s.TenantId,
 		s.WellKnownUrl,
 		s.OpenId.Issuer,
+		s.OpenId.AccessTokenIssuer,
 		s.OpenId.JwksUri,
 		s.OpenId.TokenEndpoint,
+		s.WorkloadIdentity.TokenFile,
+	}
+
+	for _, certificateKeys := range []CertificateKeys{s.CurrentCredentials.Certificate, s.NextCredentials.Certificate} {
+		if certificateKeys.Enabled() {
+			keys = append(keys,
+				certificateKeys.CertificatePem,
+				certificateKeys.PrivateKeyPem,
+				certificateKeys.CertificateThumbprint,
+				certificateKeys.CertificateThumbprintSHA256,
+			)
+		}
 	}
+
+	return slices.DeleteFunc(keys, func(key string) bool {
+		return len(key) == 0
+	})
+}
+
+// KeysChanged returns true if the secret does not contain all the given keys, or contains optional keys or keys for
+// kinds of credentials that are not enabled in the given keys.
+func KeysChanged(secret corev1.Secret, keys SecretDataKeys) bool {
+	desired := keys.AllKeys()
+	for _, key := range desired {
+		if _, found := secret.Data[key]; !found {
+			return true
+		}
+	}
+
+	all := NewSecretDataKeys(keys.prefix).WithCertificateKeys()
+	all.WorkloadIdentity = NewSecretDataKeys(keys.prefix).WithCredentialMode(credentials.ModeWorkloadIdentity).WorkloadIdentity
+
+	for _, key := range all.AllKeys() {
+		if _, found := secret.Data[key]; found && !slices.Contains(desired, key) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func secretPrefix(prefix string) string {
@@ -113,28 +216,53 @@ type CredentialKeys struct {
 	PasswordKeyId    string
 	Jwks             string
 	Jwk              string
+	// Certificate holds the optional keys for the certificate in other formats than JWK.
+	Certificate CertificateKeys
+}
+
+type CertificateKeys struct {
+	CertificatePem              string
+	PrivateKeyPem               string
+	CertificateThumbprint       string
+	CertificateThumbprintSHA256 string
+}
+
+func (c CertificateKeys) Enabled() bool {
+	return len(c.CertificatePem) > 0
+}
+
+type WorkloadIdentityKeys struct {
+	TokenFile string
 }
 
 type OpenIdConfigKeys struct {
-	Issuer        string
-	JwksUri       string
-	TokenEndpoint string
+	Issuer            string
+	AccessTokenIssuer string
+	JwksUri           string
+	TokenEndpoint     string
 }
 
+// SecretData returns the data for the secret. Keys that are empty, i.e. for kinds of credentials disabled by
+// SecretDataKeys.WithCredentialMode, are omitted.
 func SecretData(app result.Application, set credentials.Set, azureOpenIDConfig config.AzureOpenIdConfig, keys SecretDataKeys) (map[string]string, error) {
-	jwkJson, err := json.Marshal(set.Current.Certificate.Jwk.Private)
-	if err != nil {
-		return nil, fmt.Errorf("marshalling private JWK: %w", err)
-	}
+	var jwkJson, nextJwkJson, jwksJson []byte
+	var err error
 
-	nextJwkJson, err := json.Marshal(set.Next.Certificate.Jwk.Private)
-	if err != nil {
-		return nil, fmt.Errorf("marshalling next private JWK: %w", err)
-	}
+	if len(keys.CurrentCredentials.Jwk) > 0 {
+		jwkJson, err = json.Marshal(set.Current.Certificate.Jwk.Private)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling private JWK: %w", err)
+		}
 
-	jwksJson, err := json.Marshal(set.Current.Certificate.Jwk.ToPrivateJwks())
-	if err != nil {
-		return nil, fmt.Errorf("marshalling private JWKS: %w", err)
+		nextJwkJson, err = json.Marshal(set.Next.Certificate.Jwk.Private)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling next private JWK: %w", err)
+		}
+
+		jwksJson, err = json.Marshal(set.Current.Certificate.Jwk.ToPrivateJwks())
+		if err != nil {
+			return nil, fmt.Errorf("marshalling private JWKS: %w", err)
+		}
 	}
 
 	preAuthAppsJson, err := json.Marshal(app.PreAuthorizedApps.Valid)
@@ -142,7 +270,7 @@ func SecretData(app result.Application, set credentials.Set, azureOpenIDConfig c
 		return nil, fmt.Errorf("marshalling preauthorized apps: %w", err)
 	}
 
-	return map[string]string{
+	data := map[string]string{
 		keys.ClientId:                            app.ClientId,
 		keys.CurrentCredentials.CertificateKeyId: set.Current.Certificate.KeyId,
 		keys.CurrentCredentials.ClientSecret:     set.Current.Password.ClientSecret,
@@ -157,7 +285,57 @@ func SecretData(app result.Application, set credentials.Set, azureOpenIDConfig c
 		keys.TenantId:                            app.Tenant,
 		keys.WellKnownUrl:                        azureOpenIDConfig.WellKnownEndpoint,
 		keys.OpenId.Issuer:                       azureOpenIDConfig.Issuer,
+		keys.OpenId.AccessTokenIssuer:            accessTokenIssuer(app, azureOpenIDConfig),
 		keys.OpenId.JwksUri:                      azureOpenIDConfig.JwksURI,
 		keys.OpenId.TokenEndpoint:                azureOpenIDConfig.TokenEndpoint,
-	}, nil
+	}
+	delete(data, "")
+
+	if err := addCertificateData(data, set.Current.Certificate.Jwk, keys.CurrentCredentials.Certificate); err != nil {
+		return nil, fmt.Errorf("adding certificate data: %w", err)
+	}
+
+	if err := addCertificateData(data, set.Next.Certificate.Jwk, keys.NextCredentials.Certificate); err != nil {
+		return nil, fmt.Errorf("adding next certificate data: %w", err)
+	}
+
+	return data, nil
+}
+
+// accessTokenIssuer returns the issuer of access tokens for the application. Azure AD issues v1.0 access tokens with
+// the issuer of the v1.0 endpoint, regardless of which endpoint the token was requested from.
+func accessTokenIssuer(app result.Application, azureOpenIDConfig config.AzureOpenIdConfig) string {
+	if app.AccessTokenVersion == 1 {
+		return fmt.Sprintf("https://sts.windows.net/%s/", app.Tenant)
+	}
+	return azureOpenIDConfig.Issuer
+}
+
+// WorkloadIdentityData returns the data for the secret describing how to use workload identity federation, or nil if
+// not enabled for the given keys.
+func WorkloadIdentityData(keys SecretDataKeys, workloadIdentity credentials.WorkloadIdentity) map[string]string {
+	if len(keys.WorkloadIdentity.TokenFile) == 0 {
+		return nil
+	}
+
+	return map[string]string{
+		keys.WorkloadIdentity.TokenFile: workloadIdentity.TokenFile,
+	}
+}
+
+func addCertificateData(data map[string]string, jwk crypto.Jwk, keys CertificateKeys) error {
+	if !keys.Enabled() {
+		return nil
+	}
+
+	privateKeyPem, err := jwk.PrivateKeyPem()
+	if err != nil {
+		return err
+	}
+
+	data[keys.CertificatePem] = string(jwk.PublicPem)
+	data[keys.PrivateKeyPem] = string(privateKeyPem)
+	data[keys.CertificateThumbprint] = jwk.Thumbprint()
+	data[keys.CertificateThumbprintSHA256] = jwk.ThumbprintSHA256()
+	return nil
 }
@@ -1,23 +1,34 @@
 package secrets
 
 import (
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"maps"
 	"strings"
 	"testing"
 
 	"github.com/go-jose/go-jose/v4"
 	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/nais/liberator/pkg/kubernetes"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/nais/azureator/pkg/azure/credentials"
 	"github.com/nais/azureator/pkg/azure/fake"
 	"github.com/nais/azureator/pkg/azure/resource"
 	"github.com/nais/azureator/pkg/azure/result"
 )
 
 const (
-	AllSecretKeyCount = 16
+	AllSecretKeyCount = 17
 )
 
 func TestSecretData(t *testing.T) {
@@ -149,6 +160,61 @@ func TestSecretData(t *testing.T) {
 	})
 }
 
+func TestSecretData_AccessTokenVersion(t *testing.T) {
+	app := &v1.AzureAdApplication{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-app",
+			Namespace: "test",
+		},
+		Spec: v1.AzureAdApplicationSpec{
+			SecretName: "test-secret",
+		},
+	}
+	azureOpenIdConfig := fake.AzureOpenIdConfig()
+	azureCredentialsSet := fake.AzureCredentialsSet(app, "test-cluster")
+	keys := NewSecretDataKeys()
+
+	for _, tt := range []struct {
+		name     string
+		version  int
+		expected func(tenant string) string
+	}{
+		{
+			name:    "default version uses access token issuer from OpenID configuration",
+			version: 0,
+			expected: func(string) string {
+				return azureOpenIdConfig.Issuer
+			},
+		},
+		{
+			name:    "version 2 uses access token issuer from OpenID configuration",
+			version: 2,
+			expected: func(string) string {
+				return azureOpenIdConfig.Issuer
+			},
+		},
+		{
+			name:    "version 1 uses v1.0 access token issuer",
+			version: 1,
+			expected: func(tenant string) string {
+				return fmt.Sprintf("https://sts.windows.net/%s/", tenant)
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			azureApp := fake.AzureApplicationResult(app, result.OperationCreated)
+			azureApp.AccessTokenVersion = tt.version
+
+			stringData, err := SecretData(azureApp, azureCredentialsSet, azureOpenIdConfig, keys)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected(azureApp.Tenant), stringData[keys.OpenId.AccessTokenIssuer])
+
+			// the issuer from the OpenID configuration is kept regardless of version
+			assert.Equal(t, azureOpenIdConfig.Issuer, stringData[keys.OpenId.Issuer])
+		})
+	}
+}
+
 func TestNewSecretDataKeys(t *testing.T) {
 	t.Run("SecretDataKeys with no args should return keys with default prefix", func(t *testing.T) {
 		keys := NewSecretDataKeys()
@@ -191,3 +257,236 @@ func assertAllKeysWithPrefix(t *testing.T, keys SecretDataKeys, prefix string) {
 		assert.True(t, strings.HasPrefix(key, prefix))
 	}
 }
+
+func TestSecretData_CertificateKeys(t *testing.T) {
+	app := &v1.AzureAdApplication{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-app",
+			Namespace: "test",
+		},
+	}
+	azureApp := fake.AzureApplicationResult(app, result.OperationCreated)
+	azureCredentialsSet := fake.AzureCredentialsSet(app, "test-cluster")
+
+	keys := NewSecretDataKeys().WithCertificateKeys()
+	stringData, err := SecretData(azureApp, azureCredentialsSet, fake.AzureOpenIdConfig(), keys)
+	require.NoError(t, err)
+
+	assert.Len(t, stringData, AllSecretKeyCount+8)
+	assert.Len(t, keys.AllKeys(), AllSecretKeyCount+8)
+
+	for _, tt := range []struct {
+		name        string
+		credentials credentials.Credentials
+		keys        CertificateKeys
+	}{
+		{
+			name:        "current",
+			credentials: azureCredentialsSet.Current,
+			keys:        keys.CurrentCredentials.Certificate,
+		},
+		{
+			name:        "next",
+			credentials: azureCredentialsSet.Next,
+			keys:        keys.NextCredentials.Certificate,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			jwk := tt.credentials.Certificate.Jwk
+
+			block, _ := pem.Decode([]byte(stringData[tt.keys.CertificatePem]))
+			require.NotNil(t, block)
+			assert.Equal(t, "CERTIFICATE", block.Type)
+			assert.Equal(t, jwk.Private.Certificates[0].Raw, block.Bytes)
+
+			block, _ = pem.Decode([]byte(stringData[tt.keys.PrivateKeyPem]))
+			require.NotNil(t, block)
+			assert.Equal(t, "PRIVATE KEY", block.Type)
+			privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			require.NoError(t, err)
+			assert.Equal(t, jwk.Private.Certificates[0].PublicKey, privateKey.(crypto.Signer).Public())
+
+			x5t := sha1.Sum(jwk.Private.Certificates[0].Raw)
+			assert.Equal(t, base64.RawURLEncoding.EncodeToString(x5t[:]), stringData[tt.keys.CertificateThumbprint])
+			x5tS256 := sha256.Sum256(jwk.Private.Certificates[0].Raw)
+			assert.Equal(t, base64.RawURLEncoding.EncodeToString(x5tS256[:]), stringData[tt.keys.CertificateThumbprintSHA256])
+		})
+	}
+
+	t.Run("JWK contains x5c and x5t", func(t *testing.T) {
+		var jwk map[string]any
+		require.NoError(t, json.Unmarshal([]byte(stringData[keys.CurrentCredentials.Jwk]), &jwk))
+		assert.NotEmpty(t, jwk["x5c"])
+		assert.Equal(t, stringData[keys.CurrentCredentials.Certificate.CertificateThumbprint], jwk["x5t"])
+		assert.Equal(t, stringData[keys.CurrentCredentials.Certificate.CertificateThumbprintSHA256], jwk["x5t#S256"])
+	})
+}
+
+func TestKeysChanged(t *testing.T) {
+	toSecret := func(stringData map[string]string) corev1.Secret {
+		data := make(map[string][]byte)
+		for key, value := range stringData {
+			data[key] = []byte(value)
+		}
+		return corev1.Secret{Data: data}
+	}
+
+	app := &v1.AzureAdApplication{ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "test"}}
+	azureApp := fake.AzureApplicationResult(app, result.OperationCreated)
+	azureCredentialsSet := fake.AzureCredentialsSet(app, "test-cluster")
+
+	keys := NewSecretDataKeys()
+	withCertificateKeys := keys.WithCertificateKeys()
+
+	stringData, err := SecretData(azureApp, azureCredentialsSet, fake.AzureOpenIdConfig(), keys)
+	require.NoError(t, err)
+	withoutCertificates := toSecret(stringData)
+
+	stringData, err = SecretData(azureApp, azureCredentialsSet, fake.AzureOpenIdConfig(), withCertificateKeys)
+	require.NoError(t, err)
+	withCertificates := toSecret(stringData)
+
+	assert.False(t, KeysChanged(withoutCertificates, keys))
+	assert.False(t, KeysChanged(withCertificates, withCertificateKeys))
+	assert.True(t, KeysChanged(withoutCertificates, withCertificateKeys), "enabling certificate keys")
+	assert.True(t, KeysChanged(withCertificates, keys), "disabling certificate keys")
+}
+
+func TestSecretData_CredentialMode(t *testing.T) {
+	toSecret := func(stringData map[string]string) corev1.Secret {
+		data := make(map[string][]byte)
+		for key, value := range stringData {
+			data[key] = []byte(value)
+		}
+		return corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-secret"}, Data: data}
+	}
+
+	app := &v1.AzureAdApplication{ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "test"}}
+	azureApp := fake.AzureApplicationResult(app, result.OperationCreated)
+	fullSet := fake.AzureCredentialsSet(app, "test-cluster")
+	allKeys := NewSecretDataKeys().WithCertificateKeys()
+
+	for _, tt := range []struct {
+		mode    credentials.Mode
+		omitted []string
+	}{
+		{
+			mode: credentials.ModeBoth,
+		},
+		{
+			mode: credentials.ModeCertificate,
+			omitted: []string{
+				allKeys.CurrentCredentials.ClientSecret,
+				allKeys.CurrentCredentials.PasswordKeyId,
+				allKeys.NextCredentials.ClientSecret,
+				allKeys.NextCredentials.PasswordKeyId,
+			},
+		},
+		{
+			mode: credentials.ModePassword,
+			omitted: []string{
+				allKeys.CurrentCredentials.CertificateKeyId,
+				allKeys.CurrentCredentials.Jwk,
+				allKeys.CurrentCredentials.Jwks,
+				allKeys.CurrentCredentials.Certificate.CertificatePem,
+				allKeys.NextCredentials.CertificateKeyId,
+				allKeys.NextCredentials.Jwk,
+				allKeys.NextCredentials.Certificate.PrivateKeyPem,
+			},
+		},
+	} {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			set := fullSet
+			if !tt.mode.Password() {
+				set.Current.Password = credentials.Password{}
+				set.Next.Password = credentials.Password{}
+			}
+			if !tt.mode.Certificate() {
+				set.Current.Certificate = credentials.Certificate{}
+				set.Next.Certificate = credentials.Certificate{}
+			}
+
+			keys := allKeys.WithCredentialMode(tt.mode)
+
+			stringData, err := SecretData(azureApp, set, fake.AzureOpenIdConfig(), keys)
+			require.NoError(t, err)
+			assert.Len(t, stringData, len(keys.AllKeys()))
+			assert.NotContains(t, stringData, "")
+			for _, key := range tt.omitted {
+				assert.NotContains(t, stringData, key)
+				assert.NotContains(t, keys.AllKeys(), key)
+			}
+
+			secret := toSecret(stringData)
+			assert.False(t, KeysChanged(secret, keys))
+			for _, other := range credentials.SupportedModes {
+				if other != tt.mode {
+					assert.True(t, KeysChanged(secret, allKeys.WithCredentialMode(other)), "switching to mode %s", other)
+				}
+			}
+
+			extractor := NewExtractor(kubernetes.SecretLists{Used: corev1.SecretList{Items: []corev1.Secret{secret}}}, NewSecretDataKeys())
+			extracted, valid, err := extractor.GetPreviousCredentialsSet(secret.Name)
+			require.NoError(t, err)
+			assert.True(t, valid)
+			assert.True(t, tt.mode.Matches(*extracted))
+			assert.Equal(t, set.Current.Password, extracted.Current.Password)
+			assert.Equal(t, set.Next.Certificate.KeyId, extracted.Next.Certificate.KeyId)
+
+			keyIDs := extractor.GetKeyIDs()
+			assert.Equal(t, tt.mode.Certificate(), len(keyIDs.Used.Certificate) > 0)
+			assert.Equal(t, tt.mode.Password(), len(keyIDs.Used.Password) > 0)
+		})
+	}
+
+	t.Run("incomplete credentials are invalid", func(t *testing.T) {
+		stringData, err := SecretData(azureApp, fullSet, fake.AzureOpenIdConfig(), allKeys)
+		require.NoError(t, err)
+
+		secret := toSecret(stringData)
+		delete(secret.Data, allKeys.CurrentCredentials.ClientSecret)
+
+		extractor := NewExtractor(kubernetes.SecretLists{Used: corev1.SecretList{Items: []corev1.Secret{secret}}}, NewSecretDataKeys())
+		_, valid, err := extractor.GetPreviousCredentialsSet(secret.Name)
+		require.NoError(t, err)
+		assert.False(t, valid)
+	})
+}
+
+func TestSecretData_WorkloadIdentity(t *testing.T) {
+	app := &v1.AzureAdApplication{ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "test"}}
+	azureApp := fake.AzureApplicationResult(app, result.OperationCreated)
+	keys := NewSecretDataKeys().WithCredentialMode(credentials.ModeWorkloadIdentity)
+	workloadIdentity := credentials.WorkloadIdentity{TokenFile: "/var/run/secrets/azure/tokens/azure-identity-token"}
+
+	stringData, err := SecretData(azureApp, credentials.Set{}, fake.AzureOpenIdConfig(), keys)
+	require.NoError(t, err)
+	maps.Copy(stringData, WorkloadIdentityData(keys, workloadIdentity))
+
+	assert.Equal(t, azureApp.ClientId, stringData["AZURE_APP_CLIENT_ID"])
+	assert.Equal(t, azureApp.Tenant, stringData["AZURE_APP_TENANT_ID"])
+	assert.Equal(t, workloadIdentity.TokenFile, stringData["AZURE_APP_FEDERATED_TOKEN_FILE"])
+	assert.Len(t, stringData, len(keys.AllKeys()))
+	for _, key := range []string{"AZURE_APP_CLIENT_SECRET", "AZURE_APP_JWK", "AZURE_APP_JWKS", "AZURE_APP_CERTIFICATE_KEY_ID", "AZURE_APP_PASSWORD_KEY_ID"} {
+		assert.NotContains(t, stringData, key)
+	}
+
+	assert.Nil(t, WorkloadIdentityData(NewSecretDataKeys(), workloadIdentity))
+
+	data := make(map[string][]byte)
+	for key, value := range stringData {
+		data[key] = []byte(value)
+	}
+	secret := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-secret"}, Data: data}
+
+	assert.False(t, KeysChanged(secret, keys))
+	assert.True(t, KeysChanged(secret, NewSecretDataKeys()), "switching from workload identity")
+
+	extractor := NewExtractor(kubernetes.SecretLists{Used: corev1.SecretList{Items: []corev1.Secret{secret}}}, NewSecretDataKeys())
+	extracted, valid, err := extractor.GetPreviousCredentialsSet(secret.Name)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	assert.Equal(t, credentials.Set{}, *extracted)
+	assert.True(t, credentials.ModeWorkloadIdentity.Matches(*extracted))
+	assert.False(t, credentials.ModeBoth.Matches(*extracted))
+}
@@ -0,0 +1,63 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// File writes secret data to '<directory>/<namespace>/<name>/<key>', one file per key. Intended for local development
+// and testing.
+type File struct {
+	directory string
+}
+
+func NewFile(directory string) File {
+	return File{directory: directory}
+}
+
+func (f File) path(target Target) string {
+	return filepath.Join(f.directory, target.Namespace, target.Name)
+}
+
+// Write replaces the directory for the target atomically, so that readers never observe a mix of old and new keys.
+func (f File) Write(_ context.Context, target Target, data map[string]string) error {
+	path := f.path(target)
+	parent := filepath.Dir(path)
+
+	if err := os.MkdirAll(parent, 0o700); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+
+	tmp, err := os.MkdirTemp(parent, "."+target.Name+"-")
+	if err != nil {
+		return fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	for key, value := range data {
+		if err := os.WriteFile(filepath.Join(tmp, key), []byte(value), 0o600); err != nil {
+			return fmt.Errorf("writing key '%s': %w", key, err)
+		}
+	}
+
+	old := tmp + ".old"
+	if err := os.Rename(path, old); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("moving previous directory: %w", err)
+	}
+	defer os.RemoveAll(old)
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("moving directory into place: %w", err)
+	}
+
+	return nil
+}
+
+func (f File) Delete(_ context.Context, target Target) error {
+	if err := os.RemoveAll(f.path(target)); err != nil {
+		return fmt.Errorf("deleting directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/nais/azureator/pkg/config"
+)
+
+const (
+	NameFile  = "file"
+	NameVault = "vault"
+)
+
+// Target identifies the application that the secret data belongs to.
+type Target struct {
+	Cluster   string
+	Namespace string
+	Name      string
+}
+
+// Sink is an external secret store that receives a copy of the secret data for applications, in addition to the
+// Kubernetes Secret. The Kubernetes Secret remains the source of truth for the current and next credentials.
+type Sink interface {
+	// Write replaces the secret data for the target.
+	Write(ctx context.Context, target Target, data map[string]string) error
+	// Delete removes the secret data for the target, if any.
+	Delete(ctx context.Context, target Target) error
+}
+
+// Sinks holds the configured sinks by name.
+type Sinks map[string]Sink
+
+// New returns the sinks that are configured.
+func New(cfg config.SecretSinks) Sinks {
+	sinks := make(Sinks)
+	if len(cfg.File.Directory) > 0 {
+		sinks[NameFile] = NewFile(cfg.File.Directory)
+	}
+	if len(cfg.Vault.Address) > 0 {
+		sinks[NameVault] = NewVault(cfg.Vault)
+	}
+	return sinks
+}
+
+// Get returns the sink with the given name.
+func (s Sinks) Get(name string) (Sink, error) {
+	sink, found := s[name]
+	if !found {
+		return nil, fmt.Errorf("secret sink '%s' is not configured", name)
+	}
+	return sink, nil
+}
+
+// Names returns the names of all configured sinks, sorted.
+func (s Sinks) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
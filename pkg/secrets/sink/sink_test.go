@@ -0,0 +1,167 @@
+package sink_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/azureator/pkg/config"
+	"github.com/nais/azureator/pkg/secrets/sink"
+)
+
+var target = sink.Target{
+	Cluster:   "test-cluster",
+	Namespace: "test-namespace",
+	Name:      "test-app",
+}
+
+func TestNew(t *testing.T) {
+	assert.Empty(t, sink.New(config.SecretSinks{}).Names())
+
+	sinks := sink.New(config.SecretSinks{
+		File:  config.FileSink{Directory: t.TempDir()},
+		Vault: config.VaultSink{Address: "http://localhost:8200"},
+	})
+	assert.Equal(t, []string{sink.NameFile, sink.NameVault}, sinks.Names())
+
+	_, err := sinks.Get("unknown")
+	assert.EqualError(t, err, "secret sink 'unknown' is not configured")
+}
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	f := sink.NewFile(dir)
+	path := filepath.Join(dir, target.Namespace, target.Name)
+
+	err := f.Write(ctx, target, map[string]string{"a": "1", "b": "2"})
+	require.NoError(t, err)
+	assertFiles(t, path, map[string]string{"a": "1", "b": "2"})
+
+	err = f.Write(ctx, target, map[string]string{"a": "3"})
+	require.NoError(t, err)
+	assertFiles(t, path, map[string]string{"a": "3"})
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary directories should be removed")
+
+	err = f.Delete(ctx, target)
+	require.NoError(t, err)
+	assert.NoDirExists(t, path)
+
+	err = f.Delete(ctx, target)
+	assert.NoError(t, err, "deleting a missing target should succeed")
+}
+
+func assertFiles(t *testing.T, path string, expected map[string]string) {
+	entries, err := os.ReadDir(path)
+	require.NoError(t, err)
+	assert.Len(t, entries, len(expected))
+
+	for key, value := range expected {
+		info, err := os.Stat(filepath.Join(path, key))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+		content, err := os.ReadFile(filepath.Join(path, key))
+		require.NoError(t, err)
+		assert.Equal(t, value, string(content))
+	}
+}
+
+func TestVault(t *testing.T) {
+	ctx := context.Background()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("some-token\n"), 0o600))
+
+	stored := make(map[string]map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "some-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			assert.Equal(t, "/v1/kv/data/prefix/test-cluster/test-namespace/test-app", r.URL.Path)
+			var body struct {
+				Data map[string]string `json:"data"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			stored[r.URL.Path] = body.Data
+		case http.MethodDelete:
+			assert.Equal(t, "/v1/kv/metadata/prefix/test-cluster/test-namespace/test-app", r.URL.Path)
+			if len(stored) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			clear(stored)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.VaultSink{
+		Address:    server.URL,
+		Mount:      "kv",
+		PathPrefix: "prefix",
+		TokenFile:  tokenFile,
+	}
+	v := sink.NewVault(cfg)
+
+	err := v.Write(ctx, target, map[string]string{"a": "1"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "1"}, stored["/v1/kv/data/prefix/test-cluster/test-namespace/test-app"])
+
+	err = v.Delete(ctx, target)
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+
+	err = v.Delete(ctx, target)
+	assert.NoError(t, err, "deleting a missing target should succeed")
+
+	require.NoError(t, os.Remove(tokenFile))
+	err = v.Write(ctx, target, map[string]string{"a": "1"})
+	assert.NoError(t, err, "the token should be read once")
+
+	require.NoError(t, os.WriteFile(tokenFile, []byte("other-token"), 0o600))
+	v = sink.NewVault(cfg)
+	err = v.Write(ctx, target, map[string]string{"a": "1"})
+	assert.ErrorContains(t, err, "403 Forbidden")
+
+	require.NoError(t, os.WriteFile(tokenFile, []byte("some-token"), 0o600))
+	err = v.Write(ctx, target, map[string]string{"a": "2"})
+	require.NoError(t, err, "the token should be read again after being rejected")
+	assert.Equal(t, map[string]string{"a": "2"}, stored["/v1/kv/data/prefix/test-cluster/test-namespace/test-app"])
+}
+
+func TestVault_Timeout(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("some-token"), 0o600))
+
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	v := sink.NewVault(config.VaultSink{
+		Address:   server.URL,
+		Mount:     "kv",
+		Timeout:   10 * time.Millisecond,
+		TokenFile: tokenFile,
+	})
+
+	err := v.Write(context.Background(), target, map[string]string{"a": "1"})
+	assert.ErrorContains(t, err, "Client.Timeout exceeded")
+}
@@ -0,0 +1,125 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/nais/azureator/pkg/config"
+)
+
+// Vault writes secret data to a KV version 2 secrets engine in HashiCorp Vault.
+// The token is read from the configured file once, and read again if Vault rejects it, e.g. after the file is renewed.
+type Vault struct {
+	config config.VaultSink
+	client *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+func NewVault(cfg config.VaultSink) *Vault {
+	return &Vault{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (v *Vault) url(kind string, target Target) string {
+	return fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(v.config.Address, "/"), path.Join(v.config.Mount, kind, v.config.PathPrefix, target.Cluster, target.Namespace, target.Name))
+}
+
+// Write creates a new version of the secret for the target.
+func (v *Vault) Write(ctx context.Context, target Target, data map[string]string) error {
+	body, err := json.Marshal(map[string]any{"data": data})
+	if err != nil {
+		return fmt.Errorf("marshalling secret data: %w", err)
+	}
+
+	return v.do(ctx, http.MethodPost, v.url("data", target), body)
+}
+
+// Delete removes all versions and the metadata of the secret for the target.
+func (v *Vault) Delete(ctx context.Context, target Target) error {
+	return v.do(ctx, http.MethodDelete, v.url("metadata", target), nil)
+}
+
+func (v *Vault) do(ctx context.Context, method, url string, body []byte) error {
+	token, err := v.currentToken(false)
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.request(ctx, method, url, body, token)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+
+		token, err = v.currentToken(true)
+		if err != nil {
+			return err
+		}
+
+		resp, err = v.request(ctx, method, url, body, token)
+		if err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	if method == http.MethodDelete && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("vault responded with %s to %s %s: %s", resp.Status, method, url, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+func (v *Vault) request(ctx context.Context, method, url string, body []byte, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("performing vault request: %w", err)
+	}
+	return resp, nil
+}
+
+// currentToken returns the cached token, reading it from the token file if not yet read or if reload is set.
+func (v *Vault) currentToken(reload bool) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.token) > 0 && !reload {
+		return v.token, nil
+	}
+
+	token, err := os.ReadFile(v.config.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("reading vault token: %w", err)
+	}
+
+	v.token = strings.TrimSpace(string(token))
+	return v.token, nil
+}
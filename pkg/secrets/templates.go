@@ -0,0 +1,214 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// maxRenderedSize is the maximum size in bytes of a single rendered template. Kubernetes limits the size of a secret
+// to 1 MiB in total.
+const maxRenderedSize = 64 * 1024
+
+// templateFuncs are the only functions available to templates in addition to the text/template builtins.
+var templateFuncs = template.FuncMap{
+	"json":  toJSON,
+	"quote": strconv.Quote,
+}
+
+func toJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Templates render additional secret entries from the secret data. Templates are keyed by the secret key that they
+// render to.
+type Templates map[string]*template.Template
+
+// ParseTemplates parses the given templates, keyed by the secret key that they render to. Templates may only
+// reference the given known keys of the secret data, and may not render to any of them.
+func ParseTemplates(raw map[string]string, known []string) (Templates, error) {
+	templates := make(Templates, len(raw))
+
+	for _, key := range slices.Sorted(maps.Keys(raw)) {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return nil, fmt.Errorf("template '%s': invalid secret key: %s", key, strings.Join(errs, ", "))
+		}
+		if slices.Contains(known, key) {
+			return nil, fmt.Errorf("template '%s': conflicts with existing secret key", key)
+		}
+
+		tmpl, err := template.New(key).Funcs(templateFuncs).Option("missingkey=error").Parse(raw[key])
+		if err != nil {
+			return nil, fmt.Errorf("template '%s': %w", key, err)
+		}
+
+		if err := validateFields(tmpl.Root, known); err != nil {
+			return nil, fmt.Errorf("template '%s': %w", key, err)
+		}
+
+		templates[key] = tmpl
+	}
+
+	// catch errors that only occur on execution, e.g. invalid arguments to functions
+	placeholders := make(map[string]string, len(known))
+	for _, key := range known {
+		placeholders[key] = key
+	}
+	if _, err := templates.Render(placeholders); err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+// Render executes the templates against the given secret data.
+func (t Templates) Render(data map[string]string) (map[string]string, error) {
+	rendered := make(map[string]string, len(t))
+
+	for key, tmpl := range t {
+		w := &limitedWriter{remaining: maxRenderedSize}
+		if err := tmpl.Execute(w, data); err != nil {
+			return nil, fmt.Errorf("rendering template '%s': %w", key, err)
+		}
+		rendered[key] = w.String()
+	}
+
+	return rendered, nil
+}
+
+// limitedWriter fails writes that would exceed the remaining number of bytes, which stops the execution of a template.
+type limitedWriter struct {
+	strings.Builder
+	remaining int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.remaining {
+		return 0, fmt.Errorf("rendered template exceeds %d bytes", maxRenderedSize)
+	}
+	w.remaining -= len(p)
+	return w.Builder.Write(p)
+}
+
+// Digest returns a stable digest of the templates, used to detect changes to templates since the secret was written.
+// The digest is empty if there are no templates.
+func (t Templates) Digest() string {
+	if len(t) == 0 {
+		return ""
+	}
+
+	h := sha256.New()
+	for _, key := range slices.Sorted(maps.Keys(t)) {
+		fmt.Fprintf(h, "%d:%s%d:%s", len(key), key, len(t[key].Root.String()), t[key].Root.String())
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// validateFields returns an error if the template references fields of the data that are not in the known keys,
+// either directly ('.KEY', '$.KEY') or through the index builtin ('index . "KEY"').
+func validateFields(node parse.Node, known []string) error {
+	check := func(key string) error {
+		if !slices.Contains(known, key) {
+			return fmt.Errorf("unknown secret key '%s'", key)
+		}
+		return nil
+	}
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := validateFields(child, known); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return validateFields(n.Pipe, known)
+	case *parse.IfNode:
+		return validateBranch(n.BranchNode, known)
+	case *parse.RangeNode:
+		// ranging over anything but the secret data, e.g. an integer, could render output of unbounded size
+		if !isData(n.Pipe) {
+			return fmt.Errorf("range is only supported over the secret data, i.e. '.' or '$'")
+		}
+		return validateBranch(n.BranchNode, known)
+	case *parse.WithNode:
+		return validateBranch(n.BranchNode, known)
+	case *parse.TemplateNode:
+		return fmt.Errorf("nested templates are not supported")
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := validateFields(cmd, known); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		if len(n.Args) >= 3 {
+			ident, isIdent := n.Args[0].(*parse.IdentifierNode)
+			_, isDot := n.Args[1].(*parse.DotNode)
+			key, isString := n.Args[2].(*parse.StringNode)
+			if isIdent && ident.Ident == "index" && isDot && isString {
+				if err := check(key.Text); err != nil {
+					return err
+				}
+			}
+		}
+		for _, arg := range n.Args {
+			if err := validateFields(arg, known); err != nil {
+				return err
+			}
+		}
+	case *parse.FieldNode:
+		return check(n.Ident[0])
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			return check(n.Ident[1])
+		}
+	case *parse.ChainNode:
+		return validateFields(n.Node, known)
+	}
+
+	return nil
+}
+
+// isData returns true if the pipeline evaluates to the secret data itself, i.e. '.' or '$' at the top level.
+func isData(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.DotNode:
+		return true
+	case *parse.VariableNode:
+		return len(arg.Ident) == 1 && arg.Ident[0] == "$"
+	}
+	return false
+}
+
+func validateBranch(n parse.BranchNode, known []string) error {
+	for _, child := range []parse.Node{n.Pipe, n.List, n.ElseList} {
+		if err := validateFields(child, known); err != nil {
+			return err
+		}
+	}
+	return nil
+}
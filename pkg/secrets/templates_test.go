@@ -0,0 +1,142 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemplates(t *testing.T) {
+	known := []string{"AZURE_APP_CLIENT_ID", "AZURE_APP_CLIENT_SECRET"}
+
+	for _, tt := range []struct {
+		name     string
+		raw      map[string]string
+		expected map[string]string
+		err      string
+	}{
+		{
+			name: "no templates",
+			raw:  map[string]string{},
+		},
+		{
+			name: "field reference",
+			raw: map[string]string{
+				"application-azure.properties": "azure.client-id={{ .AZURE_APP_CLIENT_ID }}\n",
+			},
+			expected: map[string]string{
+				"application-azure.properties": "azure.client-id=some-client-id\n",
+			},
+		},
+		{
+			name: "functions and index",
+			raw: map[string]string{
+				"azure.env":  `CLIENT_ID={{ quote (index . "AZURE_APP_CLIENT_ID") }}`,
+				"azure.json": `{{ json . }}`,
+			},
+			expected: map[string]string{
+				"azure.env":  `CLIENT_ID="some-client-id"`,
+				"azure.json": `{"AZURE_APP_CLIENT_ID":"some-client-id","AZURE_APP_CLIENT_SECRET":"some-secret"}`,
+			},
+		},
+		{
+			name: "range and variables",
+			raw: map[string]string{
+				"keys": `{{ range $k, $v := . }}{{ $k }};{{ end }}{{ $.AZURE_APP_CLIENT_ID }}`,
+			},
+			expected: map[string]string{
+				"keys": "AZURE_APP_CLIENT_ID;AZURE_APP_CLIENT_SECRET;some-client-id",
+			},
+		},
+		{
+			name: "range over integer",
+			raw:  map[string]string{"out": "{{ range 1000000000 }}x{{ end }}"},
+			err:  "template 'out': range is only supported over the secret data",
+		},
+		{
+			name: "range over function result",
+			raw:  map[string]string{"out": "{{ range len .AZURE_APP_CLIENT_ID }}x{{ end }}"},
+			err:  "template 'out': range is only supported over the secret data",
+		},
+		{
+			name: "oversized output",
+			raw: map[string]string{
+				"out": strings.Repeat("{{ range $ }}", 8) + strings.Repeat("x", 1024) + strings.Repeat("{{ end }}", 8),
+			},
+			err: "rendered template exceeds 65536 bytes",
+		},
+		{
+			name: "unknown field",
+			raw:  map[string]string{"out": "{{ .AZURE_APP_UNKNOWN }}"},
+			err:  "template 'out': unknown secret key 'AZURE_APP_UNKNOWN'",
+		},
+		{
+			name: "unknown field in branch",
+			raw:  map[string]string{"out": "{{ if .AZURE_APP_CLIENT_ID }}{{ else }}{{ $.AZURE_APP_UNKNOWN }}{{ end }}"},
+			err:  "template 'out': unknown secret key 'AZURE_APP_UNKNOWN'",
+		},
+		{
+			name: "unknown index",
+			raw:  map[string]string{"out": `{{ index . "AZURE_APP_UNKNOWN" }}`},
+			err:  "template 'out': unknown secret key 'AZURE_APP_UNKNOWN'",
+		},
+		{
+			name: "unknown function",
+			raw:  map[string]string{"out": `{{ env "HOME" }}`},
+			err:  `function "env" not defined`,
+		},
+		{
+			name: "nested template",
+			raw:  map[string]string{"out": `{{ define "x" }}{{ end }}{{ template "x" }}`},
+			err:  "nested templates are not supported",
+		},
+		{
+			name: "conflicting key",
+			raw:  map[string]string{"AZURE_APP_CLIENT_ID": "some-value"},
+			err:  "conflicts with existing secret key",
+		},
+		{
+			name: "invalid key",
+			raw:  map[string]string{"some/key": "some-value"},
+			err:  "invalid secret key",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			templates, err := ParseTemplates(tt.raw, known)
+			if len(tt.err) > 0 {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+
+			rendered, err := templates.Render(map[string]string{
+				"AZURE_APP_CLIENT_ID":     "some-client-id",
+				"AZURE_APP_CLIENT_SECRET": "some-secret",
+			})
+			require.NoError(t, err)
+			assert.Len(t, rendered, len(tt.expected))
+			for key, value := range tt.expected {
+				assert.Equal(t, value, rendered[key])
+			}
+		})
+	}
+}
+
+func TestTemplates_Digest(t *testing.T) {
+	known := []string{"AZURE_APP_CLIENT_ID"}
+	parse := func(raw map[string]string) Templates {
+		templates, err := ParseTemplates(raw, known)
+		require.NoError(t, err)
+		return templates
+	}
+
+	assert.Empty(t, parse(nil).Digest())
+
+	digest := parse(map[string]string{"a": "{{ .AZURE_APP_CLIENT_ID }}"}).Digest()
+	assert.NotEmpty(t, digest)
+	assert.Equal(t, digest, parse(map[string]string{"a": "{{.AZURE_APP_CLIENT_ID}}"}).Digest())
+	assert.NotEqual(t, digest, parse(map[string]string{"b": "{{ .AZURE_APP_CLIENT_ID }}"}).Digest())
+	assert.NotEqual(t, digest, parse(map[string]string{"a": "id={{ .AZURE_APP_CLIENT_ID }}"}).Digest())
+}
@@ -0,0 +1,60 @@
+package synchronizer
+
+import (
+	"slices"
+	"sync"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SkippedGroups holds the references to groups that were skipped during the latest synchronization of each
+// AzureAdApplication, so that the [Sweeper] can re-check them. The status of the resource has no field for groups, so
+// the references are only kept in memory. They are lost on restart, and recorded again the next time the application
+// is synchronized with Azure AD.
+type SkippedGroups struct {
+	mu         sync.Mutex
+	references map[client.ObjectKey][]string
+}
+
+func NewSkippedGroups() *SkippedGroups {
+	return &SkippedGroups{
+		references: make(map[client.ObjectKey][]string),
+	}
+}
+
+// Set replaces the skipped group references for the application. The application is forgotten if there are none.
+func (s *SkippedGroups) Set(key client.ObjectKey, references []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(references) == 0 {
+		delete(s.references, key)
+		return
+	}
+	s.references[key] = slices.Clone(references)
+}
+
+// Get returns the skipped group references for the application, if any.
+func (s *SkippedGroups) Get(key client.ObjectKey) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.references[key])
+}
+
+// Retain forgets the applications that are not among the given ones, e.g. because they have been deleted.
+func (s *SkippedGroups) Retain(keys []client.ObjectKey) {
+	retained := make(map[client.ObjectKey]bool, len(keys))
+	for _, key := range keys {
+		retained[key] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.references {
+		if !retained[key] {
+			delete(s.references, key)
+		}
+	}
+}
@@ -33,10 +33,11 @@ type resolved struct {
 }
 
 // Sweeper periodically marks AzureAdApplications for resync, catching cases missed by the
-// event-driven path in [Synchronizer]. It handles two cases:
-//   - pre-authorized apps that were unassignable during reconcile but have since appeared, and
+// event-driven path in [Synchronizer]. It handles three cases:
+//   - pre-authorized apps that were unassignable during reconcile but have since appeared,
 //   - cross-cluster pre-authorized apps that were recreated with a new client ID (same-cluster
-//     client ID changes are handled immediately by [Synchronizer]).
+//     client ID changes are handled immediately by [Synchronizer]), and
+//   - groups that were skipped during reconcile but have since appeared.
 type Sweeper struct {
 	clusterName   string
 	kubeClient    client.Client
@@ -46,6 +47,8 @@ type Sweeper struct {
 	interval      time.Duration
 	cacheTTL      time.Duration
 	resolveCache  *cache.Cache[string, resolved]
+	groupCache    *cache.Cache[string, bool]
+	skippedGroups *SkippedGroups
 	logger        *log.Entry
 }
 
@@ -56,6 +59,7 @@ func NewSweeper(
 	azureClient azure.Client,
 	azureTenantID string,
 	interval time.Duration,
+	skippedGroups *SkippedGroups,
 ) *Sweeper {
 	const minSweepInterval = time.Second
 	interval = max(interval, minSweepInterval)
@@ -70,6 +74,8 @@ func NewSweeper(
 		interval:      interval,
 		cacheTTL:      cacheTTL,
 		resolveCache:  cache.New[string, resolved](),
+		groupCache:    cache.New[string, bool](),
+		skippedGroups: skippedGroups,
 		logger:        log.WithField("subsystem", sourceSweeper),
 	}
 }
@@ -102,6 +108,12 @@ func (s *Sweeper) sweep(ctx context.Context) {
 		return
 	}
 
+	keys := make([]client.ObjectKey, 0, len(apps.Items))
+	for _, app := range apps.Items {
+		keys = append(keys, client.ObjectKeyFromObject(&app))
+	}
+	s.skippedGroups.Retain(keys)
+
 	candidateCount := 0
 	for _, app := range apps.Items {
 		if !s.shouldResync(ctx, app) {
@@ -137,10 +149,6 @@ func (s *Sweeper) sweep(ctx context.Context) {
 }
 
 func (s *Sweeper) shouldResync(ctx context.Context, app v1.AzureAdApplication) bool {
-	if app.Status.PreAuthorizedApps == nil {
-		return false
-	}
-
 	if app.Status.SynchronizationTenant != s.azureTenantID {
 		return false
 	}
@@ -149,13 +157,17 @@ func (s *Sweeper) shouldResync(ctx context.Context, app v1.AzureAdApplication) b
 		return false
 	}
 
-	return s.hasResyncablePreAuthApp(ctx, app)
+	return s.hasResyncablePreAuthApp(ctx, app) || s.hasResyncableGroup(ctx, app)
 }
 
 // hasResyncablePreAuthApp reports whether the app has a pre-authorized app that warrants a resync:
 // an unassigned entry that has since become assignable, or a cross-cluster assigned entry whose
 // app was recreated with a new client ID.
 func (s *Sweeper) hasResyncablePreAuthApp(ctx context.Context, app v1.AzureAdApplication) bool {
+	if app.Status.PreAuthorizedApps == nil {
+		return false
+	}
+
 	for _, unassigned := range app.Status.PreAuthorizedApps.Unassigned {
 		if unassigned.AccessPolicyRule == nil {
 			continue
@@ -186,6 +198,35 @@ func (s *Sweeper) hasResyncablePreAuthApp(ctx context.Context, app v1.AzureAdApp
 	return false
 }
 
+// hasResyncableGroup reports whether the app has a skipped group that has since appeared.
+func (s *Sweeper) hasResyncableGroup(ctx context.Context, app v1.AzureAdApplication) bool {
+	for _, reference := range s.skippedGroups.Get(client.ObjectKeyFromObject(&app)) {
+		exists, ok := s.resolveGroup(ctx, reference)
+		if ok && exists {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveGroup looks up whether a group reference resolves to a group, caching the outcome for [Sweeper.cacheTTL].
+// The second return value is false when the lookup was inconclusive (e.g. a transient Azure error).
+func (s *Sweeper) resolveGroup(ctx context.Context, reference string) (bool, bool) {
+	if exists, cached := s.groupCache.Get(reference); cached {
+		return exists, true
+	}
+
+	exists, err := s.azureClient.GroupExists(ctx, reference)
+	if err != nil {
+		s.logger.Debugf("pre-flight check failed for group %s: %v", reference, err)
+		return false, false
+	}
+
+	s.groupCache.Set(reference, exists, cache.WithExpiration(s.cacheTTL))
+	return exists, true
+}
+
 // resolve looks up the live state of a pre-authorized app, caching the outcome for [Sweeper.cacheTTL].
 // The second return value is false when the lookup was inconclusive (e.g. a transient Azure error).
 func (s *Sweeper) resolve(ctx context.Context, rule v1.AccessPolicyRule) (resolved, bool) {
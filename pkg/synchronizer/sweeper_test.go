@@ -9,6 +9,8 @@ import (
 	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	azurefake "github.com/nais/azureator/pkg/azure/fake"
 	fakeazure "github.com/nais/azureator/pkg/azure/fake/client"
@@ -26,6 +28,8 @@ func newTestSweeper() *Sweeper {
 		azureTenantID: testTenantID,
 		cacheTTL:      time.Minute,
 		resolveCache:  cache.New[string, resolved](),
+		groupCache:    cache.New[string, bool](),
+		skippedGroups: NewSkippedGroups(),
 		logger:        log.NewEntry(log.StandardLogger()),
 	}
 }
@@ -125,3 +129,61 @@ func TestSweeper_shouldResync_staleAssigned(t *testing.T) {
 		})
 	}
 }
+
+func TestSweeper_shouldResync_skippedGroups(t *testing.T) {
+	app := v1.AzureAdApplication{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team"},
+		Status: v1.AzureAdApplicationStatus{
+			SynchronizationTenant: testTenantID,
+		},
+	}
+
+	tests := []struct {
+		name    string
+		skipped []string
+		want    bool
+	}{
+		{
+			name:    "skipped group that has appeared is a candidate",
+			skipped: []string{"group-missing", "group-resync"},
+			want:    true,
+		},
+		{
+			name:    "skipped group that is still missing is skipped",
+			skipped: []string{"group-missing"},
+			want:    false,
+		},
+		{
+			name: "no skipped groups is skipped",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSweeper()
+			s.skippedGroups.Set(client.ObjectKeyFromObject(&app), tt.skipped)
+
+			got := s.shouldResync(context.Background(), app)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSkippedGroups(t *testing.T) {
+	app := client.ObjectKey{Namespace: "team", Name: "app"}
+	other := client.ObjectKey{Namespace: "team", Name: "other"}
+
+	skipped := NewSkippedGroups()
+	skipped.Set(app, []string{"group-a"})
+	skipped.Set(other, []string{"group-b"})
+	assert.Equal(t, []string{"group-a"}, skipped.Get(app))
+
+	skipped.Set(app, nil)
+	assert.Empty(t, skipped.Get(app), "applications without skipped groups should be forgotten")
+
+	skipped.Set(app, []string{"group-a"})
+	skipped.Retain([]client.ObjectKey{app})
+	assert.Equal(t, []string{"group-a"}, skipped.Get(app))
+	assert.Empty(t, skipped.Get(other), "applications that are not retained should be forgotten")
+}
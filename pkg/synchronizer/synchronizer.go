@@ -26,19 +26,26 @@ const (
 // Synchronizer ensures that the Azure AD applications are resynchronized on relevant events,
 // e.g. on creation of previously non-existing pre-authorized applications.
 type Synchronizer struct {
-	clusterName string
-	client      client.Client
-	reader      client.Reader
+	clusterName   string
+	client        client.Client
+	reader        client.Reader
+	skippedGroups *SkippedGroups
 }
 
 func New(clusterName string, client client.Client, reader client.Reader) *Synchronizer {
 	return &Synchronizer{
-		clusterName: clusterName,
-		client:      client,
-		reader:      reader,
+		clusterName:   clusterName,
+		client:        client,
+		reader:        reader,
+		skippedGroups: NewSkippedGroups(),
 	}
 }
 
+// SkippedGroups returns the groups that were skipped for each application, to be re-checked by the [Sweeper].
+func (s Synchronizer) SkippedGroups() *SkippedGroups {
+	return s.skippedGroups
+}
+
 func (s Synchronizer) Synchronize(ctx context.Context, e Event, logger *log.Entry) error {
 	logger = logger.WithField("subsystem", sourceSynchronizer)
 
@@ -18,6 +18,23 @@ type optionsBuilder struct {
 	secrets  secrets.Secrets
 }
 
+// InvalidError is returned by NewOptions if the options for an application cannot be built, e.g. due to an invalid
+// annotation.
+type InvalidError struct {
+	Err error
+}
+
+func (e *InvalidError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InvalidError) Unwrap() error {
+	return e.Err
+}
+
+// NewOptions builds the options for the given application. If the options are invalid, an *InvalidError is returned
+// along with the options needed to finalize the application, so that applications with invalid annotations can still
+// be deleted.
 func NewOptions(instance v1.AzureAdApplication, cfg config.Config, secrets secrets.Secrets) (TransactionOptions, error) {
 	builder := optionsBuilder{
 		instance: instance,
@@ -27,7 +44,10 @@ func NewOptions(instance v1.AzureAdApplication, cfg config.Config, secrets secre
 
 	process, err := builder.Process()
 	if err != nil {
-		return TransactionOptions{}, err
+		return TransactionOptions{
+			Process: builder.Finalize(),
+			Tenant:  builder.Tenant(),
+		}, &InvalidError{Err: err}
 	}
 
 	return TransactionOptions{
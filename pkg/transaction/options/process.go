@@ -1,45 +1,530 @@
 package options
 
 import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/asaskevich/govalidator"
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/nais/azureator/pkg/annotations"
+	"github.com/nais/azureator/pkg/azure/apipermissions"
+	"github.com/nais/azureator/pkg/azure/client/application/optionalclaims"
+	"github.com/nais/azureator/pkg/azure/credentials"
+	"github.com/nais/azureator/pkg/azure/grouproles"
+	"github.com/nais/azureator/pkg/config"
 	"github.com/nais/azureator/pkg/customresources"
+	"github.com/nais/azureator/pkg/secrets"
+	"github.com/nais/azureator/pkg/secrets/sink"
+	"github.com/nais/azureator/pkg/util/cron"
+	"github.com/nais/azureator/pkg/util/crypto"
 )
 
 func (b optionsBuilder) Process() (ProcessOptions, error) {
 	instance := &b.instance
 
-	hashChanged, err := customresources.IsHashChanged(instance)
-	if err != nil {
-		return ProcessOptions{}, err
-	}
-
 	secretNameChanged := customresources.SecretNameChanged(instance)
 	hasResynchronizeAnnotation := customresources.HasResynchronizeAnnotation(instance)
 	hasRotateAnnotation := customresources.HasRotateAnnotation(instance)
-	hasExpiredSecrets := customresources.HasExpiredSecrets(instance, b.config.SecretRotation.MaxAge)
+	hasRevokeAnnotation := customresources.HasRevokeAnnotation(instance)
 	tenantUnchanged := strings.Contains(instance.Status.SynchronizationTenant, b.config.Azure.Tenant.Id)
 
-	needsSynchronization := hashChanged || secretNameChanged || hasExpiredSecrets || hasResynchronizeAnnotation || hasRotateAnnotation
+	maxAge, err := b.maxAge()
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+	hasExpiredSecrets := customresources.HasExpiredSecrets(instance, maxAge)
+
+	rotationWindow, err := b.config.SecretRotation.Window.ForNamespace(instance.GetNamespace())
+	if err != nil {
+		return ProcessOptions{}, fmt.Errorf("preparing rotation window: %w", err)
+	}
+	outsideRotationWindow := rotationWindow != nil && !rotationWindow.Contains(time.Now())
+	if hasExpiredSecrets && outsideRotationWindow {
+		// rotations due to age are deferred to the next window; explicit rotations and secret name changes are not
+		hasExpiredSecrets = false
+	}
+
+	keyType, explicitKeyType, err := b.keyType()
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+
+	credentialMode, err := customresources.CredentialMode(instance)
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+
+	workloadIdentity, err := b.workloadIdentity(credentialMode)
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+
+	sinks, err := b.secretSinks()
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+	staleSinks, sinksChanged := b.secretSinksChanged(sinks)
+
+	apiPermissions, err := b.apiPermissions()
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+
+	optionalClaims, err := b.optionalClaims()
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+
+	claimsMappingPolicy, err := b.claimsMappingPolicy()
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+
+	groupRoles, err := b.groupRoles()
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+
+	apiSettings, err := b.apiSettings()
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+
+	identifierUris, err := b.identifierUris()
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+
+	owners, err := b.owners()
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+
+	platformSettings, err := b.platformSettings()
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+
+	azureOptions := AzureOptions{
+		CleanupOrphans:      b.config.Azure.Features.CleanupOrphans.Enabled,
+		APIPermissions:      apiPermissions,
+		APISettings:         apiSettings,
+		ClaimsMappingPolicy: claimsMappingPolicy,
+		GroupRoles:          groupRoles,
+		IdentifierUris:      identifierUris,
+		OptionalClaims:      optionalClaims,
+		Owners:              owners,
+		PlatformSettings:    platformSettings,
+	}
+
+	// settings requested by annotations are part of the hash, so that changing them triggers a synchronization
+	hashChanged, err := customresources.IsHashChanged(instance, azureOptions.Settings()...)
+	if err != nil {
+		return ProcessOptions{}, err
+	}
+
+	templates, err := secrets.ParseTemplates(customresources.SecretTemplates(instance), b.secrets.DataKeys.AllKeys())
+	if err != nil {
+		return ProcessOptions{}, fmt.Errorf("parsing secret templates: %w", err)
+	}
+
+	if credentialMode.WorkloadIdentity() {
+		// federated credentials are short-lived tokens issued by the cluster, so there is nothing to rotate.
+		// a changed secret name is handled by adding the federated credential anew, which also writes the new secret.
+		hasExpiredSecrets = false
+		hasRotateAnnotation = false
+		tenantUnchanged = tenantUnchanged && !secretNameChanged
+		secretNameChanged = false
+	}
+
+	// revocation replaces all credentials, so the existing credentials are never valid
+	hasValidSecrets := !hasExpiredSecrets && !hasRevokeAnnotation && tenantUnchanged && b.secrets.LatestCredentials.Valid && b.secrets.LatestCredentials.Set != nil
+	// switching credential modes requires a new set of credentials; the previous set is revoked when adding the new one
+	credentialModeChanged := hasValidSecrets && !credentialMode.Matches(*b.secrets.LatestCredentials.Set)
+	hasValidSecrets = hasValidSecrets && !credentialModeChanged
+	// only a key type set for the application triggers a rotation; a changed default is picked up by age-based rotation,
+	// so that all applications are not rotated at once
+	keyTypeChanged := hasValidSecrets && explicitKeyType && b.keyTypeChanged(keyType)
+	// rotations due to key type changes are deferred to the next window, like rotations due to age
+	keyTypeChangeDeferred := keyTypeChanged && outsideRotationWindow
+	keyTypeChanged = keyTypeChanged && !outsideRotationWindow
+	secretKeysChanged := hasValidSecrets && b.secretKeysChanged()
+	secretSinksChanged := hasValidSecrets && sinksChanged
+	secretTemplatesChanged := hasValidSecrets && b.secretTemplatesChanged(templates)
+
+	needsSynchronization := hashChanged || secretNameChanged || hasExpiredSecrets || hasResynchronizeAnnotation || hasRotateAnnotation || hasRevokeAnnotation || keyTypeChanged || secretKeysChanged || secretSinksChanged || secretTemplatesChanged || credentialModeChanged
 	needsAzureSynchronization := hashChanged || hasResynchronizeAnnotation
-	hasValidSecrets := !hasExpiredSecrets && tenantUnchanged && b.secrets.LatestCredentials.Valid && b.secrets.LatestCredentials.Set != nil
-	needsSecretRotation := secretNameChanged || hasRotateAnnotation
+	needsSecretRotation := secretNameChanged || hasRotateAnnotation || keyTypeChanged
 	needsCleanup := !needsSecretRotation && b.config.SecretRotation.Cleanup
 
+	azureOptions.Synchronize = needsAzureSynchronization
+
 	return ProcessOptions{
 		Synchronize: needsSynchronization,
+		Azure:       azureOptions,
+		Secret: SecretOptions{
+			Rotate:           needsSecretRotation,
+			Revoke:           hasRevokeAnnotation,
+			RestartWorkloads: hasRevokeAnnotation && customresources.RevokeRestartsWorkloads(instance),
+			Valid:            hasValidSecrets,
+			Cleanup:          needsCleanup,
+			KeysChanged:      secretKeysChanged,
+			Sinks:            sinks,
+			StaleSinks:       staleSinks,
+			SinksChanged:     secretSinksChanged,
+			Templates:        templates,
+			TemplatesChanged: secretTemplatesChanged,
+			MaxAge:           maxAge,
+			RotationWindow:   rotationWindow,
+			KeyTypeDeferred:  keyTypeChangeDeferred,
+			CredentialMode:   credentialMode,
+			WorkloadIdentity: workloadIdentity,
+			Certificate: crypto.CertificateOptions{
+				KeyType:      keyType,
+				SerialNumber: b.config.Certificate.SerialNumber,
+				Subject:      b.config.Certificate.Subject.Name(),
+				Validity:     b.config.CertificateValidity(maxAge),
+			},
+		},
+	}, nil
+}
+
+// Finalize returns the options needed to finalize the application, i.e. the external secret sinks that may hold a copy
+// of the secret data. Unlike Process, the annotations are not validated.
+func (b optionsBuilder) Finalize() ProcessOptions {
+	sinks := customresources.SecretSinks(&b.instance)
+	staleSinks, _ := b.secretSinksChanged(sinks)
+
+	return ProcessOptions{
 		Azure: AzureOptions{
-			Synchronize:    needsAzureSynchronization,
 			CleanupOrphans: b.config.Azure.Features.CleanupOrphans.Enabled,
 		},
 		Secret: SecretOptions{
-			Rotate:  needsSecretRotation,
-			Valid:   hasValidSecrets,
-			Cleanup: needsCleanup,
+			Sinks:      sinks,
+			StaleSinks: staleSinks,
 		},
+	}
+}
+
+// latestSecret returns the managed secret holding the latest credentials, if it exists.
+func (b optionsBuilder) latestSecret() (corev1.Secret, bool) {
+	managed := b.secrets.ManagedSecrets
+	for _, secret := range slices.Concat(managed.Used.Items, managed.Unused.Items) {
+		if secret.Name == b.instance.Status.SynchronizationSecretName {
+			return secret, true
+		}
+	}
+	return corev1.Secret{}, false
+}
+
+// secretKeysChanged returns true if the keys in the secret holding the latest credentials differ from the desired keys.
+func (b optionsBuilder) secretKeysChanged() bool {
+	secret, found := b.latestSecret()
+	return found && secrets.KeysChanged(secret, b.secrets.DataKeys)
+}
+
+// secretTemplatesChanged returns true if the given templates differ from the templates that rendered the secret holding
+// the latest credentials.
+func (b optionsBuilder) secretTemplatesChanged(templates secrets.Templates) bool {
+	secret, found := b.latestSecret()
+	if !found {
+		return false
+	}
+
+	digest, _ := annotations.HasAnnotation(&secret, annotations.SecretTemplatesKey)
+	return digest != templates.Digest()
+}
+
+// secretSinks returns the external secret sinks requested for the application. Requested sinks must be configured.
+func (b optionsBuilder) secretSinks() ([]string, error) {
+	requested := customresources.SecretSinks(&b.instance)
+	configured := sink.New(b.config.SecretSinks)
+
+	for _, name := range requested {
+		if _, err := configured.Get(name); err != nil {
+			return nil, fmt.Errorf("parsing annotation '%s': %w", annotations.SecretSinksKey, err)
+		}
+	}
+
+	slices.Sort(requested)
+	return slices.Compact(requested), nil
+}
+
+// secretSinksChanged compares the desired sinks with the sinks recorded on the secret holding the latest credentials.
+// It returns the recorded sinks that are no longer desired, and whether the desired sinks differ from the recorded.
+func (b optionsBuilder) secretSinksChanged(desired []string) ([]string, bool) {
+	secret, found := b.latestSecret()
+	if !found {
+		return nil, false
+	}
+
+	recorded := annotations.Values(&secret, annotations.SecretSinksKey)
+	stale := slices.DeleteFunc(slices.Clone(recorded), func(name string) bool {
+		return slices.Contains(desired, name)
+	})
+	missing := slices.ContainsFunc(desired, func(name string) bool {
+		return !slices.Contains(recorded, name)
+	})
+	return stale, len(stale) > 0 || missing
+}
+
+// apiPermissions returns the additional API permissions requested for the application. Requested permissions must be
+// allowed by the configured allowlist.
+func (b optionsBuilder) apiPermissions() (apipermissions.Permissions, error) {
+	requested, err := apipermissions.ParseAll(customresources.APIPermissions(&b.instance))
+	if err != nil {
+		return nil, fmt.Errorf("parsing annotation '%s': %w", annotations.APIPermissionsKey, err)
+	}
+
+	allowed, err := b.config.Azure.APIPermissions.AllowedPermissions()
+	if err != nil {
+		return nil, fmt.Errorf("parsing allowed API permissions: %w", err)
+	}
+
+	for _, permission := range requested {
+		if permission.Name == apipermissions.Wildcard || !allowed.Allows(permission) {
+			return nil, fmt.Errorf("parsing annotation '%s': API permission '%s' is not allowed", annotations.APIPermissionsKey, permission)
+		}
+	}
+
+	return requested, nil
+}
+
+// optionalClaims returns the optional claims requested for the application.
+func (b optionsBuilder) optionalClaims() (optionalclaims.Claims, error) {
+	desired, err := optionalclaims.ParseAll(customresources.OptionalClaims(&b.instance))
+	if err != nil {
+		return nil, fmt.Errorf("parsing annotation '%s': %w", annotations.OptionalClaimsKey, err)
+	}
+	return desired, nil
+}
+
+// claimsMappingPolicy returns the claims-mapping policy selected for the application. Applications that do not select
+// a named policy are assigned the default policy, if any.
+func (b optionsBuilder) claimsMappingPolicy() (ClaimsMappingPolicy, error) {
+	policies := b.config.Azure.Features.ClaimsMappingPolicies
+	if !policies.Enabled {
+		return ClaimsMappingPolicy{}, nil
+	}
+
+	name, found := customresources.ClaimsMappingPolicy(&b.instance)
+	if !found {
+		return ClaimsMappingPolicy{ID: policies.ID}, nil
+	}
+
+	if name == config.ClaimsMappingPolicyNone {
+		return ClaimsMappingPolicy{Name: name}, nil
+	}
+
+	named, err := policies.NamedPolicies()
+	if err != nil {
+		return ClaimsMappingPolicy{}, fmt.Errorf("parsing claims-mapping policies: %w", err)
+	}
+
+	id, allowed := named[name]
+	if !allowed {
+		return ClaimsMappingPolicy{}, fmt.Errorf("parsing annotation '%s': claims-mapping policy '%s' is not allowed", annotations.ClaimsMappingPolicyKey, name)
+	}
+	return ClaimsMappingPolicy{Name: name, ID: id}, nil
+}
+
+// groupRoles returns the mappings of groups to app roles requested for the application. Groups are only assigned to
+// applications if the groups assignment feature is enabled.
+func (b optionsBuilder) groupRoles() (grouproles.Mappings, error) {
+	mappings, err := grouproles.ParseAll(customresources.GroupRoles(&b.instance))
+	if err != nil {
+		return nil, fmt.Errorf("parsing annotation '%s': %w", annotations.GroupRolesKey, err)
+	}
+
+	if len(mappings) > 0 && !b.config.Azure.Features.GroupsAssignment.Enabled {
+		return nil, fmt.Errorf("parsing annotation '%s': groups assignment is not enabled", annotations.GroupRolesKey)
+	}
+
+	return mappings, nil
+}
+
+// apiSettings returns the API settings requested for the application. A selected token lifetime policy must be
+// configured.
+func (b optionsBuilder) apiSettings() (APISettings, error) {
+	settings := APISettings{}
+
+	if value, found := customresources.AccessTokenVersion(&b.instance); found {
+		version, err := strconv.Atoi(value)
+		if err != nil || (version != 1 && version != 2) {
+			return APISettings{}, fmt.Errorf("parsing annotation '%s': access token version '%s' must be either 1 or 2", annotations.AccessTokenVersionKey, value)
+		}
+		settings.AccessTokenVersion = version
+	}
+
+	for _, value := range customresources.KnownClientApplications(&b.instance) {
+		clientId, err := uuid.Parse(value)
+		if err != nil {
+			return APISettings{}, fmt.Errorf("parsing annotation '%s': known client application '%s' must be a client ID", annotations.KnownClientApplicationsKey, value)
+		}
+		settings.KnownClientApplications = append(settings.KnownClientApplications, clientId.String())
+	}
+	slices.Sort(settings.KnownClientApplications)
+	settings.KnownClientApplications = slices.Compact(settings.KnownClientApplications)
+
+	if name, found := customresources.TokenLifetimePolicy(&b.instance); found {
+		named, err := b.config.Azure.APISettings.NamedTokenLifetimePolicies()
+		if err != nil {
+			return APISettings{}, fmt.Errorf("parsing token lifetime policies: %w", err)
+		}
+
+		id, allowed := named[name]
+		if !allowed {
+			return APISettings{}, fmt.Errorf("parsing annotation '%s': token lifetime policy '%s' is not allowed", annotations.TokenLifetimePolicyKey, name)
+		}
+		settings.TokenLifetimePolicy = TokenLifetimePolicy{Name: name, ID: id}
+	}
+
+	return settings, nil
+}
+
+// identifierUris returns the additional identifier URIs requested for the application. Requested URIs must be on one
+// of the allowed domains.
+func (b optionsBuilder) identifierUris() (IdentifierUris, error) {
+	desired := make(IdentifierUris, 0)
+	for _, value := range customresources.IdentifierUris(&b.instance) {
+		uri, err := url.Parse(value)
+		if err != nil || (uri.Scheme != "api" && uri.Scheme != "https") || len(uri.Host) == 0 {
+			return nil, fmt.Errorf("parsing annotation '%s': identifier URI '%s' must be an absolute URI with either the 'api' or 'https' scheme", annotations.IdentifierUrisKey, value)
+		}
+		if !b.config.Azure.IdentifierUris.Allows(uri.Hostname()) {
+			return nil, fmt.Errorf("parsing annotation '%s': identifier URI '%s' is not on an allowed domain", annotations.IdentifierUrisKey, value)
+		}
+		desired = append(desired, value)
+	}
+	slices.Sort(desired)
+	return slices.Compact(desired), nil
+}
+
+// owners returns the additional owners requested for the application. Owners are only managed if the owners feature
+// is enabled.
+func (b optionsBuilder) owners() (Owners, error) {
+	desired := Owners(customresources.Owners(&b.instance))
+	if !b.config.Azure.Features.Owners.Enabled {
+		if len(desired) > 0 {
+			return nil, fmt.Errorf("parsing annotation '%s': owners are not enabled", annotations.OwnersKey)
+		}
+		return nil, nil
+	}
+
+	slices.Sort(desired)
+	return slices.Compact(desired), nil
+}
+
+// platformSettings returns the platform settings requested for the application, in addition to the redirect URIs and
+// logout URL in the spec.
+func (b optionsBuilder) platformSettings() (PlatformSettings, error) {
+	settings := PlatformSettings{}
+
+	if value, found := customresources.HomePageUrl(&b.instance); found {
+		if !govalidator.IsURL(value) {
+			return PlatformSettings{}, fmt.Errorf("parsing annotation '%s': home page URL '%s' must be a valid URL", annotations.HomePageUrlKey, value)
+		}
+		settings.HomePageUrl = value
+	}
+
+	for _, value := range customresources.ImplicitGrant(&b.instance) {
+		switch value {
+		case ImplicitGrantIDToken:
+			settings.ImplicitGrant.IDToken = true
+		case ImplicitGrantAccessToken:
+			settings.ImplicitGrant.AccessToken = true
+		default:
+			return PlatformSettings{}, fmt.Errorf("parsing annotation '%s': implicit grant '%s' must be one of '%s' or '%s'", annotations.ImplicitGrantKey, value, ImplicitGrantIDToken, ImplicitGrantAccessToken)
+		}
+	}
+
+	for _, value := range customresources.PublicClientRedirectUris(&b.instance) {
+		// public clients may use custom schemes, e.g. 'myapp://auth', so only require an absolute URI
+		uri, err := url.Parse(value)
+		if err != nil || len(uri.Scheme) == 0 {
+			return PlatformSettings{}, fmt.Errorf("parsing annotation '%s': redirect URI '%s' must be an absolute URI", annotations.PublicClientRedirectUrisKey, value)
+		}
+		settings.PublicClientRedirectUris = append(settings.PublicClientRedirectUris, value)
+	}
+	slices.Sort(settings.PublicClientRedirectUris)
+	settings.PublicClientRedirectUris = slices.Compact(settings.PublicClientRedirectUris)
+
+	if value, found := customresources.FallbackPublicClient(&b.instance); found {
+		fallback, err := strconv.ParseBool(value)
+		if err != nil {
+			return PlatformSettings{}, fmt.Errorf("parsing annotation '%s': '%s' must be either 'true' or 'false'", annotations.FallbackPublicClientKey, value)
+		}
+		settings.FallbackPublicClient = fallback
+	}
+
+	return settings, nil
+}
+
+// workloadIdentity returns the federated identity credential for the application's service account, if enabled by the
+// given credential mode.
+func (b optionsBuilder) workloadIdentity(mode credentials.Mode) (credentials.WorkloadIdentity, error) {
+	if !mode.WorkloadIdentity() {
+		return credentials.WorkloadIdentity{}, nil
+	}
+
+	cfg := b.config.Azure.WorkloadIdentity
+	if len(cfg.Issuer) == 0 {
+		return credentials.WorkloadIdentity{}, fmt.Errorf("credential mode '%s' requires '%s' to be configured", mode, config.AzureWorkloadIdentityIssuer)
+	}
+
+	serviceAccount := customresources.ServiceAccount(&b.instance)
+	return credentials.WorkloadIdentity{
+		Audience:  cfg.Audience,
+		Issuer:    cfg.Issuer,
+		Subject:   credentials.ServiceAccountSubject(b.instance.Namespace, serviceAccount),
+		TokenFile: cfg.TokenFile,
 	}, nil
 }
 
+// maxAge returns the max age for the secrets of the application, preferring the annotation on the resource.
+// Requested values are bounded by the configured limits.
+func (b optionsBuilder) maxAge() (time.Duration, error) {
+	value, found := customresources.RotationMaxAge(&b.instance)
+	if !found {
+		return b.config.SecretRotation.MaxAge, nil
+	}
+
+	maxAge, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("parsing annotation '%s': %w", annotations.RotationMaxAgeKey, err)
+	}
+	return b.config.SecretRotation.LimitMaxAge(maxAge), nil
+}
+
+// keyType returns the key type for new certificate credentials, preferring the annotation on the resource. The boolean
+// is true if the key type is set by the annotation.
+func (b optionsBuilder) keyType() (crypto.KeyType, bool, error) {
+	value, found := customresources.KeyType(&b.instance)
+	if !found {
+		return b.config.Certificate.KeyType, false, nil
+	}
+
+	keyType, err := crypto.ParseKeyType(value)
+	if err != nil {
+		return "", false, fmt.Errorf("parsing annotation '%s': %w", annotations.KeyTypeKey, err)
+	}
+	return keyType, true, nil
+}
+
+// keyTypeChanged returns true if the next certificate credential in the latest secret is not of the desired key type.
+// Rotation only replaces the next credential, so applications move onto a new key type gradually: the current
+// credential follows at the subsequent rotation, and consumers always hold a valid key.
+func (b optionsBuilder) keyTypeChanged(desired crypto.KeyType) bool {
+	actual, ok := b.secrets.LatestCredentials.Set.Next.Certificate.Jwk.KeyType()
+	return ok && len(desired) > 0 && actual != desired
+}
+
 type ProcessOptions struct {
 	Synchronize bool
 	Azure       AzureOptions
@@ -49,10 +534,222 @@ type ProcessOptions struct {
 type AzureOptions struct {
 	Synchronize    bool
 	CleanupOrphans bool
+	// APIPermissions lists the additional API permissions requested for the application, in addition to the default
+	// permissions for Microsoft Graph.
+	APIPermissions apipermissions.Permissions
+	// APISettings are the API settings requested for the application.
+	APISettings APISettings
+	// ClaimsMappingPolicy is the claims-mapping policy to assign to the application's service principal.
+	ClaimsMappingPolicy ClaimsMappingPolicy
+	// GroupRoles lists the mappings of groups to app roles that are assignable to users.
+	GroupRoles grouproles.Mappings
+	// IdentifierUris lists the identifier URIs requested for the application, in addition to the default URIs.
+	IdentifierUris IdentifierUris
+	// OptionalClaims lists the optional claims requested for the application, in addition to the default claims.
+	OptionalClaims optionalclaims.Claims
+	// Owners lists the additional owners requested for the application and its service principal.
+	Owners Owners
+	// PlatformSettings are the platform settings requested for the application.
+	PlatformSettings PlatformSettings
+}
+
+// Settings returns the requested settings that are not part of the spec, in a fixed order. Empty values are settings
+// that are not requested.
+func (o AzureOptions) Settings() []string {
+	return []string{
+		o.APIPermissions.String(),
+		o.APISettings.String(),
+		o.ClaimsMappingPolicy.String(),
+		o.GroupRoles.String(),
+		o.IdentifierUris.String(),
+		o.OptionalClaims.String(),
+		o.Owners.String(),
+		o.PlatformSettings.String(),
+	}
+}
+
+// ClaimsMappingPolicy is the claims-mapping policy selected for an application.
+type ClaimsMappingPolicy struct {
+	// Name is the name of the policy selected by the application, or empty if the application uses the default policy.
+	Name string
+	// ID is the ID of the policy to assign, or empty if no policy should be assigned.
+	ID string
+}
+
+// String returns the selected policy in the form '<name>=<policy ID>', or just the name if no policy should be
+// assigned. Applications that use the default policy return an empty string.
+func (p ClaimsMappingPolicy) String() string {
+	if len(p.Name) == 0 || len(p.ID) == 0 {
+		return p.Name
+	}
+	return fmt.Sprintf("%s=%s", p.Name, p.ID)
+}
+
+// APISettings are the API settings requested for an application.
+type APISettings struct {
+	// AccessTokenVersion is the requested version of access tokens issued for the application, or zero for the
+	// configured default.
+	AccessTokenVersion int
+	// KnownClientApplications lists the client IDs of applications that are consented to together with the
+	// application.
+	KnownClientApplications []string
+	// TokenLifetimePolicy is the token lifetime policy to assign to the application, if any.
+	TokenLifetimePolicy TokenLifetimePolicy
+}
+
+// AccessTokenVersionOr returns the requested access token version, or the given default if no version is requested.
+func (s APISettings) AccessTokenVersionOr(defaultVersion int) int {
+	if s.AccessTokenVersion == 0 {
+		return defaultVersion
+	}
+	return s.AccessTokenVersion
+}
+
+// String returns the requested settings as a semicolon-separated list, e.g.
+// 'access-token-version=1;known-client-applications=<client ID>'. No requested settings return an empty string.
+func (s APISettings) String() string {
+	settings := make([]string, 0)
+	if s.AccessTokenVersion > 0 {
+		settings = append(settings, fmt.Sprintf("access-token-version=%d", s.AccessTokenVersion))
+	}
+	if len(s.KnownClientApplications) > 0 {
+		settings = append(settings, fmt.Sprintf("known-client-applications=%s", strings.Join(s.KnownClientApplications, ",")))
+	}
+	if policy := s.TokenLifetimePolicy.String(); len(policy) > 0 {
+		settings = append(settings, fmt.Sprintf("token-lifetime-policy=%s", policy))
+	}
+	return strings.Join(settings, ";")
+}
+
+// TokenLifetimePolicy is the token lifetime policy selected for an application.
+type TokenLifetimePolicy struct {
+	// Name is the name of the selected policy, or empty if no policy is selected.
+	Name string
+	// ID is the ID of the policy to assign, or empty if no policy should be assigned.
+	ID string
+}
+
+// String returns the selected policy in the form '<name>=<policy ID>', or an empty string if no policy is selected.
+func (p TokenLifetimePolicy) String() string {
+	if len(p.Name) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s=%s", p.Name, p.ID)
+}
+
+// IdentifierUris lists additional identifier URIs for an application.
+type IdentifierUris []string
+
+// String returns the URIs as a comma-separated list.
+func (u IdentifierUris) String() string {
+	return strings.Join(u, ",")
+}
+
+// Owners lists additional owners for an application. Each owner is either the user principal name of a user, or a
+// reference to a group whose members are owners.
+type Owners []string
+
+// String returns the owners as a comma-separated list.
+func (o Owners) String() string {
+	return strings.Join(o, ",")
+}
+
+// IsUser returns true if the given owner is the user principal name of a user, i.e. contains '@'. Any other owner is a
+// group reference.
+func IsUser(owner string) bool {
+	return strings.Contains(owner, "@")
+}
+
+const (
+	ImplicitGrantAccessToken = "access-token"
+	ImplicitGrantIDToken     = "id-token"
+)
+
+// PlatformSettings are the platform settings requested for an application, in addition to the redirect URIs and
+// logout URL in the spec.
+type PlatformSettings struct {
+	// HomePageUrl is the URL of the application's home page, or empty if none.
+	HomePageUrl string
+	// ImplicitGrant is the kinds of tokens that may be issued with the implicit grant flow.
+	ImplicitGrant ImplicitGrant
+	// PublicClientRedirectUris lists the redirect URIs for public clients, e.g. command-line tools using the device
+	// code flow or authorization code flow with PKCE.
+	PublicClientRedirectUris []string
+	// FallbackPublicClient is true if the application should be treated as a public client when the client type
+	// cannot be determined, e.g. for the device code flow.
+	FallbackPublicClient bool
+}
+
+// String returns the requested settings as a semicolon-separated list, e.g.
+// 'home-page-url=<URL>;implicit-grant=id-token'. No requested settings return an empty string.
+func (s PlatformSettings) String() string {
+	settings := make([]string, 0)
+	if len(s.HomePageUrl) > 0 {
+		settings = append(settings, fmt.Sprintf("home-page-url=%s", s.HomePageUrl))
+	}
+	if grant := s.ImplicitGrant.String(); len(grant) > 0 {
+		settings = append(settings, fmt.Sprintf("implicit-grant=%s", grant))
+	}
+	if len(s.PublicClientRedirectUris) > 0 {
+		settings = append(settings, fmt.Sprintf("public-client-redirect-uris=%s", strings.Join(s.PublicClientRedirectUris, ",")))
+	}
+	if s.FallbackPublicClient {
+		settings = append(settings, "fallback-public-client=true")
+	}
+	return strings.Join(settings, ";")
+}
+
+// ImplicitGrant is the kinds of tokens requested to be issued with the implicit grant flow.
+type ImplicitGrant struct {
+	AccessToken bool
+	IDToken     bool
+}
+
+// String returns the kinds of tokens as a comma-separated list, e.g. 'access-token,id-token'.
+func (g ImplicitGrant) String() string {
+	grants := make([]string, 0)
+	if g.AccessToken {
+		grants = append(grants, ImplicitGrantAccessToken)
+	}
+	if g.IDToken {
+		grants = append(grants, ImplicitGrantIDToken)
+	}
+	return strings.Join(grants, ",")
 }
 
 type SecretOptions struct {
-	Rotate  bool
-	Valid   bool
-	Cleanup bool
+	Rotate bool
+	// Revoke is true if all existing credentials should be removed from Azure AD and replaced by a new set.
+	Revoke bool
+	// RestartWorkloads is true if workloads using the secret should be restarted after revocation.
+	RestartWorkloads bool
+	Valid            bool
+	Cleanup          bool
+	// KeysChanged is true if the keys in the existing secret differ from the desired keys, e.g. when optional keys
+	// have been enabled or disabled.
+	KeysChanged bool
+	// Sinks lists the external secret sinks that the secret data is written to, in addition to the Kubernetes Secret.
+	Sinks []string
+	// StaleSinks lists the external secret sinks that the secret data was previously written to, but are no longer
+	// requested.
+	StaleSinks []string
+	// SinksChanged is true if Sinks differ from the sinks that the existing secret data was written to.
+	SinksChanged bool
+	// Templates render additional entries in the secret from the secret data.
+	Templates secrets.Templates
+	// TemplatesChanged is true if Templates differ from the templates that rendered the existing secret.
+	TemplatesChanged bool
+	// MaxAge is the duration after the last rotation at which the credentials are rotated.
+	MaxAge time.Duration
+	// RotationWindow restricts rotations due to MaxAge or key type changes to its periods, if set.
+	RotationWindow *cron.Window
+	// KeyTypeDeferred is true if the key type has changed, but the rotation is deferred to the next RotationWindow.
+	KeyTypeDeferred bool
+	// ExpiresAt is the earliest end date in Azure AD of the existing credentials, if known.
+	ExpiresAt time.Time
+	// CredentialMode determines the kinds of credentials registered in Azure AD and written to the secret.
+	CredentialMode credentials.Mode
+	// WorkloadIdentity holds the desired federated identity credential if enabled by the CredentialMode.
+	WorkloadIdentity credentials.WorkloadIdentity
+	Certificate      crypto.CertificateOptions
 }
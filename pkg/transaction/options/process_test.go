@@ -0,0 +1,1342 @@
+package options_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
+	"github.com/nais/liberator/pkg/kubernetes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/nais/azureator/pkg/annotations"
+	"github.com/nais/azureator/pkg/azure/credentials"
+	"github.com/nais/azureator/pkg/config"
+	"github.com/nais/azureator/pkg/customresources"
+	"github.com/nais/azureator/pkg/fixtures"
+	secretdata "github.com/nais/azureator/pkg/secrets"
+	"github.com/nais/azureator/pkg/transaction/options"
+	"github.com/nais/azureator/pkg/transaction/secrets"
+	"github.com/nais/azureator/pkg/util/crypto"
+)
+
+func TestProcess_KeyType(t *testing.T) {
+	cfg := testConfig()
+	cfg.Certificate.KeyType = crypto.KeyTypeRSA3072
+
+	credentialsSet := func(t *testing.T, keyType crypto.KeyType) secrets.Secrets {
+		app := fixtures.MinimalApplication()
+		current, err := crypto.GenerateJwk(app, "test-cluster", crypto.CertificateOptions{KeyType: keyType})
+		require.NoError(t, err)
+		next, err := crypto.GenerateJwk(app, "test-cluster", crypto.CertificateOptions{KeyType: keyType})
+		require.NoError(t, err)
+
+		return secrets.Secrets{
+			LatestCredentials: secrets.Credentials{
+				Set: &credentials.Set{
+					Current: credentials.Credentials{
+						Certificate: credentials.Certificate{KeyId: "current-key", Jwk: current},
+						Password:    credentials.Password{KeyId: "current-password", ClientSecret: "current-secret"},
+					},
+					Next: credentials.Credentials{
+						Certificate: credentials.Certificate{KeyId: "next-key", Jwk: next},
+						Password:    credentials.Password{KeyId: "next-password", ClientSecret: "next-secret"},
+					},
+				},
+				Valid: true,
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		name            string
+		annotation      string
+		existing        crypto.KeyType
+		expectedKeyType crypto.KeyType
+		expectedRotate  bool
+	}{
+		{
+			name:            "existing keys match configured key type",
+			existing:        crypto.KeyTypeRSA3072,
+			expectedKeyType: crypto.KeyTypeRSA3072,
+			expectedRotate:  false,
+		},
+		{
+			name:            "existing keys differ from configured key type",
+			existing:        crypto.KeyTypeRSA4096,
+			expectedKeyType: crypto.KeyTypeRSA3072,
+			expectedRotate:  false,
+		},
+		{
+			name:            "annotation overrides configured key type",
+			annotation:      string(crypto.KeyTypeRSA4096),
+			existing:        crypto.KeyTypeRSA3072,
+			expectedKeyType: crypto.KeyTypeRSA4096,
+			expectedRotate:  true,
+		},
+		{
+			name:            "existing keys match annotation",
+			annotation:      string(crypto.KeyTypeRSA4096),
+			existing:        crypto.KeyTypeRSA4096,
+			expectedKeyType: crypto.KeyTypeRSA4096,
+			expectedRotate:  false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fixtures.MinimalApplication()
+			app.Status.SynchronizationTenant = "some-tenant"
+			if len(tt.annotation) > 0 {
+				annotations.SetAnnotation(app, annotations.KeyTypeKey, tt.annotation)
+			}
+
+			opts, err := options.NewOptions(*app, cfg, credentialsSet(t, tt.existing))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedKeyType, opts.Process.Secret.Certificate.KeyType)
+			assert.Equal(t, tt.expectedRotate, opts.Process.Secret.Rotate)
+			assert.True(t, opts.Process.Secret.Valid)
+			if tt.expectedRotate {
+				assert.True(t, opts.Process.Synchronize)
+			}
+		})
+	}
+
+	t.Run("invalid annotation", func(t *testing.T) {
+		app := fixtures.MinimalApplication()
+		annotations.SetAnnotation(app, annotations.KeyTypeKey, "RSA-1024")
+
+		_, err := options.NewOptions(*app, cfg, secrets.Secrets{})
+		assert.ErrorContains(t, err, annotations.KeyTypeKey)
+	})
+
+	t.Run("elliptic curve keys are rejected", func(t *testing.T) {
+		app := fixtures.MinimalApplication()
+		annotations.SetAnnotation(app, annotations.KeyTypeKey, "EC-P256")
+
+		_, err := options.NewOptions(*app, cfg, secrets.Secrets{})
+		assert.ErrorContains(t, err, annotations.KeyTypeKey)
+		assert.ErrorContains(t, err, "RS256 or PS256")
+	})
+}
+
+func TestProcess_CredentialMode(t *testing.T) {
+	cfg := testConfig()
+
+	jwk, err := crypto.GenerateJwk(fixtures.MinimalApplication(), "test-cluster", crypto.CertificateOptions{})
+	require.NoError(t, err)
+
+	certificate := credentials.Certificate{KeyId: "some-key", Jwk: jwk}
+	password := credentials.Password{KeyId: "some-password", ClientSecret: "some-secret"}
+
+	credentialsSet := func(c credentials.Credentials) secrets.Secrets {
+		return secrets.Secrets{
+			LatestCredentials: secrets.Credentials{
+				Set:   &credentials.Set{Current: c, Next: c},
+				Valid: true,
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		name          string
+		annotation    string
+		existing      credentials.Credentials
+		expectedMode  credentials.Mode
+		expectedValid bool
+	}{
+		{
+			name:          "default mode with both kinds of credentials",
+			existing:      credentials.Credentials{Certificate: certificate, Password: password},
+			expectedMode:  credentials.ModeBoth,
+			expectedValid: true,
+		},
+		{
+			name:          "certificate mode with both kinds of credentials",
+			annotation:    string(credentials.ModeCertificate),
+			existing:      credentials.Credentials{Certificate: certificate, Password: password},
+			expectedMode:  credentials.ModeCertificate,
+			expectedValid: false,
+		},
+		{
+			name:          "certificate mode with certificate credentials",
+			annotation:    string(credentials.ModeCertificate),
+			existing:      credentials.Credentials{Certificate: certificate},
+			expectedMode:  credentials.ModeCertificate,
+			expectedValid: true,
+		},
+		{
+			name:          "password mode with certificate credentials",
+			annotation:    string(credentials.ModePassword),
+			existing:      credentials.Credentials{Certificate: certificate},
+			expectedMode:  credentials.ModePassword,
+			expectedValid: false,
+		},
+		{
+			name:          "default mode with password credentials",
+			existing:      credentials.Credentials{Password: password},
+			expectedMode:  credentials.ModeBoth,
+			expectedValid: false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fixtures.MinimalApplication()
+			app.Status.SynchronizationTenant = "some-tenant"
+			if len(tt.annotation) > 0 {
+				annotations.SetAnnotation(app, annotations.CredentialModeKey, tt.annotation)
+			}
+
+			opts, err := options.NewOptions(*app, cfg, credentialsSet(tt.existing))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedMode, opts.Process.Secret.CredentialMode)
+			assert.Equal(t, tt.expectedValid, opts.Process.Secret.Valid)
+			if !tt.expectedValid {
+				assert.True(t, opts.Process.Synchronize)
+			}
+		})
+	}
+
+	t.Run("invalid annotation", func(t *testing.T) {
+		app := fixtures.MinimalApplication()
+		annotations.SetAnnotation(app, annotations.CredentialModeKey, "none")
+
+		_, err := options.NewOptions(*app, cfg, secrets.Secrets{})
+		assert.ErrorContains(t, err, annotations.CredentialModeKey)
+	})
+}
+
+func TestProcess_WorkloadIdentity(t *testing.T) {
+	cfg := testConfig()
+	cfg.Azure.WorkloadIdentity = config.WorkloadIdentity{
+		Audience:  "api://AzureADTokenExchange",
+		Issuer:    "https://issuer.example.com",
+		TokenFile: "/var/run/secrets/azure/tokens/azure-identity-token",
+	}
+
+	existing := secrets.Secrets{
+		LatestCredentials: secrets.Credentials{
+			Set:   &credentials.Set{},
+			Valid: true,
+		},
+	}
+
+	newApp := func() *v1.AzureAdApplication {
+		app := fixtures.MinimalApplication()
+		app.Status.SynchronizationTenant = "some-tenant"
+		app.Status.SynchronizationSecretName = app.Spec.SecretName
+		// long past the max age, which should not trigger rotation
+		app.Status.SynchronizationSecretRotationTime = &metav1.Time{Time: time.Now().Add(-30 * 24 * time.Hour)}
+		annotations.SetAnnotation(app, annotations.CredentialModeKey, string(credentials.ModeWorkloadIdentity))
+		return app
+	}
+
+	t.Run("valid federated credential is not rotated", func(t *testing.T) {
+		app := newApp()
+		annotations.SetAnnotation(app, annotations.RotateKey, "true")
+
+		opts, err := options.NewOptions(*app, cfg, existing)
+		require.NoError(t, err)
+		assert.True(t, opts.Process.Secret.Valid)
+		assert.False(t, opts.Process.Secret.Rotate)
+		assert.Equal(t, credentials.WorkloadIdentity{
+			Audience:  "api://AzureADTokenExchange",
+			Issuer:    "https://issuer.example.com",
+			Subject:   "system:serviceaccount:test-namespace:test-app",
+			TokenFile: "/var/run/secrets/azure/tokens/azure-identity-token",
+		}, opts.Process.Secret.WorkloadIdentity)
+	})
+
+	t.Run("service account annotation", func(t *testing.T) {
+		app := newApp()
+		annotations.SetAnnotation(app, annotations.ServiceAccountKey, "some-service-account")
+
+		opts, err := options.NewOptions(*app, cfg, existing)
+		require.NoError(t, err)
+		assert.Equal(t, "system:serviceaccount:test-namespace:some-service-account", opts.Process.Secret.WorkloadIdentity.Subject)
+	})
+
+	t.Run("changed secret name adds federated credential anew", func(t *testing.T) {
+		app := newApp()
+		app.Spec.SecretName = "new-secret"
+
+		opts, err := options.NewOptions(*app, cfg, existing)
+		require.NoError(t, err)
+		assert.True(t, opts.Process.Synchronize)
+		assert.False(t, opts.Process.Secret.Valid)
+		assert.False(t, opts.Process.Secret.Rotate)
+	})
+
+	t.Run("issuer is required", func(t *testing.T) {
+		cfg := cfg
+		cfg.Azure.WorkloadIdentity.Issuer = ""
+
+		_, err := options.NewOptions(*newApp(), cfg, existing)
+		assert.ErrorContains(t, err, config.AzureWorkloadIdentityIssuer)
+	})
+}
+
+func TestProcess_RotationMaxAge(t *testing.T) {
+	cfg := testConfig()
+	cfg.SecretRotation.MaxAge = 120 * time.Hour
+	cfg.SecretRotation.MaxAgeLimits = config.MaxAgeLimits{
+		Min: 24 * time.Hour,
+		Max: 240 * time.Hour,
+	}
+
+	c := credentials.Credentials{
+		Certificate: credentials.Certificate{KeyId: "some-key"},
+		Password:    credentials.Password{KeyId: "some-password", ClientSecret: "some-secret"},
+	}
+	existing := secrets.Secrets{
+		LatestCredentials: secrets.Credentials{
+			Set:   &credentials.Set{Current: c, Next: c},
+			Valid: true,
+		},
+	}
+
+	for _, tt := range []struct {
+		name           string
+		annotation     string
+		expectedMaxAge time.Duration
+		expectedValid  bool
+	}{
+		{
+			name:           "no annotation uses configured max age",
+			expectedMaxAge: 120 * time.Hour,
+			expectedValid:  true,
+		},
+		{
+			name:           "annotation shortens max age",
+			annotation:     "36h",
+			expectedMaxAge: 36 * time.Hour,
+			expectedValid:  false,
+		},
+		{
+			name:           "annotation extends max age",
+			annotation:     "200h",
+			expectedMaxAge: 200 * time.Hour,
+			expectedValid:  true,
+		},
+		{
+			name:           "annotation below lower limit",
+			annotation:     "1h",
+			expectedMaxAge: 24 * time.Hour,
+			expectedValid:  false,
+		},
+		{
+			name:           "annotation above upper limit",
+			annotation:     "2000h",
+			expectedMaxAge: 240 * time.Hour,
+			expectedValid:  true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fixtures.MinimalApplication()
+			app.Status.SynchronizationTenant = "some-tenant"
+			app.Status.SynchronizationSecretRotationTime = &metav1.Time{Time: time.Now().Add(-48 * time.Hour)}
+			if len(tt.annotation) > 0 {
+				annotations.SetAnnotation(app, annotations.RotationMaxAgeKey, tt.annotation)
+			}
+
+			opts, err := options.NewOptions(*app, cfg, existing)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedMaxAge, opts.Process.Secret.MaxAge)
+			assert.Equal(t, tt.expectedValid, opts.Process.Secret.Valid)
+			assert.Equal(t, 2*tt.expectedMaxAge+30*24*time.Hour, opts.Process.Secret.Certificate.Validity)
+			if !tt.expectedValid {
+				assert.True(t, opts.Process.Synchronize)
+			}
+		})
+	}
+
+	t.Run("invalid annotation", func(t *testing.T) {
+		app := fixtures.MinimalApplication()
+		annotations.SetAnnotation(app, annotations.RotationMaxAgeKey, "30 days")
+
+		_, err := options.NewOptions(*app, cfg, secrets.Secrets{})
+		assert.ErrorContains(t, err, annotations.RotationMaxAgeKey)
+	})
+}
+
+func TestProcess_RotationWindow(t *testing.T) {
+	// a window that starts 12 hours from now, and thus never contains the current time
+	outside := fmt.Sprintf("0 %d * * *", (time.Now().UTC().Hour()+12)%24)
+
+	newConfig := func(window config.RotationWindow) config.Config {
+		window.Duration = time.Hour
+		window.TimeZone = "UTC"
+		cfg := testConfig()
+		cfg.SecretRotation.Window = window
+		return cfg
+	}
+
+	c := credentials.Credentials{
+		Certificate: credentials.Certificate{KeyId: "some-key"},
+		Password:    credentials.Password{KeyId: "some-password", ClientSecret: "some-secret"},
+	}
+	existing := secrets.Secrets{
+		LatestCredentials: secrets.Credentials{
+			Set:   &credentials.Set{Current: c, Next: c},
+			Valid: true,
+		},
+	}
+
+	newApp := func() *v1.AzureAdApplication {
+		app := fixtures.MinimalApplication()
+		app.Status.SynchronizationTenant = "some-tenant"
+		app.Status.SynchronizationSecretName = app.Spec.SecretName
+		app.Status.SynchronizationSecretRotationTime = &metav1.Time{Time: time.Now().Add(-48 * time.Hour)}
+		return app
+	}
+
+	for _, tt := range []struct {
+		name           string
+		window         config.RotationWindow
+		rotate         bool
+		expectedValid  bool
+		expectedRotate bool
+	}{
+		{
+			name:          "expired secrets are rotated within window",
+			window:        config.RotationWindow{Schedule: "* * * * *"},
+			expectedValid: false,
+		},
+		{
+			name:          "expired secrets are not rotated outside window",
+			window:        config.RotationWindow{Schedule: outside},
+			expectedValid: true,
+		},
+		{
+			name:           "rotate annotation ignores window",
+			window:         config.RotationWindow{Schedule: outside},
+			rotate:         true,
+			expectedValid:  true,
+			expectedRotate: true,
+		},
+		{
+			name:          "namespace schedule overrides global schedule",
+			window:        config.RotationWindow{Schedule: "* * * * *", Namespaces: []string{"test-namespace=" + outside}},
+			expectedValid: true,
+		},
+		{
+			name:          "namespace without schedule is not restricted",
+			window:        config.RotationWindow{Schedule: outside, Namespaces: []string{"test-namespace="}},
+			expectedValid: false,
+		},
+		{
+			name:          "other namespaces use global schedule",
+			window:        config.RotationWindow{Schedule: outside, Namespaces: []string{"other-namespace="}},
+			expectedValid: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newApp()
+			if tt.rotate {
+				annotations.SetAnnotation(app, annotations.RotateKey, "true")
+			}
+
+			opts, err := options.NewOptions(*app, newConfig(tt.window), existing)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedValid, opts.Process.Secret.Valid)
+			assert.Equal(t, tt.expectedRotate, opts.Process.Secret.Rotate)
+		})
+	}
+
+	t.Run("key type changes are deferred outside window", func(t *testing.T) {
+		app := newApp()
+		app.Status.SynchronizationSecretRotationTime = &metav1.Time{Time: time.Now()}
+		annotations.SetAnnotation(app, annotations.KeyTypeKey, string(crypto.KeyTypeRSA4096))
+
+		jwk, err := crypto.GenerateJwk(app, "test-cluster", crypto.CertificateOptions{KeyType: crypto.KeyTypeRSA3072})
+		require.NoError(t, err)
+		rsa := c
+		rsa.Certificate.Jwk = jwk
+		existing := secrets.Secrets{
+			LatestCredentials: secrets.Credentials{
+				Set:   &credentials.Set{Current: rsa, Next: rsa},
+				Valid: true,
+			},
+		}
+
+		opts, err := options.NewOptions(*app, newConfig(config.RotationWindow{Schedule: outside}), existing)
+		require.NoError(t, err)
+		assert.False(t, opts.Process.Secret.Rotate)
+		assert.True(t, opts.Process.Secret.KeyTypeDeferred)
+
+		opts, err = options.NewOptions(*app, newConfig(config.RotationWindow{Schedule: "* * * * *"}), existing)
+		require.NoError(t, err)
+		assert.True(t, opts.Process.Secret.Rotate)
+		assert.False(t, opts.Process.Secret.KeyTypeDeferred)
+	})
+
+	t.Run("invalid schedule", func(t *testing.T) {
+		_, err := options.NewOptions(*newApp(), newConfig(config.RotationWindow{Schedule: "not a schedule"}), existing)
+		assert.Error(t, err)
+	})
+}
+
+func TestProcess_SecretSinks(t *testing.T) {
+	cfg := testConfig()
+	cfg.SecretSinks = config.SecretSinks{
+		File:  config.FileSink{Directory: t.TempDir()},
+		Vault: config.VaultSink{Address: "http://localhost:8200"},
+	}
+
+	c := credentials.Credentials{
+		Certificate: credentials.Certificate{KeyId: "some-key"},
+		Password:    credentials.Password{KeyId: "some-password", ClientSecret: "some-secret"},
+	}
+
+	for _, tt := range []struct {
+		name            string
+		annotation      string
+		recorded        string
+		expectedSinks   []string
+		expectedStale   []string
+		expectedChanged bool
+	}{
+		{
+			name: "no sinks",
+		},
+		{
+			name:            "sink added",
+			annotation:      "vault",
+			expectedSinks:   []string{"vault"},
+			expectedChanged: true,
+		},
+		{
+			name:          "sinks unchanged",
+			annotation:    "vault, file",
+			recorded:      "file,vault",
+			expectedSinks: []string{"file", "vault"},
+		},
+		{
+			name:            "sink removed",
+			annotation:      "file",
+			recorded:        "file,vault",
+			expectedSinks:   []string{"file"},
+			expectedStale:   []string{"vault"},
+			expectedChanged: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fixtures.MinimalApplication()
+			app.Status.SynchronizationTenant = "some-tenant"
+			app.Status.SynchronizationSecretName = app.Spec.SecretName
+			app.Status.SynchronizationSecretRotationTime = new(metav1.Now())
+			if len(tt.annotation) > 0 {
+				annotations.SetAnnotation(app, annotations.SecretSinksKey, tt.annotation)
+			}
+
+			secret := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: app.Spec.SecretName}}
+			if len(tt.recorded) > 0 {
+				annotations.SetAnnotation(&secret, annotations.SecretSinksKey, tt.recorded)
+			}
+
+			existing := secrets.Secrets{
+				LatestCredentials: secrets.Credentials{
+					Set:   &credentials.Set{Current: c, Next: c},
+					Valid: true,
+				},
+				ManagedSecrets: kubernetes.SecretLists{
+					Used: corev1.SecretList{Items: []corev1.Secret{secret}},
+				},
+			}
+
+			opts, err := options.NewOptions(*app, cfg, existing)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSinks, opts.Process.Secret.Sinks)
+			assert.ElementsMatch(t, tt.expectedStale, opts.Process.Secret.StaleSinks)
+			assert.Equal(t, tt.expectedChanged, opts.Process.Secret.SinksChanged)
+			if tt.expectedChanged {
+				assert.True(t, opts.Process.Synchronize)
+			}
+		})
+	}
+
+	t.Run("unconfigured sink", func(t *testing.T) {
+		app := fixtures.MinimalApplication()
+		app.Status.SynchronizationSecretName = app.Spec.SecretName
+		annotations.SetAnnotation(app, annotations.SecretSinksKey, "unknown")
+
+		secret := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: app.Spec.SecretName}}
+		annotations.SetAnnotation(&secret, annotations.SecretSinksKey, "vault")
+		existing := secrets.Secrets{
+			ManagedSecrets: kubernetes.SecretLists{
+				Used: corev1.SecretList{Items: []corev1.Secret{secret}},
+			},
+		}
+
+		opts, err := options.NewOptions(*app, cfg, existing)
+		assert.ErrorContains(t, err, annotations.SecretSinksKey)
+
+		var invalidErr *options.InvalidError
+		assert.ErrorAs(t, err, &invalidErr)
+
+		// the sinks are still returned, so that they can be purged when the application is deleted
+		assert.Equal(t, []string{"unknown"}, opts.Process.Secret.Sinks)
+		assert.Equal(t, []string{"vault"}, opts.Process.Secret.StaleSinks)
+	})
+}
+
+func TestProcess_SecretTemplates(t *testing.T) {
+	cfg := testConfig()
+
+	c := credentials.Credentials{
+		Certificate: credentials.Certificate{KeyId: "some-key"},
+		Password:    credentials.Password{KeyId: "some-password", ClientSecret: "some-secret"},
+	}
+	dataKeys := secretdata.NewSecretDataKeys()
+	template := map[string]string{"azure.env": "AZURE_APP_CLIENT_ID={{ .AZURE_APP_CLIENT_ID }}"}
+	templates, err := secretdata.ParseTemplates(template, dataKeys.AllKeys())
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name            string
+		template        map[string]string
+		recorded        string
+		expectedChanged bool
+	}{
+		{
+			name: "no templates",
+		},
+		{
+			name:            "template added",
+			template:        template,
+			expectedChanged: true,
+		},
+		{
+			name:     "template unchanged",
+			template: template,
+			recorded: templates.Digest(),
+		},
+		{
+			name:            "template removed",
+			recorded:        templates.Digest(),
+			expectedChanged: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fixtures.MinimalApplication()
+			app.Status.SynchronizationTenant = "some-tenant"
+			app.Status.SynchronizationSecretName = app.Spec.SecretName
+			app.Status.SynchronizationSecretRotationTime = new(metav1.Now())
+			for key, value := range tt.template {
+				annotations.SetAnnotation(app, annotations.SecretTemplatePrefix+key, value)
+			}
+
+			secret := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: app.Spec.SecretName}}
+			if len(tt.recorded) > 0 {
+				annotations.SetAnnotation(&secret, annotations.SecretTemplatesKey, tt.recorded)
+			}
+
+			existing := secrets.Secrets{
+				LatestCredentials: secrets.Credentials{
+					Set:   &credentials.Set{Current: c, Next: c},
+					Valid: true,
+				},
+				DataKeys: dataKeys,
+				ManagedSecrets: kubernetes.SecretLists{
+					Used: corev1.SecretList{Items: []corev1.Secret{secret}},
+				},
+			}
+
+			opts, err := options.NewOptions(*app, cfg, existing)
+			require.NoError(t, err)
+			assert.Len(t, opts.Process.Secret.Templates, len(tt.template))
+			assert.Equal(t, tt.expectedChanged, opts.Process.Secret.TemplatesChanged)
+			if tt.expectedChanged {
+				assert.True(t, opts.Process.Synchronize)
+			}
+		})
+	}
+
+	t.Run("invalid template", func(t *testing.T) {
+		app := fixtures.MinimalApplication()
+		annotations.SetAnnotation(app, annotations.SecretTemplatePrefix+"azure.env", "{{ .AZURE_APP_UNKNOWN }}")
+
+		_, err := options.NewOptions(*app, cfg, secrets.Secrets{DataKeys: dataKeys})
+		assert.ErrorContains(t, err, "unknown secret key 'AZURE_APP_UNKNOWN'")
+	})
+}
+
+func TestProcess_Revoke(t *testing.T) {
+	cfg := testConfig()
+	cfg.SecretRotation.Cleanup = true
+
+	c := credentials.Credentials{
+		Certificate: credentials.Certificate{KeyId: "some-key"},
+		Password:    credentials.Password{KeyId: "some-password", ClientSecret: "some-secret"},
+	}
+	existing := secrets.Secrets{
+		LatestCredentials: secrets.Credentials{
+			Set:   &credentials.Set{Current: c, Next: c},
+			Valid: true,
+		},
+	}
+
+	for _, tt := range []struct {
+		name            string
+		annotation      string
+		expectedRevoke  bool
+		expectedRestart bool
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:           "revoke",
+			annotation:     "true",
+			expectedRevoke: true,
+		},
+		{
+			name:            "revoke and restart",
+			annotation:      "restart",
+			expectedRevoke:  true,
+			expectedRestart: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fixtures.MinimalApplication()
+			app.Status.SynchronizationTenant = "some-tenant"
+			app.Status.SynchronizationSecretName = app.Spec.SecretName
+			app.Status.SynchronizationSecretRotationTime = new(metav1.Now())
+			if len(tt.annotation) > 0 {
+				annotations.SetAnnotation(app, annotations.RevokeKey, tt.annotation)
+			}
+
+			opts, err := options.NewOptions(*app, cfg, existing)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedRevoke, opts.Process.Secret.Revoke)
+			assert.Equal(t, tt.expectedRestart, opts.Process.Secret.RestartWorkloads)
+			assert.Equal(t, !tt.expectedRevoke, opts.Process.Secret.Valid)
+			if tt.expectedRevoke {
+				assert.True(t, opts.Process.Synchronize)
+			}
+		})
+	}
+}
+
+func TestProcess_APIPermissions(t *testing.T) {
+	cfg := testConfig()
+	cfg.Azure.APIPermissions.Allowed = []string{"application:graph/User.Read.All", "delegated:graph/*"}
+
+	for _, tt := range []struct {
+		name            string
+		annotation      string
+		applied         string
+		expected        string
+		expectedChanged bool
+		err             string
+	}{
+		{
+			name: "no permissions",
+		},
+		{
+			name:            "permissions added",
+			annotation:      "delegated:graph/Mail.Send, application:graph/User.Read.All",
+			expected:        "application:graph/User.Read.All,delegated:graph/Mail.Send",
+			expectedChanged: true,
+		},
+		{
+			name:       "permissions unchanged",
+			annotation: "delegated:graph/Mail.Send,application:graph/User.Read.All",
+			applied:    "application:graph/User.Read.All,delegated:graph/Mail.Send",
+			expected:   "application:graph/User.Read.All,delegated:graph/Mail.Send",
+		},
+		{
+			name:            "permissions removed",
+			applied:         "application:graph/User.Read.All",
+			expectedChanged: true,
+		},
+		{
+			name:       "permission not allowed",
+			annotation: "application:graph/Directory.Read.All",
+			err:        "API permission 'application:graph/Directory.Read.All' is not allowed",
+		},
+		{
+			name:       "wildcard not allowed",
+			annotation: "delegated:graph/*",
+			err:        "API permission 'delegated:graph/*' is not allowed",
+		},
+		{
+			name:       "invalid permission",
+			annotation: "graph/User.Read.All",
+			err:        annotations.APIPermissionsKey,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := synchronizedApplication(t, cfg, map[string]string{annotations.APIPermissionsKey: tt.applied})
+			if len(tt.annotation) > 0 {
+				annotations.SetAnnotation(app, annotations.APIPermissionsKey, tt.annotation)
+			}
+
+			azure, ok := processAzure(t, app, cfg, tt.err)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tt.expected, azure.APIPermissions.String())
+			assert.Equal(t, tt.expectedChanged, azure.Synchronize)
+		})
+	}
+}
+
+func TestProcess_OptionalClaims(t *testing.T) {
+	cfg := testConfig()
+
+	for _, tt := range []struct {
+		name            string
+		annotation      string
+		applied         string
+		expected        string
+		expectedChanged bool
+		err             string
+	}{
+		{
+			name: "no claims",
+		},
+		{
+			name:            "claims added",
+			annotation:      "id:email, access:groups+emit_as_roles",
+			expected:        "access:groups+emit_as_roles,id:email",
+			expectedChanged: true,
+		},
+		{
+			name:       "claims unchanged",
+			annotation: "id:email,access:groups+emit_as_roles",
+			applied:    "access:groups+emit_as_roles,id:email",
+			expected:   "access:groups+emit_as_roles,id:email",
+		},
+		{
+			name:            "claims removed",
+			applied:         "id:email",
+			expectedChanged: true,
+		},
+		{
+			name:       "unsupported claim",
+			annotation: "id:some_claim",
+			err:        annotations.OptionalClaimsKey,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := synchronizedApplication(t, cfg, map[string]string{annotations.OptionalClaimsKey: tt.applied})
+			if len(tt.annotation) > 0 {
+				annotations.SetAnnotation(app, annotations.OptionalClaimsKey, tt.annotation)
+			}
+
+			azure, ok := processAzure(t, app, cfg, tt.err)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tt.expected, azure.OptionalClaims.String())
+			assert.Equal(t, tt.expectedChanged, azure.Synchronize)
+		})
+	}
+}
+
+func TestProcess_ClaimsMappingPolicy(t *testing.T) {
+	cfg := testConfig()
+	cfg.Azure.Features.ClaimsMappingPolicies = config.ClaimsMappingPolicies{
+		Enabled: true,
+		ID:      "default-policy-id",
+		Named:   []string{"navident=navident-policy-id"},
+	}
+
+	for _, tt := range []struct {
+		name            string
+		annotation      string
+		applied         string
+		disabled        bool
+		expected        options.ClaimsMappingPolicy
+		expectedChanged bool
+		err             string
+	}{
+		{
+			name:     "default policy",
+			expected: options.ClaimsMappingPolicy{ID: "default-policy-id"},
+		},
+		{
+			name:            "named policy selected",
+			annotation:      "navident",
+			expected:        options.ClaimsMappingPolicy{Name: "navident", ID: "navident-policy-id"},
+			expectedChanged: true,
+		},
+		{
+			name:       "named policy unchanged",
+			annotation: "navident",
+			applied:    "navident",
+			expected:   options.ClaimsMappingPolicy{Name: "navident", ID: "navident-policy-id"},
+		},
+		{
+			name:            "named policy deselected",
+			applied:         "navident",
+			expected:        options.ClaimsMappingPolicy{ID: "default-policy-id"},
+			expectedChanged: true,
+		},
+		{
+			name:            "opted out",
+			annotation:      "none",
+			expected:        options.ClaimsMappingPolicy{Name: "none"},
+			expectedChanged: true,
+		},
+		{
+			name:       "feature disabled",
+			annotation: "navident",
+			disabled:   true,
+		},
+		{
+			name:       "unknown policy",
+			annotation: "unknown",
+			err:        "claims-mapping policy 'unknown' is not allowed",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			cfg.Azure.Features.ClaimsMappingPolicies.Enabled = !tt.disabled
+
+			app := synchronizedApplication(t, cfg, map[string]string{annotations.ClaimsMappingPolicyKey: tt.applied})
+			if len(tt.annotation) > 0 {
+				annotations.SetAnnotation(app, annotations.ClaimsMappingPolicyKey, tt.annotation)
+			}
+
+			azure, ok := processAzure(t, app, cfg, tt.err)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tt.expected, azure.ClaimsMappingPolicy)
+			assert.Equal(t, tt.expectedChanged, azure.Synchronize)
+		})
+	}
+}
+
+func TestProcess_GroupRoles(t *testing.T) {
+	const groupId = "6d4d7b60-9c7a-4f1b-a1e4-5b1c2e0b8a11"
+
+	cfg := testConfig()
+	cfg.Azure.Features.GroupsAssignment.Enabled = true
+
+	for _, tt := range []struct {
+		name            string
+		annotation      string
+		applied         string
+		disabled        bool
+		expected        string
+		expectedChanged bool
+		err             string
+	}{
+		{
+			name: "no group roles",
+		},
+		{
+			name:            "group roles added",
+			annotation:      "reader=" + groupId + ", admin=" + groupId,
+			expected:        "admin=" + groupId + ",reader=" + groupId,
+			expectedChanged: true,
+		},
+		{
+			name:       "group roles unchanged",
+			annotation: "admin=" + groupId,
+			applied:    "admin=" + groupId,
+			expected:   "admin=" + groupId,
+		},
+		{
+			name:            "group roles removed",
+			applied:         "admin=" + groupId,
+			expectedChanged: true,
+		},
+		{
+			name:       "invalid group role",
+			annotation: "admin",
+			err:        annotations.GroupRolesKey,
+		},
+		{
+			name:       "groups assignment disabled",
+			annotation: "admin=" + groupId,
+			disabled:   true,
+			err:        "groups assignment is not enabled",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			cfg.Azure.Features.GroupsAssignment.Enabled = !tt.disabled
+
+			app := synchronizedApplication(t, cfg, map[string]string{annotations.GroupRolesKey: tt.applied})
+			if len(tt.annotation) > 0 {
+				annotations.SetAnnotation(app, annotations.GroupRolesKey, tt.annotation)
+			}
+
+			azure, ok := processAzure(t, app, cfg, tt.err)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tt.expected, azure.GroupRoles.String())
+			assert.Equal(t, tt.expectedChanged, azure.Synchronize)
+		})
+	}
+}
+
+func TestProcess_APISettings(t *testing.T) {
+	const clientId = "6d4d7b60-9c7a-4f1b-a1e4-5b1c2e0b8a11"
+	const otherClientId = "0b7e2d1c-3f4a-4e5b-8c6d-7e8f9a0b1c2d"
+
+	cfg := testConfig()
+	cfg.Azure.APISettings = config.AzureAPISettings{
+		AccessTokenVersion:    2,
+		TokenLifetimePolicies: []string{"short-lived=short-lived-policy-id"},
+	}
+
+	for _, tt := range []struct {
+		name            string
+		annotations     map[string]string
+		applied         map[string]string
+		expected        string
+		expectedChanged bool
+		err             string
+	}{
+		{
+			name: "no settings",
+		},
+		{
+			name: "settings added",
+			annotations: map[string]string{
+				annotations.AccessTokenVersionKey:      "1",
+				annotations.KnownClientApplicationsKey: otherClientId + ", " + clientId + "," + clientId,
+				annotations.TokenLifetimePolicyKey:     "short-lived",
+			},
+			expected:        "access-token-version=1;known-client-applications=" + otherClientId + "," + clientId + ";token-lifetime-policy=short-lived=short-lived-policy-id",
+			expectedChanged: true,
+		},
+		{
+			name: "settings unchanged",
+			annotations: map[string]string{
+				annotations.AccessTokenVersionKey: "1",
+			},
+			applied: map[string]string{
+				annotations.AccessTokenVersionKey: "1",
+			},
+			expected: "access-token-version=1",
+		},
+		{
+			name: "settings removed",
+			applied: map[string]string{
+				annotations.AccessTokenVersionKey: "1",
+			},
+			expectedChanged: true,
+		},
+		{
+			name: "known client applications removed",
+			applied: map[string]string{
+				annotations.KnownClientApplicationsKey: otherClientId + "," + clientId,
+				annotations.TokenLifetimePolicyKey:     "short-lived",
+			},
+			expectedChanged: true,
+		},
+		{
+			name: "invalid access token version",
+			annotations: map[string]string{
+				annotations.AccessTokenVersionKey: "3",
+			},
+			err: "access token version '3' must be either 1 or 2",
+		},
+		{
+			name: "invalid known client application",
+			annotations: map[string]string{
+				annotations.KnownClientApplicationsKey: "some-app",
+			},
+			err: "known client application 'some-app' must be a client ID",
+		},
+		{
+			name: "unknown token lifetime policy",
+			annotations: map[string]string{
+				annotations.TokenLifetimePolicyKey: "unknown",
+			},
+			err: "token lifetime policy 'unknown' is not allowed",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := synchronizedApplication(t, cfg, tt.applied)
+			for key, value := range tt.annotations {
+				annotations.SetAnnotation(app, key, value)
+			}
+
+			azure, ok := processAzure(t, app, cfg, tt.err)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tt.expected, azure.APISettings.String())
+			assert.Equal(t, tt.expectedChanged, azure.Synchronize)
+		})
+	}
+}
+
+func TestProcess_PlatformSettings(t *testing.T) {
+	cfg := testConfig()
+
+	for _, tt := range []struct {
+		name            string
+		annotations     map[string]string
+		applied         map[string]string
+		expected        string
+		expectedChanged bool
+		err             string
+	}{
+		{
+			name: "no settings",
+		},
+		{
+			name: "settings added",
+			annotations: map[string]string{
+				annotations.HomePageUrlKey:              "https://test.host",
+				annotations.ImplicitGrantKey:            "id-token, access-token",
+				annotations.PublicClientRedirectUrisKey: "myapp://auth,http://localhost,http://localhost",
+				annotations.FallbackPublicClientKey:     "true",
+			},
+			expected:        "home-page-url=https://test.host;implicit-grant=access-token,id-token;public-client-redirect-uris=http://localhost,myapp://auth;fallback-public-client=true",
+			expectedChanged: true,
+		},
+		{
+			name: "settings unchanged",
+			annotations: map[string]string{
+				annotations.ImplicitGrantKey: "id-token",
+			},
+			applied: map[string]string{
+				annotations.ImplicitGrantKey: "id-token",
+			},
+			expected: "implicit-grant=id-token",
+		},
+		{
+			name: "settings removed",
+			applied: map[string]string{
+				annotations.ImplicitGrantKey: "id-token",
+			},
+			expectedChanged: true,
+		},
+		{
+			name: "fallback public client disabled",
+			annotations: map[string]string{
+				annotations.FallbackPublicClientKey: "false",
+			},
+		},
+		{
+			name: "invalid home page URL",
+			annotations: map[string]string{
+				annotations.HomePageUrlKey: "not a url",
+			},
+			err: "home page URL 'not a url' must be a valid URL",
+		},
+		{
+			name: "invalid implicit grant",
+			annotations: map[string]string{
+				annotations.ImplicitGrantKey: "code",
+			},
+			err: "implicit grant 'code' must be one of 'id-token' or 'access-token'",
+		},
+		{
+			name: "relative public client redirect URI",
+			annotations: map[string]string{
+				annotations.PublicClientRedirectUrisKey: "/callback",
+			},
+			err: "redirect URI '/callback' must be an absolute URI",
+		},
+		{
+			name: "invalid fallback public client",
+			annotations: map[string]string{
+				annotations.FallbackPublicClientKey: "yes please",
+			},
+			err: annotations.FallbackPublicClientKey,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := synchronizedApplication(t, cfg, tt.applied)
+			for key, value := range tt.annotations {
+				annotations.SetAnnotation(app, key, value)
+			}
+
+			azure, ok := processAzure(t, app, cfg, tt.err)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tt.expected, azure.PlatformSettings.String())
+			assert.Equal(t, tt.expectedChanged, azure.Synchronize)
+		})
+	}
+}
+
+func TestProcess_IdentifierUris(t *testing.T) {
+	cfg := testConfig()
+	cfg.Azure.IdentifierUris.AllowedDomains = []string{"example.com"}
+
+	for _, tt := range []struct {
+		name            string
+		annotation      string
+		applied         string
+		expected        string
+		expectedChanged bool
+		err             string
+	}{
+		{
+			name: "no identifier uris",
+		},
+		{
+			name:            "identifier uris added",
+			annotation:      "https://test.example.com, api://example.com/test,https://test.example.com",
+			expected:        "api://example.com/test,https://test.example.com",
+			expectedChanged: true,
+		},
+		{
+			name:       "identifier uris unchanged",
+			annotation: "https://test.example.com",
+			applied:    "https://test.example.com",
+			expected:   "https://test.example.com",
+		},
+		{
+			name:            "identifier uris removed",
+			applied:         "https://test.example.com",
+			expectedChanged: true,
+		},
+		{
+			name:       "domain not allowed",
+			annotation: "https://test.example.org",
+			err:        "identifier URI 'https://test.example.org' is not on an allowed domain",
+		},
+		{
+			name:       "suffix of allowed domain",
+			annotation: "https://notexample.com",
+			err:        "identifier URI 'https://notexample.com' is not on an allowed domain",
+		},
+		{
+			name:       "unsupported scheme",
+			annotation: "http://test.example.com",
+			err:        "identifier URI 'http://test.example.com' must be an absolute URI with either the 'api' or 'https' scheme",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := synchronizedApplication(t, cfg, map[string]string{annotations.IdentifierUrisKey: tt.applied})
+			if len(tt.annotation) > 0 {
+				annotations.SetAnnotation(app, annotations.IdentifierUrisKey, tt.annotation)
+			}
+
+			azure, ok := processAzure(t, app, cfg, tt.err)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tt.expected, azure.IdentifierUris.String())
+			assert.Equal(t, tt.expectedChanged, azure.Synchronize)
+		})
+	}
+}
+
+func TestProcess_Owners(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		enabled         bool
+		annotation      string
+		applied         string
+		expected        string
+		expectedChanged bool
+		err             string
+	}{
+		{
+			name:    "no owners",
+			enabled: true,
+		},
+		{
+			name:            "owners added",
+			enabled:         true,
+			annotation:      "some-group, some.user@example.com,some-group",
+			expected:        "some-group,some.user@example.com",
+			expectedChanged: true,
+		},
+		{
+			name:       "owners unchanged",
+			enabled:    true,
+			annotation: "some.user@example.com",
+			applied:    "some.user@example.com",
+			expected:   "some.user@example.com",
+		},
+		{
+			name:            "owners removed",
+			enabled:         true,
+			applied:         "some.user@example.com",
+			expectedChanged: true,
+		},
+		{
+			name:       "owners not enabled",
+			annotation: "some.user@example.com",
+			err:        "owners are not enabled",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Azure.Features.Owners.Enabled = tt.enabled
+
+			app := synchronizedApplication(t, cfg, map[string]string{annotations.OwnersKey: tt.applied})
+			if len(tt.annotation) > 0 {
+				annotations.SetAnnotation(app, annotations.OwnersKey, tt.annotation)
+			}
+
+			azure, ok := processAzure(t, app, cfg, tt.err)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tt.expected, azure.Owners.String())
+			assert.Equal(t, tt.expectedChanged, azure.Synchronize)
+		})
+	}
+}
+
+// synchronizedApplication returns an application that was last synchronized with the given annotations, i.e. whose
+// synchronization hash covers the settings they requested. The annotations are not set on the returned application.
+func synchronizedApplication(t *testing.T, cfg config.Config, applied map[string]string) *v1.AzureAdApplication {
+	t.Helper()
+
+	app := fixtures.MinimalApplication()
+	app.Status.SynchronizationTenant = cfg.Azure.Tenant.Id
+	app.Status.SynchronizationSecretName = app.Spec.SecretName
+	for key, value := range applied {
+		if len(value) > 0 {
+			annotations.SetAnnotation(app, key, value)
+		}
+	}
+
+	opts, err := options.NewOptions(*app, cfg, secrets.Secrets{})
+	require.NoError(t, err)
+	app.Status.SynchronizationHash, err = customresources.Hash(app, opts.Process.Azure.Settings()...)
+	require.NoError(t, err)
+
+	for key := range applied {
+		annotations.RemoveAnnotation(app, key)
+	}
+	return app
+}
+
+// testConfig returns the configuration shared by the tests, i.e. the tenant of the test applications and the default
+// max age of credentials. Tests add the configuration for the feature under test.
+func testConfig() config.Config {
+	return config.Config{
+		Azure: config.AzureConfig{
+			Tenant: config.AzureTenant{Id: "some-tenant"},
+		},
+		SecretRotation: config.SecretRotation{
+			MaxAge: 24 * time.Hour,
+		},
+	}
+}
+
+// processAzure returns the Azure options for the application. If an error is expected, the error is asserted and false
+// is returned. A synchronization with Azure AD must always be part of a synchronization of the application.
+func processAzure(t *testing.T, app *v1.AzureAdApplication, cfg config.Config, expectedErr string) (options.AzureOptions, bool) {
+	t.Helper()
+
+	opts, err := options.NewOptions(*app, cfg, secrets.Secrets{})
+	if len(expectedErr) > 0 {
+		assert.ErrorContains(t, err, expectedErr)
+		return options.AzureOptions{}, false
+	}
+	require.NoError(t, err)
+	if opts.Process.Azure.Synchronize {
+		assert.True(t, opts.Process.Synchronize)
+	}
+	return opts.Process.Azure, true
+}
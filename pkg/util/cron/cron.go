@@ -0,0 +1,182 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearch bounds the search for the next activation, so that expressions that never match (e.g. February 30th)
+// terminate.
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Schedule is a parsed cron expression with the standard five fields: minute, hour, day of month, month and day of week.
+type Schedule struct {
+	minute     uint64
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64
+	// restrictedDays is true if both day fields are restricted, in which case a time matches if either field matches.
+	restrictedDays bool
+}
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// Parse parses a cron expression such as "0 2 * * 1-5". Each field accepts '*', single values, ranges, steps and
+// comma-separated lists thereof. Sunday is both 0 and 7 in the day of week field.
+func Parse(expression string) (Schedule, error) {
+	parts := strings.Fields(expression)
+	if len(parts) != len(fields) {
+		return Schedule{}, fmt.Errorf("cron expression '%s' must have %d fields, got %d", expression, len(fields), len(parts))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		b, err := parseField(parts[i], f)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("cron expression '%s': %w", expression, err)
+		}
+		bits[i] = b
+	}
+
+	dayOfWeek := bits[4]
+	if dayOfWeek&(1<<7) != 0 {
+		dayOfWeek |= 1 << 0
+	}
+
+	return Schedule{
+		minute:         bits[0],
+		hour:           bits[1],
+		dayOfMonth:     bits[2],
+		month:          bits[3],
+		dayOfWeek:      dayOfWeek,
+		restrictedDays: parts[2] != "*" && parts[4] != "*",
+	}, nil
+}
+
+// Next returns the first activation of the schedule strictly after t, in the location of t.
+// The zero time is returned if the schedule has no activation within the next five years.
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+
+	for t.Before(limit) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !has(s.minute, t.Minute()):
+			t = t.Truncate(time.Minute).Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (s Schedule) matchesDay(t time.Time) bool {
+	dayOfMonth := has(s.dayOfMonth, t.Day())
+	dayOfWeek := has(s.dayOfWeek, int(t.Weekday()))
+	if s.restrictedDays {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
+
+func has(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
+
+func parseField(value string, f field) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step '%s' in %s field", stepPart, f.name)
+			}
+		}
+
+		start, end := f.min, f.max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			start, err = parseValue(lowPart, f)
+			if err != nil {
+				return 0, err
+			}
+
+			end = start
+			if isRange {
+				end, err = parseValue(highPart, f)
+				if err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				end = f.max
+			}
+
+			if start > end {
+				return 0, fmt.Errorf("invalid range '%s' in %s field", rangePart, f.name)
+			}
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseValue(value string, f field) (int, error) {
+	i, err := strconv.Atoi(value)
+	if err != nil || i < f.min || i > f.max {
+		return 0, fmt.Errorf("invalid value '%s' in %s field, must be between %d and %d", value, f.name, f.min, f.max)
+	}
+	return i, nil
+}
+
+// Window is a recurring period of time that starts at each activation of a schedule.
+type Window struct {
+	Schedule Schedule
+	Duration time.Duration
+	Location *time.Location
+}
+
+// Contains returns true if t is within one of the periods of the window.
+func (w Window) Contains(t time.Time) bool {
+	start := w.Schedule.Next(t.In(w.Location).Add(-w.Duration))
+	return !start.IsZero() && !start.After(t)
+}
+
+// Next returns t if t is within the window, and the start of the following period otherwise.
+func (w Window) Next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	return w.Schedule.Next(t.In(w.Location))
+}
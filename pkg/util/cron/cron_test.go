@@ -0,0 +1,128 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/azureator/pkg/util/cron"
+)
+
+func TestParse(t *testing.T) {
+	for _, expression := range []string{
+		"* * * * *",
+		"0 2 * * 1-5",
+		"*/15 0-6,22-23 1,15 */2 7",
+		"30 4 1-10/3 * *",
+	} {
+		_, err := cron.Parse(expression)
+		assert.NoError(t, err, expression)
+	}
+
+	for _, expression := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	} {
+		_, err := cron.Parse(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// a Thursday
+	from := time.Date(2026, 1, 1, 12, 34, 56, 0, time.UTC)
+
+	for _, tt := range []struct {
+		expression string
+		want       time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 1, 12, 35, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2026, 1, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 1, 2, 2, 0, 0, 0, time.UTC)},
+		{"0 2 * * 1-5", time.Date(2026, 1, 2, 2, 0, 0, 0, time.UTC)},
+		{"0 2 * * 1", time.Date(2026, 1, 5, 2, 0, 0, 0, time.UTC)},
+		{"0 2 * * 0", time.Date(2026, 1, 4, 2, 0, 0, 0, time.UTC)},
+		{"0 2 * * 7", time.Date(2026, 1, 4, 2, 0, 0, 0, time.UTC)},
+		{"0 0 1 3 *", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		// either day field matches if both are restricted
+		{"0 0 10 * 6", time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"*/20 12 * * *", time.Date(2026, 1, 1, 12, 40, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		t.Run(tt.expression, func(t *testing.T) {
+			schedule, err := cron.Parse(tt.expression)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, schedule.Next(from))
+		})
+	}
+}
+
+func TestWindow(t *testing.T) {
+	schedule, err := cron.Parse("0 2 * * 1-5")
+	require.NoError(t, err)
+
+	window := cron.Window{Schedule: schedule, Duration: 2 * time.Hour, Location: time.UTC}
+
+	for _, tt := range []struct {
+		name     string
+		at       time.Time
+		contains bool
+		next     time.Time
+	}{
+		{
+			name:     "before window",
+			at:       time.Date(2026, 1, 1, 1, 59, 0, 0, time.UTC),
+			contains: false,
+			next:     time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "start of window",
+			at:       time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC),
+			contains: true,
+			next:     time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "within window",
+			at:       time.Date(2026, 1, 1, 3, 59, 59, 0, time.UTC),
+			contains: true,
+			next:     time.Date(2026, 1, 1, 3, 59, 59, 0, time.UTC),
+		},
+		{
+			name:     "end of window",
+			at:       time.Date(2026, 1, 1, 4, 0, 0, 0, time.UTC),
+			contains: false,
+			next:     time.Date(2026, 1, 2, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "weekend",
+			at:       time.Date(2026, 1, 3, 2, 30, 0, 0, time.UTC),
+			contains: false,
+			next:     time.Date(2026, 1, 5, 2, 0, 0, 0, time.UTC),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.contains, window.Contains(tt.at))
+			assert.Equal(t, tt.next, window.Next(tt.at))
+		})
+	}
+
+	t.Run("location", func(t *testing.T) {
+		location := time.FixedZone("UTC+1", 60*60)
+		window := cron.Window{Schedule: schedule, Duration: 2 * time.Hour, Location: location}
+
+		assert.True(t, window.Contains(time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC)))
+		assert.False(t, window.Contains(time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)))
+	})
+}
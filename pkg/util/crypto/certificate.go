@@ -7,11 +7,59 @@ import (
 	"encoding/pem"
 	"fmt"
 	"math/big"
+	"slices"
 	"time"
 
 	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 )
 
+// DefaultCertificateValidity is used for certificates when no validity is given.
+const DefaultCertificateValidity = 365 * 24 * time.Hour
+
+// SerialNumber is the strategy for assigning serial numbers to certificates.
+type SerialNumber string
+
+const (
+	// SerialNumberRandom assigns a random, positive 128-bit serial number to each certificate.
+	SerialNumberRandom SerialNumber = "random"
+	// SerialNumberFixed assigns the serial number 1 to every certificate, as done before serial numbers were configurable.
+	SerialNumberFixed SerialNumber = "fixed"
+)
+
+var SupportedSerialNumbers = []SerialNumber{SerialNumberRandom, SerialNumberFixed}
+
+func ParseSerialNumber(s string) (SerialNumber, error) {
+	serialNumber := SerialNumber(s)
+	if !slices.Contains(SupportedSerialNumbers, serialNumber) {
+		return "", fmt.Errorf("unsupported serial number strategy '%s', must be one of %v", s, SupportedSerialNumbers)
+	}
+	return serialNumber, nil
+}
+
+func (s SerialNumber) generate() (*big.Int, error) {
+	if s == SerialNumberFixed {
+		return big.NewInt(1), nil
+	}
+
+	// RFC 5280 limits serial numbers to 20 octets; 128 bits leaves room for the sign bit
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generating serial number: %w", err)
+	}
+	return serialNumber.Add(serialNumber, big.NewInt(1)), nil
+}
+
+// CertificateOptions configures the self-signed certificates generated for certificate credentials.
+// The zero value yields a certificate with the DefaultKeyType, a random serial number, the DefaultCertificateValidity
+// and a subject consisting only of the common name.
+type CertificateOptions struct {
+	KeyType      KeyType
+	SerialNumber SerialNumber
+	// Subject holds the distinguished name of the certificate. The common name is always derived from the application.
+	Subject  pkix.Name
+	Validity time.Duration
+}
+
 func GenerateCertificate(template *x509.Certificate, keyPair KeyPair) (*x509.Certificate, error) {
 	derBytes, err := x509.CreateCertificate(rand.Reader, template, template, keyPair.Public, keyPair.Private)
 	if err != nil {
@@ -25,27 +73,32 @@ func GenerateCertificate(template *x509.Certificate, keyPair KeyPair) (*x509.Cer
 	return cert, nil
 }
 
-func CertificateTemplate(application *v1.AzureAdApplication, clusterName string) *x509.Certificate {
+func CertificateTemplate(application *v1.AzureAdApplication, clusterName string, opts CertificateOptions) (*x509.Certificate, error) {
+	serialNumber, err := opts.SerialNumber.generate()
+	if err != nil {
+		return nil, err
+	}
+
+	validity := opts.Validity
+	if validity <= 0 {
+		validity = DefaultCertificateValidity
+	}
+
 	notBefore := time.Now()
+	notAfter := notBefore.Add(validity)
 
-	notAfter := notBefore.AddDate(1, 0, 0)
+	subject := opts.Subject
+	subject.CommonName = fmt.Sprintf("%s.%s.%s.azurerator.nais.io", application.Name, application.Namespace, clusterName)
 
 	return &x509.Certificate{
-		SerialNumber: big.NewInt(1),
-		Subject: pkix.Name{
-			Country:            []string{"NO"},
-			Province:           []string{"Oslo"},
-			Locality:           []string{"Oslo"},
-			Organization:       []string{"NAV (Arbeids- og velferdsdirektoratet"},
-			OrganizationalUnit: []string{"NAV IT"},
-			CommonName:         fmt.Sprintf("%s.%s.%s.azurerator.nais.io", application.Name, application.Namespace, clusterName),
-		},
+		SerialNumber:          serialNumber,
+		Subject:               subject,
 		NotBefore:             notBefore,
 		NotAfter:              notAfter,
 		KeyUsage:              x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
 		BasicConstraintsValid: true,
-	}
+	}, nil
 }
 
 func ConvertToPem(cert *x509.Certificate) []byte {
@@ -0,0 +1,77 @@
+package crypto_test
+
+import (
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/azureator/pkg/fixtures"
+	"github.com/nais/azureator/pkg/util/crypto"
+)
+
+func TestCertificateTemplate(t *testing.T) {
+	app := fixtures.MinimalApplication()
+
+	t.Run("zero options", func(t *testing.T) {
+		template, err := crypto.CertificateTemplate(app, "test-cluster", crypto.CertificateOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, pkix.Name{CommonName: "test-app.test-namespace.test-cluster.azurerator.nais.io"}, template.Subject)
+		assert.Equal(t, crypto.DefaultCertificateValidity, template.NotAfter.Sub(template.NotBefore))
+		assert.Equal(t, 1, template.SerialNumber.Sign())
+	})
+
+	t.Run("configured options", func(t *testing.T) {
+		opts := crypto.CertificateOptions{
+			SerialNumber: crypto.SerialNumberFixed,
+			Subject: pkix.Name{
+				Country:      []string{"SE"},
+				Organization: []string{"Some Organization"},
+				CommonName:   "ignored",
+			},
+			Validity: 90 * 24 * time.Hour,
+		}
+
+		template, err := crypto.CertificateTemplate(app, "test-cluster", opts)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"SE"}, template.Subject.Country)
+		assert.Equal(t, []string{"Some Organization"}, template.Subject.Organization)
+		assert.Equal(t, "test-app.test-namespace.test-cluster.azurerator.nais.io", template.Subject.CommonName)
+		assert.Equal(t, opts.Validity, template.NotAfter.Sub(template.NotBefore))
+		assert.Equal(t, big.NewInt(1), template.SerialNumber)
+	})
+
+	t.Run("random serial numbers are unique", func(t *testing.T) {
+		seen := make(map[string]bool)
+		for range 100 {
+			template, err := crypto.CertificateTemplate(app, "test-cluster", crypto.CertificateOptions{SerialNumber: crypto.SerialNumberRandom})
+			require.NoError(t, err)
+
+			serialNumber := template.SerialNumber
+			assert.Equal(t, 1, serialNumber.Sign())
+			assert.LessOrEqual(t, len(serialNumber.Bytes()), 20)
+			assert.False(t, seen[serialNumber.String()])
+			seen[serialNumber.String()] = true
+		}
+	})
+}
+
+func TestGenerateJwk_CertificateOptions(t *testing.T) {
+	opts := crypto.CertificateOptions{
+		KeyType:  crypto.KeyTypeRSA4096,
+		Subject:  pkix.Name{OrganizationalUnit: []string{"Some Unit"}},
+		Validity: 240 * 24 * time.Hour,
+	}
+
+	jwk, err := crypto.GenerateJwk(fixtures.MinimalApplication(), "test-cluster", opts)
+	require.NoError(t, err)
+
+	cert := jwk.Private.Certificates[0]
+	assert.Equal(t, []string{"Some Unit"}, cert.Subject.OrganizationalUnit)
+	assert.Equal(t, opts.Validity, cert.NotAfter.Sub(cert.NotBefore).Round(time.Second))
+}
@@ -5,14 +5,16 @@ import (
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
+	"fmt"
 
 	"github.com/go-jose/go-jose/v4"
 	v1 "github.com/nais/liberator/pkg/apis/nais.io/v1"
 )
 
 const (
-	KeyUseSignature string = "sig"
-	KeyAlgorithm    string = "RS256"
+	KeyUseSignature   string = "sig"
+	KeyAlgorithmRS256 string = "RS256"
 )
 
 type Jwk struct {
@@ -20,36 +22,55 @@ type Jwk struct {
 	PublicPem []byte          `json:"publicPem"`
 }
 
-func GenerateJwk(application *v1.AzureAdApplication, clusterName string) (Jwk, error) {
-	keyPair, err := NewRSAKeyPair()
+// GenerateJwk generates a key pair with a self-signed certificate as described by the given options.
+func GenerateJwk(application *v1.AzureAdApplication, clusterName string, opts CertificateOptions) (Jwk, error) {
+	keyType := opts.KeyType
+	if len(keyType) == 0 {
+		keyType = DefaultKeyType
+	}
+
+	keyPair, err := NewKeyPair(keyType)
+	if err != nil {
+		return Jwk{}, err
+	}
+
+	template, err := CertificateTemplate(application, clusterName, opts)
 	if err != nil {
 		return Jwk{}, err
 	}
 
-	template := CertificateTemplate(application, clusterName)
 	cert, err := GenerateCertificate(template, keyPair)
 	if err != nil {
 		return Jwk{}, err
 	}
-	certificates := []*x509.Certificate{cert}
-	x5tSHA1 := sha1.Sum(certificates[0].Raw)
-	x5tSHA256 := sha256.Sum256(certificates[0].Raw)
+	x5tSHA1 := sha1.Sum(cert.Raw)
 	keyId := base64.RawURLEncoding.EncodeToString(x5tSHA1[:])
 
 	jwk := jose.JSONWebKey{
-		Key:                         keyPair.Private,
-		KeyID:                       keyId,
-		Use:                         KeyUseSignature,
-		Algorithm:                   KeyAlgorithm,
-		Certificates:                certificates,
-		CertificateThumbprintSHA1:   x5tSHA1[:],
-		CertificateThumbprintSHA256: x5tSHA256[:],
+		Key:          keyPair.Private,
+		KeyID:        keyId,
+		Use:          KeyUseSignature,
+		Algorithm:    KeyAlgorithmRS256,
+		Certificates: []*x509.Certificate{cert},
 	}
 
 	return FromJwk(jwk), nil
 }
 
+// FromJwk wraps the given private key. Missing certificate thumbprints ('x5t' and 'x5t#S256') are derived from the
+// certificate.
 func FromJwk(jwk jose.JSONWebKey) Jwk {
+	if len(jwk.Certificates) > 0 {
+		if len(jwk.CertificateThumbprintSHA1) == 0 {
+			x5tSHA1 := sha1.Sum(jwk.Certificates[0].Raw)
+			jwk.CertificateThumbprintSHA1 = x5tSHA1[:]
+		}
+		if len(jwk.CertificateThumbprintSHA256) == 0 {
+			x5tSHA256 := sha256.Sum256(jwk.Certificates[0].Raw)
+			jwk.CertificateThumbprintSHA256 = x5tSHA256[:]
+		}
+	}
+
 	jwkPublic := jwk.Public()
 
 	return Jwk{
@@ -58,6 +79,31 @@ func FromJwk(jwk jose.JSONWebKey) Jwk {
 	}
 }
 
+// KeyType returns the type of the private key, if recognized.
+func (j Jwk) KeyType() (KeyType, bool) {
+	return KeyTypeOf(j.Private.Key)
+}
+
+// PrivateKeyPem returns the private key as a PEM-encoded PKCS #8 structure.
+func (j Jwk) PrivateKeyPem() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(j.Private.Key)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// Thumbprint returns the base64url-encoded SHA-1 thumbprint of the certificate, i.e. the 'x5t' header expected by
+// Entra ID in client assertions.
+func (j Jwk) Thumbprint() string {
+	return base64.RawURLEncoding.EncodeToString(j.Private.CertificateThumbprintSHA1)
+}
+
+// ThumbprintSHA256 returns the base64url-encoded SHA-256 thumbprint of the certificate, i.e. the 'x5t#S256' header.
+func (j Jwk) ThumbprintSHA256() string {
+	return base64.RawURLEncoding.EncodeToString(j.Private.CertificateThumbprintSHA256)
+}
+
 func (j Jwk) ToPrivateJwks() jose.JSONWebKeySet {
 	return jose.JSONWebKeySet{
 		Keys: []jose.JSONWebKey{
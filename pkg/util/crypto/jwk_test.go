@@ -0,0 +1,87 @@
+package crypto_test
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nais/azureator/pkg/fixtures"
+	"github.com/nais/azureator/pkg/util/crypto"
+)
+
+func TestParseKeyType(t *testing.T) {
+	for _, keyType := range crypto.SupportedKeyTypes {
+		parsed, err := crypto.ParseKeyType(string(keyType))
+		assert.NoError(t, err)
+		assert.Equal(t, keyType, parsed)
+	}
+
+	for _, invalid := range []string{"", "RSA-2048", "RSA-1024", "EC-P256", "EC-P384", "rsa-3072"} {
+		_, err := crypto.ParseKeyType(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	_, err := crypto.ParseKeyType("EC-P256")
+	assert.ErrorContains(t, err, "only accepts client assertions signed with RS256 or PS256")
+}
+
+func TestGenerateJwk(t *testing.T) {
+	app := fixtures.MinimalApplication()
+
+	for _, tt := range []struct {
+		keyType            crypto.KeyType
+		algorithm          string
+		signatureAlgorithm x509.SignatureAlgorithm
+	}{
+		{
+			keyType:            "",
+			algorithm:          crypto.KeyAlgorithmRS256,
+			signatureAlgorithm: x509.SHA256WithRSA,
+		},
+		{
+			keyType:            crypto.KeyTypeRSA3072,
+			algorithm:          crypto.KeyAlgorithmRS256,
+			signatureAlgorithm: x509.SHA256WithRSA,
+		},
+		{
+			keyType:            crypto.KeyTypeRSA4096,
+			algorithm:          crypto.KeyAlgorithmRS256,
+			signatureAlgorithm: x509.SHA256WithRSA,
+		},
+	} {
+		t.Run(string(tt.keyType), func(t *testing.T) {
+			jwk, err := crypto.GenerateJwk(app, "test-cluster", crypto.CertificateOptions{KeyType: tt.keyType})
+			require.NoError(t, err)
+
+			expectedKeyType := tt.keyType
+			if len(expectedKeyType) == 0 {
+				expectedKeyType = crypto.DefaultKeyType
+			}
+
+			keyType, ok := jwk.KeyType()
+			assert.True(t, ok)
+			assert.Equal(t, expectedKeyType, keyType)
+			assert.Equal(t, tt.algorithm, jwk.Private.Algorithm)
+			assert.Equal(t, crypto.KeyUseSignature, jwk.Private.Use)
+			assert.Equal(t, tt.signatureAlgorithm, jwk.Private.Certificates[0].SignatureAlgorithm)
+			assert.NotEmpty(t, jwk.PublicPem)
+
+			// keys read back from a secret keep their type and algorithm
+			marshalled, err := json.Marshal(jwk.Private)
+			require.NoError(t, err)
+
+			var parsed jose.JSONWebKey
+			require.NoError(t, parsed.UnmarshalJSON(marshalled))
+
+			fromJwk := crypto.FromJwk(parsed)
+			keyType, ok = fromJwk.KeyType()
+			assert.True(t, ok)
+			assert.Equal(t, expectedKeyType, keyType)
+			assert.Equal(t, tt.algorithm, fromJwk.Private.Algorithm)
+		})
+	}
+}
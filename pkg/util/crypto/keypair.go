@@ -5,15 +5,67 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
+	"slices"
+	"strings"
 )
 
+// KeyType is the algorithm and size of the key pair backing a certificate credential.
+type KeyType string
+
+const (
+	// KeyTypeRSA2048 is the key type of credentials created before key types were configurable.
+	// It is recognized in existing credentials, but is not accepted for new keys.
+	KeyTypeRSA2048 KeyType = "RSA-2048"
+	KeyTypeRSA3072 KeyType = "RSA-3072"
+	KeyTypeRSA4096 KeyType = "RSA-4096"
+
+	DefaultKeyType = KeyTypeRSA3072
+)
+
+// SupportedKeyTypes are the key types that may be used for new keys.
+// Elliptic curve keys are not supported, as Entra ID only accepts client assertions signed with RS256 or PS256.
+var SupportedKeyTypes = []KeyType{KeyTypeRSA3072, KeyTypeRSA4096}
+
+func ParseKeyType(s string) (KeyType, error) {
+	keyType := KeyType(s)
+	if strings.HasPrefix(s, "EC") {
+		return "", fmt.Errorf("unsupported key type '%s': Entra ID only accepts client assertions signed with RS256 or PS256, must be one of %v", s, SupportedKeyTypes)
+	}
+	if !slices.Contains(SupportedKeyTypes, keyType) {
+		return "", fmt.Errorf("unsupported key type '%s', must be one of %v", s, SupportedKeyTypes)
+	}
+	return keyType, nil
+}
+
+// KeyTypeOf returns the key type of the given public or private key, if recognized.
+func KeyTypeOf(key any) (KeyType, bool) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return KeyTypeOf(&k.PublicKey)
+	case *rsa.PublicKey:
+		return KeyType(fmt.Sprintf("RSA-%d", k.N.BitLen())), true
+	}
+	return "", false
+}
+
 type KeyPair struct {
 	Private crypto.PrivateKey
 	Public  crypto.PublicKey
 }
 
-func NewRSAKeyPair() (KeyPair, error) {
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+func NewKeyPair(keyType KeyType) (KeyPair, error) {
+	switch keyType {
+	case KeyTypeRSA3072:
+		return NewRSAKeyPair(3072)
+	case KeyTypeRSA4096:
+		return NewRSAKeyPair(4096)
+	default:
+		return KeyPair{}, fmt.Errorf("unsupported key type '%s'", keyType)
+	}
+}
+
+func NewRSAKeyPair(bits int) (KeyPair, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
 	if err != nil {
 		return KeyPair{}, fmt.Errorf("failed to generate RSA keypair: %w", err)
 	}
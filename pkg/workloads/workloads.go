@@ -0,0 +1,146 @@
+package workloads
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;patch
+
+// RestartedAtKey is the pod template annotation used by 'kubectl rollout restart'.
+const RestartedAtKey = "kubectl.kubernetes.io/restartedAt"
+
+// Workload identifies a controller of pods.
+type Workload struct {
+	Kind string
+	Name string
+}
+
+func (w Workload) String() string {
+	return fmt.Sprintf("%s/%s", w.Kind, w.Name)
+}
+
+// UsingSecret returns the workloads in the namespace with pods that reference the secret with the given name.
+// Pods without a supported controller are skipped.
+func UsingSecret(ctx context.Context, reader client.Reader, namespace, secretName string) ([]Workload, error) {
+	var pods corev1.PodList
+	if err := reader.List(ctx, &pods, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+
+	workloads := make([]Workload, 0)
+	for _, pod := range pods.Items {
+		if !usesSecret(pod.Spec, secretName) {
+			continue
+		}
+
+		workload, found, err := controllerOf(ctx, reader, pod)
+		if err != nil {
+			return nil, err
+		}
+		if found && !slices.Contains(workloads, workload) {
+			workloads = append(workloads, workload)
+		}
+	}
+
+	return workloads, nil
+}
+
+// Restart triggers a rolling restart of the given workloads in the namespace, equivalent to 'kubectl rollout restart'.
+func Restart(ctx context.Context, c client.Client, namespace string, workloads []Workload) error {
+	restartedAt := time.Now().UTC().Format(time.RFC3339)
+	patch := fmt.Appendf(nil, `{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`, RestartedAtKey, restartedAt)
+
+	for _, workload := range workloads {
+		obj, err := newObject(workload.Kind)
+		if err != nil {
+			return err
+		}
+		obj.SetNamespace(namespace)
+		obj.SetName(workload.Name)
+
+		if err := c.Patch(ctx, obj, client.RawPatch(types.StrategicMergePatchType, patch)); err != nil {
+			return fmt.Errorf("restarting %s: %w", workload, err)
+		}
+	}
+
+	return nil
+}
+
+func newObject(kind string) (client.Object, error) {
+	switch kind {
+	case "Deployment":
+		return &appsv1.Deployment{}, nil
+	case "StatefulSet":
+		return &appsv1.StatefulSet{}, nil
+	case "DaemonSet":
+		return &appsv1.DaemonSet{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported workload kind '%s'", kind)
+	}
+}
+
+// controllerOf returns the workload that controls the pod, following ReplicaSets to their Deployment.
+func controllerOf(ctx context.Context, reader client.Reader, pod corev1.Pod) (Workload, bool, error) {
+	owner := metav1.GetControllerOfNoCopy(&pod)
+	if owner == nil {
+		return Workload{}, false, nil
+	}
+
+	switch owner.Kind {
+	case "StatefulSet", "DaemonSet":
+		return Workload{Kind: owner.Kind, Name: owner.Name}, true, nil
+	case "ReplicaSet":
+		var rs appsv1.ReplicaSet
+		if err := reader.Get(ctx, client.ObjectKey{Namespace: pod.Namespace, Name: owner.Name}, &rs); err != nil {
+			return Workload{}, false, fmt.Errorf("getting replicaset for pod '%s': %w", pod.Name, err)
+		}
+
+		rsOwner := metav1.GetControllerOfNoCopy(&rs)
+		if rsOwner == nil || rsOwner.Kind != "Deployment" {
+			return Workload{}, false, nil
+		}
+		return Workload{Kind: rsOwner.Kind, Name: rsOwner.Name}, true, nil
+	default:
+		return Workload{}, false, nil
+	}
+}
+
+// usesSecret returns true if the pod references the secret in volumes or environment variables.
+func usesSecret(spec corev1.PodSpec, secretName string) bool {
+	for _, volume := range spec.Volumes {
+		if volume.Secret != nil && volume.Secret.SecretName == secretName {
+			return true
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil && source.Secret.Name == secretName {
+					return true
+				}
+			}
+		}
+	}
+
+	for _, container := range slices.Concat(spec.InitContainers, spec.Containers) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil && envFrom.SecretRef.Name == secretName {
+				return true
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil && env.ValueFrom.SecretKeyRef.Name == secretName {
+				return true
+			}
+		}
+	}
+
+	return false
+}
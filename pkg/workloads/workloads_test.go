@@ -0,0 +1,83 @@
+package workloads_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/nais/azureator/pkg/workloads"
+)
+
+const (
+	namespace  = "test-namespace"
+	secretName = "test-secret"
+)
+
+func TestUsingSecretAndRestart(t *testing.T) {
+	ctx := context.Background()
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "some-deployment", Namespace: namespace}}
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:            "some-deployment-abc",
+		Namespace:       namespace,
+		OwnerReferences: []metav1.OwnerReference{controller("Deployment", "some-deployment")},
+	}}
+	statefulSet := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "some-statefulset", Namespace: namespace}}
+
+	volume := corev1.PodSpec{Volumes: []corev1.Volume{{
+		Name:         "secret",
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secretName}},
+	}}}
+	envFrom := corev1.PodSpec{Containers: []corev1.Container{{
+		EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: secretName}}}},
+	}}}
+	otherSecret := corev1.PodSpec{Containers: []corev1.Container{{
+		EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "other-secret"}}}},
+	}}}
+
+	objects := []client.Object{
+		deployment,
+		replicaSet,
+		statefulSet,
+		pod("deployment-pod-1", volume, controller("ReplicaSet", replicaSet.Name)),
+		pod("deployment-pod-2", envFrom, controller("ReplicaSet", replicaSet.Name)),
+		pod("statefulset-pod", envFrom, controller("StatefulSet", statefulSet.Name)),
+		pod("other-pod", otherSecret, controller("StatefulSet", "other-statefulset")),
+		pod("bare-pod", volume),
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+
+	found, err := workloads.UsingSecret(ctx, c, namespace, secretName)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []workloads.Workload{
+		{Kind: "Deployment", Name: "some-deployment"},
+		{Kind: "StatefulSet", Name: "some-statefulset"},
+	}, found)
+
+	err = workloads.Restart(ctx, c, namespace, found)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(deployment), deployment))
+	assert.NotEmpty(t, deployment.Spec.Template.Annotations[workloads.RestartedAtKey])
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(statefulSet), statefulSet))
+	assert.NotEmpty(t, statefulSet.Spec.Template.Annotations[workloads.RestartedAtKey])
+}
+
+func controller(kind, name string) metav1.OwnerReference {
+	return metav1.OwnerReference{Kind: kind, Name: name, Controller: new(true)}
+}
+
+func pod(name string, spec corev1.PodSpec, owners ...metav1.OwnerReference) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, OwnerReferences: owners},
+		Spec:       spec,
+	}
+}